@@ -0,0 +1,92 @@
+package rmc
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// corpusPage is a page of the test corpus in testdata/corpus
+type corpusPage struct {
+	name string
+	data []byte
+	tree *parser.SceneTree
+}
+
+// readCorpus reads and parses every .rm file in testdata/corpus
+func readCorpus(tb testing.TB) []corpusPage {
+	tb.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.rm"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if len(paths) == 0 {
+		tb.Fatal("no .rm files in testdata/corpus")
+	}
+
+	quiet := slog.New(slog.DiscardHandler)
+	pages := make([]corpusPage, len(paths))
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		tree, err := parser.ReadSceneTreeWithLogger(bytes.NewReader(data), quiet)
+		if err != nil {
+			tb.Fatalf("%s: %v", path, err)
+		}
+		pages[i] = corpusPage{name: strings.TrimSuffix(filepath.Base(path), ".rm"), data: data, tree: tree}
+	}
+	return pages
+}
+
+func BenchmarkReadSceneTree(b *testing.B) {
+	quiet := slog.New(slog.DiscardHandler)
+	for _, page := range readCorpus(b) {
+		b.Run(page.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(page.data)))
+			for b.Loop() {
+				if _, err := parser.ReadSceneTreeWithLogger(bytes.NewReader(page.data), quiet); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExportSVG(b *testing.B) {
+	for _, page := range readCorpus(b) {
+		b.Run(page.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := export.ExportToSVGWithOptions(page.tree, io.Discard, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExportPDF(b *testing.B) {
+	if _, ok := export.CairoVersion(); !ok {
+		b.Skip("PDF export needs a Cairo build (-tags cairo)")
+	}
+	for _, page := range readCorpus(b) {
+		b.Run(page.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := export.ExportToPDFWithOptions(page.tree, io.Discard, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Package cloud downloads documents from the reMarkable cloud so they can be
+// converted without copying files off the tablet.
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultAuthHost is the reMarkable authentication service
+	DefaultAuthHost = "https://webapp-prod.cloud.remarkable.engineering"
+	// DefaultSyncHost is the reMarkable document storage service
+	DefaultSyncHost = "https://internal.cloud.remarkable.com"
+)
+
+// Client talks to the reMarkable cloud API
+type Client struct {
+	// AuthHost and SyncHost override the service URLs (default: DefaultAuthHost, DefaultSyncHost)
+	AuthHost string
+	SyncHost string
+
+	// HTTPClient is used for all requests (default: http.DefaultClient)
+	HTTPClient *http.Client
+
+	// Logger receives warnings about documents that are skipped because
+	// they can't be read (default: slog.Default())
+	Logger *slog.Logger
+
+	deviceToken string
+	userToken   string
+}
+
+// NewClient creates a client for a registered device token.
+// Use Register to obtain a device token the first time.
+func NewClient(deviceToken string) *Client {
+	return &Client{
+		AuthHost:    DefaultAuthHost,
+		SyncHost:    DefaultSyncHost,
+		HTTPClient:  http.DefaultClient,
+		Logger:      slog.Default(),
+		deviceToken: deviceToken,
+	}
+}
+
+// DeviceToken returns the long-lived device token for storing between runs
+func (c *Client) DeviceToken() string {
+	return c.deviceToken
+}
+
+// Register pairs this client with a reMarkable account using the one-time
+// code from https://my.remarkable.com/device/desktop/connect and returns the
+// device token
+func (c *Client) Register(ctx context.Context, code string) (string, error) {
+	deviceID, err := newUUID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate device ID: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"code":       strings.TrimSpace(code),
+		"deviceDesc": "desktop-linux",
+		"deviceID":   deviceID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	token, err := c.do(ctx, http.MethodPost, c.AuthHost+"/token/json/2/device/new", bytes.NewReader(body), "")
+	if err != nil {
+		return "", fmt.Errorf("failed to register device: %w", err)
+	}
+
+	c.deviceToken = strings.TrimSpace(string(token))
+	c.userToken = ""
+	return c.deviceToken, nil
+}
+
+// Authenticate exchanges the device token for a short-lived user token.
+// It is called automatically before the first API request.
+func (c *Client) Authenticate(ctx context.Context) error {
+	if c.deviceToken == "" {
+		return fmt.Errorf("no device token: register this device first")
+	}
+
+	token, err := c.do(ctx, http.MethodPost, c.AuthHost+"/token/json/2/user/new", nil, c.deviceToken)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	c.userToken = strings.TrimSpace(string(token))
+	return nil
+}
+
+// get performs an authenticated GET against the sync service
+func (c *Client) get(ctx context.Context, path string) ([]byte, error) {
+	if c.userToken == "" {
+		if err := c.Authenticate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c.do(ctx, http.MethodGet, c.SyncHost+path, nil, c.userToken)
+}
+
+// do sends a request and returns the response body, treating non-2xx
+// statuses as errors
+func (c *Client) do(ctx context.Context, method, url string, body io.Reader, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return nil, fmt.Errorf("%s %s: %s %s", method, url, resp.Status, msg)
+	}
+
+	return data, nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
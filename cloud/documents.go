@@ -0,0 +1,266 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// Document describes a notebook or folder stored in the cloud
+type Document struct {
+	ID     string // Document UUID
+	Name   string // Visible name shown on the tablet
+	Type   string // "DocumentType" or "CollectionType"
+	Parent string // ID of the containing folder, empty at the root, "trash" when deleted
+
+	hash string           // Hash of the document's index file
+	meta *parser.Metadata // Parsed .metadata file
+}
+
+// IsFolder reports whether the document is a folder (collection)
+func (d Document) IsFolder() bool {
+	return d.Type == "CollectionType"
+}
+
+// indexEntry is one line of a sync index file
+type indexEntry struct {
+	hash string
+	id   string
+}
+
+// errNoMetadata is returned for documents whose index lists no .metadata file
+var errNoMetadata = errors.New("no metadata file")
+
+// rootInfo is the response from the root hash endpoint
+type rootInfo struct {
+	Hash       string `json:"hash"`
+	Generation int64  `json:"generation"`
+}
+
+// parseIndex parses a sync index file. Each entry line has the form
+// hash:type:id:subfiles:size; schema 4 files add a summary line starting with "0:.:".
+func parseIndex(data []byte) ([]indexEntry, error) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, nil
+	}
+
+	schema, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || (schema != 3 && schema != 4) {
+		return nil, fmt.Errorf("unsupported index schema: %q", lines[0])
+	}
+
+	var entries []indexEntry
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "0:.:") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("malformed index line: %q", line)
+		}
+		entries = append(entries, indexEntry{hash: fields[0], id: fields[2]})
+	}
+	return entries, nil
+}
+
+// index downloads and parses the index file with the given hash
+func (c *Client) index(ctx context.Context, hash string) ([]indexEntry, error) {
+	data, err := c.file(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return parseIndex(data)
+}
+
+// file downloads a blob by hash
+func (c *Client) file(ctx context.Context, hash string) ([]byte, error) {
+	return c.get(ctx, "/sync/v3/files/"+hash)
+}
+
+// Documents lists all documents and folders in the account, including
+// deleted ones (whose Parent is "trash"). Entries of the root index without
+// a .metadata file are skipped with a warning.
+func (c *Client) Documents(ctx context.Context) ([]Document, error) {
+	data, err := c.get(ctx, "/sync/v4/root")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get root index: %w", err)
+	}
+
+	var root rootInfo
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse root index: %w", err)
+	}
+
+	entries, err := c.index(ctx, root.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root index: %w", err)
+	}
+
+	docs := make([]Document, 0, len(entries))
+	for _, entry := range entries {
+		doc, err := c.document(ctx, entry)
+		if errors.Is(err, errNoMetadata) {
+			// Entries without metadata, e.g. left over from a failed sync,
+			// shouldn't keep the other documents from being listed
+			c.logger().Warn("skipping document without metadata", "id", entry.id)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", entry.id, err)
+		}
+		if doc != nil {
+			docs = append(docs, *doc)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Name < docs[j].Name })
+	return docs, nil
+}
+
+// document reads the metadata of a single document from its index.
+// It returns nil for documents marked as deleted.
+func (c *Client) document(ctx context.Context, entry indexEntry) (*Document, error) {
+	files, err := c.index(ctx, entry.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if f.id != entry.id+".metadata" {
+			continue
+		}
+		data, err := c.file(ctx, f.hash)
+		if err != nil {
+			return nil, err
+		}
+		meta, err := parser.ParseMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+		if meta.Deleted {
+			return nil, nil
+		}
+		return &Document{
+			ID:     entry.id,
+			Name:   meta.VisibleName,
+			Type:   meta.Type,
+			Parent: meta.Parent,
+			hash:   entry.hash,
+			meta:   meta,
+		}, nil
+	}
+
+	return nil, errNoMetadata
+}
+
+// logger returns the client's logger, or the default one if none is set
+func (c *Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// FindDocument returns the notebook whose visible name (or ID) matches name.
+// Trashed documents are ignored. It fails if no notebook or more than one matches.
+func (c *Client) FindDocument(ctx context.Context, name string) (*Document, error) {
+	docs, err := c.Documents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Document
+	for _, doc := range docs {
+		if doc.IsFolder() || doc.Parent == "trash" {
+			continue
+		}
+		if doc.ID == name || strings.EqualFold(doc.Name, name) {
+			matches = append(matches, doc)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no document named %q", name)
+	case 1:
+		return &matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, m := range matches {
+			ids[i] = m.ID
+		}
+		return nil, fmt.Errorf("%d documents named %q, use an ID instead: %s", len(matches), name, strings.Join(ids, ", "))
+	}
+}
+
+// DownloadPages downloads the v6 .rm data for each page of a document, in
+// the page order recorded in its .content file. Pages without any strokes or
+// text have no .rm file and are skipped.
+func (c *Client) DownloadPages(ctx context.Context, doc *Document) ([][]byte, error) {
+	nb, err := c.DownloadNotebook(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return nb.OrderedPages(), nil
+}
+
+// DownloadNotebook downloads a document's .content file and the v6 .rm data
+// of the pages it lists, so callers can also use the document's orientation
+// and page details. Deleted pages are not downloaded.
+func (c *Client) DownloadNotebook(ctx context.Context, doc *Document) (*parser.Notebook, error) {
+	files, err := c.index(ctx, doc.hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document index: %w", err)
+	}
+
+	var content *parser.ContentFile
+	pageHashes := make(map[string]string)
+	var pageIDs []string
+	for _, f := range files {
+		switch {
+		case f.id == doc.ID+".content":
+			data, err := c.file(ctx, f.hash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to download content file: %w", err)
+			}
+			content, err = parser.ParseContent(data)
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(f.id, doc.ID+"/") && strings.HasSuffix(f.id, ".rm"):
+			pageID := strings.TrimSuffix(strings.TrimPrefix(f.id, doc.ID+"/"), ".rm")
+			pageHashes[pageID] = f.hash
+			pageIDs = append(pageIDs, pageID)
+		}
+	}
+
+	if content == nil {
+		return nil, fmt.Errorf("document %s has no content file", doc.ID)
+	}
+
+	nb := parser.NewNotebook(doc.ID)
+	nb.Content = content
+	nb.Metadata = doc.meta
+	ordered, _ := content.OrderPageIDs(pageIDs)
+	for _, pageID := range ordered {
+		data, err := c.file(ctx, pageHashes[pageID])
+		if err != nil {
+			return nil, fmt.Errorf("failed to download page %s: %w", pageID, err)
+		}
+		nb.Pages[pageID] = data
+	}
+
+	if len(nb.Pages) == 0 {
+		return nil, fmt.Errorf("document %q has no v6 pages", doc.Name)
+	}
+	return nb, nil
+}
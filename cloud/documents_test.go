@@ -0,0 +1,89 @@
+package cloud
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseIndex checks the entries read from sync index files
+func TestParseIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []indexEntry
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"schema 3", "3\nh1:80000000:doc1:4:0\nh2:80000000:doc2:3:0\n",
+			[]indexEntry{{hash: "h1", id: "doc1"}, {hash: "h2", id: "doc2"}}, false},
+		{"schema 4 summary line", "4\n0:.:2:1234\nh1:0:doc1.metadata:0:200\nh2:0:doc1.content:0:1034\n",
+			[]indexEntry{{hash: "h1", id: "doc1.metadata"}, {hash: "h2", id: "doc1.content"}}, false},
+		{"blank lines", "3\n\nh1:80000000:doc1:4:0\n\n", []indexEntry{{hash: "h1", id: "doc1"}}, false},
+		{"unknown schema", "5\nh1:80000000:doc1:4:0\n", nil, true},
+		{"schema not a number", "three\n", nil, true},
+		{"too few fields", "3\nh1:80000000:doc1\n", nil, true},
+		{"too many fields", "4\nh1:0:doc1.metadata:0:200:extra\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIndex([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got entries %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDocumentsWithoutMetadata checks that documents whose index has no
+// .metadata file are skipped with a warning instead of failing the listing
+func TestDocumentsWithoutMetadata(t *testing.T) {
+	files := map[string]string{
+		"root":       "4\n0:.:2:0\ndochash:80000000:doc1:1:0\nbrokenhash:80000000:doc2:1:0\n",
+		"dochash":    "4\n0:.:1:0\nmetahash:0:doc1.metadata:0:0\n",
+		"metahash":   `{"visibleName": "Notes", "type": "DocumentType", "parent": ""}`,
+		"brokenhash": "4\n0:.:1:0\ncontenthash:0:doc2.content:0:0\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token/json/2/user/new":
+			w.Write([]byte("user-token"))
+		case r.URL.Path == "/sync/v4/root":
+			w.Write([]byte(`{"hash": "root", "generation": 1}`))
+		case strings.HasPrefix(r.URL.Path, "/sync/v3/files/"):
+			data, ok := files[strings.TrimPrefix(r.URL.Path, "/sync/v3/files/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(data))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var warnings strings.Builder
+	client := NewClient("device-token")
+	client.AuthHost, client.SyncHost = server.URL, server.URL
+	client.Logger = slog.New(slog.NewTextHandler(&warnings, nil))
+
+	docs, err := client.Documents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].ID != "doc1" || docs[0].Name != "Notes" {
+		t.Errorf("got documents %+v, want only doc1", docs)
+	}
+	if !strings.Contains(warnings.String(), "doc2") {
+		t.Errorf("got warnings %q, want one for doc2", warnings.String())
+	}
+}
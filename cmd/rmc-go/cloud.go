@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joagonca/rmc-go/cloud"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cloudRegister  string
+	cloudList      bool
+	cloudTokenFile string
+)
+
+var cloudCmd = &cobra.Command{
+	Use:   "cloud [document-name]",
+	Short: "Convert a document directly from the reMarkable cloud",
+	Long: `cloud downloads the pages of a notebook from the reMarkable cloud and
+converts them in memory, without extracting files from the tablet.
+
+Pair this computer once with a one-time code from
+https://my.remarkable.com/device/desktop/connect:
+  rmc-go cloud --register abcdefgh
+
+Then convert documents by name (or ID):
+  rmc-go cloud "Meeting notes" -o notes.pdf
+  rmc-go cloud --list`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCloud,
+}
+
+func init() {
+	cloudCmd.Flags().StringVar(&cloudRegister, "register", "", "Pair with the cloud using a one-time code and save the device token")
+	cloudCmd.Flags().BoolVar(&cloudList, "list", false, "List the documents in the cloud")
+	cloudCmd.Flags().StringVar(&cloudTokenFile, "token-file", "", "File holding the device token (default: <user config dir>/rmc-go/device-token)")
+	rootCmd.AddCommand(cloudCmd)
+}
+
+func runCloud(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+	startProgress()
+	ctx := context.Background()
+
+	tokenPath, err := deviceTokenPath()
+	if err != nil {
+		return err
+	}
+
+	if cloudRegister != "" {
+		token, err := cloud.NewClient("").Register(ctx, cloudRegister)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(tokenPath), 0700); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		if err := os.WriteFile(tokenPath, []byte(token), 0600); err != nil {
+			return fmt.Errorf("failed to save device token: %w", err)
+		}
+		logger.Info("registered with the reMarkable cloud", "token", tokenPath)
+		if len(args) == 0 && !cloudList {
+			return nil
+		}
+	}
+
+	token, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to read device token (run 'rmc-go cloud --register <code>' first): %w", err)
+	}
+	client := cloud.NewClient(strings.TrimSpace(string(token)))
+	client.Logger = logger
+
+	if cloudList {
+		docs, err := client.Documents(ctx)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if doc.IsFolder() || doc.Parent == "trash" {
+				continue
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s  %s\n", doc.ID, doc.Name)
+		}
+		return nil
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("a document name is required")
+	}
+
+	doc, err := client.FindDocument(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	logger.Info("downloading document", "name", doc.Name, "id", doc.ID)
+
+	nb, err := client.DownloadNotebook(ctx, doc)
+	if err != nil {
+		return err
+	}
+	setOrientation(nb.Content)
+	setDocumentInfo(nb.Metadata, nb.Content)
+
+	pages, err := notebookPages(nb)
+	if err != nil {
+		return err
+	}
+	trees, err := parsePages(pages)
+	if err != nil {
+		return err
+	}
+
+	return exportPages(trees, outputFormat())
+}
+
+// deviceTokenPath returns where the cloud device token is stored
+func deviceTokenPath() (string, error) {
+	if cloudTokenFile != "" {
+		return cloudTokenFile, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "rmc-go", "device-token"), nil
+}
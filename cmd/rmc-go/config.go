@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/joagonca/rmc-go"
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/spf13/pflag"
+)
+
+var (
+	configFile string
+
+	// Set from the config file
+	configPalette map[parser.PenColor]export.RGB
+	defaultFormat = "pdf"
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "YAML file with default options (default: <user config dir>/rmc-go/config.yaml)")
+}
+
+// applyConfig sets the options that were not given on the command line from
+// the config file. Without --config, a missing default config file is not an
+// error.
+func applyConfig(flags *pflag.FlagSet) error {
+	path := configFile
+	if path == "" {
+		var err error
+		if path, err = rmc.DefaultConfigPath(); err != nil {
+			return nil
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+	}
+
+	cfg, err := rmc.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	logger.Debug("using config file", "path", path)
+
+	unset := func(name string) bool { return !flags.Changed(name) }
+	if cfg.Renderer != "" && unset("legacy") {
+		useLegacy = cfg.Renderer == "legacy"
+	}
+	if cfg.Format != "" {
+		defaultFormat = string(cfg.Format)
+	}
+	if cfg.PageSize != "" && unset("page-size") {
+		pageSize = cfg.PageSize
+	}
+	if cfg.Palette != nil && unset("palette") {
+		if configPalette, err = export.PaletteFromMap(cfg.Palette); err != nil {
+			return err
+		}
+	}
+	if cfg.PenProfile != "" && unset("pen-profile") {
+		penProfile = cfg.PenProfile
+	}
+	if cfg.InkscapePath != "" && unset("inkscape") {
+		inkscape = cfg.InkscapePath
+	}
+	if cfg.SVGConverter != "" && unset("svg-converter") {
+		converter = cfg.SVGConverter
+	}
+	if cfg.PdfMergeTool != "" && unset("pdf-merge-tool") {
+		mergeTool = cfg.PdfMergeTool
+	}
+	if cfg.TempDir != "" && unset("temp-dir") {
+		tempDir = cfg.TempDir
+	}
+	if cfg.Outline && unset("outline") {
+		outline = true
+	}
+	if cfg.Contents && unset("contents") {
+		contents = true
+	}
+	if cfg.NUp != "" && unset("nup") {
+		nup = cfg.NUp
+	}
+	if cfg.Booklet && unset("booklet") {
+		booklet = true
+	}
+	if cfg.Simplify > 0 && unset("simplify") {
+		simplify = cfg.Simplify
+	}
+	if cfg.Smooth && unset("smooth") {
+		smooth = true
+	}
+	if cfg.VariableWidth && unset("variable-width") {
+		varWidth = true
+	}
+	if cfg.ChiselMarker && unset("chisel-marker") {
+		chisel = true
+	}
+	if cfg.PencilGradient && unset("pencil-gradient") {
+		pencilGrad = true
+	}
+	return nil
+}
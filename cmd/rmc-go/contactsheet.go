@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sheetColumns int
+	sheetSize    string
+)
+
+var contactSheetCmd = &cobra.Command{
+	Use:   "contact-sheet <folder|file.rmdoc|file.rm>",
+	Short: "Draw every page of a notebook as a grid on a few PDF or PNG pages",
+	Long: `contact-sheet draws the pages of a notebook, a folder of .rm files or an
+.rm file as a grid of small pages with their page numbers, for a printable
+overview of a whole notebook. PDF output fills as many sheets as needed;
+PNG output is one image with as many rows as needed. The format follows
+the output file name or --type.
+
+--pages limits the pages drawn, and the page layout and pen flags apply to
+the pages as for conversions. Requires a Cairo build.
+
+Example:
+  rmc-go contact-sheet notebook.rmdoc -o overview.pdf
+  rmc-go contact-sheet notebook.rmdoc -o overview.png --columns 6`,
+	Args:              cobra.ExactArgs(1),
+	RunE:              runContactSheet,
+	ValidArgsFunction: rootCmd.ValidArgsFunction,
+}
+
+func init() {
+	contactSheetCmd.Flags().IntVar(&sheetColumns, "columns", 4, "Number of pages in each row")
+	contactSheetCmd.Flags().StringVar(&sheetSize, "sheet-size", "a4", "Size of the sheets: a4 or letter (the width of PNG output)")
+	contactSheetCmd.RegisterFlagCompletionFunc("sheet-size", cobra.FixedCompletions([]string{"a4", "letter"}, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.AddCommand(contactSheetCmd)
+}
+
+func runContactSheet(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+	format := outputFormat()
+	if format != "pdf" && format != "png" {
+		return fmt.Errorf("contact sheets are written as PDF or PNG, not %s; name the output .pdf or .png, or use --type", format)
+	}
+	if sheetColumns <= 0 {
+		return fmt.Errorf("--columns must be positive")
+	}
+	size, err := export.ParsePageSize(sheetSize)
+	if err != nil {
+		return err
+	}
+	if size != export.PageSizeA4 && size != export.PageSizeLetter {
+		return fmt.Errorf("--sheet-size must be a4 or letter")
+	}
+
+	_, trees, err := readPages(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := selectPages(trees); err != nil {
+		return err
+	}
+
+	opts := export.DefaultContactSheetOptions()
+	opts.SVGOptions = pngOpts.SVGOptions
+	opts.Columns = sheetColumns
+	opts.SheetWidth, opts.SheetHeight = size.Dimensions()
+
+	// Keep the page numbers of the notebook when only some pages are drawn
+	var selected []*parser.SceneTree
+	for i, tree := range trees {
+		if pageRanges.Contains(i + 1) {
+			selected = append(selected, tree)
+			opts.Labels = append(opts.Labels, strconv.Itoa(i+1))
+		}
+	}
+
+	out, err := createOutput()
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		defer out.Close()
+	}
+	if format == "png" {
+		err = export.ExportContactSheetPNG(selected, out, opts)
+	} else {
+		err = export.ExportContactSheetPDF(selected, out, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to draw contact sheet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Report which external tools and export paths are available",
+	Long: `doctor checks for the external tools used by rmc-go (Cairo, Inkscape,
+rsvg-convert, pdfunite, Ghostscript and ffmpeg), reports their versions, and lists
+which export paths will work on this system.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	opts, err := toolOptions()
+	if err != nil {
+		return err
+	}
+
+	tools := make(map[string]export.ToolStatus)
+	out := cmd.OutOrStdout()
+
+	fmt.Fprintln(out, "Tools:")
+	for _, t := range export.CheckTools(opts) {
+		tools[t.Name] = t
+		if t.Available() {
+			fmt.Fprintf(out, "  [ok]      %-9s %s\n", t.Name, describeTool(t))
+		} else {
+			fmt.Fprintf(out, "  [missing] %-9s %v\n", t.Name, t.Err)
+		}
+	}
+
+	ok := func(name string) bool { return tools[name].Available() }
+	conv, convNeed := ok("inkscape"), "inkscape"
+	if opts.Converter == export.ConverterRsvg {
+		conv, convNeed = ok("rsvg"), "rsvg-convert"
+	}
+	// The built-in merger is used when no merge tool is chosen or installed
+	merge, outline := true, true
+	switch opts.MergeTool {
+	case export.MergeToolPdfunite:
+		merge, outline = ok("pdfunite"), ok("gs")
+	case export.MergeToolGhostscript:
+		merge, outline = ok("gs"), ok("gs")
+	}
+
+	fmt.Fprintln(out, "\nExport paths:")
+	printPath(out, "SVG / HTML", true, "")
+	printPath(out, "PDF (Cairo)", ok("cairo"), "needs a Cairo build")
+	printPath(out, "Multipage PDF (Cairo)", ok("cairo"), "needs a Cairo build")
+	printPath(out, "EPS (Cairo)", ok("cairo"), "needs a Cairo build")
+	printPath(out, "GIF replay (Cairo)", ok("cairo"), "needs a Cairo build")
+	printPath(out, "MP4 replay (Cairo)", ok("cairo") && ok("ffmpeg"), "needs a Cairo build and ffmpeg")
+	printPath(out, "PDF (--legacy)", conv, "needs "+convNeed)
+	printPath(out, "EPS (--legacy)", conv, "needs "+convNeed)
+	printPath(out, "Multipage PDF (--legacy)", conv && merge, "needs "+convNeed+" and a PDF merge tool")
+	printPath(out, "Outline (--legacy --outline)", conv && outline, "needs "+convNeed+" and gs")
+	printPath(out, "PDF/A (--pdf-profile pdfa-2b)", ok("gs"), "needs gs")
+
+	return nil
+}
+
+// describeTool formats the version and location of an available tool
+func describeTool(t export.ToolStatus) string {
+	if t.Path == "" {
+		return t.Version
+	}
+	return fmt.Sprintf("%s (%s)", t.Version, t.Path)
+}
+
+// printPath prints whether an export path will work, with the reason if not
+func printPath(w io.Writer, name string, available bool, requirement string) {
+	if available {
+		fmt.Fprintf(w, "  [ok]      %s\n", name)
+	} else {
+		fmt.Fprintf(w, "  [missing] %s: %s\n", name, requirement)
+	}
+}
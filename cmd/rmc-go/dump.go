@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/spf13/cobra"
+)
+
+var dumpRaw bool
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump <file.rm>",
+	Short: "Print the raw blocks of an .rm file",
+	Long: `dump lists every block of a version 6 .rm file with its offset, size,
+type and versions, followed by the tagged fields it contains. Data that
+cannot be decoded as tagged fields is shown as a hex dump.
+
+The output does not depend on the parser understanding the blocks, which
+makes it useful when reporting problems with files from new firmware.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDump,
+}
+
+func init() {
+	dumpCmd.Flags().BoolVar(&dumpRaw, "raw", false, "Also print a hex dump of every block")
+	rootCmd.AddCommand(dumpCmd)
+}
+
+func runDump(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	version, err := parser.DetectVersion(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+	if version != 6 {
+		return fmt.Errorf("dump only supports version 6 files, %s is version %d", args[0], version)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind input file: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	blocks, err := parser.ReadRawBlocks(f)
+	for i, block := range blocks {
+		fmt.Fprintf(out, "Block %d at offset %d: %s (0x%02X), %d bytes, version %d (min %d)\n",
+			i, block.Offset, parser.BlockTypeName(block.BlockType), block.BlockType,
+			block.Size, block.CurrentVersion, block.MinVersion)
+
+		fields, rest := parser.DecodeFields(block.Data)
+		printFields(out, fields, "  ")
+		if len(rest) > 0 {
+			fmt.Fprintf(out, "  undecoded, %d bytes:\n", len(rest))
+			printHex(out, rest, "    ")
+		}
+		if dumpRaw {
+			fmt.Fprintln(out, "  raw:")
+			printHex(out, block.Data, "    ")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// printFields prints decoded fields, indenting the contents of subblocks
+func printFields(w io.Writer, fields []parser.Field, indent string) {
+	for _, field := range fields {
+		fmt.Fprintf(w, "%s+%-5d [%d] %-7s %s\n", indent, field.Offset, field.Index, field.Type, field.Value)
+		printFields(w, field.Children, indent+"  ")
+		if len(field.Raw) > 0 {
+			printHex(w, field.Raw, indent+"    ")
+		}
+	}
+}
+
+// printHex prints a hex dump of data with every line indented
+func printHex(w io.Writer, data []byte, indent string) {
+	for line := range strings.Lines(hex.Dump(data)) {
+		fmt.Fprint(w, indent, line)
+	}
+}
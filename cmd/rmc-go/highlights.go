@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/spf13/cobra"
+)
+
+var highlightsJSON bool
+
+var highlightsCmd = &cobra.Command{
+	Use:   "highlights <file.rm|folder|file.rmdoc>",
+	Short: "Extract the highlights of an annotated PDF or EPUB as Markdown or JSON",
+	Long: `highlights collects the text selected with the highlighter on each page of
+a notebook, or of a folder of .rm files or a single .rm file, and prints it
+as Markdown grouped by page (or as JSON with --json). Highlighter strokes
+drawn freehand are listed too; the file does not record the text under them.
+
+The color of each highlight is the pen color name, and with --json also the
+color it is drawn in (see list-colors). --pages limits the pages searched.
+
+Example:
+  rmc-go highlights book.rmdoc -o highlights.md`,
+	Args:              cobra.ExactArgs(1),
+	RunE:              runHighlights,
+	ValidArgsFunction: rootCmd.ValidArgsFunction,
+}
+
+func init() {
+	highlightsCmd.Flags().BoolVar(&highlightsJSON, "json", false, "Print the highlights as JSON")
+	rootCmd.AddCommand(highlightsCmd)
+}
+
+// highlightEntry is the JSON form of a highlight
+type highlightEntry struct {
+	Page   int        `json:"page"`
+	Text   string     `json:"text,omitempty"`
+	Color  string     `json:"color"`
+	Hex    string     `json:"hex"`
+	Stroke bool       `json:"stroke,omitempty"`
+	Bounds [4]float64 `json:"bounds"` // x, y, width, height
+
+	label string // Color shown in Markdown: the hex color when the file stores one, otherwise the name
+}
+
+func runHighlights(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+
+	title, trees, err := readPages(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := selectPages(trees); err != nil {
+		return err
+	}
+
+	// Keep the page numbers of the notebook when only some pages are searched
+	var entries []highlightEntry
+	for i, tree := range trees {
+		if !pageRanges.Contains(i + 1) {
+			continue
+		}
+		for _, h := range parser.PageHighlights(tree, i+1) {
+			b := h.Bounds
+			entry := highlightEntry{
+				Page:   h.Page,
+				Text:   h.Text,
+				Color:  h.Color.String(),
+				Hex:    highlightHex(h),
+				Stroke: h.Stroke,
+				Bounds: [4]float64{b.X, b.Y, b.W, b.H},
+				label:  h.Color.String(),
+			}
+			if h.ColorOverride != nil {
+				entry.label = entry.Hex
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	out, err := createOutput()
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		defer out.Close()
+	}
+
+	if highlightsJSON {
+		if entries == nil {
+			entries = []highlightEntry{}
+		}
+		return printJSON(out, entries)
+	}
+	writeHighlightsMarkdown(out, title, entries)
+	return nil
+}
+
+// readPages parses an .rm file, a folder of .rm files or a notebook archive,
+// and returns a title for it with its pages
+func readPages(path string) (string, []*parser.SceneTree, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to access input path: %w", err)
+	}
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	if info.IsDir() {
+		trees, err := readDirectory(path)
+		return title, trees, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".rmdoc", ".zip":
+		nb, err := readArchive(path)
+		if err != nil {
+			return "", nil, err
+		}
+		trees, err := parsePages(nb.OrderedPages())
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nb.Name(), trees, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+	tree, err := parser.ReadSceneTreeWithLogger(f, logger)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse .rm file: %w", err)
+	}
+	return title, []*parser.SceneTree{tree}, nil
+}
+
+// highlightHex returns the color a highlight is drawn in: the color stored
+// with it, or the --palette color, or the device color
+func highlightHex(h parser.Highlight) string {
+	if c := h.ColorOverride; c != nil {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	if rgb, ok := pdfOpts.Palette[h.Color]; ok {
+		return fmt.Sprintf("#%02x%02x%02x", rgb.R, rgb.G, rgb.B)
+	}
+	for _, c := range export.Colors() {
+		if c.Color == h.Color && !c.FromFile {
+			return fmt.Sprintf("#%02x%02x%02x", c.RGB.R, c.RGB.G, c.RGB.B)
+		}
+	}
+	return ""
+}
+
+// writeHighlightsMarkdown writes the highlights under a heading per page.
+// Highlighted text is quoted; freehand strokes are counted per page.
+func writeHighlightsMarkdown(w io.Writer, title string, entries []highlightEntry) {
+	fmt.Fprintf(w, "# %s\n", title)
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "\nNo highlights found.\n")
+		return
+	}
+
+	for i := 0; i < len(entries); {
+		page := entries[i].Page
+		fmt.Fprintf(w, "\n## Page %d\n", page)
+
+		strokes := make(map[string]int)
+		var colors []string
+		for ; i < len(entries) && entries[i].Page == page; i++ {
+			e := entries[i]
+			if e.Stroke {
+				if strokes[e.label] == 0 {
+					colors = append(colors, e.label)
+				}
+				strokes[e.label]++
+				continue
+			}
+			text := strings.ReplaceAll(strings.TrimSpace(e.Text), "\n", "\n> ")
+			fmt.Fprintf(w, "\n> %s\n>\n> — *%s*\n", text, e.label)
+		}
+		if len(colors) > 0 {
+			fmt.Fprintln(w)
+		}
+		for _, color := range colors {
+			noun := "stroke"
+			if strokes[color] > 1 {
+				noun = "strokes"
+			}
+			fmt.Fprintf(w, "- %d freehand highlighter %s (*%s*)\n", strokes[color], noun, color)
+		}
+	}
+}
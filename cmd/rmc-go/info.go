@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/spf13/cobra"
+)
+
+var infoJSON bool
+
+var infoCmd = &cobra.Command{
+	Use:   "info <file.rm>",
+	Short: "Print a summary of an .rm file",
+	Long: `info parses an .rm file and prints its format version, the block types
+it contains, its layers with their labels and visibility, stroke and point
+counts, total ink length, when the strokes were written (if the file
+records it), the bounds of the strokes, stroke counts per pen type and
+color, whether it has typed text and text-anchored groups, and
+any unknown blocks or other problems found while parsing.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInfo,
+}
+
+func init() {
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, "Print the summary as JSON")
+	rootCmd.AddCommand(infoCmd)
+}
+
+// infoReport is the JSON form of the info output
+type infoReport struct {
+	File           string         `json:"file"`
+	Version        int            `json:"version"`
+	Blocks         map[string]int `json:"blocks,omitempty"`
+	UnknownBlocks  map[string]int `json:"unknownBlocks,omitempty"`
+	Layers         int            `json:"layers"`
+	LayerDetails   []infoLayer    `json:"layerDetails"`
+	Strokes        int            `json:"strokes"`
+	Points         int            `json:"points"`
+	InkLength      float64        `json:"inkLength"`
+	TimedStrokes   int            `json:"timedStrokes"`
+	FirstStroke    *time.Time     `json:"firstStroke,omitempty"`
+	LastStroke     *time.Time     `json:"lastStroke,omitempty"`
+	HasText        bool           `json:"hasText"`
+	AnchoredGroups int            `json:"anchoredGroups"`
+	Bounds         *[4]float64    `json:"bounds,omitempty"` // minX, minY, maxX, maxY
+	StrokesByTool  map[string]int `json:"strokesByTool"`
+	StrokesByColor map[string]int `json:"strokesByColor"`
+	Warnings       []string       `json:"warnings,omitempty"`
+}
+
+// infoLayer describes one layer in the info output
+type infoLayer struct {
+	Label   string `json:"label"`
+	Visible bool   `json:"visible"`
+	Strokes int    `json:"strokes"`
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	// Warnings are part of the report, so don't log them as well
+	result, err := parser.ReadScene(f, slog.New(slog.DiscardHandler))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[0], err)
+	}
+
+	stats := parser.ComputeStats(result.Tree)
+	report := infoReport{
+		File:           args[0],
+		Version:        result.Version,
+		Layers:         stats.Layers,
+		Strokes:        stats.Strokes,
+		Points:         stats.Points,
+		InkLength:      stats.InkLength,
+		HasText:        stats.HasText,
+		AnchoredGroups: stats.AnchoredGroups,
+		StrokesByTool:  make(map[string]int),
+		StrokesByColor: make(map[string]int),
+	}
+	if stats.TimedStrokes > 0 {
+		report.TimedStrokes = stats.TimedStrokes
+		report.FirstStroke, report.LastStroke = &stats.FirstStroke, &stats.LastStroke
+	}
+	if stats.Strokes > 0 {
+		report.Bounds = &[4]float64{stats.MinX, stats.MinY, stats.MaxX, stats.MaxY}
+	}
+	for blockType, n := range result.BlockCounts {
+		if parser.IsKnownBlockType(blockType) {
+			if report.Blocks == nil {
+				report.Blocks = make(map[string]int)
+			}
+			report.Blocks[parser.BlockTypeName(blockType)] = n
+		} else {
+			if report.UnknownBlocks == nil {
+				report.UnknownBlocks = make(map[string]int)
+			}
+			report.UnknownBlocks[fmt.Sprintf("0x%02X", blockType)] = n
+		}
+	}
+	for _, layer := range stats.LayerDetails {
+		report.LayerDetails = append(report.LayerDetails, infoLayer(layer))
+	}
+	for _, w := range result.Warnings {
+		report.Warnings = append(report.Warnings, w.String())
+	}
+	for tool, n := range stats.StrokesByTool {
+		report.StrokesByTool[tool.String()] += n
+	}
+	for color, n := range stats.StrokesByColor {
+		report.StrokesByColor[color.String()] += n
+	}
+
+	out := cmd.OutOrStdout()
+	if infoJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	fmt.Fprintf(out, "File:        %s\n", report.File)
+	fmt.Fprintf(out, "Version:     %d\n", report.Version)
+	fmt.Fprintf(out, "Layers:      %d\n", report.Layers)
+	for i, layer := range report.LayerDetails {
+		label := layer.Label
+		if label == "" {
+			label = fmt.Sprintf("(unnamed layer %d)", i+1)
+		}
+		visibility := "visible"
+		if !layer.Visible {
+			visibility = "hidden"
+		}
+		fmt.Fprintf(out, "  %-18s %s, %d strokes\n", label, visibility, layer.Strokes)
+	}
+	fmt.Fprintf(out, "Strokes:     %d (%d points)\n", report.Strokes, report.Points)
+	fmt.Fprintf(out, "Ink length:  %.1f screen units\n", report.InkLength)
+	if report.FirstStroke != nil {
+		fmt.Fprintf(out, "Written:     %s to %s (%d of %d strokes have times)\n",
+			report.FirstStroke.Format(time.DateTime), report.LastStroke.Format(time.DateTime), report.TimedStrokes, report.Strokes)
+	}
+	if b := report.Bounds; b != nil {
+		fmt.Fprintf(out, "Bounds:      x %.1f to %.1f, y %.1f to %.1f\n", b[0], b[2], b[1], b[3])
+	}
+	fmt.Fprintf(out, "Typed text:  %s\n", yesNo(report.HasText))
+	fmt.Fprintf(out, "Anchored:    %d groups positioned relative to text\n", report.AnchoredGroups)
+	printCounts(out, "Strokes by tool:", report.StrokesByTool)
+	printCounts(out, "Strokes by color:", report.StrokesByColor)
+	printCounts(out, "Blocks:", report.Blocks)
+	printCounts(out, "Unknown blocks:", report.UnknownBlocks)
+	if len(report.Warnings) > 0 {
+		fmt.Fprintln(out, "Warnings:")
+		for _, w := range report.Warnings {
+			fmt.Fprintf(out, "  %s\n", w)
+		}
+	}
+	return nil
+}
+
+// printCounts prints named counts, largest first
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Fprintln(w, title)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-18s %d\n", name, counts[name])
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
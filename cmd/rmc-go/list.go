@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/spf13/cobra"
+)
+
+// listJSON is shared by the list commands
+var listJSON bool
+
+// listThicknessScales are the thickness scales list-tools shows widths for,
+// matching the three thickness settings on the device
+var listThicknessScales = []float64{1, 2, 3}
+
+var listToolsCmd = &cobra.Command{
+	Use:   "list-tools",
+	Short: "List the supported pen types and how they are rendered",
+	Args:  cobra.NoArgs,
+	RunE:  runListTools,
+}
+
+var listColorsCmd = &cobra.Command{
+	Use:   "list-colors",
+	Short: "List the supported pen colors and the colors they are drawn in",
+	Long: `list-colors prints every pen color ID with the color it is drawn in.
+Colors can be replaced with --palette or the palette key of the config file,
+using the names printed here. Highlighter and shader strokes written by
+newer firmware carry their own color, which is used instead.`,
+	Args: cobra.NoArgs,
+	RunE: runListColors,
+}
+
+var listStylesCmd = &cobra.Command{
+	Use:   "list-styles",
+	Short: "List the paragraph styles of typed text and how they are rendered",
+	Args:  cobra.NoArgs,
+	RunE:  runListStyles,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{listToolsCmd, listColorsCmd, listStylesCmd} {
+		cmd.Flags().BoolVar(&listJSON, "json", false, "Print the list as JSON")
+		rootCmd.AddCommand(cmd)
+	}
+}
+
+// listTool is the JSON form of a list-tools entry
+type listTool struct {
+	Name          string    `json:"name"`
+	IDs           []int     `json:"ids"`
+	Widths        []float64 `json:"widths"` // At each of listThicknessScales
+	Cap           string    `json:"cap"`
+	Opacity       float64   `json:"opacity"`
+	Multiply      bool      `json:"multiply"`
+	VariableWidth bool      `json:"variableWidth"`
+}
+
+func runListTools(cmd *cobra.Command, args []string) error {
+	var tools []listTool
+	for _, p := range export.Pens() {
+		tool := listTool{
+			Name:          p.Name,
+			Cap:           p.Cap,
+			Opacity:       p.Opacity,
+			Multiply:      p.Multiply,
+			VariableWidth: p.VariableWidth,
+		}
+		for _, id := range p.IDs {
+			tool.IDs = append(tool.IDs, int(id))
+		}
+		for _, scale := range listThicknessScales {
+			tool.Widths = append(tool.Widths, p.Width(scale))
+		}
+		tools = append(tools, tool)
+	}
+
+	out := cmd.OutOrStdout()
+	if listJSON {
+		return printJSON(out, tools)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tIDS\tWIDTH (THIN/MEDIUM/THICK)\tCAP\tOPACITY\tBLEND\tVARIABLE WIDTH")
+	for _, t := range tools {
+		ids := make([]string, len(t.IDs))
+		for i, id := range t.IDs {
+			ids[i] = fmt.Sprint(id)
+		}
+		widths := make([]string, len(t.Widths))
+		for i, width := range t.Widths {
+			widths[i] = fmt.Sprintf("%.1f", width)
+		}
+		blend := "normal"
+		if t.Multiply {
+			blend = "multiply"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f\t%s\t%s\n", t.Name, strings.Join(ids, ","),
+			strings.Join(widths, "/"), t.Cap, t.Opacity, blend, yesNo(t.VariableWidth))
+	}
+	return w.Flush()
+}
+
+// listColor is the JSON form of a list-colors entry
+type listColor struct {
+	Name     string `json:"name"`
+	ID       int    `json:"id"`
+	Hex      string `json:"hex,omitempty"`
+	FromFile bool   `json:"fromFile,omitempty"`
+}
+
+func runListColors(cmd *cobra.Command, args []string) error {
+	var colors []listColor
+	for _, c := range export.Colors() {
+		color := listColor{Name: c.Color.String(), ID: int(c.Color), FromFile: c.FromFile}
+		if !c.FromFile {
+			color.Hex = fmt.Sprintf("#%02x%02x%02x", c.RGB.R, c.RGB.G, c.RGB.B)
+		}
+		colors = append(colors, color)
+	}
+
+	out := cmd.OutOrStdout()
+	if listJSON {
+		return printJSON(out, colors)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tCOLOR")
+	for _, c := range colors {
+		hex := c.Hex
+		if c.FromFile {
+			hex = "from file"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", c.Name, c.ID, hex)
+	}
+	return w.Flush()
+}
+
+// listStyle is the JSON form of a list-styles entry
+type listStyle struct {
+	Name       string  `json:"name"`
+	ID         int     `json:"id"`
+	Font       string  `json:"font"`
+	FontSize   float64 `json:"fontSize"`
+	Bold       bool    `json:"bold"`
+	LineHeight float64 `json:"lineHeight"`
+	Prefix     string  `json:"prefix,omitempty"`
+}
+
+func runListStyles(cmd *cobra.Command, args []string) error {
+	var styles []listStyle
+	for _, s := range export.ParagraphStyles() {
+		styles = append(styles, listStyle{
+			Name:       s.Name,
+			ID:         int(s.Style),
+			Font:       s.Font,
+			FontSize:   s.FontSize,
+			Bold:       s.Bold,
+			LineHeight: s.LineHeight,
+			Prefix:     s.Prefix,
+		})
+	}
+
+	out := cmd.OutOrStdout()
+	if listJSON {
+		return printJSON(out, styles)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tFONT\tSIZE\tWEIGHT\tLINE HEIGHT\tPREFIX")
+	for _, s := range styles {
+		weight := "normal"
+		if s.Bold {
+			weight = "bold"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%g\t%s\t%g\t%q\n", s.Name, s.ID, s.Font, s.FontSize, weight, s.LineHeight, s.Prefix)
+	}
+	return w.Flush()
+}
+
+// printJSON writes v as indented JSON
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 
+	"github.com/joagonca/rmc-go"
 	"github.com/joagonca/rmc-go/export"
 	"github.com/joagonca/rmc-go/parser"
 	"github.com/spf13/cobra"
@@ -17,32 +21,224 @@ var (
 	outputType  string
 	useLegacy   bool
 	contentFile string
+	glyphStyle  string
+	verbose     bool
+	quiet       bool
+	pageSize    string
+	pageSelect  string
+	perPage     bool
+	stdinTar    bool
+	crop        bool
+	outScale    float64
+	outWidth    float64
+	outHeight   float64
+	padding     string
+	outline     bool
+	contents    bool
+	nup         string
+	booklet     bool
+	textLayer   bool
+	fontFile    string
+	ocrCommand  string
+	pdfProfile  string
+	docTitle    string
+	docAuthor   string
+	inkscape    string
+	converter   string
+	mergeTool   string
+	tempDir     string
+	simplify    float64
+	smooth      bool
+	varWidth    bool
+	chisel      bool
+	pencilGrad  bool
+	keepErasers bool
+	snapHL      bool
+	fixedOutput bool
+	compactSVG  bool
+	svgDigits   int
+	since       string
+	until       string
+	onlyTools   []string
+	exclColors  []string
+	animate     time.Duration
+	fps         float64
+	paletteFile string
+	penProfile  string
+
+	filterTags []string
+	pageTags   [][]string // Tags of the notebook's pages, for the table of contents
+	pageIDs    []string   // IDs of the notebook's pages, for links to pages
+
+	logger     = slog.Default()
+	pageRanges parser.PageRanges
+	pdfOpts    = export.DefaultPDFOptions()
+	pngOpts    = export.DefaultPNGOptions()
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "rmc-go [input.rm|folder]",
+	Use:   "rmc-go [input.rm|input.rmdoc|input.zip|folder]",
 	Short: "Convert reMarkable v6 files to PDF/SVG",
 	Long: `rmc-go is a tool to convert reMarkable tablet v6 format files to PDF or SVG.
 
 Example usage:
   rmc-go file.rm -o output.pdf
   rmc-go file.rm -o output.svg
+  rmc-go file.rm -o output.html
+  rmc-go file.rm -o output.eps
+  rmc-go file.rm -o output.png
+  rmc-go file.rm -o output.gif --animate 20s  # Replay the page being drawn
+  rmc-go file.rm -o output.pdf --pdf-profile pdfa-2b  # PDF/A for archiving
   rmc-go file.rm -t pdf > output.pdf
   rmc-go file.rm -o output.pdf --legacy  # Use Inkscape renderer
+  rmc-go file.rm -o output.pdf --legacy --svg-converter rsvg  # Use rsvg-convert instead
+  rmc-go notebook.rmdoc -o output.pdf  # Archive exported by the reMarkable app
+  rmc-go notebook.zip -o output.pdf  # Zipped notebook folder
   rmc-go folder/ -o output.pdf  # Multipage PDF from all .rm files in folder
-  rmc-go folder/ -o output.pdf --content folder.content  # Use .content file for page ordering`,
-	Args: cobra.ExactArgs(1),
+  rmc-go notebook.rmdoc -o output.pdf --nup 2x2  # Four pages per sheet for printing
+  rmc-go folder/ -o booklet.pdf --booklet  # Saddle-stitch booklet, printed on both sides
+  rmc-go notebook.rmdoc -o output.pdf --contents  # Table of contents linking to the pages
+  rmc-go folder/ -o output.pdf --content folder.content  # Use .content file for page ordering
+  ssh root@10.11.99.1 'cd .local/share/remarkable/xochitl && tar c <uuid>.content <uuid>/' | rmc-go --stdin-tar -o output.pdf`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if stdinTar {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"rm", "rmdoc", "zip"}, cobra.ShellCompDirectiveFilterFileExt
+	},
 	RunE: run,
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
-	rootCmd.Flags().StringVarP(&outputType, "type", "t", "", "Output type: svg or pdf (default: guess from filename)")
-	rootCmd.Flags().BoolVar(&useLegacy, "legacy", false, "Use legacy Inkscape renderer for PDF export (requires Inkscape)")
-	rootCmd.Flags().StringVar(&contentFile, "content", "", "Path to .content file for page ordering (only used with folders)")
+	rootCmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
+	rootCmd.PersistentFlags().StringVarP(&outputType, "type", "t", "", "Output type: svg, svgz, pdf, html, eps, png, gif, mp4, hocr, alto, tikz or excalidraw (default: guess from filename)")
+	rootCmd.PersistentFlags().BoolVar(&useLegacy, "legacy", false, "Use legacy SVG conversion for PDF export (requires Inkscape or rsvg-convert)")
+	rootCmd.Flags().BoolVar(&stdinTar, "stdin-tar", false, "Read a notebook as a tar stream of its .rm files (and optional .content) from stdin")
+	rootCmd.Flags().StringVar(&contentFile, "content", "", "Path to .content file for page ordering of folders (default: <folder>.content, if it exists)")
+	rootCmd.PersistentFlags().BoolVar(&perPage, "per-page", false, "Write each page of a notebook or folder to its own file (page-001.svg, ...) in the -o directory")
+	rootCmd.PersistentFlags().StringVar(&pageSelect, "pages", "", "Pages of a notebook or folder to export, e.g. 1-5,8,10- (default: all)")
+	rootCmd.PersistentFlags().StringSliceVar(&filterTags, "filter-tag", nil, "Export only the pages of a notebook or folder carrying one of these tags")
+	rootCmd.PersistentFlags().StringVar(&glyphStyle, "glyphs", "unicode", "Glyph style for bullets and checkboxes: unicode, ascii or none")
+	rootCmd.PersistentFlags().StringVar(&pageSize, "page-size", "auto", "Output page size: device, a4, letter or auto (fit to content)")
+	rootCmd.PersistentFlags().BoolVar(&crop, "crop", false, "Crop pages tightly around the drawn content instead of the full screen area")
+	rootCmd.PersistentFlags().StringVar(&padding, "padding", "0", "Space around the content, e.g. 10pt, 5mm or 0.25in")
+	rootCmd.PersistentFlags().Float64Var(&outScale, "scale", 1, "Scale the output page and everything on it, e.g. 2 for a poster or 0.25 for a thumbnail")
+	rootCmd.PersistentFlags().Float64Var(&outWidth, "width", 0, "Resize the output to this width in points (pixels for PNG), keeping the aspect ratio")
+	rootCmd.PersistentFlags().Float64Var(&outHeight, "height", 0, "Resize the output to this height in points (pixels for PNG), keeping the aspect ratio")
+	rootCmd.PersistentFlags().BoolVar(&outline, "outline", false, "Add a bookmark per page to multipage PDFs, named after the page's first heading")
+	rootCmd.PersistentFlags().BoolVar(&contents, "contents", false, "Start multipage PDFs with a table of contents linking to every page")
+	rootCmd.PersistentFlags().StringVar(&nup, "nup", "", "Place several pages on each PDF sheet as columns x rows, e.g. 2x2 (sheets: --page-size, or A4)")
+	rootCmd.PersistentFlags().BoolVar(&booklet, "booklet", false, "Arrange PDF pages two per sheet side for a folded, stapled booklet printed on both sides")
+	rootCmd.PersistentFlags().BoolVar(&textLayer, "text-layer", false, "Embed fonts so typed text is selectable and searchable in PDFs (Cairo only)")
+	rootCmd.PersistentFlags().StringVar(&fontFile, "font", "", "TrueType/OpenType font to embed with --text-layer (default: a system sans-serif font)")
+	rootCmd.PersistentFlags().StringVar(&ocrCommand, "ocr-command", "", "Command that recognizes handwriting: reads a page as PNG on stdin, prints JSON words or tesseract TSV on stdout")
+	rootCmd.PersistentFlags().StringVar(&pdfProfile, "pdf-profile", "none", "PDF conformance profile: none or pdfa-2b (requires Ghostscript)")
+	rootCmd.PersistentFlags().StringVar(&docTitle, "title", "", "Title of PDF output (default: the notebook's name from its .metadata file)")
+	rootCmd.PersistentFlags().StringVar(&docAuthor, "author", "", "Author of PDF output")
+	rootCmd.PersistentFlags().StringVar(&inkscape, "inkscape", "inkscape", "Inkscape executable used by the legacy renderer")
+	rootCmd.PersistentFlags().StringVar(&converter, "svg-converter", "inkscape", "Program that converts SVG for the legacy renderer: inkscape or rsvg")
+	rootCmd.PersistentFlags().StringVar(&mergeTool, "pdf-merge-tool", "auto", "Tool for merging legacy multipage PDFs: auto, pdfunite, gs or builtin")
+	rootCmd.PersistentFlags().StringVar(&tempDir, "temp-dir", "", "Directory for the files passed to external tools (default: $TMPDIR or /tmp; Cairo output needs none)")
+	rootCmd.PersistentFlags().Float64Var(&simplify, "simplify", 0, "Drop stroke points closer than this distance (screen units, e.g. 0.5) to shrink output")
+	rootCmd.PersistentFlags().BoolVar(&smooth, "smooth", false, "Draw strokes as smooth Bezier curves instead of polylines")
+	rootCmd.PersistentFlags().BoolVar(&varWidth, "variable-width", false, "Draw pressure-sensitive pens as filled outlines with continuously varying width")
+	rootCmd.PersistentFlags().BoolVar(&chisel, "chisel-marker", false, "Draw marker strokes with a chisel tip that follows the pen's tilt, as filled shapes")
+	rootCmd.PersistentFlags().BoolVar(&pencilGrad, "pencil-gradient", false, "Shade pencil strokes smoothly with pressure instead of in steps (larger output)")
+	rootCmd.PersistentFlags().BoolVar(&keepErasers, "keep-erasers", false, "Draw eraser strokes in white instead of removing the ink they cover")
+	rootCmd.PersistentFlags().BoolVar(&snapHL, "snap-highlights", false, "Draw highlighted PDF or EPUB text as clean rectangles over the text instead of the freehand strokes")
+	rootCmd.PersistentFlags().BoolVar(&compactSVG, "compact-svg", false, "Write smaller SVG and HTML: relative path data, rounded coordinates and shared CSS classes")
+	rootCmd.PersistentFlags().IntVar(&svgDigits, "svg-precision", 2, "Decimals of coordinates with --compact-svg")
+	rootCmd.PersistentFlags().BoolVar(&fixedOutput, "deterministic", false, "Write byte-identical output for identical input: fixed PDF dates and IDs, numbered SVG group IDs")
+	rootCmd.PersistentFlags().StringVar(&since, "since", "", "Only draw strokes created at or after this time: today, yesterday, a duration like 36h, YYYY-MM-DD or RFC 3339")
+	rootCmd.PersistentFlags().StringVar(&until, "until", "", "Only draw strokes created before this time (same formats as --since)")
+	rootCmd.PersistentFlags().StringSliceVar(&onlyTools, "only-tools", nil, "Only draw strokes of these pens, e.g. highlighter,fineliner (default: all)")
+	rootCmd.PersistentFlags().StringSliceVar(&exclColors, "exclude-colors", nil, "Leave out strokes of these colors, e.g. yellow,highlight-yellow")
+	rootCmd.PersistentFlags().DurationVar(&animate, "animate", 0, "Replay the strokes as they were drawn over this long, e.g. 30s (SVG, HTML, GIF and MP4; default for GIF and MP4: 10s)")
+	rootCmd.PersistentFlags().Float64Var(&fps, "fps", 10, "Frames per second of GIF and MP4 replays")
+	rootCmd.PersistentFlags().StringVar(&paletteFile, "palette", "", "JSON file mapping pen colors to CSS colors, e.g. {\"blue\": \"#1a4f9c\"} or {\"*\": \"#000\"}")
+	rootCmd.PersistentFlags().StringVar(&penProfile, "pen-profile", "", "JSON file tuning the stroke model of each pen, e.g. {\"ballpoint\": {\"width\": 1.2}} (see the pen-profile command)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show debug output from the parser")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only show errors")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
+
+	// Shell completion for flags that take one of a few names or a file
+	for name, values := range map[string][]string{
+		"type":           {"svg", "svgz", "pdf", "html", "eps", "png", "gif", "mp4", "hocr", "alto", "tikz", "excalidraw"},
+		"page-size":      {"auto", "device", "a4", "letter"},
+		"glyphs":         {"unicode", "ascii", "none"},
+		"pdf-profile":    {"none", "pdfa-2b"},
+		"svg-converter":  {"inkscape", "rsvg"},
+		"pdf-merge-tool": {"auto", "pdfunite", "gs", "builtin"},
+	} {
+		rootCmd.RegisterFlagCompletionFunc(name, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+	}
+	rootCmd.MarkPersistentFlagFilename("palette", "json")
+	rootCmd.MarkPersistentFlagFilename("font", "ttf", "otf")
+	rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml")
+	rootCmd.MarkPersistentFlagDirname("temp-dir")
+	rootCmd.MarkFlagFilename("content", "content")
+}
+
+// toolOptions creates export options with the external tool settings from
+// the persistent flags
+func toolOptions() (*export.PDFOptions, error) {
+	tool, err := export.ParsePDFMergeTool(mergeTool)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := export.ParseSVGConverter(converter)
+	if err != nil {
+		return nil, err
+	}
+	opts := export.DefaultPDFOptions()
+	opts.InkscapePath = inkscape
+	opts.Converter = conv
+	opts.MergeTool = tool
+	opts.TempDir = tempDir
+	return opts, nil
+}
+
+// newLogger creates the stderr logger according to --verbose/--quiet
+func newLogger() *slog.Logger {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet {
+		level = slog.LevelError
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Timestamps are just noise for a one-shot CLI
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
 }
 
 func run(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+	startProgress()
+
+	format := outputFormat()
+	switch strings.ToLower(format) {
+	case "svg", "svgz", "html", "gif", "mp4":
+	default:
+		if animate > 0 {
+			return fmt.Errorf("--animate only applies to SVG, HTML, GIF and MP4 output, not %s", strings.ToUpper(format))
+		}
+	}
+
+	if stdinTar {
+		return handleTar(os.Stdin, format)
+	}
 	inputPath := args[0]
 
 	// Check if input is a file or directory
@@ -51,25 +247,275 @@ func run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to access input path: %w", err)
 	}
 
-	// Determine output type
-	format := outputType
-	if format == "" {
-		if outputFile != "" {
-			format = guessFormat(outputFile)
-		} else {
-			format = "pdf" // default
-		}
-	}
-
 	// Handle directory input
 	if info.IsDir() {
 		return handleDirectory(inputPath, format)
 	}
 
+	// Handle notebook archives: .rmdoc exports or a zipped notebook folder
+	switch strings.ToLower(filepath.Ext(inputPath)) {
+	case ".rmdoc", ".zip":
+		return handleArchive(inputPath, format)
+	}
+
 	// Handle single file input
 	return handleSingleFile(inputPath, format)
 }
 
+// setupConversion configures the logger and export options from the
+// conversion flags and the config file
+func setupConversion(cmd *cobra.Command) error {
+	logger = newLogger()
+	if err := applyConfig(cmd.Flags()); err != nil {
+		return err
+	}
+
+	glyphs, err := export.GlyphSetByName(glyphStyle)
+	if err != nil {
+		return err
+	}
+
+	size, err := export.ParsePageSize(pageSize)
+	if err != nil {
+		return err
+	}
+	pageRanges, err = parser.ParsePageRanges(pageSelect)
+	if err != nil {
+		return fmt.Errorf("invalid --pages: %w", err)
+	}
+	profile, err := export.ParsePDFProfile(pdfProfile)
+	if err != nil {
+		return err
+	}
+	pdfOpts, err = toolOptions()
+	if err != nil {
+		return err
+	}
+	pdfOpts.UseLegacy = useLegacy
+	pdfOpts.PageWidth, pdfOpts.PageHeight = size.Dimensions()
+	margin, err := export.ParseLength(padding)
+	if err != nil {
+		return fmt.Errorf("invalid --padding: %w", err)
+	}
+	pdfOpts.CropToContent = crop
+	pdfOpts.Margin = margin
+	if outScale <= 0 || outWidth < 0 || outHeight < 0 {
+		return fmt.Errorf("--scale must be positive, and --width and --height must not be negative")
+	}
+	pdfOpts.OutputScale = outScale
+	pdfOpts.OutputWidth, pdfOpts.OutputHeight = outWidth, outHeight
+	pdfOpts.Outline = outline
+	if pdfOpts.NUp, err = export.ParseNUp(nup); err != nil {
+		return fmt.Errorf("invalid --nup: %w", err)
+	}
+	if booklet && pdfOpts.NUp.PerSheet() > 1 {
+		return fmt.Errorf("--booklet and --nup cannot be combined")
+	}
+	pdfOpts.Booklet = booklet
+	if contents && (pdfOpts.Booklet || pdfOpts.NUp.PerSheet() > 1) {
+		return fmt.Errorf("--contents cannot be combined with --nup or --booklet")
+	}
+	pdfOpts.Contents = contents
+	pdfOpts.TextLayer = textLayer || fontFile != ""
+	pdfOpts.FontFile = fontFile
+	pdfOpts.Profile = profile
+	setDocumentInfo(nil, nil)
+	if simplify < 0 {
+		return fmt.Errorf("--simplify must not be negative")
+	}
+	pdfOpts.SimplifyTolerance = simplify
+	pdfOpts.Smooth = smooth
+	pdfOpts.VariableWidth = varWidth
+	pdfOpts.ChiselMarker = chisel
+	pdfOpts.PencilGradient = pencilGrad
+	pdfOpts.KeepErasers = keepErasers
+	pdfOpts.SnapHighlights = snapHL
+	pdfOpts.Deterministic = fixedOutput
+	if svgDigits < 1 || svgDigits > 6 {
+		return fmt.Errorf("--svg-precision must be between 1 and 6")
+	}
+	pdfOpts.Compact = compactSVG
+	pdfOpts.Precision = svgDigits
+	pdfOpts.Glyphs = &glyphs
+	if animate < 0 {
+		return fmt.Errorf("--animate must not be negative")
+	}
+	pdfOpts.Animate = animate
+	if pdfOpts.StrokeTimes, err = strokeTimes(time.Now()); err != nil {
+		return err
+	}
+	if pdfOpts.OnlyTools, pdfOpts.ExcludeColors, err = strokeFilters(); err != nil {
+		return err
+	}
+	if paletteFile != "" {
+		palette, err := export.ReadPaletteFile(paletteFile)
+		if err != nil {
+			return err
+		}
+		pdfOpts.Palette = palette
+	} else {
+		pdfOpts.Palette = configPalette
+	}
+	if penProfile != "" {
+		if pdfOpts.PenProfile, err = export.ReadPenProfileFile(penProfile); err != nil {
+			return err
+		}
+	}
+	if ocrCommand != "" {
+		fields := strings.Fields(ocrCommand)
+		pdfOpts.Recognizer = export.CommandRecognizer{Name: fields[0], Args: fields[1:]}
+	}
+	pngOpts = export.DefaultPNGOptions()
+	pngOpts.PageWidth, pngOpts.PageHeight = pdfOpts.PageWidth, pdfOpts.PageHeight
+	pngOpts.CropToContent, pngOpts.Margin = crop, margin
+	pngOpts.OutputScale = outScale
+	pngOpts.OutputWidth, pngOpts.OutputHeight = outWidth, outHeight
+	pngOpts.SimplifyTolerance = simplify
+	pngOpts.Smooth = smooth
+	pngOpts.VariableWidth = varWidth
+	pngOpts.ChiselMarker = chisel
+	pngOpts.PencilGradient = pencilGrad
+	pngOpts.KeepErasers = keepErasers
+	pngOpts.SnapHighlights = snapHL
+	pngOpts.Deterministic = fixedOutput
+	pngOpts.StrokeTimes = pdfOpts.StrokeTimes
+	pngOpts.OnlyTools, pngOpts.ExcludeColors = pdfOpts.OnlyTools, pdfOpts.ExcludeColors
+	pngOpts.Palette = pdfOpts.Palette
+	pngOpts.PenProfile = pdfOpts.PenProfile
+	pngOpts.Glyphs = pdfOpts.Glyphs
+	if fps <= 0 {
+		return fmt.Errorf("--fps must be positive")
+	}
+	return nil
+}
+
+// strokeTimes parses --since and --until
+func strokeTimes(now time.Time) (parser.TimeRange, error) {
+	var r parser.TimeRange
+	var err error
+	if since != "" {
+		if r.Since, err = parser.ParseTime(since, now); err != nil {
+			return r, fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if until != "" {
+		if r.Until, err = parser.ParseTime(until, now); err != nil {
+			return r, fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+	if !r.Since.IsZero() && !r.Until.IsZero() && !r.Since.Before(r.Until) {
+		return r, fmt.Errorf("--since must be before --until")
+	}
+	return r, nil
+}
+
+// strokeFilters parses --only-tools and --exclude-colors
+func strokeFilters() ([]parser.Pen, []parser.PenColor, error) {
+	var tools []parser.Pen
+	for _, name := range onlyTools {
+		tool, ok := parser.ParsePen(strings.TrimSpace(name))
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid --only-tools: unknown pen: %s", name)
+		}
+		tools = append(tools, tool)
+	}
+	var colors []parser.PenColor
+	for _, name := range exclColors {
+		color, ok := parser.ParsePenColor(strings.TrimSpace(name))
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid --exclude-colors: unknown color: %s", name)
+		}
+		colors = append(colors, color)
+	}
+	return tools, colors, nil
+}
+
+// warnUntimed warns when --since or --until leaves out strokes only because
+// the file has no stroke creation times
+func warnUntimed(trees []*parser.SceneTree) {
+	if pdfOpts.StrokeTimes.IsZero() {
+		return
+	}
+	for _, tree := range trees {
+		if stats := parser.ComputeStats(tree); stats.Strokes > stats.TimedStrokes {
+			logger.Warn("some strokes have no creation time and are left out by --since and --until",
+				"strokes", stats.Strokes, "timed", stats.TimedStrokes)
+			return
+		}
+	}
+}
+
+// setOrientation lays out the output pages for the notebook's orientation
+func setOrientation(content *parser.ContentFile) {
+	landscape := content != nil && content.IsLandscape()
+	pdfOpts.Landscape = landscape
+	pngOpts.Landscape = landscape
+}
+
+// setDocumentInfo sets the title, author, dates and keywords of PDF output
+// from --title, --author and the notebook's .metadata and .content files,
+// if it has them
+func setDocumentInfo(meta *parser.Metadata, content *parser.ContentFile) {
+	info := export.PDFMetadata{Title: docTitle, Author: docAuthor}
+	pdfOpts.Metadata = info.WithNotebook(meta).WithTags(content)
+}
+
+// notebookPages returns the .rm data of a notebook's pages in order, only
+// those carrying one of the --filter-tag tags if any are given, and keeps
+// their tags for the table of contents
+func notebookPages(nb *parser.Notebook) ([][]byte, error) {
+	ids := nb.OrderedPageIDs()
+	if len(filterTags) > 0 {
+		if ids = nb.TaggedPageIDs(filterTags...); len(ids) == 0 {
+			return nil, fmt.Errorf("no pages of %s are tagged %s", nb.Name(), strings.Join(filterTags, ", "))
+		}
+	}
+
+	pages := make([][]byte, len(ids))
+	for i, id := range ids {
+		pages[i] = nb.Pages[id]
+	}
+	setPageTags(nb.Content, ids)
+	pageIDs = ids
+	return pages, nil
+}
+
+// setPageTags keeps the tags of the given pages for the table of contents
+func setPageTags(content *parser.ContentFile, ids []string) {
+	pageTags = nil
+	if content == nil {
+		return
+	}
+	pageTags = make([][]string, len(ids))
+	for i, id := range ids {
+		pageTags[i] = content.PageTagNames(id)
+	}
+}
+
+// outputFormat determines the output type from --type or the output filename
+func outputFormat() string {
+	if outputType != "" {
+		return outputType
+	}
+	if outputFile != "" {
+		return guessFormat(outputFile)
+	}
+	return defaultFormat
+}
+
+// createOutput opens the output file, or stdout if none was given.
+// The caller closes the file when it is not stdout.
+func createOutput() (*os.File, error) {
+	if outputFile == "" {
+		return os.Stdout, nil
+	}
+	out, err := os.Create(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return out, nil
+}
+
 func handleSingleFile(inputFile string, format string) error {
 	// Open input file
 	f, err := os.Open(inputFile)
@@ -79,133 +525,354 @@ func handleSingleFile(inputFile string, format string) error {
 	defer f.Close()
 
 	// Parse the .rm file
-	tree, err := parser.ReadSceneTree(f)
+	tree, err := parser.ReadSceneTreeWithLogger(f, logger)
 	if err != nil {
 		return fmt.Errorf("failed to parse .rm file: %w", err)
 	}
+	if perPage {
+		return writePerPage([]*parser.SceneTree{tree}, format)
+	}
 
 	// Determine output writer
-	var out *os.File
-	if outputFile != "" {
-		out, err = os.Create(outputFile)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
-		}
+	out, err := createOutput()
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
 		defer out.Close()
-	} else {
-		out = os.Stdout
 	}
 
-	// Export
+	return writePages([]*parser.SceneTree{tree}, out, format)
+}
+
+// exportTree exports a single page in the given format
+func exportTree(tree *parser.SceneTree, out io.Writer, format string) error {
 	switch strings.ToLower(format) {
 	case "svg":
-		if err := export.ExportToSVG(tree, out); err != nil {
+		if err := export.ExportToSVGWithOptions(tree, out, &pdfOpts.SVGOptions); err != nil {
 			return fmt.Errorf("failed to export to SVG: %w", err)
 		}
+	case "svgz":
+		if err := export.ExportToSVGZWithOptions(tree, out, &pdfOpts.SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to SVGZ: %w", err)
+		}
 	case "pdf":
-		if err := export.ExportToPDF(tree, out, useLegacy); err != nil {
+		if err := export.ExportToPDFWithOptions(tree, out, pdfOpts); err != nil {
 			return fmt.Errorf("failed to export to PDF: %w", err)
 		}
+	case "html":
+		if err := export.ExportToHTMLWithOptions(tree, out, &pdfOpts.SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to HTML: %w", err)
+		}
+	case "eps":
+		if err := export.ExportToEPSWithOptions(tree, out, pdfOpts); err != nil {
+			return fmt.Errorf("failed to export to EPS: %w", err)
+		}
+	case "png":
+		if err := export.ExportToPNGWithOptions(tree, out, pngOpts); err != nil {
+			return fmt.Errorf("failed to export to PNG: %w", err)
+		}
+	case "gif":
+		if err := export.ExportToGIFWithOptions(tree, out, replayOptions()); err != nil {
+			return fmt.Errorf("failed to export to GIF: %w", err)
+		}
+	case "mp4":
+		if err := export.ExportToMP4WithOptions(tree, out, replayOptions()); err != nil {
+			return fmt.Errorf("failed to export to MP4: %w", err)
+		}
+	case "hocr", "alto":
+		return writeTextLayout([]*parser.SceneTree{tree}, out, format)
+	case "tikz":
+		if err := export.ExportToTikZWithOptions(tree, out, &pdfOpts.SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to TikZ: %w", err)
+		}
+	case "excalidraw":
+		if err := export.ExportToExcalidrawWithOptions(tree, out, &pdfOpts.SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to Excalidraw: %w", err)
+		}
 	default:
-		return fmt.Errorf("unknown format: %s (supported: svg, pdf)", format)
+		return fmt.Errorf("unknown format: %s (supported: svg, svgz, pdf, html, eps, png, gif, mp4, hocr, alto, tikz, excalidraw)", format)
 	}
 
 	return nil
 }
 
+// replayOptions returns the options for GIF and MP4 replays, which draw
+// frames like PNG images at one pixel per point
+func replayOptions() *export.ReplayOptions {
+	opts := export.DefaultReplayOptions()
+	opts.PNGOptions = *pngOpts
+	opts.Scale = 1
+	if animate > 0 {
+		opts.Duration = animate
+	}
+	opts.FPS = fps
+	return opts
+}
+
 func handleDirectory(inputDir string, format string) error {
-	// Validate that SVG output is not requested for folders
-	if strings.ToLower(format) == "svg" {
-		return fmt.Errorf("multipage output is only supported for PDF format, not SVG")
+	// Validate that only PDF output is requested for folders
+	if f := strings.ToLower(format); !multipageFormat(f) && !perPage {
+		return fmt.Errorf("multipage output is only supported for PDF format, not %s", strings.ToUpper(f))
 	}
 
-	// Collect all .rm files from the directory
-	files, err := collectRmFiles(inputDir)
+	trees, err := readDirectory(inputDir)
 	if err != nil {
-		return fmt.Errorf("failed to collect .rm files: %w", err)
+		return err
 	}
+	return exportPages(trees, format)
+}
 
-	if len(files) == 0 {
-		return fmt.Errorf("no .rm files found in directory: %s", inputDir)
+// readDirectory parses the .rm files of a folder in page order
+func readDirectory(inputDir string) ([]*parser.SceneTree, error) {
+	// Order the pages by the .content file given with --content, or the one
+	// next to a notebook folder copied from the tablet
+	pages, err := rmc.ListDirectoryPages(inputDir, contentFile, logger)
+	if err != nil {
+		return nil, err
 	}
-
-	// Try to order files using .content file if specified
-	usedContentFile := false
-	if contentFile != "" {
-		var orderedFiles []string
-		orderedFiles, usedContentFile = parser.OrderFilesByContent(files, contentFile)
-		if usedContentFile {
-			files = orderedFiles
-			fmt.Fprintf(os.Stderr, "Using page ordering from content file: %s\n", contentFile)
-		} else {
-			fmt.Fprintf(os.Stderr, "Warning: Could not use content file %s, falling back to modification time ordering\n", contentFile)
-		}
+	if pages.Content != nil {
+		setOrientation(pages.Content)
 	}
-
-	// If no content file was used, sort by modification time (oldest first)
-	if !usedContentFile {
-		sort.Slice(files, func(i, j int) bool {
-			infoI, _ := os.Stat(files[i])
-			infoJ, _ := os.Stat(files[j])
-			return infoI.ModTime().Before(infoJ.ModTime())
-		})
-		if contentFile == "" {
-			fmt.Fprintf(os.Stderr, "Warning: Using modification time for page ordering. For reliable ordering, use --content flag.\n")
+	setDocumentInfo(pages.Metadata, pages.Content)
+	files := pages.Files
+	if len(filterTags) > 0 {
+		if files = pages.Tagged(filterTags...); len(files) == 0 {
+			return nil, fmt.Errorf("no pages of %s are tagged %s", inputDir, strings.Join(filterTags, ", "))
 		}
 	}
+	ids := make([]string, len(files))
+	for i, file := range files {
+		ids[i] = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	setPageTags(pages.Content, ids)
+	pageIDs = ids
 
 	// Parse all .rm files into scene trees
 	var trees []*parser.SceneTree
-	for _, file := range files {
+	for i, file := range files {
+		progress.update(i+1, len(files), export.StageParse)
 		f, err := os.Open(file)
 		if err != nil {
-			return fmt.Errorf("failed to open file %s: %w", file, err)
+			return nil, fmt.Errorf("failed to open file %s: %w", file, err)
 		}
-		tree, err := parser.ReadSceneTree(f)
+		tree, err := parser.ReadSceneTreeWithLogger(f, logger)
 		f.Close()
 		if err != nil {
-			return fmt.Errorf("failed to parse file %s: %w", file, err)
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
 		}
 		trees = append(trees, tree)
 	}
+	return trees, nil
+}
+
+// exportPages writes pages to the output. A single page can be exported in
+// any format; several pages are combined into a multipage PDF.
+func exportPages(trees []*parser.SceneTree, format string) error {
+	if perPage {
+		return writePerPage(trees, format)
+	}
+	trees, err := selectPages(trees)
+	if err != nil {
+		return err
+	}
+	pdfOpts.PageTags = parser.SelectPages(pageTags, pageRanges)
+	pdfOpts.PageIDs = parser.SelectPages(pageIDs, pageRanges)
+	if len(trees) > 1 && !multipageFormat(format) {
+		return fmt.Errorf("multipage output is only supported for PDF format, not %s", strings.ToUpper(format))
+	}
 
 	// Determine output writer
-	var out *os.File
-	if outputFile != "" {
-		out, err = os.Create(outputFile)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
-		}
+	out, err := createOutput()
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
 		defer out.Close()
-	} else {
-		out = os.Stdout
+	}
+
+	defer progress.finish()
+	return writePages(trees, out, format)
+}
+
+// writePages writes one page in any format, or several pages as a multipage PDF
+func writePages(trees []*parser.SceneTree, out io.Writer, format string) error {
+	warnUntimed(trees)
+	if len(trees) == 1 {
+		return exportTree(trees[0], out, format)
+	}
+	if !multipageFormat(format) {
+		return fmt.Errorf("multipage output is only supported for PDF format, not %s", strings.ToUpper(format))
+	}
+	if f := strings.ToLower(format); f != "pdf" {
+		return writeTextLayout(trees, out, f)
 	}
 
 	// Export multipage PDF
-	if err := export.ExportToMultipagePDF(trees, out, useLegacy); err != nil {
+	if err := export.ExportToMultipagePDFWithOptions(trees, out, pdfOpts); err != nil {
 		return fmt.Errorf("failed to export multipage PDF: %w", err)
 	}
 
 	return nil
 }
 
-func collectRmFiles(dir string) ([]string, error) {
-	var files []string
-	entries, err := os.ReadDir(dir)
+// multipageFormat reports whether a format holds several pages in one file:
+// PDF, and the hOCR and ALTO text layouts
+func multipageFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "pdf", "hocr", "alto":
+		return true
+	}
+	return false
+}
+
+// writeTextLayout writes the typed text of pages with its positions as hOCR
+// or ALTO XML
+func writeTextLayout(trees []*parser.SceneTree, out io.Writer, format string) error {
+	if strings.ToLower(format) == "hocr" {
+		if err := export.ExportToHOCR(trees, out, &pdfOpts.SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to hOCR: %w", err)
+		}
+		return nil
+	}
+	if err := export.ExportToALTO(trees, out, &pdfOpts.SVGOptions); err != nil {
+		return fmt.Errorf("failed to export to ALTO: %w", err)
+	}
+	return nil
+}
+
+func handleArchive(archivePath string, format string) error {
+	nb, err := readArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	setOrientation(nb.Content)
+	setDocumentInfo(nb.Metadata, nb.Content)
+
+	pages, err := notebookPages(nb)
+	if err != nil {
+		return err
+	}
+	trees, err := parsePages(pages)
+	if err != nil {
+		return fmt.Errorf("%s: %w", archivePath, err)
+	}
+
+	return exportPages(trees, format)
+}
+
+// readArchive reads a notebook from an .rmdoc export or a zipped notebook folder
+func readArchive(archivePath string) (*parser.Notebook, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	nb, err := parser.ReadNotebookArchive(f, info.Size())
 	if err != nil {
 		return nil, err
 	}
+	if nb.Content == nil {
+		logger.Warn("archive has no .content file, using modification time for page ordering", "path", archivePath)
+	}
+	return nb, nil
+}
+
+// handleTar converts a notebook streamed as a tar archive
+func handleTar(r io.Reader, format string) error {
+	nb, err := parser.ReadNotebookTar(r)
+	if err != nil {
+		return err
+	}
+	if nb.Content == nil {
+		logger.Warn("tar stream has no .content file, using modification time for page ordering")
+	}
+	setOrientation(nb.Content)
+	setDocumentInfo(nb.Metadata, nb.Content)
+
+	pages, err := notebookPages(nb)
+	if err != nil {
+		return err
+	}
+	trees, err := parsePages(pages)
+	if err != nil {
+		return fmt.Errorf("%s: %w", nb.Name(), err)
+	}
+
+	return exportPages(trees, format)
+}
+
+// parsePages parses the .rm data of each page of a notebook
+func parsePages(pages [][]byte) ([]*parser.SceneTree, error) {
+	trees := make([]*parser.SceneTree, 0, len(pages))
+	for i, page := range pages {
+		progress.update(i+1, len(pages), export.StageParse)
+		tree, err := parser.ReadSceneTreeWithLogger(bytes.NewReader(page), logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse page %d: %w", i+1, err)
+		}
+		trees = append(trees, tree)
+	}
+	return trees, nil
+}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
+// writePerPage writes each selected page to its own file in the output
+// directory, named after its page number: page-001.svg, page-002.svg, ...
+func writePerPage(trees []*parser.SceneTree, format string) error {
+	selected, err := selectPages(trees)
+	if err != nil {
+		return err
+	}
+	warnUntimed(selected)
+
+	dir := outputFile
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	defer progress.finish()
+	written := 0
+	for i, tree := range trees {
+		if !pageRanges.Contains(i + 1) {
 			continue
 		}
-		if strings.HasSuffix(strings.ToLower(entry.Name()), ".rm") {
-			files = append(files, filepath.Join(dir, entry.Name()))
+		progress.update(i+1, len(trees), export.StageRender)
+
+		path := filepath.Join(dir, fmt.Sprintf("page-%03d.%s", i+1, strings.ToLower(format)))
+		out, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		err = exportTree(tree, out, format)
+		if closeErr := out.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to write %s: %w", path, closeErr)
 		}
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		written++
 	}
 
-	return files, nil
+	logger.Info("wrote pages", "count", written, "dir", dir)
+	return nil
+}
+
+// selectPages keeps the pages chosen with --pages, in their original order
+func selectPages[T any](all []T) ([]T, error) {
+	selected := parser.SelectPages(all, pageRanges)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("--pages %s selects none of the %d pages", pageSelect, len(all))
+	}
+	return selected, nil
 }
 
 func guessFormat(filename string) string {
@@ -213,10 +880,30 @@ func guessFormat(filename string) string {
 	switch ext {
 	case ".svg":
 		return "svg"
+	case ".svgz":
+		return "svgz"
 	case ".pdf":
 		return "pdf"
+	case ".html", ".htm":
+		return "html"
+	case ".eps":
+		return "eps"
+	case ".png":
+		return "png"
+	case ".gif":
+		return "gif"
+	case ".mp4":
+		return "mp4"
+	case ".hocr":
+		return "hocr"
+	case ".alto":
+		return "alto"
+	case ".tikz", ".tex":
+		return "tikz"
+	case ".excalidraw":
+		return "excalidraw"
 	default:
-		return "pdf"
+		return defaultFormat
 	}
 }
 
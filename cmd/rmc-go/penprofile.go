@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/spf13/cobra"
+)
+
+var penSheet bool
+
+var penProfileCmd = &cobra.Command{
+	Use:   "pen-profile",
+	Short: "Print the pen profile or draw a calibration sheet",
+	Long: `pen-profile prints the pen profile in use as JSON: the built-in profile,
+with the changes of --pen-profile or the pen-profile key of the config file.
+Save it to a file and edit it to tune how each pen is drawn:
+
+  width          multiplies the stroke width
+  opacity        multiplies the stroke opacity
+  pressureCurve  exponent applied to the pressure (0 to 1); above 1 takes a
+                 firmer press for the same effect
+  speed          multiplies the speed, which thins and lightens fast strokes
+  tilt           multiplies the tilt, which thins tilted strokes
+  segmentLength  points drawn with the same width and color
+
+A profile only needs the pens and parameters it changes; the rest keep their
+built-in values.
+
+--sheet draws a calibration sheet instead, with -o and the other conversion
+flags: a row of strokes for every pen, at a quarter, half, three quarters and
+full pressure, then two faster strokes at full pressure. Compare it with a
+screenshot of the same strokes on the device while tuning a profile.`,
+	Args: cobra.NoArgs,
+	RunE: runPenProfile,
+}
+
+func init() {
+	penProfileCmd.Flags().BoolVar(&penSheet, "sheet", false, "Draw a calibration sheet with the profile instead of printing it")
+	rootCmd.AddCommand(penProfileCmd)
+}
+
+func runPenProfile(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+
+	if penSheet {
+		out, err := createOutput()
+		if err != nil {
+			return err
+		}
+		if out != os.Stdout {
+			defer out.Close()
+		}
+		return exportTree(export.CalibrationSheet(), out, outputFormat())
+	}
+
+	profile := pdfOpts.PenProfile
+	if profile == nil {
+		profile = export.DefaultPenProfile()
+	}
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pen profile: %w", err)
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s\n", data)
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// progressWidth is the number of cells in the progress bar
+const progressWidth = 30
+
+// progress is the progress bar of the current conversion, or nil when
+// progress is not shown
+var progress *progressBar
+
+// progressBar draws a single, continuously updated line such as
+// "[##########--------------------] 12/36 render"
+type progressBar struct {
+	w     io.Writer
+	width int // Length of the line currently drawn, 0 when none is
+}
+
+// startProgress shows progress for multipage conversions on stderr, unless
+// --quiet is set or stderr is not a terminal
+func startProgress() {
+	progress = nil
+	if quiet || !isTerminal(os.Stderr) {
+		return
+	}
+	progress = &progressBar{w: os.Stderr}
+	pdfOpts.Progress = progress.update
+}
+
+// update redraws the bar for a page and stage. It does nothing on a nil bar
+// or for single pages, which finish too quickly to need one.
+func (p *progressBar) update(page, total int, stage string) {
+	if p == nil || total < 2 {
+		return
+	}
+
+	// Every stage fills the bar once, from the first page to the last
+	filled := progressWidth * page / total
+	line := fmt.Sprintf("[%s%s] %d/%d %s",
+		strings.Repeat("#", filled), strings.Repeat("-", progressWidth-filled), page, total, stage)
+	fmt.Fprintf(p.w, "\r%-*s", p.width, line)
+	p.width = len(line)
+}
+
+// finish clears the bar so later output starts on an empty line
+func (p *progressBar) finish() {
+	if p == nil || p.width == 0 {
+		return
+	}
+	fmt.Fprintf(p.w, "\r%s\r", strings.Repeat(" ", p.width))
+	p.width = 0
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/joagonca/rmc-go/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen        string
+	serveMaxBytes      int64
+	serveMaxConcurrent int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP server that converts uploaded files",
+	Long: `serve runs an HTTP conversion service.
+
+POST a .rm file (or a zipped notebook folder) to /convert as the raw body or
+as the "file" field of a multipart form, choosing the output with ?format=
+(pdf, svg, png, html or eps). GET /healthz reports that the server is up.
+
+Example:
+  rmc-go serve --listen :8080
+  curl --data-binary @page.rm 'http://localhost:8080/convert?format=svg' > page.svg`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", ":8080", "Address to listen on")
+	serveCmd.Flags().Int64Var(&serveMaxBytes, "max-upload", 32<<20, "Maximum upload size in bytes")
+	serveCmd.Flags().IntVar(&serveMaxConcurrent, "max-concurrent", 0, "Maximum concurrent conversions (default: number of CPUs)")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+
+	handler := server.New(&server.Options{
+		MaxRequestBytes: serveMaxBytes,
+		MaxConcurrent:   serveMaxConcurrent,
+		PDF:             pdfOpts,
+		PNG:             pngOpts,
+		Logger:          logger,
+	})
+
+	srv := &http.Server{
+		Addr:              serveListen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	logger.Info("listening", "address", serveListen)
+	return srv.ListenAndServe()
+}
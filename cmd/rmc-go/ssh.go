@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joagonca/rmc-go/tablet"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sshPort     int
+	sshIdentity string
+)
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh [user@host:]<uuid>",
+	Short: "Fetch a notebook from the tablet over SSH and convert it",
+	Long: `ssh copies a notebook's pages, .content and .metadata files from the
+tablet using the system ssh client and converts them locally in one step.
+The host defaults to ` + tablet.DefaultHost + ` (USB connection).
+
+Example:
+  rmc-go ssh root@10.11.99.1:0a1b2c3d-4e5f-6789-abcd-ef0123456789 -o out.pdf`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSSH,
+}
+
+func init() {
+	sshCmd.Flags().IntVar(&sshPort, "port", 0, "SSH port (default: 22)")
+	sshCmd.Flags().StringVar(&sshIdentity, "identity", "", "Private key file for SSH authentication")
+	rootCmd.AddCommand(sshCmd)
+}
+
+func runSSH(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+	startProgress()
+
+	host, id, err := tablet.ParseTarget(args[0])
+	if err != nil {
+		return err
+	}
+
+	logger.Info("fetching notebook", "host", host, "id", id)
+	nb, err := tablet.Fetch(context.Background(), host, id, &tablet.SSHOptions{
+		Port:         sshPort,
+		IdentityFile: sshIdentity,
+	})
+	if err != nil {
+		return err
+	}
+	if nb.Content == nil {
+		logger.Warn("notebook has no .content file, ordering pages by modification time", "id", id)
+	}
+	setOrientation(nb.Content)
+	setDocumentInfo(nb.Metadata, nb.Content)
+
+	pages, err := notebookPages(nb)
+	if err != nil {
+		return err
+	}
+	trees, err := parsePages(pages)
+	if err != nil {
+		return fmt.Errorf("%s: %w", nb.Name(), err)
+	}
+
+	return exportPages(trees, outputFormat())
+}
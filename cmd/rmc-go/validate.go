@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <file.rm|folder>...",
+	Short: "Check that .rm files are complete and consistent",
+	Long: `validate reads .rm files (folders are searched for .rm files) strictly
+and prints a JSON report of the problems found, for scripts that check the
+integrity of backups. A file is valid when it parses without error and:
+
+  - the length of every block matches its data: what the parser doesn't
+    decode must be tagged values that end exactly at the end of the block
+  - every group referred to has a tree node block
+  - paragraph styles and text anchors refer to characters in the text
+
+Unknown blocks and anything else the parser would warn about are problems
+too. validate exits with an error when a file is not valid. The format has
+no checksums, so data changed without breaking its structure can't be
+detected.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+// validateReport is the JSON output of validate
+type validateReport struct {
+	Valid bool           `json:"valid"`
+	Files []validateFile `json:"files"`
+}
+
+// validateFile is the result of validating one file
+type validateFile struct {
+	File     string            `json:"file"`
+	Valid    bool              `json:"valid"`
+	Version  int               `json:"version,omitempty"`
+	Blocks   int               `json:"blocks,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Problems []validateProblem `json:"problems,omitempty"`
+}
+
+// validateProblem is a problem found in a file. Offset and Block are
+// omitted for problems of the file as a whole.
+type validateProblem struct {
+	Offset  int64  `json:"offset,omitempty"`
+	Block   string `json:"block,omitempty"`
+	Message string `json:"message"`
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	files, err := corpusFiles(args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no .rm files found in %v", args)
+	}
+
+	report := validateReport{Valid: true}
+	invalid := 0
+	for _, f := range files {
+		result := validateOne(f.path)
+		if !result.Valid {
+			report.Valid = false
+			invalid++
+		}
+		report.Files = append(report.Files, result)
+	}
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d files are not valid", invalid, len(files))
+	}
+	return nil
+}
+
+// validateOne reads a file strictly and reports what is wrong with it
+func validateOne(path string) validateFile {
+	report := validateFile{File: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to open input file: %v", err)
+		return report
+	}
+	defer f.Close()
+
+	// Warnings are part of the report, so don't log them as well
+	result, err := parser.ReadSceneStrict(f, slog.New(slog.DiscardHandler))
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	report.Version = result.Version
+	for _, n := range result.BlockCounts {
+		report.Blocks += n
+	}
+	for _, w := range result.Warnings {
+		problem := validateProblem{Message: w.Message}
+		if w.Offset != 0 {
+			problem.Offset = w.Offset
+			problem.Block = parser.BlockTypeName(w.BlockType)
+		}
+		report.Problems = append(report.Problems, problem)
+	}
+	report.Valid = len(report.Problems) == 0
+	return report
+}
+
+// corpusFile is an .rm file of a corpus, named by its path relative to the
+// folder it was found in, without the extension
+type corpusFile struct {
+	path string
+	name string
+}
+
+// corpusFiles returns the .rm files named by args, searching folders
+func corpusFiles(args []string) ([]corpusFile, error) {
+	var files []corpusFile
+	for _, arg := range args {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (path != arg && filepath.Ext(path) != ".rm") {
+				return nil
+			}
+			name := filepath.Base(path)
+			if path != arg {
+				name, _ = filepath.Rel(arg, path)
+			}
+			files = append(files, corpusFile{path: path, name: strings.TrimSuffix(name, ".rm")})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read corpus: %w", err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchOutDir   string
+	watchInterval time.Duration
+	watchDebounce time.Duration
+	watchOnce     bool
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <dir> -o <outdir>",
+	Short: "Watch a synced notebook directory and re-convert changed notebooks",
+	Long: `watch monitors a directory laid out like the tablet's storage
+(<uuid>.content, <uuid>.metadata and <uuid>/ page folders), for example one
+synced to a NAS, and converts every notebook to <outdir>/<folders>/<name>.pdf,
+mirroring the folders it is in on the tablet. Only notebooks whose files
+changed since the last conversion are converted again. -o names the output
+directory, like --outdir.
+
+Changes are picked up from file system events, and converted once no more
+events arrive for the debounce time, so a sync that writes many files
+converts each notebook once. Where events are not available the directory
+is polled every interval instead, and a notebook is converted once its
+files have stopped changing for one interval.
+
+Example:
+  rmc-go watch ~/remarkable-sync -o ~/notes-pdf`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&watchOutDir, "outdir", ".", "Directory to write converted notebooks to (also -o)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 10*time.Second, "How often to check for changes when file system events are not available")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 2*time.Second, "How long to wait after the last file system event before converting")
+	watchCmd.Flags().BoolVar(&watchOnce, "once", false, "Convert changed notebooks once and exit")
+	rootCmd.AddCommand(watchCmd)
+}
+
+// notebookState tracks what has been converted for a notebook
+type notebookState struct {
+	seen      string // change signature from the previous scan
+	converted string // change signature at the last conversion
+}
+
+// watcher converts the notebooks of a directory as they change
+type watcher struct {
+	dir    string
+	format string
+	states map[string]*notebookState
+	paths  map[string]string // Output path of each notebook ID
+	owners map[string]string // Notebook ID writing each output path
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	if err := setupConversion(cmd); err != nil {
+		return err
+	}
+
+	// -o is the global --output flag, which names the output directory here
+	if cmd.Flags().Changed("output") {
+		if cmd.Flags().Changed("outdir") && outputFile != watchOutDir {
+			return fmt.Errorf("-o and --outdir name different directories")
+		}
+		watchOutDir = outputFile
+	}
+	if err := os.MkdirAll(watchOutDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	format := outputType
+	if format == "" {
+		format = "pdf"
+	}
+	w := &watcher{
+		dir:    args[0],
+		format: format,
+		states: make(map[string]*notebookState),
+		paths:  make(map[string]string),
+		owners: make(map[string]string),
+	}
+
+	if watchOnce {
+		if failed := w.scan(false); failed > 0 {
+			return fmt.Errorf("%d notebook(s) failed to convert", failed)
+		}
+		return nil
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+
+	// Start watching before the first scan so no change is missed
+	events, err := w.newEventWatcher()
+	if err != nil {
+		logger.Warn("file system events not available, polling instead", "error", err)
+		logger.Info("watching for changes", "dir", w.dir, "outdir", watchOutDir, "interval", watchInterval)
+		w.scan(false)
+		return w.poll(stop)
+	}
+	defer events.Close()
+
+	logger.Info("watching for changes", "dir", w.dir, "outdir", watchOutDir, "debounce", watchDebounce)
+	w.scan(false)
+	return w.watchEvents(events, stop)
+}
+
+// newEventWatcher watches the directory and the page folders of its
+// notebooks for file system events
+func (w *watcher) newEventWatcher() (*fsnotify.Watcher, error) {
+	events, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := events.Add(w.dir); err != nil {
+		events.Close()
+		return nil, err
+	}
+	ids, err := listNotebooks(w.dir)
+	if err != nil {
+		events.Close()
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := events.Add(filepath.Join(w.dir, id)); err != nil {
+			logger.Warn("failed to watch notebook folder", "id", id, "error", err)
+		}
+	}
+	return events, nil
+}
+
+// watchEvents converts changed notebooks once file system events have
+// stopped arriving for the debounce time, until stop receives a signal
+func (w *watcher) watchEvents(events *fsnotify.Watcher, stop <-chan os.Signal) error {
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	for {
+		select {
+		case event, ok := <-events.Events:
+			if !ok {
+				return nil
+			}
+			// Watch the page folders of new notebooks as well
+			if event.Has(fsnotify.Create) && filepath.Dir(event.Name) == filepath.Clean(w.dir) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := events.Add(event.Name); err != nil {
+						logger.Warn("failed to watch notebook folder", "path", event.Name, "error", err)
+					}
+				}
+			}
+			debounce.Reset(watchDebounce)
+		case err, ok := <-events.Errors:
+			if !ok {
+				return nil
+			}
+			// Events may have been lost, so scan everything
+			logger.Warn("file system watcher error", "error", err)
+			debounce.Reset(watchDebounce)
+		case <-debounce.C:
+			w.scan(false)
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// poll scans the directory every interval until stop receives a signal
+func (w *watcher) poll(stop <-chan os.Signal) error {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.scan(true)
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// scan converts the changed notebooks and returns how many failed. With
+// settle, notebooks that changed since the previous scan are left for the
+// next one, as they may still be syncing.
+func (w *watcher) scan(settle bool) int {
+	ids, err := listNotebooks(w.dir)
+	if err != nil {
+		logger.Error("failed to scan directory", "dir", w.dir, "error", err)
+		return 1
+	}
+	failed := 0
+	for _, id := range ids {
+		state := w.states[id]
+		if state == nil {
+			state = &notebookState{}
+			w.states[id] = state
+		}
+
+		sig := notebookSignature(w.dir, id)
+		stable := sig == state.seen
+		state.seen = sig
+		if sig == state.converted || (settle && !stable) {
+			continue
+		}
+
+		if err := w.convertNotebook(id); err != nil {
+			logger.Error("failed to convert notebook", "id", id, "error", err)
+			failed++
+		}
+		// Record failures too, so a broken notebook is retried only after it changes
+		state.converted = sig
+	}
+	return failed
+}
+
+// listNotebooks returns the IDs of the notebook page folders in dir
+func listNotebooks(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
+// notebookSignature summarizes the size and modification time of a
+// notebook's files, so any change to them changes the signature
+func notebookSignature(dir, id string) string {
+	var sb strings.Builder
+	add := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&sb, "%s:%d:%d;", filepath.Base(path), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	add(filepath.Join(dir, id+".content"))
+	add(filepath.Join(dir, id+".metadata"))
+	pages, _ := filepath.Glob(filepath.Join(dir, id, "*.rm"))
+	for _, page := range pages {
+		add(page)
+	}
+	return sb.String()
+}
+
+// convertNotebook converts one notebook, replacing the output file atomically
+func (w *watcher) convertNotebook(id string) error {
+	nb, err := parser.ReadNotebookDir(w.dir, id)
+	if err != nil {
+		return err
+	}
+	if nb.Metadata != nil && (nb.Metadata.Deleted || nb.Metadata.Parent == "trash") {
+		return nil
+	}
+	// Most notebooks have no pages with the tags asked for
+	if len(filterTags) > 0 && len(nb.TaggedPageIDs(filterTags...)) == 0 {
+		logger.Debug("skipping notebook without tagged pages", "name", nb.Name())
+		return nil
+	}
+	pages, err := notebookPages(nb)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return nil
+	}
+
+	trees, err := parsePages(pages)
+	if err != nil {
+		return err
+	}
+	trees, err = selectPages(trees)
+	if err != nil {
+		return err
+	}
+	pdfOpts.PageIDs = parser.SelectPages(pageIDs, pageRanges)
+	setOrientation(nb.Content)
+	setDocumentInfo(nb.Metadata, nb.Content)
+
+	var buf bytes.Buffer
+	if err := writePages(trees, &buf, w.format); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial output
+	outPath := w.outputPath(id, nb)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	tmpPath := outPath + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	logger.Info("converted notebook", "name", nb.Name(), "pages", len(trees), "output", outPath)
+	return nil
+}
+
+// outputPath returns the file a notebook is written to: its name in the
+// folders it is in on the tablet, below the output directory. A notebook
+// whose path is already written by another one gets its ID added to the
+// name, so neither overwrites the other.
+func (w *watcher) outputPath(id string, nb *parser.Notebook) string {
+	if old, ok := w.paths[id]; ok {
+		delete(w.owners, old)
+	}
+
+	rel := filepath.Join(append(notebookFolders(w.dir, nb.Metadata), fileName(nb.Name()))...)
+	ext := "." + strings.ToLower(w.format)
+	path := filepath.Join(watchOutDir, rel+ext)
+	if owner, ok := w.owners[path]; ok && owner != id {
+		logger.Warn("notebooks have the same name, adding the ID to the output name",
+			"name", rel, "id", id, "other", owner)
+		path = filepath.Join(watchOutDir, rel+" ("+id+")"+ext)
+	}
+
+	w.paths[id] = path
+	w.owners[path] = id
+	return path
+}
+
+// notebookFolders returns the names of the folders containing a notebook,
+// outermost first, from the .metadata files of its parents
+func notebookFolders(dir string, meta *parser.Metadata) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for meta != nil && meta.Parent != "" && meta.Parent != "trash" && !seen[meta.Parent] {
+		seen[meta.Parent] = true
+		data, err := os.ReadFile(filepath.Join(dir, meta.Parent+".metadata"))
+		if err != nil {
+			break
+		}
+		if meta, err = parser.ParseMetadata(data); err != nil {
+			break
+		}
+		names = append([]string{fileName(meta.VisibleName)}, names...)
+	}
+	return names
+}
+
+// fileName makes a notebook or folder name safe to use as a file name
+func fileName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,258 @@
+//go:build js && wasm
+
+// Command rmc-wasm is the WebAssembly build of the parser and the SVG
+// exporter, for rendering .rm files in the browser. It has no Cairo and runs
+// no external programs, so it converts to SVG, SVGZ and HTML only.
+//
+// Build it with make build-wasm, which also copies rmc.js, the JavaScript
+// wrapper that loads it, and wasm_exec.js from the Go distribution.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"syscall/js"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+)
+
+func main() {
+	js.Global().Set("rmcGo", js.ValueOf(map[string]any{
+		"toSVG":  function(toSVG),
+		"toSVGZ": function(toSVGZ),
+		"toHTML": function(toHTML),
+		"info":   function(info),
+	}))
+
+	// Keep the functions available to JavaScript
+	select {}
+}
+
+// function makes a JavaScript function of a conversion. It takes the bytes
+// of an .rm file as a Uint8Array and the options as a JSON string, and
+// returns {value} or {error}, which rmc.js turns into a return value or an
+// exception.
+func function(convert func(data []byte, options string) (any, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 0 || !args[0].InstanceOf(js.Global().Get("Uint8Array")) {
+			return map[string]any{"error": "expected the bytes of an .rm file as a Uint8Array"}
+		}
+		data := make([]byte, args[0].Get("length").Int())
+		js.CopyBytesToGo(data, args[0])
+
+		options := ""
+		if len(args) > 1 && args[1].Type() == js.TypeString {
+			options = args[1].String()
+		}
+
+		value, err := convert(data, options)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return map[string]any{"value": value}
+	})
+}
+
+func toSVG(data []byte, options string) (any, error) {
+	tree, opts, err := load(data, options)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := export.ExportToSVGWithOptions(tree, &buf, opts); err != nil {
+		return nil, fmt.Errorf("failed to export SVG: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func toSVGZ(data []byte, options string) (any, error) {
+	tree, opts, err := load(data, options)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := export.ExportToSVGZWithOptions(tree, &buf, opts); err != nil {
+		return nil, fmt.Errorf("failed to export SVGZ: %w", err)
+	}
+	out := js.Global().Get("Uint8Array").New(buf.Len())
+	js.CopyBytesToJS(out, buf.Bytes())
+	return out, nil
+}
+
+func toHTML(data []byte, options string) (any, error) {
+	tree, opts, err := load(data, options)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := export.ExportToHTMLWithOptions(tree, &buf, opts); err != nil {
+		return nil, fmt.Errorf("failed to export HTML: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// infoReport is the JSON summary of a page returned by info
+type infoReport struct {
+	Version   int         `json:"version"`
+	Layers    []infoLayer `json:"layers"`
+	Strokes   int         `json:"strokes"`
+	Points    int         `json:"points"`
+	InkLength float64     `json:"inkLength"`
+	Bounds    *[4]float64 `json:"bounds,omitempty"` // minX, minY, maxX, maxY
+	Text      string      `json:"text"`
+	Warnings  []string    `json:"warnings,omitempty"`
+}
+
+// infoLayer describes one layer of a page
+type infoLayer struct {
+	Label   string `json:"label"`
+	Visible bool   `json:"visible"`
+	Strokes int    `json:"strokes"`
+}
+
+// info summarizes a page as JSON, which rmc.js parses
+func info(data []byte, options string) (any, error) {
+	// Warnings are part of the report, so don't log them as well
+	result, err := parser.ReadSceneBytes(data, slog.New(slog.DiscardHandler))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .rm file: %w", err)
+	}
+
+	stats := parser.ComputeStats(result.Tree)
+	report := infoReport{
+		Version:   result.Version,
+		Layers:    []infoLayer{},
+		Strokes:   stats.Strokes,
+		Points:    stats.Points,
+		InkLength: stats.InkLength,
+	}
+	for _, layer := range stats.LayerDetails {
+		report.Layers = append(report.Layers, infoLayer(layer))
+	}
+	if stats.Strokes > 0 {
+		report.Bounds = &[4]float64{stats.MinX, stats.MinY, stats.MaxX, stats.MaxY}
+	}
+	if result.Tree.RootText != nil {
+		doc, err := parser.BuildTextDocument(result.Tree.RootText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text: %w", err)
+		}
+		report.Text = doc.String()
+	}
+	for _, w := range result.Warnings {
+		report.Warnings = append(report.Warnings, w.String())
+	}
+
+	out, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return string(out), nil
+}
+
+// load parses an .rm file and the options of a conversion
+func load(data []byte, options string) (*parser.SceneTree, *export.SVGOptions, error) {
+	var o pageOptions
+	if options != "" {
+		dec := json.NewDecoder(bytes.NewReader([]byte(options)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&o); err != nil {
+			return nil, nil, fmt.Errorf("invalid options: %w", err)
+		}
+	}
+	opts, err := o.svgOptions()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	result, err := parser.ReadSceneBytes(data, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse .rm file: %w", err)
+	}
+	return result.Tree, opts, nil
+}
+
+// pageOptions are the conversion options accepted from JavaScript. They are
+// named after the fields of export.SVGOptions, and the values that name a
+// choice take the same names as the rmc-go flags.
+type pageOptions struct {
+	PageSize          string            `json:"pageSize"`
+	CropToContent     bool              `json:"cropToContent"`
+	Margin            float64           `json:"margin"`
+	Background        string            `json:"background"`
+	StrokeScale       float64           `json:"strokeScale"`
+	Smooth            bool              `json:"smooth"`
+	VariableWidth     bool              `json:"variableWidth"`
+	ChiselMarker      bool              `json:"chiselMarker"`
+	PencilGradient    bool              `json:"pencilGradient"`
+	SimplifyTolerance float64           `json:"simplifyTolerance"`
+	Landscape         bool              `json:"landscape"`
+	OutputScale       float64           `json:"outputScale"`
+	OutputWidth       float64           `json:"outputWidth"`
+	OutputHeight      float64           `json:"outputHeight"`
+	KeepErasers       bool              `json:"keepErasers"`
+	SnapHighlights    bool              `json:"snapHighlights"`
+	Deterministic     bool              `json:"deterministic"`
+	Compact           bool              `json:"compact"`
+	Precision         int               `json:"precision"`
+	Glyphs            string            `json:"glyphs"`
+	Palette           map[string]string `json:"palette"`
+	PenProfile        json.RawMessage   `json:"penProfile"`
+}
+
+// svgOptions converts the options to export options
+func (o pageOptions) svgOptions() (*export.SVGOptions, error) {
+	opts := export.DefaultSVGOptions()
+
+	size, err := export.ParsePageSize(o.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	opts.PageWidth, opts.PageHeight = size.Dimensions()
+	if o.Margin < 0 || o.StrokeScale < 0 || o.SimplifyTolerance < 0 ||
+		o.OutputScale < 0 || o.OutputWidth < 0 || o.OutputHeight < 0 || o.Precision < 0 {
+		return nil, errors.New("sizes and scales must not be negative")
+	}
+	opts.CropToContent = o.CropToContent
+	opts.Margin = o.Margin
+	opts.Background = o.Background
+	if o.StrokeScale > 0 {
+		opts.StrokeScale = o.StrokeScale
+	}
+	opts.Smooth = o.Smooth
+	opts.VariableWidth = o.VariableWidth
+	opts.ChiselMarker = o.ChiselMarker
+	opts.PencilGradient = o.PencilGradient
+	opts.SimplifyTolerance = o.SimplifyTolerance
+	opts.Landscape = o.Landscape
+	opts.OutputScale = o.OutputScale
+	opts.OutputWidth, opts.OutputHeight = o.OutputWidth, o.OutputHeight
+	opts.KeepErasers = o.KeepErasers
+	opts.SnapHighlights = o.SnapHighlights
+	opts.Deterministic = o.Deterministic
+	opts.Compact = o.Compact
+	opts.Precision = o.Precision
+
+	if o.Glyphs != "" {
+		glyphs, err := export.GlyphSetByName(o.Glyphs)
+		if err != nil {
+			return nil, err
+		}
+		opts.Glyphs = &glyphs
+	}
+	if o.Palette != nil {
+		if opts.Palette, err = export.PaletteFromMap(o.Palette); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.PenProfile) > 0 {
+		if opts.PenProfile, err = export.ParsePenProfile(o.PenProfile); err != nil {
+			return nil, fmt.Errorf("invalid pen profile: %w", err)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,58 @@
+// rmc.js loads rmc.wasm, the WebAssembly build of rmc-go, and wraps its
+// functions. Load wasm_exec.js from the Go distribution first; it defines
+// the Go class that runs the module.
+//
+//   <script src="wasm_exec.js"></script>
+//   <script type="module">
+//     import { load } from "./rmc.js";
+//
+//     const rmc = await load("rmc.wasm");
+//     const data = new Uint8Array(await file.arrayBuffer());
+//     document.body.innerHTML = rmc.toSVG(data, { cropToContent: true });
+//   </script>
+//
+// Every function takes the bytes of an .rm file as a Uint8Array and throws
+// an Error when the file can't be read or converted. The options are named
+// after the fields of export.SVGOptions: pageSize, cropToContent, margin,
+// background, strokeScale, smooth, variableWidth, chiselMarker,
+// pencilGradient, simplifyTolerance, landscape, outputScale, outputWidth,
+// outputHeight, keepErasers, snapHighlights, deterministic, compact,
+// precision, glyphs ("unicode", "ascii" or "none"), palette (pen color names
+// to CSS colors) and penProfile (an object in the pen profile format).
+
+// load fetches and starts the module at url, which may also be a Response
+// or a promise of one, and returns its functions
+export async function load(url = "rmc.wasm") {
+  const go = new Go();
+  const source = typeof url === "string" || url instanceof URL ? fetch(url) : url;
+  const { instance } = await WebAssembly.instantiateStreaming(source, go.importObject);
+
+  // main registers the functions before it blocks, so they are available
+  // once run returns control; the promise it returns never settles
+  go.run(instance);
+  const api = globalThis.rmcGo;
+
+  const call = (name, data, options) => {
+    const { value, error } = api[name](data, JSON.stringify(options ?? {}));
+    if (error !== undefined) {
+      throw new Error(error);
+    }
+    return value;
+  };
+
+  return {
+    // toSVG returns the page as an SVG document
+    toSVG: (data, options) => call("toSVG", data, options),
+
+    // toSVGZ returns the page as gzip-compressed SVG, as a Uint8Array
+    toSVGZ: (data, options) => call("toSVGZ", data, options),
+
+    // toHTML returns the page as an HTML document
+    toHTML: (data, options) => call("toHTML", data, options),
+
+    // info returns the format version, layers, stroke and point counts,
+    // ink length, stroke bounds ([minX, minY, maxX, maxY] in screen units),
+    // typed text and parse warnings of the page
+    info: (data) => JSON.parse(call("info", data)),
+  };
+}
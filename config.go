@@ -0,0 +1,171 @@
+package rmc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joagonca/rmc-go/export"
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds default conversion settings read from a YAML file, so they
+// don't have to be repeated for every conversion. The keys match the
+// command-line flags:
+//
+//	renderer: legacy        # cairo (default) or legacy
+//	format: svg             # output format when the output file name has none
+//	page-size: a4
+//	inkscape: /opt/inkscape/bin/inkscape
+//	palette:
+//	  blue: "#1a4f9c"
+//	pen-profile: /home/me/pens.json  # see export.ParsePenProfile
+//
+// The rmc-go command reads it from DefaultConfigPath(); flags given on the
+// command line take precedence.
+type Config struct {
+	Renderer       string            `yaml:"renderer"`
+	Format         Format            `yaml:"format"`
+	PageSize       string            `yaml:"page-size"`
+	Palette        map[string]string `yaml:"palette"`
+	PenProfile     string            `yaml:"pen-profile"`
+	InkscapePath   string            `yaml:"inkscape"`
+	SVGConverter   string            `yaml:"svg-converter"`
+	PdfMergeTool   string            `yaml:"pdf-merge-tool"`
+	TempDir        string            `yaml:"temp-dir"`
+	Outline        bool              `yaml:"outline"`
+	Contents       bool              `yaml:"contents"`
+	NUp            string            `yaml:"nup"`
+	Booklet        bool              `yaml:"booklet"`
+	Simplify       float64           `yaml:"simplify"`
+	Smooth         bool              `yaml:"smooth"`
+	VariableWidth  bool              `yaml:"variable-width"`
+	ChiselMarker   bool              `yaml:"chisel-marker"`
+	PencilGradient bool              `yaml:"pencil-gradient"`
+}
+
+// DefaultConfigPath returns where the rmc-go command looks for its config
+// file: rmc-go/config.yaml in the user's config directory, such as
+// ~/.config/rmc-go/config.yaml on Linux
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "rmc-go", "config.yaml"), nil
+}
+
+// LoadConfig reads and validates a YAML config file. Unknown keys are
+// rejected, so typos don't go unnoticed.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// validate checks the values that name a choice
+func (c *Config) validate() error {
+	switch c.Renderer {
+	case "", "cairo", "legacy":
+	default:
+		return fmt.Errorf("unknown renderer: %s (supported: cairo, legacy)", c.Renderer)
+	}
+	switch c.Format {
+	case "", FormatPDF, FormatSVG, FormatSVGZ, FormatHTML, FormatEPS, FormatPNG, FormatGIF, FormatMP4, FormatHOCR, FormatALTO, FormatTikZ, FormatExcalidraw:
+	default:
+		return fmt.Errorf("unknown format: %s (supported: svg, svgz, pdf, html, eps, png, gif, mp4, hocr, alto, tikz, excalidraw)", c.Format)
+	}
+	if c.Simplify < 0 {
+		return fmt.Errorf("simplify must not be negative")
+	}
+	if _, err := export.ParsePageSize(c.PageSize); err != nil {
+		return err
+	}
+	if _, err := export.ParseSVGConverter(c.SVGConverter); err != nil {
+		return err
+	}
+	if _, err := export.ParsePDFMergeTool(c.PdfMergeTool); err != nil {
+		return err
+	}
+	if _, err := export.PaletteFromMap(c.Palette); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Apply sets the conversion options that the config file sets. The output
+// format is not an option; use c.Format when choosing one.
+func (c *Config) Apply(opts *Options) error {
+	if c.Renderer != "" {
+		opts.UseLegacy = c.Renderer == "legacy"
+	}
+	if c.PageSize != "" {
+		size, err := export.ParsePageSize(c.PageSize)
+		if err != nil {
+			return err
+		}
+		opts.PageSize = size
+	}
+	if c.Palette != nil {
+		palette, err := export.PaletteFromMap(c.Palette)
+		if err != nil {
+			return err
+		}
+		opts.Palette = palette
+	}
+	if c.PenProfile != "" {
+		profile, err := export.ReadPenProfileFile(c.PenProfile)
+		if err != nil {
+			return err
+		}
+		opts.PenProfile = profile
+	}
+	if c.InkscapePath != "" {
+		opts.InkscapePath = c.InkscapePath
+	}
+	if c.SVGConverter != "" {
+		conv, err := export.ParseSVGConverter(c.SVGConverter)
+		if err != nil {
+			return err
+		}
+		opts.SVGConverter = conv
+	}
+	if c.PdfMergeTool != "" {
+		tool, err := export.ParsePDFMergeTool(c.PdfMergeTool)
+		if err != nil {
+			return err
+		}
+		opts.PdfMergeTool = tool
+	}
+	if c.TempDir != "" {
+		opts.TempDir = c.TempDir
+	}
+	opts.Outline = opts.Outline || c.Outline
+	opts.Contents = opts.Contents || c.Contents
+	if c.NUp != "" {
+		nup, err := export.ParseNUp(c.NUp)
+		if err != nil {
+			return err
+		}
+		opts.NUp = nup
+	}
+	opts.Booklet = opts.Booklet || c.Booklet
+	if c.Simplify > 0 {
+		opts.SimplifyTolerance = c.Simplify
+	}
+	opts.Smooth = opts.Smooth || c.Smooth
+	opts.VariableWidth = opts.VariableWidth || c.VariableWidth
+	opts.ChiselMarker = opts.ChiselMarker || c.ChiselMarker
+	opts.PencilGradient = opts.PencilGradient || c.PencilGradient
+	return nil
+}
@@ -0,0 +1,215 @@
+package rmc
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// DirectoryPages lists the pages of a notebook folder, such as one copied
+// from the tablet's storage
+type DirectoryPages struct {
+	// Files are the .rm files of the folder in page order
+	Files []string
+
+	// Content is the .content file that ordered the pages, or nil when they
+	// are ordered by modification time
+	Content *parser.ContentFile
+
+	// Metadata is the notebook's .metadata file, or nil if there is none
+	Metadata *parser.Metadata
+}
+
+// ListDirectoryPages lists the .rm files of a notebook folder in page order.
+// The pages are ordered by a .content file: contentPath, or <dir>.content
+// next to the folder as on the tablet when contentPath is empty. Pages it
+// doesn't list come last. Without a usable .content file, pages are ordered
+// by modification time, which may not be the notebook's order. The
+// .metadata file is looked for next to the folder or the .content file.
+// Problems with these files are reported to logger; a nil logger uses
+// slog.Default().
+func ListDirectoryPages(dir, contentPath string, logger *slog.Logger) (*DirectoryPages, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	files, err := collectRmFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect .rm files: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .rm files found in directory: %s", dir)
+	}
+
+	pages := &DirectoryPages{Files: files}
+	usedContent := false
+	if contentPath == "" {
+		contentPath = siblingContentFile(dir)
+	}
+	if contentPath != "" {
+		var ordered []string
+		ordered, usedContent = parser.OrderFilesByContent(files, contentPath)
+		if usedContent {
+			pages.Files = ordered
+			logger.Info("using page ordering from content file", "path", contentPath)
+			if content, err := parser.ReadContentFile(contentPath); err == nil {
+				pages.Content = content
+			}
+		} else {
+			logger.Warn("could not use content file, falling back to modification time ordering", "path", contentPath)
+		}
+	}
+
+	// Take the title and dates from the .metadata file next to the folder or
+	// its .content file
+	if contentPath != "" {
+		pages.Metadata = siblingMetadata(contentPath, logger)
+	} else {
+		pages.Metadata = siblingMetadata(dir, logger)
+	}
+
+	// Without a content file, sort by modification time (oldest first)
+	if !usedContent {
+		sort.Slice(pages.Files, func(i, j int) bool {
+			infoI, _ := os.Stat(pages.Files[i])
+			infoJ, _ := os.Stat(pages.Files[j])
+			return infoI.ModTime().Before(infoJ.ModTime())
+		})
+		if contentPath == "" {
+			logger.Warn("using modification time for page ordering; for reliable ordering, provide the notebook's .content file")
+		}
+	}
+
+	return pages, nil
+}
+
+// ConvertDirectory converts the .rm files of a notebook folder to a
+// multipage PDF, in the page order found by ListDirectoryPages from
+// opts.ContentFile. Like ConvertArchive, landscape notebooks are turned and
+// the PDF is titled after the notebook when its .metadata file is found.
+// Pages can be selected by their tags with opts.Tags.
+//
+// Example:
+//
+//	err := rmc.ConvertDirectory("xochitl/0b2c8a3e-...", "output.pdf", nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+func ConvertDirectory(dir, outputPath string, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	pages, err := ListDirectoryPages(dir, opts.ContentFile, opts.Logger)
+	if err != nil {
+		return err
+	}
+
+	notebookOpts := *opts
+	notebookOpts.Landscape = opts.Landscape || (pages.Content != nil && pages.Content.IsLandscape())
+	notebookOpts.PDFMetadata = opts.PDFMetadata.WithNotebook(pages.Metadata).WithTags(pages.Content)
+
+	files := pages.Files
+	if len(opts.Tags) > 0 {
+		if files = pages.Tagged(opts.Tags...); len(files) == 0 {
+			return fmt.Errorf("no pages tagged %s", strings.Join(opts.Tags, ", "))
+		}
+	}
+	ids := make([]string, len(files))
+	for i, file := range files {
+		ids[i] = pageID(file)
+	}
+	if notebookOpts.PageTags == nil {
+		notebookOpts.PageTags = parser.SelectPages(pageTagNames(pages.Content, ids), opts.Pages)
+	}
+	if notebookOpts.PageIDs == nil {
+		notebookOpts.PageIDs = parser.SelectPages(ids, opts.Pages)
+	}
+	return ConvertFiles(files, outputPath, &notebookOpts)
+}
+
+// Tagged returns the files of the pages carrying any of the given tags, in
+// page order. Tags are compared without regard to case, and a tag of the
+// whole notebook is carried by all of its pages. Without a .content file no
+// page has tags.
+func (p *DirectoryPages) Tagged(tags ...string) []string {
+	if p.Content == nil {
+		return nil
+	}
+	for _, tag := range tags {
+		if p.Content.HasTag(tag) {
+			return p.Files
+		}
+	}
+
+	var files []string
+	for _, file := range p.Files {
+		id := pageID(file)
+		for _, tag := range tags {
+			if p.Content.HasPageTag(id, tag) {
+				files = append(files, file)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// pageID returns the page ID of an .rm file, its name without extension
+func pageID(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+// collectRmFiles returns the .rm files directly in a folder
+func collectRmFiles(dir string) ([]string, error) {
+	var files []string
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(strings.ToLower(entry.Name()), ".rm") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+// siblingContentFile returns the .content file named after a notebook
+// folder, <uuid>.content next to <uuid>/ as on the tablet, or "" if there
+// is none
+func siblingContentFile(dir string) string {
+	path := filepath.Clean(dir) + ".content"
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return ""
+	}
+	return path
+}
+
+// siblingMetadata reads the .metadata file named after a notebook folder or
+// its .content file, <uuid>.metadata as on the tablet, or returns nil if
+// there is none
+func siblingMetadata(path string, logger *slog.Logger) *parser.Metadata {
+	path = strings.TrimSuffix(filepath.Clean(path), ".content") + ".metadata"
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	meta, err := parser.ParseMetadata(data)
+	if err != nil {
+		logger.Warn("could not use metadata file", "path", path, "error", err)
+		return nil
+	}
+	logger.Debug("using metadata file", "path", path)
+	return meta
+}
@@ -0,0 +1,194 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// animationPenLift is the pause between strokes in the replay, as the
+// length of ink that could be drawn in that time, in screen units
+const animationPenLift = 50
+
+// timeSpan is the part of the replay in which a stroke is drawn, in seconds
+type timeSpan struct {
+	begin, duration float64
+}
+
+// strokeTimeline spreads the replay of a page over duration seconds. Strokes
+// are replayed in the order they were created when every stroke records its
+// creation time, and in drawing order otherwise. Each stroke takes time in
+// proportion to its length, followed by a short pause. Strokes that are not
+// drawn get no time.
+func strokeTimeline(tree *parser.SceneTree, w *pageWalker, duration float64) map[*parser.Line]timeSpan {
+	var lines []*parser.Line
+	timed := true
+	var collect func(group *parser.Group)
+	collect = func(group *parser.Group) {
+		if group.Children == nil {
+			return
+		}
+		for _, item := range group.Children.Items {
+			if item.Deleted() {
+				continue
+			}
+			switch v := item.Value.(type) {
+			case *parser.Group:
+				collect(v)
+			case *parser.Line:
+				if !w.opts.drawsLine(v) || w.snapped[v] {
+					continue
+				}
+				if pieces, ok := w.erased[v]; ok && len(pieces) == 0 {
+					continue
+				}
+				_, ok := v.Time()
+				timed = timed && ok
+				lines = append(lines, v)
+			}
+		}
+	}
+	collect(tree.Root)
+
+	if timed {
+		sort.SliceStable(lines, func(i, j int) bool {
+			a, _ := lines[i].Time()
+			b, _ := lines[j].Time()
+			return a.Before(b)
+		})
+	}
+
+	lengths := make([]float64, len(lines))
+	total := 0.0
+	for i, line := range lines {
+		lengths[i] = lineLength(line)
+		total += lengths[i] + animationPenLift
+	}
+
+	timeline := make(map[*parser.Line]timeSpan, len(lines))
+	begin := 0.0
+	for i, line := range lines {
+		timeline[line] = timeSpan{begin: begin, duration: duration * lengths[i] / total}
+		begin += duration * (lengths[i] + animationPenLift) / total
+	}
+	return timeline
+}
+
+// lineLength returns the length of a stroke in screen units
+func lineLength(line *parser.Line) float64 {
+	length := 0.0
+	for i := 1; i < len(line.Points); i++ {
+		a, b := line.Points[i-1], line.Points[i]
+		length += math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+	}
+	return length
+}
+
+// split divides a span between parts in proportion to their lengths, or
+// evenly when they have no length
+func (s timeSpan) split(lengths []float64) []timeSpan {
+	total := 0.0
+	for _, l := range lengths {
+		total += l
+	}
+
+	spans := make([]timeSpan, len(lengths))
+	begin := s.begin
+	for i, l := range lengths {
+		d := s.duration / float64(len(lengths))
+		if total > 0 {
+			d = s.duration * l / total
+		}
+		spans[i] = timeSpan{begin: begin, duration: d}
+		begin += d
+	}
+	return spans
+}
+
+// pathLength returns the length of a path in points. Curves are measured
+// along their control polygon, which is close enough to pace a replay.
+func pathLength(path []PathElement) float64 {
+	length := 0.0
+	var start, current Point
+	for _, e := range path {
+		p := e.Points
+		switch e.Op {
+		case PathMoveTo:
+			start, current = p[0], p[0]
+		case PathLineTo:
+			length += math.Hypot(p[0].X-current.X, p[0].Y-current.Y)
+			current = p[0]
+		case PathCurveTo:
+			length += math.Hypot(p[0].X-current.X, p[0].Y-current.Y) +
+				math.Hypot(p[1].X-p[0].X, p[1].Y-p[0].Y) +
+				math.Hypot(p[2].X-p[1].X, p[2].Y-p[1].Y)
+			current = p[2]
+		case PathClose:
+			length += math.Hypot(start.X-current.X, start.Y-current.Y)
+			current = start
+		case PathCircle:
+			length += 2 * math.Pi * e.Radius
+		}
+	}
+	return length
+}
+
+// revealAttrs returns the attributes that hide an SVG element until its part
+// of the replay. Stroked paths are also drawn along their length, which the
+// animation written by writeReveal does through the dash offset.
+func revealAttrs(stroked bool) string {
+	if stroked {
+		return `visibility="hidden" pathLength="1" stroke-dasharray="1 1" stroke-dashoffset="1" `
+	}
+	return `visibility="hidden" `
+}
+
+// writeReveal writes the SMIL animation that shows an element during span
+func writeReveal(w io.Writer, span timeSpan, stroked bool, indent string) {
+	fmt.Fprintf(w, "%s\t<set attributeName=\"visibility\" to=\"visible\" begin=\"%.3fs\" />\n", indent, span.begin)
+	if stroked {
+		fmt.Fprintf(w, "%s\t<animate attributeName=\"stroke-dashoffset\" from=\"1\" to=\"0\" begin=\"%.3fs\" dur=\"%.3fs\" fill=\"freeze\" />\n",
+			indent, span.begin, math.Max(span.duration, 0.001))
+	}
+}
+
+// drawnPart returns the part of a stroke with this span that has been drawn
+// at seconds into the replay: nothing before the span, the whole stroke
+// after it, and the first part of it, by length, during it. It returns nil
+// when too little has been drawn to show.
+func (s timeSpan) drawnPart(line *parser.Line, at float64) *parser.Line {
+	if at < s.begin {
+		return nil
+	}
+	if at >= s.begin+s.duration || len(line.Points) < 2 {
+		return line
+	}
+
+	remaining := lineLength(line) * (at - s.begin) / s.duration
+	points := []parser.Point{line.Points[0]}
+	for i := 1; i < len(line.Points); i++ {
+		a, b := line.Points[i-1], line.Points[i]
+		d := math.Hypot(float64(b.X-a.X), float64(b.Y-a.Y))
+		if d > remaining {
+			// End part way along this segment
+			p := b
+			t := float32(remaining / d)
+			p.X = a.X + t*(b.X-a.X)
+			p.Y = a.Y + t*(b.Y-a.Y)
+			points = append(points, p)
+			break
+		}
+		remaining -= d
+		points = append(points, b)
+	}
+	if len(points) < 2 {
+		return nil
+	}
+
+	part := *line
+	part.Points = points
+	return &part
+}
@@ -0,0 +1,231 @@
+package export
+
+import (
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// smoothFitError is the largest distance, in reMarkable screen units, that a
+// fitted curve may stray from the stroke points it replaces
+const smoothFitError = 1.0
+
+// vec is a 2D point or direction in reMarkable screen coordinates
+type vec struct{ x, y float64 }
+
+func pointVec(p parser.Point) vec { return vec{float64(p.X), float64(p.Y)} }
+
+func (a vec) add(b vec) vec      { return vec{a.x + b.x, a.y + b.y} }
+func (a vec) sub(b vec) vec      { return vec{a.x - b.x, a.y - b.y} }
+func (a vec) mul(s float64) vec  { return vec{a.x * s, a.y * s} }
+func (a vec) neg() vec           { return vec{-a.x, -a.y} }
+func (a vec) dot(b vec) float64  { return a.x*b.x + a.y*b.y }
+func (a vec) dist(b vec) float64 { return math.Hypot(a.x-b.x, a.y-b.y) }
+func (a vec) isZero() bool       { return a.x == 0 && a.y == 0 }
+
+// normalize returns the unit vector in the direction of a (zero stays zero)
+func (a vec) normalize() vec {
+	l := math.Hypot(a.x, a.y)
+	if l == 0 {
+		return a
+	}
+	return vec{a.x / l, a.y / l}
+}
+
+// cubicBezier is a cubic Bezier curve from p0 to p3 with control points p1 and p2
+type cubicBezier struct {
+	p0, p1, p2, p3 vec
+}
+
+// at evaluates the curve at parameter t in [0, 1]
+func (b cubicBezier) at(t float64) vec {
+	mt := 1 - t
+	return b.p0.mul(mt * mt * mt).
+		add(b.p1.mul(3 * mt * mt * t)).
+		add(b.p2.mul(3 * mt * t * t)).
+		add(b.p3.mul(t * t * t))
+}
+
+// smoothSegment is a run of stroke points drawn with one set of pen
+// settings, fitted with cubic Bezier curves
+type smoothSegment struct {
+	settings parser.Point // point the segment's color, width and opacity come from
+	start    int          // index of settings in the stroke
+	curves   []cubicBezier
+}
+
+// smoothStroke splits a stroke into the same segments as the polyline
+// renderers and fits smooth curves to each. Tangents at segment boundaries
+// are taken from the neighbouring points so adjacent segments join smoothly.
+func smoothStroke(points []parser.Point, segmentLength int) []smoothSegment {
+	var segments []smoothSegment
+	for start := 0; start < len(points); start += segmentLength {
+		// Each segment continues from the last point of the previous one
+		first := max(start-1, 0)
+		last := min(start+segmentLength-1, len(points)-1)
+
+		segment := smoothSegment{settings: points[start], start: start}
+		if last > first {
+			segment.curves = fitCurves(points, first, last)
+		} else {
+			// A lone point still gets drawn, as a dot
+			p := pointVec(points[first])
+			segment.curves = []cubicBezier{{p, p, p, p}}
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+// fitCurves fits cubic Bezier curves to points[first..last] using Schneider's
+// algorithm ("An Algorithm for Automatically Fitting Digitized Curves",
+// Graphics Gems, 1990). Repeated points are dropped before fitting.
+func fitCurves(points []parser.Point, first, last int) []cubicBezier {
+	d := make([]vec, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		p := pointVec(points[i])
+		if len(d) == 0 || p != d[len(d)-1] {
+			d = append(d, p)
+		}
+	}
+	if len(d) == 1 {
+		return []cubicBezier{{d[0], d[0], d[0], d[0]}}
+	}
+
+	// Use the whole stroke for the end tangents so segments join smoothly
+	left := d[1].sub(d[0]).normalize()
+	if first > 0 {
+		if t := pointVec(points[first+1]).sub(pointVec(points[first-1])).normalize(); !t.isZero() {
+			left = t
+		}
+	}
+	right := d[len(d)-2].sub(d[len(d)-1]).normalize()
+	if last < len(points)-1 {
+		if t := pointVec(points[last-1]).sub(pointVec(points[last+1])).normalize(); !t.isZero() {
+			right = t
+		}
+	}
+
+	return fitCubic(d, left, right, nil)
+}
+
+// fitCubic fits one curve to d with the given end tangents, splitting at the
+// point of largest error until every curve is within smoothFitError
+func fitCubic(d []vec, left, right vec, curves []cubicBezier) []cubicBezier {
+	if len(d) == 2 {
+		dist := d[0].dist(d[1]) / 3
+		return append(curves, cubicBezier{d[0], d[0].add(left.mul(dist)), d[1].add(right.mul(dist)), d[1]})
+	}
+
+	u := chordLengthParameterize(d)
+	bez := generateBezier(d, u, left, right)
+	maxError, split := computeMaxError(d, bez, u)
+	if maxError < smoothFitError*smoothFitError {
+		return append(curves, bez)
+	}
+
+	// Close fits are improved by reparameterizing before giving up
+	if maxError < 4*smoothFitError*smoothFitError {
+		for range 4 {
+			u = reparameterize(d, u, bez)
+			bez = generateBezier(d, u, left, right)
+			maxError, split = computeMaxError(d, bez, u)
+			if maxError < smoothFitError*smoothFitError {
+				return append(curves, bez)
+			}
+		}
+	}
+
+	center := d[split-1].sub(d[split+1]).normalize()
+	if center.isZero() {
+		center = d[split-1].sub(d[split]).normalize()
+	}
+	curves = fitCubic(d[:split+1], left, center, curves)
+	return fitCubic(d[split:], center.neg(), right, curves)
+}
+
+// chordLengthParameterize assigns each point a parameter proportional to
+// its distance along the polyline
+func chordLengthParameterize(d []vec) []float64 {
+	u := make([]float64, len(d))
+	for i := 1; i < len(d); i++ {
+		u[i] = u[i-1] + d[i].dist(d[i-1])
+	}
+	for i := range u {
+		u[i] /= u[len(u)-1]
+	}
+	return u
+}
+
+// generateBezier finds the control points along the end tangents that best
+// fit the points in the least-squares sense
+func generateBezier(d []vec, u []float64, left, right vec) cubicBezier {
+	first, last := d[0], d[len(d)-1]
+
+	var c00, c01, c11, x0, x1 float64
+	for i, t := range u {
+		mt := 1 - t
+		a1 := left.mul(3 * mt * mt * t)
+		a2 := right.mul(3 * mt * t * t)
+		c00 += a1.dot(a1)
+		c01 += a1.dot(a2)
+		c11 += a2.dot(a2)
+
+		tmp := d[i].sub(first.mul(mt*mt*mt + 3*mt*mt*t)).sub(last.mul(3*mt*t*t + t*t*t))
+		x0 += a1.dot(tmp)
+		x1 += a2.dot(tmp)
+	}
+
+	det := c00*c11 - c01*c01
+	alphaL, alphaR := 0.0, 0.0
+	if det != 0 {
+		alphaL = (x0*c11 - x1*c01) / det
+		alphaR = (c00*x1 - c01*x0) / det
+	}
+
+	// Fall back to a third of the chord when the solution is degenerate, or
+	// overshoots so far that the curve would loop away from the points
+	segLength := first.dist(last)
+	epsilon := 1e-6 * segLength
+	if alphaL < epsilon || alphaR < epsilon || alphaL > segLength || alphaR > segLength {
+		alphaL, alphaR = segLength/3, segLength/3
+	}
+
+	return cubicBezier{first, first.add(left.mul(alphaL)), last.add(right.mul(alphaR)), last}
+}
+
+// reparameterize improves each point's parameter with a Newton-Raphson step
+// towards the closest point on the curve
+func reparameterize(d []vec, u []float64, bez cubicBezier) []float64 {
+	q1 := []vec{bez.p1.sub(bez.p0).mul(3), bez.p2.sub(bez.p1).mul(3), bez.p3.sub(bez.p2).mul(3)}
+	q2 := []vec{q1[1].sub(q1[0]).mul(2), q1[2].sub(q1[1]).mul(2)}
+
+	next := make([]float64, len(u))
+	for i, t := range u {
+		mt := 1 - t
+		q := bez.at(t)
+		d1 := q1[0].mul(mt * mt).add(q1[1].mul(2 * mt * t)).add(q1[2].mul(t * t))
+		d2 := q2[0].mul(mt).add(q2[1].mul(t))
+
+		diff := q.sub(d[i])
+		denominator := d1.dot(d1) + diff.dot(d2)
+		next[i] = t
+		if denominator != 0 {
+			next[i] = t - diff.dot(d1)/denominator
+		}
+	}
+	return next
+}
+
+// computeMaxError returns the largest squared distance between the points
+// and the curve, and the index of that point
+func computeMaxError(d []vec, bez cubicBezier, u []float64) (float64, int) {
+	maxDist, split := 0.0, len(d)/2
+	for i := 1; i < len(d)-1; i++ {
+		diff := bez.at(u[i]).sub(d[i])
+		if dist := diff.dot(diff); dist >= maxDist {
+			maxDist, split = dist, i
+		}
+	}
+	return maxDist, split
+}
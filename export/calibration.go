@@ -0,0 +1,210 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// PenCalibration tunes the stroke model of a pen type. The width, color and
+// opacity of a stroke follow the pressure, speed and tilt recorded with each
+// point; the calibration reshapes these inputs and scales the result, so
+// strokes can be matched to the device without changing the model.
+type PenCalibration struct {
+	Width         float64 `json:"width"`         // Multiplies the stroke width
+	Opacity       float64 `json:"opacity"`       // Multiplies the stroke opacity
+	PressureCurve float64 `json:"pressureCurve"` // Exponent applied to the pressure (0 to 1); above 1 takes a firmer press for the same effect
+	Speed         float64 `json:"speed"`         // Multiplies the speed, which thins and lightens fast strokes
+	Tilt          float64 `json:"tilt"`          // Multiplies the tilt, which thins tilted strokes
+	SegmentLength int     `json:"segmentLength"` // Points drawn with the same width and color
+}
+
+// PenProfile holds the calibration of each pen type, by the pen names of
+// Pens (such as "ballpoint" or "mechanical-pencil")
+type PenProfile map[string]PenCalibration
+
+//go:embed pen_profile.json
+var defaultPenProfileJSON []byte
+
+// defaultPenProfile is the profile used by every pen that is not given one
+var defaultPenProfile = mustParseDefaultPenProfile()
+
+// neutralCalibration is used for pen types the default profile does not list
+var neutralCalibration = PenCalibration{Width: 1, Opacity: 1, PressureCurve: 1, Speed: 1, Tilt: 1, SegmentLength: 1000}
+
+func mustParseDefaultPenProfile() PenProfile {
+	profile, err := decodePenProfile(defaultPenProfileJSON, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in pen profile: %v", err))
+	}
+	return profile
+}
+
+// DefaultPenProfile returns a copy of the built-in pen profile, which
+// reproduces the renderer's stroke model as it is
+func DefaultPenProfile() PenProfile {
+	profile := make(PenProfile, len(defaultPenProfile))
+	for name, c := range defaultPenProfile {
+		profile[name] = c
+	}
+	return profile
+}
+
+// ParsePenProfile parses a JSON pen profile that maps pen names to the
+// parameters to change:
+//
+//	{"ballpoint": {"width": 1.2, "pressureCurve": 0.8}, "pencil": {"opacity": 0.9}}
+//
+// Parameters and pens that are left out keep the values of the default
+// profile. Unknown pens and parameters are rejected, so typos don't go
+// unnoticed.
+func ParsePenProfile(data []byte) (PenProfile, error) {
+	profile, err := decodePenProfile(data, defaultPenProfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pen profile: %w", err)
+	}
+	return profile, nil
+}
+
+// ReadPenProfileFile reads a JSON pen profile file (see ParsePenProfile)
+func ReadPenProfileFile(path string) (PenProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pen profile file: %w", err)
+	}
+	return ParsePenProfile(data)
+}
+
+// decodePenProfile decodes a profile over the calibrations of base
+func decodePenProfile(data []byte, base PenProfile) (PenProfile, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	profile := make(PenProfile, len(penIDs))
+	for name, c := range base {
+		profile[name] = c
+	}
+	for name, raw := range entries {
+		if !knownPenName(name) {
+			return nil, fmt.Errorf("unknown pen: %s (supported: %s)", name, strings.Join(penNames(), ", "))
+		}
+		c, ok := profile[name]
+		if !ok {
+			c = neutralCalibration
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		profile[name] = c
+	}
+	return profile, nil
+}
+
+// validate checks that the parameters are in range
+func (c PenCalibration) validate() error {
+	switch {
+	case c.Width < 0 || math.IsNaN(c.Width):
+		return fmt.Errorf("width must not be negative")
+	case c.Opacity < 0 || math.IsNaN(c.Opacity):
+		return fmt.Errorf("opacity must not be negative")
+	case !(c.PressureCurve > 0):
+		return fmt.Errorf("pressureCurve must be positive")
+	case c.SegmentLength < 1:
+		return fmt.Errorf("segmentLength must be at least 1")
+	}
+	return nil
+}
+
+// calibration returns the calibration of a pen type, falling back to the
+// default profile
+func (p PenProfile) calibration(penType parser.Pen) PenCalibration {
+	if c, ok := p[penType.String()]; ok {
+		return c
+	}
+	if c, ok := defaultPenProfile[penType.String()]; ok {
+		return c
+	}
+	return neutralCalibration
+}
+
+// knownPenName reports whether name is the name of a pen type in Pens
+func knownPenName(name string) bool {
+	for _, ids := range penIDs {
+		if ids[0].String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// penNames returns the names of the pen types in Pens, sorted
+func penNames() []string {
+	names := make([]string, 0, len(penIDs))
+	for _, ids := range penIDs {
+		names = append(names, ids[0].String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// calibrationPressures and calibrationSpeeds are the columns of the
+// calibration sheet: four pressures drawn slowly, then full pressure drawn
+// at two higher speeds
+var (
+	calibrationPressures = []uint8{64, 128, 192, 255, 255, 255}
+	calibrationSpeeds    = []uint16{20, 20, 20, 20, 100, 200}
+)
+
+// CalibrationSheet returns a page with a row of strokes for every pen that
+// draws ink, in the order of Pens. Each row has strokes at a quarter, half,
+// three quarters and full pressure, followed by two faster strokes at full
+// pressure, so the effect of a pen profile can be compared with the same
+// strokes drawn on the device.
+func CalibrationSheet() *parser.SceneTree {
+	tree := parser.NewSceneTree()
+	layer := parser.NewEmptyGroup(parser.CrdtID{Part1: 0, Part2: 11})
+	tree.Nodes[layer.NodeID] = layer
+	tree.Root.Children.Add(parser.CrdtSequenceItem{ItemID: layer.NodeID, Value: layer})
+
+	const points = 40
+	id := uint64(12)
+	row := 0
+	for _, ids := range penIDs {
+		if ids[0] == parser.PenEraser || ids[0] == parser.PenEraserArea {
+			continue
+		}
+		y := 150 + 180*float64(row)
+		for col := range calibrationPressures {
+			x := -620 + 210*float64(col)
+			line := &parser.Line{Tool: ids[0], Color: parser.ColorBlack, ThicknessScale: 2}
+			for i := 0; i < points; i++ {
+				t := float64(i) / (points - 1)
+				line.Points = append(line.Points, parser.Point{
+					X:        float32(x + 160*t),
+					Y:        float32(y + 30*math.Sin(2*math.Pi*t)),
+					Speed:    calibrationSpeeds[col] * 4,
+					Width:    24,
+					Pressure: calibrationPressures[col],
+				})
+			}
+			layer.Children.Add(parser.CrdtSequenceItem{ItemID: parser.CrdtID{Part1: 1, Part2: id}, Value: line})
+			id++
+		}
+		row++
+	}
+	return tree
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// PenInfo describes how a pen type is rendered
+type PenInfo struct {
+	Name          string       // Pen name, as returned by parser.Pen.String
+	IDs           []parser.Pen // Tool IDs for the pen (several pens have a v1 and a v2 ID)
+	Cap           string       // Line cap: round, square or butt
+	Opacity       float64      // Base opacity; pencils vary it with pressure
+	Multiply      bool         // Blends with what is underneath, like ink
+	VariableWidth bool         // Width follows pressure, speed or tilt
+}
+
+// Width returns the pen's base stroke width in screen units at a thickness
+// scale from the file. Pens with VariableWidth vary it along the stroke.
+func (p PenInfo) Width(thicknessScale float64) float64 {
+	return createPen(p.IDs[0], parser.ColorBlack, nil, thicknessScale, nil).baseWidth
+}
+
+// penIDs lists the tool IDs of every known pen, in the order Pens reports them
+var penIDs = [][]parser.Pen{
+	{parser.PenBallpoint1, parser.PenBallpoint2},
+	{parser.PenFineliner1, parser.PenFineliner2},
+	{parser.PenMarker1, parser.PenMarker2},
+	{parser.PenPencil1, parser.PenPencil2},
+	{parser.PenMechanicalPencil1, parser.PenMechanicalPencil2},
+	{parser.PenPaintbrush1, parser.PenPaintbrush2},
+	{parser.PenCalligraphy},
+	{parser.PenHighlighter1, parser.PenHighlighter2},
+	{parser.PenShader},
+	{parser.PenEraser},
+	{parser.PenEraserArea},
+}
+
+// Pens returns the rendering properties of every pen type the renderer knows
+func Pens() []PenInfo {
+	pens := make([]PenInfo, 0, len(penIDs))
+	for _, ids := range penIDs {
+		p := createPen(ids[0], parser.ColorBlack, nil, 1, nil)
+		pens = append(pens, PenInfo{
+			Name:          ids[0].String(),
+			IDs:           ids,
+			Cap:           p.strokeLinecap,
+			Opacity:       p.baseOpacity,
+			Multiply:      p.multiply,
+			VariableWidth: p.hasVariableWidth(),
+		})
+	}
+	return pens
+}
+
+// ColorInfo describes how a pen color is rendered
+type ColorInfo struct {
+	Color    parser.PenColor
+	RGB      RGB  // Device color; zero when FromFile is set
+	FromFile bool // The color is read from each stroke in the file
+}
+
+// Colors returns every known pen color with the color it is drawn in. A
+// palette (see ParsePalette) can replace any of them.
+func Colors() []ColorInfo {
+	var colors []ColorInfo
+	for _, c := range parser.PenColors() {
+		rgb, ok := rmPalette[c]
+		colors = append(colors, ColorInfo{Color: c, RGB: rgb, FromFile: !ok})
+	}
+	return colors
+}
+
+// StyleInfo describes how a paragraph style of typed text is rendered
+type StyleInfo struct {
+	Style      parser.ParagraphStyle
+	Name       string  // As returned by parser.ParagraphStyle.String
+	Font       string  // Font family
+	FontSize   float64 // In points
+	Bold       bool
+	LineHeight float64 // Space above the paragraph, in screen units
+	Prefix     string  // List marker from the current glyph set, if any; numbered lists show their first item
+}
+
+// paragraphStyles lists the paragraph styles in the order ParagraphStyles
+// reports them
+var paragraphStyles = []parser.ParagraphStyle{
+	parser.StylePlain,
+	parser.StyleHeading,
+	parser.StyleBold,
+	parser.StyleBullet,
+	parser.StyleBullet2,
+	parser.StyleCheckbox,
+	parser.StyleCheckboxChecked,
+	parser.StyleNumbered,
+}
+
+// ParagraphStyles returns the rendering properties of every paragraph style
+func ParagraphStyles() []StyleInfo {
+	styles := make([]StyleInfo, 0, len(paragraphStyles))
+	for _, style := range paragraphStyles {
+		font, size, bold := paragraphFont(style)
+		number := 1
+		styles = append(styles, StyleInfo{
+			Style:      style,
+			Name:       style.String(),
+			Font:       font,
+			FontSize:   size,
+			Bold:       bold,
+			LineHeight: lineHeight(style),
+			Prefix:     Glyphs.Prefix(style, &number),
+		})
+	}
+	return styles
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// chiselDepth is the thickness of a marker's chisel tip, as a fraction of
+// its width
+const chiselDepth = 0.3
+
+// chiselSegments returns the filled shape of a marker stroke drawn with a
+// chisel tip, as a single segment. The tip is a flat rectangle turned by the
+// direction the pen leans at each point; the shape is the tip at every point
+// joined by the quadrilaterals its edge sweeps between points, so the stroke
+// is broad when drawn across the edge and narrow when drawn along it. Color
+// and opacity are averaged over the stroke, like outlineSegments.
+func chiselSegments(line *parser.Line, pen *pen, strokeScale float64) []StrokeSegment {
+	var r, g, b, opacity float64
+	var polygons [][4]vec
+
+	lastWidth := 0.0
+	var prevEdge, prevCenter vec
+	for i, point := range line.Points {
+		color := pen.getSegmentColorRGB(point, lastWidth)
+		r, g, b = r+float64(color.R), g+float64(color.G), b+float64(color.B)
+		opacity += pen.getSegmentOpacity(point, lastWidth)
+
+		width := pen.getSegmentWidth(point, strokeHeading(line.Points, i), lastWidth)
+		lastWidth = width
+
+		// Half the tip's edge, across the direction the pen leans
+		half := math.Max(scale(width)*strokeScale/2, 0)
+		angle := directionToTilt(point.Direction) + math.Pi/2
+		edge := vec{math.Cos(angle) * half, math.Sin(angle) * half}
+		center := vec{scale(float64(point.X)), scale(float64(point.Y))}
+
+		if half > 0 {
+			depth := vec{-edge.y, edge.x}.mul(chiselDepth)
+			polygons = append(polygons, clockwise([4]vec{
+				center.add(edge).add(depth),
+				center.add(edge).sub(depth),
+				center.sub(edge).sub(depth),
+				center.sub(edge).add(depth),
+			}))
+		}
+		if i > 0 && center != prevCenter {
+			// The tip is symmetric, so turn it the short way round to keep
+			// the swept quadrilateral from crossing itself
+			if edge.dot(prevEdge) < 0 {
+				edge = edge.neg()
+			}
+			polygons = append(polygons, clockwise([4]vec{
+				prevCenter.add(prevEdge),
+				center.add(edge),
+				center.sub(edge),
+				prevCenter.sub(prevEdge),
+			}))
+		}
+		prevEdge, prevCenter = edge, center
+	}
+
+	segment := StrokeSegment{Fill: true}
+	if n := float64(len(line.Points)); n > 0 {
+		segment.Color = RGB{R: int(r / n), G: int(g / n), B: int(b / n)}
+		segment.Opacity = opacity / n
+	}
+	for _, q := range polygons {
+		segment.Path = append(segment.Path, PathElement{Op: PathMoveTo, Points: [3]Point{{q[0].x, q[0].y}}})
+		for _, v := range q[1:] {
+			segment.Path = append(segment.Path, PathElement{Op: PathLineTo, Points: [3]Point{{v.x, v.y}}})
+		}
+		segment.Path = append(segment.Path, PathElement{Op: PathClose})
+	}
+	return []StrokeSegment{segment}
+}
+
+// clockwise returns a quadrilateral wound clockwise on screen, so that all
+// parts of a shape fill together under the nonzero rule
+func clockwise(q [4]vec) [4]vec {
+	if signedArea(q[:]) < 0 {
+		q[1], q[3] = q[3], q[1]
+	}
+	return q
+}
@@ -0,0 +1,40 @@
+//go:build !js
+
+package export
+
+import (
+	"context"
+	"io"
+	"os/exec"
+)
+
+// External programs are run through these functions only, so that builds
+// that can't run programs, such as WebAssembly, replace them in one place
+// (see command_js.go).
+
+// lookPath finds an executable in PATH
+func lookPath(executable string) (string, error) {
+	return exec.LookPath(executable)
+}
+
+// runCommand runs a program and waits for it to exit. A nil stdin reads
+// nothing, and nil stdout and stderr discard the output.
+func runCommand(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+	return cmd.Run()
+}
+
+// startCommand starts a program that reads its input from the returned
+// pipe. wait waits for the program to exit once the pipe is closed.
+func startCommand(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (stdin io.WriteCloser, wait func() error, err error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return stdin, cmd.Wait, nil
+}
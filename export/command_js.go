@@ -0,0 +1,26 @@
+//go:build js
+
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// errNoCommands is returned by everything that needs an external program,
+// such as the legacy PDF renderer, PDF/A and MP4 output, in WebAssembly,
+// where programs can't be run
+var errNoCommands = errors.New("external programs can't be run in WebAssembly")
+
+func lookPath(executable string) (string, error) {
+	return "", errNoCommands
+}
+
+func runCommand(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	return errNoCommands
+}
+
+func startCommand(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) (io.WriteCloser, func() error, error) {
+	return nil, nil, errNoCommands
+}
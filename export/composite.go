@@ -0,0 +1,67 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// CompositeInput is a page overlaid by CompositeTree
+type CompositeInput struct {
+	Tree *parser.SceneTree
+
+	// Label names the layer holding the page (default: "Page 1", "Page 2", ...)
+	Label string
+
+	// OffsetX and OffsetY move the page, in reMarkable screen units
+	OffsetX, OffsetY float32
+
+	// Opacity of the page's strokes, from 0 to 1. Zero draws them opaque.
+	Opacity float64
+}
+
+// CompositeTree overlays several pages onto a single page, e.g. a template
+// page and the page written on it, or the layers of two people. The pages
+// are drawn in order, the first at the bottom, each as a layer of its own
+// that holds its layers. Only the typed text of the first page is kept.
+//
+// The opacities of the pages are returned as group opacities to set in
+// SVGOptions.GroupOpacity for the export; nil if every page is opaque.
+func CompositeTree(inputs []CompositeInput) (*parser.SceneTree, map[parser.CrdtID]float64, error) {
+	if len(inputs) == 0 {
+		return nil, nil, fmt.Errorf("no pages to combine")
+	}
+
+	tree := parser.NewSceneTree()
+	var opacity map[parser.CrdtID]float64
+	for i, input := range inputs {
+		if input.Tree == nil || input.Tree.Root == nil {
+			return nil, nil, fmt.Errorf("page %d has no scene tree", i+1)
+		}
+		if input.Opacity < 0 || input.Opacity > 1 {
+			return nil, nil, fmt.Errorf("opacity of page %d must be between 0 and 1", i+1)
+		}
+
+		label := input.Label
+		if label == "" {
+			label = fmt.Sprintf("Page %d", i+1)
+		}
+		layer := tree.AddPage(input.Tree, label)
+		if input.OffsetX != 0 || input.OffsetY != 0 {
+			layer.Translate(input.OffsetX, input.OffsetY)
+		}
+		if input.Opacity > 0 && input.Opacity < 1 {
+			if opacity == nil {
+				opacity = make(map[parser.CrdtID]float64)
+			}
+			opacity[layer.NodeID] = input.Opacity
+		}
+
+		if i == 0 && input.Tree.RootText != nil {
+			tree.RootText = input.Tree.Clone().RootText
+			tree.RootText.PosX += float64(input.OffsetX)
+			tree.RootText.PosY += float64(input.OffsetY)
+		}
+	}
+	return tree, opacity, nil
+}
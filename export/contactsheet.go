@@ -0,0 +1,162 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ContactSheetOptions controls the overview sheets drawn by
+// ExportContactSheetPDF and ExportContactSheetPNG
+type ContactSheetOptions struct {
+	// SVGOptions holds the options of the pages drawn in the cells, such as
+	// CropToContent, the pens and the palette. Each page is fitted into its
+	// cell, so the page and output sizes are ignored. Background fills the
+	// cells.
+	SVGOptions
+
+	// Columns is the number of pages in each row (default: 4)
+	Columns int
+
+	// SheetWidth and SheetHeight are the size of a sheet in points
+	// (default: A4). PNG output is a single image of SheetWidth with as
+	// many rows as the pages need, so SheetHeight only applies to PDF.
+	SheetWidth, SheetHeight float64
+
+	// Gap is the space between the cells and around the grid, in points
+	// (default: 18)
+	Gap float64
+
+	// Labels are printed under the cells, by page index, e.g. the page
+	// numbers. Pages without a label have none.
+	Labels []string
+
+	// Scale is the number of pixels per point of PNG output (default: 2)
+	Scale float64
+}
+
+// DefaultContactSheetOptions returns the options used when nil options are
+// given: A4 sheets of four columns of pages on white
+func DefaultContactSheetOptions() *ContactSheetOptions {
+	opts := &ContactSheetOptions{SVGOptions: *DefaultSVGOptions(), Columns: 4, Gap: 18, Scale: 2}
+	opts.SheetWidth, opts.SheetHeight = PageSizeA4.Dimensions()
+	opts.Background = "white"
+	return opts
+}
+
+// ExportContactSheetPDF draws every page as a cell of a grid, for a
+// printable overview of a notebook (requires a Cairo build). Pages fill the
+// sheets row by row, and as many sheets as needed are written to one PDF. A
+// nil opts uses DefaultContactSheetOptions().
+func ExportContactSheetPDF(trees []*parser.SceneTree, w io.Writer, opts *ContactSheetOptions) error {
+	if opts == nil {
+		opts = DefaultContactSheetOptions()
+	}
+	grid, err := opts.grid(len(trees), true)
+	if err != nil {
+		return err
+	}
+	return exportContactSheetCairo(trees, w, opts, grid, false)
+}
+
+// ExportContactSheetPNG draws every page as a cell of a grid in a single
+// image (requires a Cairo build). A nil opts uses
+// DefaultContactSheetOptions().
+func ExportContactSheetPNG(trees []*parser.SceneTree, w io.Writer, opts *ContactSheetOptions) error {
+	if opts == nil {
+		opts = DefaultContactSheetOptions()
+	}
+	grid, err := opts.grid(len(trees), false)
+	if err != nil {
+		return err
+	}
+	return exportContactSheetCairo(trees, w, opts, grid, true)
+}
+
+// contactSheetLabelSize is the font size of the cell labels in points
+const contactSheetLabelSize = 8
+
+// sheetGrid is the layout of the cells of a contact sheet, in points
+type sheetGrid struct {
+	width, height         float64 // Sheet size
+	columns, rows         int     // Cells per row, and rows per sheet
+	sheets                int
+	cellWidth, cellHeight float64 // Size of a page, without its label
+	rowHeight             float64 // Distance between the tops of rows
+	left, top             float64 // Position of the first cell
+	gap                   float64
+}
+
+// grid lays out the cells for the given number of pages. Cells have the
+// shape of the device's screen. Paged grids fit as many rows on a sheet as
+// SheetHeight allows; otherwise one sheet holds every row.
+func (o *ContactSheetOptions) grid(pages int, paged bool) (sheetGrid, error) {
+	if pages == 0 {
+		return sheetGrid{}, fmt.Errorf("no scene trees provided")
+	}
+	if o.Columns <= 0 {
+		return sheetGrid{}, fmt.Errorf("contact sheet needs at least one column, got %d", o.Columns)
+	}
+	if o.SheetWidth <= 0 || (paged && o.SheetHeight <= 0) || o.Gap < 0 {
+		return sheetGrid{}, fmt.Errorf("contact sheet size must be positive and its gap not negative")
+	}
+
+	aspect := float64(ScreenHeight) / ScreenWidth
+	if o.Landscape {
+		aspect = 1 / aspect
+	}
+	labelHeight := 0.0
+	if len(o.Labels) > 0 {
+		labelHeight = 1.5 * contactSheetLabelSize
+	}
+
+	g := sheetGrid{width: o.SheetWidth, columns: o.Columns, gap: o.Gap}
+	g.cellWidth = (o.SheetWidth - float64(o.Columns+1)*o.Gap) / float64(o.Columns)
+	g.cellHeight = g.cellWidth * aspect
+	if paged {
+		// Shrink the cells when not even one row fits
+		if maxHeight := o.SheetHeight - 2*o.Gap - labelHeight; g.cellHeight > maxHeight {
+			g.cellHeight = maxHeight
+			g.cellWidth = maxHeight / aspect
+		}
+	}
+	if g.cellWidth <= 0 || g.cellHeight <= 0 {
+		return sheetGrid{}, fmt.Errorf("contact sheet is too small for %d columns", o.Columns)
+	}
+	g.rowHeight = g.cellHeight + labelHeight + o.Gap
+
+	totalRows := (pages + o.Columns - 1) / o.Columns
+	if paged {
+		g.height = o.SheetHeight
+		g.rows = max(1, int(math.Floor((o.SheetHeight-o.Gap)/g.rowHeight)))
+	} else {
+		g.height = o.Gap + float64(totalRows)*g.rowHeight
+		g.rows = totalRows
+	}
+	g.sheets = (totalRows + g.rows - 1) / g.rows
+
+	// Center the columns when the cells were shrunk
+	g.left = (o.SheetWidth - float64(o.Columns)*g.cellWidth - float64(o.Columns-1)*o.Gap) / 2
+	g.top = o.Gap
+	return g, nil
+}
+
+// cell returns the sheet of page i and the position of its cell on it
+func (g sheetGrid) cell(i int) (sheet int, x, y float64) {
+	perSheet := g.columns * g.rows
+	sheet = i / perSheet
+	row, column := (i%perSheet)/g.columns, i%g.columns
+	return sheet, g.left + float64(column)*(g.cellWidth+g.gap), g.top + float64(row)*g.rowHeight
+}
+
+// cellOptions returns the options that fit a page into a cell
+func (o *ContactSheetOptions) cellOptions(g sheetGrid) SVGOptions {
+	opts := o.SVGOptions
+	opts.PageWidth, opts.PageHeight = 0, 0
+	opts.OutputScale = 0
+	opts.OutputWidth, opts.OutputHeight = g.cellWidth, g.cellHeight
+	opts.Recognizer = nil
+	return opts
+}
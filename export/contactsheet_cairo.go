@@ -0,0 +1,120 @@
+//go:build cairo
+// +build cairo
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/ungerik/go-cairo"
+)
+
+// exportContactSheetCairo draws the cells of a contact sheet as PDF sheets,
+// or as a PNG image when raster is set
+func exportContactSheetCairo(trees []*parser.SceneTree, w io.Writer, opts *ContactSheetOptions, grid sheetGrid, raster bool) error {
+	stream := newCairoStream(w)
+	defer stream.close()
+
+	var surface *cairo.Surface
+	if raster {
+		pixelScale := opts.Scale
+		if pixelScale <= 0 {
+			pixelScale = 1
+		}
+		width := int(math.Ceil(grid.width*pixelScale - pixelEpsilon))
+		height := int(math.Ceil(grid.height*pixelScale - pixelEpsilon))
+		surface = cairo.NewSurface(cairo.FORMAT_ARGB32, width, height)
+		surface.Scale(pixelScale, pixelScale)
+	} else {
+		surface = newPDFStreamSurface(stream, grid.width, grid.height)
+	}
+	defer surface.Finish()
+
+	fonts := &cairoFonts{}
+	cellOpts := opts.cellOptions(grid)
+	fillSheet(surface, grid)
+	current := 0
+	for i, tree := range trees {
+		sheet, x, y := grid.cell(i)
+		if sheet != current {
+			surface.ShowPage()
+			fillSheet(surface, grid)
+			current = sheet
+		}
+
+		if err := drawContactSheetCell(surface, tree, x, y, grid, cellOpts, fonts); err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		if i < len(opts.Labels) && opts.Labels[i] != "" {
+			drawContactSheetLabel(surface, opts.Labels[i], x+grid.cellWidth/2, y+grid.cellHeight)
+		}
+
+		if status := surface.Status(); status != cairo.STATUS_SUCCESS {
+			return fmt.Errorf("page %d: cairo error: %s", i+1, status)
+		}
+	}
+
+	if raster {
+		surface.Flush()
+		if err := writePNGStream(surface, stream); err != nil {
+			return fmt.Errorf("failed to write PNG output: %w", err)
+		}
+		return nil
+	}
+	surface.Finish()
+	if err := stream.close(); err != nil {
+		return fmt.Errorf("failed to write PDF output: %w", err)
+	}
+	return nil
+}
+
+// fillSheet paints a sheet white
+func fillSheet(surface *cairo.Surface, grid sheetGrid) {
+	surface.SetSourceRGB(1, 1, 1)
+	surface.Rectangle(0, 0, grid.width, grid.height)
+	surface.Fill()
+}
+
+// drawContactSheetCell draws a page fitted into the cell at (x, y), framed
+// by a thin gray line
+func drawContactSheetCell(surface *cairo.Surface, tree *parser.SceneTree, x, y float64, grid sheetGrid, opts SVGOptions, fonts *cairoFonts) error {
+	dims, err := calculatePageDimensions(tree, &opts)
+	if err != nil {
+		return err
+	}
+	if opts.SimplifyTolerance == 0 {
+		factor, _, _ := dims.layout.fit()
+		opts.SimplifyTolerance = previewTolerance(factor)
+	}
+
+	surface.Save()
+	surface.Rectangle(x, y, grid.cellWidth, grid.cellHeight)
+	surface.Clip()
+	surface.Translate(x, y)
+	err = renderPageToCairo(context.Background(), tree, surface, dims, &opts, fonts, 0, 0)
+	surface.Restore()
+	if err != nil {
+		return err
+	}
+
+	surface.SetSourceRGB(0.7, 0.7, 0.7)
+	surface.SetLineWidth(0.5)
+	surface.Rectangle(x, y, grid.cellWidth, grid.cellHeight)
+	surface.Stroke()
+	return nil
+}
+
+// drawContactSheetLabel prints a label centered under a cell whose bottom
+// center is at (x, y)
+func drawContactSheetLabel(surface *cairo.Surface, label string, x, y float64) {
+	surface.SetSourceRGB(0, 0, 0)
+	surface.SelectFontFace("sans-serif", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_NORMAL)
+	surface.SetFontSize(contactSheetLabelSize)
+	extents := surface.TextExtents(label)
+	surface.MoveTo(x-extents.Xadvance/2, y+1.25*contactSheetLabelSize)
+	surface.ShowText(label)
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// Layout of the table of contents pages, in points
+const (
+	contentsMargin    = 56
+	contentsTitleSize = 20
+	contentsFontSize  = 11
+	contentsRowHeight = 20
+)
+
+// contentsTitle heads every page of the table of contents
+const contentsTitle = "Contents"
+
+// contentsEntry is a line of the table of contents
+type contentsEntry struct {
+	page  int    // 1-based page number among the exported pages
+	title string // first heading or line of typed text, or "" if none
+	tags  []string
+}
+
+// contentsLayout is the size of the table of contents pages and the number
+// of entries that fit on each
+type contentsLayout struct {
+	width, height float64
+	rowsPerPage   int
+	pages         int
+}
+
+// PageLabel returns the text of the first heading on a page, or its first
+// line of typed text if it has no heading, or an empty string if the page
+// has no typed text
+func PageLabel(tree *parser.SceneTree) string {
+	if heading := PageHeading(tree); heading != "" {
+		return heading
+	}
+	if tree == nil || tree.RootText == nil {
+		return ""
+	}
+
+	doc, err := parser.BuildTextDocument(tree.RootText)
+	if err != nil {
+		return ""
+	}
+	for _, p := range doc.Paragraphs {
+		if text := strings.TrimSpace(p.Text); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// contentsEntries returns an entry for every page: its title from
+// PageTitles, or its label, and its tags from PageTags
+func contentsEntries(trees []*parser.SceneTree, opts *PDFOptions) []contentsEntry {
+	entries := make([]contentsEntry, len(trees))
+	for i, tree := range trees {
+		entries[i] = contentsEntry{page: i + 1, title: PageLabel(tree)}
+		if i < len(opts.PageTitles) && opts.PageTitles[i] != "" {
+			entries[i].title = opts.PageTitles[i]
+		}
+		if i < len(opts.PageTags) {
+			entries[i].tags = opts.PageTags[i]
+		}
+	}
+	return entries
+}
+
+// contentsLayout lays out the table of contents for the given number of
+// entries, on pages of the fixed page size of the options or A4 if none is
+// set
+func (o *PDFOptions) contentsLayout(entries int) contentsLayout {
+	l := contentsLayout{width: o.PageWidth, height: o.PageHeight}
+	if l.width <= 0 || l.height <= 0 {
+		l.width, l.height = PageSizeA4.Dimensions()
+	}
+	l.rowsPerPage = max(1, int((l.height-2*contentsMargin-2*contentsTitleSize)/contentsRowHeight))
+	l.pages = max(1, (entries+l.rowsPerPage-1)/l.rowsPerPage)
+	return l
+}
+
+// row returns the contents page of entry i, 0-based, and the baseline of
+// its line on that page
+func (l contentsLayout) row(i int) (page int, y float64) {
+	page = i / l.rowsPerPage
+	return page, contentsMargin + 2*contentsTitleSize + float64(i%l.rowsPerPage+1)*contentsRowHeight
+}
@@ -0,0 +1,89 @@
+//go:build cairo
+// +build cairo
+
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ungerik/go-cairo"
+)
+
+// drawContentsCairo draws the pages of the table of contents, each entry
+// linking to its page, which follows the contents pages. Every contents
+// page is finished with ShowPage.
+func drawContentsCairo(surface *cairo.Surface, entries []contentsEntry, layout contentsLayout) {
+	setPDFPageSize(surface, layout.width, layout.height)
+	right := layout.width - contentsMargin
+	current := -1
+	for i, entry := range entries {
+		page, y := layout.row(i)
+		if page != current {
+			if current >= 0 {
+				surface.ShowPage()
+			}
+			current = page
+			drawContentsTitle(surface)
+		}
+
+		surface.SelectFontFace("sans-serif", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_NORMAL)
+		surface.SetFontSize(contentsFontSize)
+
+		// The page number is right-aligned; the title and tags take the
+		// space left of it
+		number := strconv.Itoa(entry.page)
+		numberWidth := surface.TextExtents(number).Xadvance
+		surface.SetSourceRGB(0, 0, 0)
+		surface.MoveTo(right-numberWidth, y)
+		surface.ShowText(number)
+
+		title := entry.title
+		if title == "" {
+			title = fmt.Sprintf("Page %d", entry.page)
+		}
+		available := right - numberWidth - contentsFontSize - contentsMargin
+		title = fitTextCairo(surface, title, available)
+		surface.MoveTo(contentsMargin, y)
+		surface.ShowText(title)
+
+		if len(entry.tags) > 0 {
+			tags := "  " + strings.Join(entry.tags, ", ")
+			available -= surface.TextExtents(title).Xadvance
+			if tags = fitTextCairo(surface, tags, available); strings.TrimSpace(tags) != "" {
+				surface.SetSourceRGB(0.45, 0.45, 0.45)
+				surface.ShowText(tags)
+			}
+		}
+
+		linkPageCairo(surface, layout.pages+entry.page,
+			contentsMargin, y-contentsRowHeight+contentsFontSize/2, right-contentsMargin, contentsRowHeight)
+	}
+	surface.ShowPage()
+}
+
+// drawContentsTitle draws the heading of a contents page
+func drawContentsTitle(surface *cairo.Surface) {
+	surface.SetSourceRGB(0, 0, 0)
+	surface.SelectFontFace("sans-serif", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_BOLD)
+	surface.SetFontSize(contentsTitleSize)
+	surface.MoveTo(contentsMargin, contentsMargin+contentsTitleSize)
+	surface.ShowText(contentsTitle)
+}
+
+// fitTextCairo shortens text with an ellipsis until it fits the given width
+// in the current font, or returns "" if not even the ellipsis fits
+func fitTextCairo(surface *cairo.Surface, text string, width float64) string {
+	if surface.TextExtents(text).Xadvance <= width {
+		return text
+	}
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		short := strings.TrimRight(string(runes[:n]), " ") + "…"
+		if surface.TextExtents(short).Xadvance <= width {
+			return short
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package export
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// deterministicDate is the date written in deterministic mode when the
+// metadata gives none
+var deterministicDate = time.Unix(0, 0).UTC()
+
+var (
+	// pdfDate matches the dates of a PDF's document info dictionary
+	pdfDate = regexp.MustCompile(`/(CreationDate|ModDate)\s*\(D:([^)]*)\)`)
+	// xmpDate matches the dates of XMP metadata, written as elements or attributes
+	xmpDate = regexp.MustCompile(`xmp:(CreateDate|ModifyDate|MetadataDate)(?:>|=["'])([0-9][0-9T:.+\-Z]*)`)
+	// pdfID matches the file identifiers of a trailer or cross-reference stream
+	pdfID = regexp.MustCompile(`/ID\s*\[\s*<([0-9A-Fa-f]*)>\s*<([0-9A-Fa-f]*)>\s*\]`)
+	// xmpUUID matches the document and instance IDs of XMP metadata
+	xmpUUID = regexp.MustCompile(`uuid:([0-9A-Fa-f-]{36})`)
+	// epsDate matches the creation date comment of PostScript output
+	epsDate = regexp.MustCompile(`(?m)^%%CreationDate:.*$`)
+)
+
+// deterministicPDF writes a PDF with the dates and file identifiers that
+// its renderer took from the clock replaced: dates by those of the metadata,
+// or by the Unix epoch, and identifiers by a hash of the rest of the file.
+// Values are overwritten in place with the same length, so the offsets in
+// the file stay valid.
+func deterministicPDF(pdfData []byte, w io.Writer, meta PDFMetadata) error {
+	out := bytes.Clone(pdfData)
+	created, modified := deterministicDate, deterministicDate
+	if !meta.Created.IsZero() {
+		created = meta.Created
+	}
+	if !meta.Modified.IsZero() {
+		modified = meta.Modified
+	}
+	dateDigits := func(key string) string {
+		t := modified
+		if key == "CreationDate" || key == "CreateDate" {
+			t = created
+		}
+		return t.UTC().Format("20060102150405")
+	}
+
+	for _, m := range pdfDate.FindAllSubmatchIndex(out, -1) {
+		overwriteDate(out[m[4]:m[5]], dateDigits(string(out[m[2]:m[3]])))
+	}
+	for _, m := range xmpDate.FindAllSubmatchIndex(out, -1) {
+		overwriteDate(out[m[4]:m[5]], dateDigits(string(out[m[2]:m[3]])))
+	}
+
+	// Clear the identifiers before hashing, so the hash only depends on the
+	// content of the file
+	var ids [][]byte
+	for _, m := range pdfID.FindAllSubmatchIndex(out, -1) {
+		ids = append(ids, out[m[2]:m[3]], out[m[4]:m[5]])
+	}
+	for _, m := range xmpUUID.FindAllSubmatchIndex(out, -1) {
+		ids = append(ids, out[m[2]:m[3]])
+	}
+	for _, id := range ids {
+		overwriteHex(id, "0")
+	}
+	sum := md5.Sum(out)
+	for _, id := range ids {
+		overwriteHex(id, hex.EncodeToString(sum[:]))
+	}
+
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("failed to write PDF output: %w", err)
+	}
+	return nil
+}
+
+// deterministicEPS writes PostScript with its creation date comment set to
+// the modification date of the metadata, or the Unix epoch
+func deterministicEPS(epsData []byte, w io.Writer, meta PDFMetadata) error {
+	date := deterministicDate
+	if !meta.Modified.IsZero() {
+		date = meta.Modified.UTC()
+	}
+	out := epsDate.ReplaceAllLiteral(epsData, []byte("%%CreationDate: "+date.Format(time.ANSIC)))
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("failed to write EPS output: %w", err)
+	}
+	return nil
+}
+
+// overwriteDate replaces the digits of a date, as written in PDF or XMP,
+// with digits (year to second, as "20060102150405"). Digits beyond these,
+// fractions of a second and the time zone offset, become zero, and a
+// negative offset becomes positive.
+func overwriteDate(date []byte, digits string) {
+	i := 0
+	for k, c := range date {
+		switch {
+		case c >= '0' && c <= '9':
+			if i < len(digits) {
+				date[k] = digits[i]
+				i++
+			} else {
+				date[k] = '0'
+			}
+		case c == '-' && i >= len(digits):
+			date[k] = '+'
+		}
+	}
+}
+
+// overwriteHex replaces the hex digits of id with those of digits, repeated
+// as needed, keeping separators such as the dashes of a UUID
+func overwriteHex(id []byte, digits string) {
+	i := 0
+	for k, c := range id {
+		if strings.IndexByte("0123456789abcdefABCDEF", c) < 0 {
+			continue
+		}
+		id[k] = digits[i%len(digits)]
+		i++
+	}
+}
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// DiffColors are the colors DiffTree draws strokes in
+type DiffColors struct {
+	Added   RGB // Strokes only in the new version
+	Removed RGB // Strokes only in the old version
+	Changed RGB // Strokes moved or reshaped since the old version
+
+	// Unchanged is the color of the strokes both versions share. Nil keeps
+	// their own colors.
+	Unchanged *RGB
+}
+
+// DefaultDiffColors returns green for added, red for removed and blue for
+// changed strokes, with the rest in light gray
+func DefaultDiffColors() DiffColors {
+	return DiffColors{
+		Added:     RGB{46, 158, 68},
+		Removed:   RGB{214, 39, 40},
+		Changed:   RGB{31, 119, 180},
+		Unchanged: &RGB{192, 192, 192},
+	}
+}
+
+// DiffTree returns a page that shows how newTree differs from oldTree, to be
+// exported like any other, and the differences found by parser.DiffPages.
+// The page is a copy of newTree with its strokes recolored, and the removed
+// strokes drawn on top in a layer of their own. The colors are stored with
+// the strokes, so a palette given to the export replaces them.
+func DiffTree(oldTree, newTree *parser.SceneTree, colors DiffColors) (*parser.SceneTree, *parser.PageDiff) {
+	tree := newTree.Clone()
+	diff := parser.DiffPages(oldTree, tree)
+
+	if colors.Unchanged != nil {
+		recolorLines(diff.Unchanged, *colors.Unchanged)
+	}
+	recolorLines(diff.Added, colors.Added)
+	recolorLines(diff.Changed, colors.Changed)
+
+	if len(diff.Removed) > 0 {
+		layer := tree.AddLayer("Removed")
+		for _, line := range diff.Removed {
+			removed := *line
+			removed.ColorOverride = diffColor(colors.Removed)
+			tree.AddLine(layer, &removed)
+		}
+	}
+	return tree, diff
+}
+
+// recolorLines draws lines in the given color
+func recolorLines(lines []*parser.Line, color RGB) {
+	for _, line := range lines {
+		line.ColorOverride = diffColor(color)
+	}
+}
+
+// diffColor converts a color to the stored color of a stroke
+func diffColor(c RGB) *parser.RGBA {
+	return &parser.RGBA{R: uint8(c.R), G: uint8(c.G), B: uint8(c.B), A: 255}
+}
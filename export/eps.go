@@ -0,0 +1,52 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ExportToEPS exports a scene tree to Encapsulated PostScript, for inclusion
+// in print and LaTeX workflows
+func ExportToEPS(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToEPSWithOptions(tree, w, nil)
+}
+
+// ExportToEPSWithOptions exports a scene tree to Encapsulated PostScript using
+// the renderer and page layout settings from opts. A nil opts uses
+// DefaultPDFOptions(). The outline and profile settings do not apply to EPS.
+func ExportToEPSWithOptions(tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return ExportToEPSContext(context.Background(), tree, w, opts)
+}
+
+// ExportToEPSContext is like ExportToEPSWithOptions, but returns the
+// context's error once ctx is done, killing the SVG converter if it was started
+func ExportToEPSContext(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	if opts == nil {
+		opts = DefaultPDFOptions()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Render as usual first, then replace the creation date
+	if opts.Deterministic {
+		plain := *opts
+		plain.Deterministic = false
+		epsBuf := &bytes.Buffer{}
+		if err := ExportToEPSContext(ctx, tree, epsBuf, &plain); err != nil {
+			return err
+		}
+		return deterministicEPS(epsBuf.Bytes(), w, opts.Metadata)
+	}
+
+	// Use legacy SVG conversion if requested
+	if opts.UseLegacy {
+		return exportViaSVG(ctx, tree, w, opts, "eps")
+	}
+
+	// Otherwise use native Cairo-based export (default)
+	return exportToEPSCairo(ctx, tree, w, opts)
+}
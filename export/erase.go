@@ -0,0 +1,201 @@
+package export
+
+import (
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// eraseBisections is the number of halvings used to find where a stroke
+// enters or leaves an erased region
+const eraseBisections = 8
+
+// eraser is the region covered by an eraser or erase-area stroke
+type eraser struct {
+	points                 []parser.Point
+	radius                 float64 // Half the eraser width; zero for erase areas
+	area                   bool
+	minX, minY, maxX, maxY float64
+}
+
+// newEraser returns the region covered by an eraser stroke, or nil if the
+// line is not one
+func newEraser(line *parser.Line) *eraser {
+	e := &eraser{points: line.Points}
+	switch line.Tool {
+	case parser.PenEraser:
+		e.radius = createPen(line.Tool, line.Color, nil, line.ThicknessScale, nil).baseWidth / 2
+	case parser.PenEraserArea:
+		e.area = true
+	default:
+		return nil
+	}
+
+	e.minX, e.minY = math.Inf(1), math.Inf(1)
+	e.maxX, e.maxY = math.Inf(-1), math.Inf(-1)
+	for _, p := range line.Points {
+		e.minX, e.maxX = math.Min(e.minX, float64(p.X)), math.Max(e.maxX, float64(p.X))
+		e.minY, e.maxY = math.Min(e.minY, float64(p.Y)), math.Max(e.maxY, float64(p.Y))
+	}
+	e.minX, e.minY = e.minX-e.radius, e.minY-e.radius
+	e.maxX, e.maxY = e.maxX+e.radius, e.maxY+e.radius
+	return e
+}
+
+// covers reports whether the eraser removes the ink of a stroke whose
+// center line passes (x, y), halfWidth being half the stroke's width.
+// Erasers remove strokes whose ink they touch, so they cover everything
+// within their radius plus halfWidth of the eraser stroke; erase areas cover
+// the inside of the polygon through their points.
+func (e *eraser) covers(x, y, halfWidth float64) bool {
+	if e.area {
+		halfWidth = 0
+	}
+	if x < e.minX-halfWidth || x > e.maxX+halfWidth || y < e.minY-halfWidth || y > e.maxY+halfWidth {
+		return false
+	}
+
+	p := parser.Point{X: float32(x), Y: float32(y)}
+	if !e.area {
+		reach := e.radius + halfWidth
+		if len(e.points) == 1 {
+			return segmentDistance(p, e.points[0], e.points[0]) <= reach
+		}
+		for i := 1; i < len(e.points); i++ {
+			if segmentDistance(p, e.points[i-1], e.points[i]) <= reach {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Even-odd rule, with the polygon closed from the last point to the first
+	inside := false
+	for i, j := 0, len(e.points)-1; i < len(e.points); j, i = i, i+1 {
+		a, b := e.points[i], e.points[j]
+		ay, by := float64(a.Y), float64(b.Y)
+		if (ay > y) != (by > y) {
+			ax, bx := float64(a.X), float64(b.X)
+			if x < ax+(y-ay)*(bx-ax)/(by-ay) {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// boundary returns the point between a (kept) and b (erased) where a stroke
+// of the given half width enters the erased region, with the attributes of a
+func (e *eraser) boundary(a, b parser.Point, halfWidth float64) parser.Point {
+	lo, hi := 0.0, 1.0
+	for range eraseBisections {
+		mid := (lo + hi) / 2
+		x := float64(a.X) + mid*float64(b.X-a.X)
+		y := float64(a.Y) + mid*float64(b.Y-a.Y)
+		if e.covers(x, y, halfWidth) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	p := a
+	p.X = a.X + float32(lo)*(b.X-a.X)
+	p.Y = a.Y + float32(lo)*(b.Y-a.Y)
+	return p
+}
+
+// erase returns the parts of a stroke that lie outside the eraser, each as
+// a copy of the line. The line itself is returned when nothing is erased.
+func (e *eraser) erase(line *parser.Line) []*parser.Line {
+	halfWidth := createPen(line.Tool, line.Color, nil, line.ThicknessScale, nil).baseWidth / 2
+	covered := make([]bool, len(line.Points))
+	hit := false
+	for i, p := range line.Points {
+		covered[i] = e.covers(float64(p.X), float64(p.Y), halfWidth)
+		hit = hit || covered[i]
+	}
+	if !hit {
+		return []*parser.Line{line}
+	}
+
+	var pieces []*parser.Line
+	var points []parser.Point
+	for i, p := range line.Points {
+		switch {
+		case !covered[i]:
+			// Start where the stroke leaves the erased region
+			if i > 0 && covered[i-1] {
+				points = append(points, e.boundary(p, line.Points[i-1], halfWidth))
+			}
+			points = append(points, p)
+		case i > 0 && !covered[i-1]:
+			// End where the stroke enters it
+			points = append(points, e.boundary(line.Points[i-1], p, halfWidth))
+			pieces = appendPiece(pieces, line, points)
+			points = nil
+		}
+	}
+	return appendPiece(pieces, line, points)
+}
+
+// appendPiece appends a copy of line with the given points to pieces,
+// unless there are too few points left to draw
+func appendPiece(pieces []*parser.Line, line *parser.Line, points []parser.Point) []*parser.Line {
+	if len(points) < 2 {
+		return pieces
+	}
+	piece := *line
+	piece.Points = points
+	return append(pieces, &piece)
+}
+
+// applyErasers works out what is left of the strokes of a group and its
+// subgroups after erasing. Like on the device, an eraser removes ink from
+// the strokes drawn before it in the same group. The returned map holds the
+// remaining parts of every stroke that was erased, and no parts for the
+// eraser strokes themselves; strokes that are not in the map are untouched.
+func applyErasers(group *parser.Group) map[*parser.Line][]*parser.Line {
+	erased := make(map[*parser.Line][]*parser.Line)
+	eraseGroup(group, erased)
+	return erased
+}
+
+// eraseGroup applies the erasers of a group and its subgroups, recording
+// the results in erased
+func eraseGroup(group *parser.Group, erased map[*parser.Line][]*parser.Line) {
+	if group.Children == nil {
+		return
+	}
+
+	var lines []*parser.Line
+	for _, item := range group.Children.Items {
+		if item.Deleted() {
+			continue
+		}
+		switch v := item.Value.(type) {
+		case *parser.Group:
+			eraseGroup(v, erased)
+		case *parser.Line:
+			e := newEraser(v)
+			if e == nil {
+				lines = append(lines, v)
+				continue
+			}
+			erased[v] = nil
+			for _, line := range lines {
+				pieces, ok := erased[line]
+				if !ok {
+					pieces = []*parser.Line{line}
+				}
+				var remaining []*parser.Line
+				for _, piece := range pieces {
+					remaining = append(remaining, e.erase(piece)...)
+				}
+				if ok || len(remaining) != 1 || remaining[0] != line {
+					erased[line] = remaining
+				}
+			}
+		}
+	}
+}
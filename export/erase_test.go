@@ -0,0 +1,88 @@
+package export
+
+import (
+	"math"
+	"testing"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// testLine returns a stroke of the given pen through the given points
+func testLine(tool parser.Pen, thickness float64, points ...[2]float32) *parser.Line {
+	line := &parser.Line{Tool: tool, ThicknessScale: thickness}
+	for _, p := range points {
+		line.Points = append(line.Points, parser.Point{X: p[0], Y: p[1]})
+	}
+	return line
+}
+
+// horizontalLine returns a ballpoint stroke 2 wide along y = 0 from x = 0 to
+// x = 100, with a point every 10
+func horizontalLine() *parser.Line {
+	var points [][2]float32
+	for x := float32(0); x <= 100; x += 10 {
+		points = append(points, [2]float32{x, 0})
+	}
+	return testLine(parser.PenBallpoint2, 2, points...)
+}
+
+// TestErase checks the parts of a stroke left by an eraser, given as the x
+// of the first and last point of each part. Erasers are 4 wide, so they
+// reach 3 from the center line of the stroke.
+func TestErase(t *testing.T) {
+	tests := []struct {
+		name      string
+		eraser    *parser.Line
+		want      [][2]float64
+		untouched bool // The stroke itself is returned
+	}{
+		{"no hit", testLine(parser.PenEraser, 2, [2]float32{0, 10}, [2]float32{100, 10}), [][2]float64{{0, 100}}, true},
+		{"edge of the ink", testLine(parser.PenEraser, 2, [2]float32{0, 2.5}, [2]float32{100, 2.5}), nil, false},
+		{"middle cut", testLine(parser.PenEraser, 2, [2]float32{50, -50}, [2]float32{50, 50}), [][2]float64{{0, 47}, {53, 100}}, false},
+		{"both ends cut", testLine(parser.PenEraser, 2,
+			[2]float32{0, -50}, [2]float32{0, 50}, [2]float32{100, 50}, [2]float32{100, -50}), [][2]float64{{3, 97}}, false},
+		{"erase area", testLine(parser.PenEraserArea, 2,
+			[2]float32{20, -10}, [2]float32{40, -10}, [2]float32{40, 10}, [2]float32{20, 10}), [][2]float64{{0, 20}, {40, 100}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := horizontalLine()
+			pieces := newEraser(tt.eraser).erase(line)
+			if len(pieces) != len(tt.want) {
+				t.Fatalf("got %d parts, want %d", len(pieces), len(tt.want))
+			}
+			for i, piece := range pieces {
+				first, last := piece.Points[0].X, piece.Points[len(piece.Points)-1].X
+				if math.Abs(float64(first)-tt.want[i][0]) > 0.1 || math.Abs(float64(last)-tt.want[i][1]) > 0.1 {
+					t.Errorf("part %d goes from x %g to %g, want %g to %g", i+1, first, last, tt.want[i][0], tt.want[i][1])
+				}
+			}
+			if untouched := len(pieces) == 1 && pieces[0] == line; untouched != tt.untouched {
+				t.Errorf("got the stroke itself %v, want %v", untouched, tt.untouched)
+			}
+		})
+	}
+}
+
+// TestApplyErasersOrder checks that an eraser only removes ink from the
+// strokes drawn before it
+func TestApplyErasersOrder(t *testing.T) {
+	before, after := horizontalLine(), horizontalLine()
+	eraser := testLine(parser.PenEraser, 2, [2]float32{50, -50}, [2]float32{50, 50})
+	group := parser.NewEmptyGroup(parser.CrdtID{Part1: 0, Part2: 1})
+	for i, line := range []*parser.Line{before, eraser, after} {
+		group.Children.Add(parser.CrdtSequenceItem{ItemID: parser.CrdtID{Part1: 1, Part2: uint64(i + 1)}, Value: line})
+	}
+
+	erased := applyErasers(group)
+	if pieces, ok := erased[before]; !ok || len(pieces) != 2 {
+		t.Errorf("got %d parts of the stroke before the eraser, want 2", len(pieces))
+	}
+	if pieces, ok := erased[eraser]; !ok || pieces != nil {
+		t.Errorf("got parts %v of the eraser, want none", pieces)
+	}
+	if pieces, ok := erased[after]; ok {
+		t.Errorf("got %d parts of the stroke after the eraser, want it untouched", len(pieces))
+	}
+}
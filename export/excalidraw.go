@@ -0,0 +1,365 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// excalidrawBrush is how much wider than its stroke width Excalidraw draws
+// a freedraw line, as its freehand brush is sized from the stroke width
+const excalidrawBrush = 4.25
+
+// excalidrawCurveSteps is the number of straight pieces a Bezier curve is
+// flattened into, as freedraw elements are polylines
+const excalidrawCurveSteps = 8
+
+// ExportToExcalidraw exports a scene tree as an Excalidraw scene, to import
+// sketches into the whiteboard and edit them there
+func ExportToExcalidraw(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToExcalidrawWithOptions(tree, w, nil)
+}
+
+// ExportToExcalidrawWithOptions exports a scene tree as an Excalidraw scene
+// file with the page layout of opts. Every stroke becomes a freedraw element
+// along its path, with its color, average width and opacity; Excalidraw
+// draws it with its own brush, so VariableWidth, ChiselMarker and
+// PencilGradient are ignored. Highlighter strokes are made half transparent,
+// as Excalidraw cannot multiply colors. Typed text becomes a text element
+// per paragraph, in Excalidraw's sans-serif font. Recognized handwriting is
+// left out. A nil opts uses DefaultSVGOptions().
+func ExportToExcalidrawWithOptions(tree *parser.SceneTree, w io.Writer, opts *SVGOptions) error {
+	if opts == nil {
+		opts = DefaultSVGOptions()
+	}
+	o := *opts
+	o.VariableWidth = false
+	o.ChiselMarker = false
+	o.PencilGradient = false
+
+	r := &excalidrawRenderer{}
+	if err := Render(tree, r, &o); err != nil {
+		return err
+	}
+
+	background := "#ffffff"
+	if color, ok := parseCSSColor(r.page.Background); ok {
+		background = excalidrawColor(color)
+	}
+	scene := excalidrawScene{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   pdfCreator,
+		Elements: r.elements,
+		AppState: excalidrawAppState{ViewBackgroundColor: background},
+		Files:    map[string]any{},
+	}
+	if scene.Elements == nil {
+		scene.Elements = []any{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(scene); err != nil {
+		return fmt.Errorf("failed to write Excalidraw output: %w", err)
+	}
+	return nil
+}
+
+// excalidrawScene is the document of an .excalidraw file
+type excalidrawScene struct {
+	Type     string             `json:"type"`
+	Version  int                `json:"version"`
+	Source   string             `json:"source"`
+	Elements []any              `json:"elements"`
+	AppState excalidrawAppState `json:"appState"`
+	Files    map[string]any     `json:"files"`
+}
+
+type excalidrawAppState struct {
+	ViewBackgroundColor string `json:"viewBackgroundColor"`
+	GridSize            *int   `json:"gridSize"`
+}
+
+// excalidrawElement holds the properties every Excalidraw element has
+type excalidrawElement struct {
+	ID              string    `json:"id"`
+	Type            string    `json:"type"`
+	X               float64   `json:"x"`
+	Y               float64   `json:"y"`
+	Width           float64   `json:"width"`
+	Height          float64   `json:"height"`
+	Angle           float64   `json:"angle"`
+	StrokeColor     string    `json:"strokeColor"`
+	BackgroundColor string    `json:"backgroundColor"`
+	FillStyle       string    `json:"fillStyle"`
+	StrokeWidth     float64   `json:"strokeWidth"`
+	StrokeStyle     string    `json:"strokeStyle"`
+	Roughness       int       `json:"roughness"`
+	Opacity         int       `json:"opacity"`
+	GroupIDs        []string  `json:"groupIds"`
+	FrameID         *string   `json:"frameId"`
+	Roundness       *struct{} `json:"roundness"`
+	Seed            int       `json:"seed"`
+	Version         int       `json:"version"`
+	VersionNonce    int       `json:"versionNonce"`
+	IsDeleted       bool      `json:"isDeleted"`
+	BoundElements   []any     `json:"boundElements"`
+	Updated         int64     `json:"updated"`
+	Link            *string   `json:"link"`
+	Locked          bool      `json:"locked"`
+}
+
+type excalidrawFreedraw struct {
+	excalidrawElement
+	Points             [][2]float64 `json:"points"`
+	Pressures          []float64    `json:"pressures"`
+	SimulatePressure   bool         `json:"simulatePressure"`
+	LastCommittedPoint *[2]float64  `json:"lastCommittedPoint"`
+}
+
+type excalidrawText struct {
+	excalidrawElement
+	Text          string  `json:"text"`
+	OriginalText  string  `json:"originalText"`
+	FontSize      float64 `json:"fontSize"`
+	FontFamily    int     `json:"fontFamily"`
+	TextAlign     string  `json:"textAlign"`
+	VerticalAlign string  `json:"verticalAlign"`
+	ContainerID   *string `json:"containerId"`
+	LineHeight    float64 `json:"lineHeight"`
+	AutoResize    bool    `json:"autoResize"`
+}
+
+// excalidrawRenderer collects the elements of a page, in page points
+type excalidrawRenderer struct {
+	page     Page
+	elements []any
+}
+
+func (r *excalidrawRenderer) BeginPage(page Page) error {
+	r.page = page
+	return nil
+}
+
+func (r *excalidrawRenderer) EndPage() error {
+	return nil
+}
+
+// element returns the common properties of the next element, with ids and
+// seeds numbered in drawing order so that output is reproducible
+func (r *excalidrawRenderer) element(kind string, color RGB, opacity float64) excalidrawElement {
+	n := len(r.elements) + 1
+	return excalidrawElement{
+		ID:              fmt.Sprintf("rmc-%d", n),
+		Type:            kind,
+		StrokeColor:     excalidrawColor(color),
+		BackgroundColor: "transparent",
+		FillStyle:       "solid",
+		StrokeStyle:     "solid",
+		Opacity:         int(math.Round(math.Max(0, math.Min(opacity, 1)) * 100)),
+		GroupIDs:        []string{},
+		Seed:            n,
+		Version:         1,
+		VersionNonce:    n,
+		Updated:         1,
+	}
+}
+
+func (r *excalidrawRenderer) DrawStroke(stroke Stroke) error {
+	factor, _, _ := r.page.Fit()
+
+	// Stroked segments continue one another, so they are joined into one
+	// line with their width and opacity averaged by length
+	var points []Point
+	var color RGB
+	var width, opacity, weight float64
+	flush := func() {
+		if len(points) > 0 {
+			r.freedraw(points, color, width/weight*factor, excalidrawOpacity(opacity/weight, stroke.Multiply), "")
+		}
+		points, width, opacity, weight = nil, 0, 0, 0
+	}
+
+	for _, segment := range stroke.Segments {
+		if segment.Fill {
+			r.fill(segment, stroke.Multiply, factor)
+			continue
+		}
+		for _, subpath := range flattenPath(segment.Path) {
+			if len(points) > 0 && subpath[0] != points[len(points)-1] {
+				flush()
+			}
+			if len(points) == 0 {
+				color = segment.Color
+			} else {
+				subpath = subpath[1:]
+			}
+			n := float64(len(subpath))
+			points = append(points, subpath...)
+			width += segment.Width * n
+			opacity += segment.Opacity * n
+			weight += n
+		}
+	}
+	flush()
+	return nil
+}
+
+// fill adds the elements of a filled segment. A stroke outline of circles
+// along the path becomes a line through their centers as wide as they are;
+// other shapes become closed lines filled with their color.
+func (r *excalidrawRenderer) fill(segment StrokeSegment, multiply bool, factor float64) {
+	opacity := excalidrawOpacity(segment.Opacity, multiply)
+
+	var centers []Point
+	var radius float64
+	for _, e := range segment.Path {
+		if e.Op == PathCircle {
+			centers = append(centers, e.Points[0])
+			radius += e.Radius
+		}
+	}
+	if len(centers) > 0 {
+		r.freedraw(centers, segment.Color, 2*radius/float64(len(centers))*factor, opacity, "")
+		return
+	}
+
+	for _, subpath := range flattenPath(segment.Path) {
+		if subpath[0] != subpath[len(subpath)-1] {
+			subpath = append(subpath, subpath[0])
+		}
+		r.freedraw(subpath, segment.Color, 0, opacity, excalidrawColor(segment.Color))
+	}
+}
+
+// freedraw adds a freedraw element through points in content coordinates,
+// drawn width points wide on the page, or filled with background if set
+func (r *excalidrawRenderer) freedraw(points []Point, color RGB, width, opacity float64, background string) {
+	mapped := make([]Point, len(points))
+	x0, y0 := math.Inf(1), math.Inf(1)
+	x1, y1 := math.Inf(-1), math.Inf(-1)
+	for i, p := range points {
+		x, y := r.page.Map(p.X, p.Y)
+		mapped[i] = Point{x, y}
+		x0, y0 = math.Min(x0, x), math.Min(y0, y)
+		x1, y1 = math.Max(x1, x), math.Max(y1, y)
+	}
+
+	e := excalidrawFreedraw{
+		excalidrawElement: r.element("freedraw", color, opacity),
+		Points:            make([][2]float64, len(mapped)),
+		Pressures:         []float64{},
+	}
+	e.X, e.Y = excalidrawNum(mapped[0].X), excalidrawNum(mapped[0].Y)
+	e.Width, e.Height = excalidrawNum(x1-x0), excalidrawNum(y1-y0)
+	e.StrokeWidth = excalidrawNum(width / excalidrawBrush)
+	if background != "" {
+		e.BackgroundColor = background
+	}
+	for i, p := range mapped {
+		e.Points[i] = [2]float64{excalidrawNum(p.X - mapped[0].X), excalidrawNum(p.Y - mapped[0].Y)}
+	}
+	r.elements = append(r.elements, e)
+}
+
+func (r *excalidrawRenderer) DrawText(text Text) error {
+	factor, _, _ := r.page.Fit()
+	angle := 0.0
+	if r.page.Rotated {
+		angle = math.Pi / 2
+	}
+	for _, p := range text.Paragraphs {
+		content := p.Prefix + p.Text
+		if content == "" {
+			continue
+		}
+		_, size, _ := paragraphFont(p.Style)
+
+		// Excalidraw turns elements about their center, so the box is
+		// placed by its center, from the top of the line in content
+		// coordinates
+		width := float64(utf8.RuneCountInString(content)) * size * averageCharWidth
+		height := size * 1.25
+		cx, cy := r.page.Map(p.X+width/2, p.Y-size+height/2)
+
+		e := excalidrawText{
+			excalidrawElement: r.element("text", RGB{}, 1),
+			Text:              content,
+			OriginalText:      content,
+			FontSize:          excalidrawNum(size * factor),
+			FontFamily:        2,
+			TextAlign:         "left",
+			VerticalAlign:     "top",
+			LineHeight:        1.25,
+			AutoResize:        true,
+		}
+		e.Width, e.Height = excalidrawNum(width*factor), excalidrawNum(height*factor)
+		e.X, e.Y = excalidrawNum(cx-width*factor/2), excalidrawNum(cy-height*factor/2)
+		e.Angle = angle
+		r.elements = append(r.elements, e)
+	}
+	return nil
+}
+
+// flattenPath returns the subpaths of a path as polylines, with curves
+// flattened and circles left out
+func flattenPath(path []PathElement) [][]Point {
+	var subpaths [][]Point
+	var current []Point
+	for _, e := range path {
+		switch e.Op {
+		case PathMoveTo:
+			if len(current) > 0 {
+				subpaths = append(subpaths, current)
+			}
+			current = []Point{e.Points[0]}
+		case PathLineTo:
+			current = append(current, e.Points[0])
+		case PathCurveTo:
+			if len(current) == 0 {
+				continue
+			}
+			p0 := current[len(current)-1]
+			for i := 1; i <= excalidrawCurveSteps; i++ {
+				t := float64(i) / excalidrawCurveSteps
+				u := 1 - t
+				current = append(current, Point{
+					X: u*u*u*p0.X + 3*u*u*t*e.Points[0].X + 3*u*t*t*e.Points[1].X + t*t*t*e.Points[2].X,
+					Y: u*u*u*p0.Y + 3*u*u*t*e.Points[0].Y + 3*u*t*t*e.Points[1].Y + t*t*t*e.Points[2].Y,
+				})
+			}
+		case PathClose:
+			if len(current) > 0 {
+				current = append(current, current[0])
+			}
+		}
+	}
+	if len(current) > 0 {
+		subpaths = append(subpaths, current)
+	}
+	return subpaths
+}
+
+// excalidrawOpacity returns the opacity of an element, at most half for
+// strokes that multiply with what is underneath
+func excalidrawOpacity(opacity float64, multiply bool) float64 {
+	if multiply {
+		return math.Min(opacity, 0.5)
+	}
+	return opacity
+}
+
+// excalidrawColor returns the hex notation of a color
+func excalidrawColor(c RGB) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// excalidrawNum rounds a coordinate to hundredths
+func excalidrawNum(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,35 @@
+package export
+
+import (
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// drawsLine reports whether a stroke is selected by the stroke filters of
+// the options: StrokeTimes, OnlyTools and ExcludeColors
+func (o *SVGOptions) drawsLine(line *parser.Line) bool {
+	return o.StrokeTimes.Contains(line) && o.drawsInk(line.Tool, line.Color)
+}
+
+// drawsInk reports whether ink of a pen type and color is selected by
+// OnlyTools and ExcludeColors. Pen types are compared by name, so a pen
+// matches both of its tool IDs.
+func (o *SVGOptions) drawsInk(tool parser.Pen, color parser.PenColor) bool {
+	if len(o.OnlyTools) > 0 {
+		found := false
+		for _, t := range o.OnlyTools {
+			if t.String() == tool.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, c := range o.ExcludeColors {
+		if c == color {
+			return false
+		}
+	}
+	return true
+}
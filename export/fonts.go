@@ -0,0 +1,26 @@
+package export
+
+import "os"
+
+// systemFontPaths lists common locations of a Unicode sans-serif TrueType font
+var systemFontPaths = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu-sans-fonts/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf",
+	"/Library/Fonts/Arial Unicode.ttf",
+	"/System/Library/Fonts/Supplemental/Arial.ttf",
+	"C:\\Windows\\Fonts\\arial.ttf",
+}
+
+// findSystemFont returns the path of the first available system font, or an
+// empty string if none of the known fonts are installed
+func findSystemFont() string {
+	for _, path := range systemFontPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
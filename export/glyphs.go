@@ -0,0 +1,94 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// GlyphSet defines the prefix glyphs drawn before list and checkbox paragraphs
+type GlyphSet struct {
+	Name            string
+	Bullet          string
+	Bullet2         string
+	Checkbox        string
+	CheckboxChecked string
+	NumberFormat    string // fmt format for numbered items, e.g. "%d. "
+}
+
+var (
+	// UnicodeGlyphs uses ballot boxes and bullet characters (default)
+	UnicodeGlyphs = GlyphSet{
+		Name:            "unicode",
+		Bullet:          "• ",
+		Bullet2:         "◦ ",
+		Checkbox:        "☐ ",
+		CheckboxChecked: "☑ ",
+		NumberFormat:    "%d. ",
+	}
+
+	// ASCIIGlyphs uses plain ASCII markers for fonts without the Unicode glyphs
+	ASCIIGlyphs = GlyphSet{
+		Name:            "ascii",
+		Bullet:          "* ",
+		Bullet2:         "- ",
+		Checkbox:        "[ ] ",
+		CheckboxChecked: "[x] ",
+		NumberFormat:    "%d. ",
+	}
+
+	// NoGlyphs renders list paragraphs without any prefix
+	NoGlyphs = GlyphSet{Name: "none"}
+
+	// Glyphs is the glyph set used when SVGOptions.Glyphs is nil.
+	//
+	// Deprecated: changing it affects every conversion in the process,
+	// including those running in other goroutines. Set SVGOptions.Glyphs
+	// instead.
+	Glyphs = UnicodeGlyphs
+)
+
+// glyphs returns the glyph set drawn before list paragraphs
+func (o *SVGOptions) glyphs() GlyphSet {
+	if o.Glyphs != nil {
+		return *o.Glyphs
+	}
+	return Glyphs
+}
+
+// GlyphSetByName returns a predefined glyph set by name (unicode, ascii or none)
+func GlyphSetByName(name string) (GlyphSet, error) {
+	for _, gs := range []GlyphSet{UnicodeGlyphs, ASCIIGlyphs, NoGlyphs} {
+		if strings.EqualFold(gs.Name, name) {
+			return gs, nil
+		}
+	}
+	return GlyphSet{}, fmt.Errorf("unknown glyph style: %s (supported: unicode, ascii, none)", name)
+}
+
+// Prefix returns the prefix for a paragraph based on its style.
+// number tracks the current position in a numbered list and is reset
+// whenever a paragraph that isn't part of a numbered list is seen.
+func (gs GlyphSet) Prefix(style parser.ParagraphStyle, number *int) string {
+	switch style {
+	case parser.StyleBullet:
+		return gs.Bullet
+	case parser.StyleBullet2:
+		return gs.Bullet2
+	case parser.StyleNumbered:
+		prefix := ""
+		if gs.NumberFormat != "" {
+			prefix = fmt.Sprintf(gs.NumberFormat, *number)
+		}
+		*number++
+		return prefix
+	case parser.StyleCheckbox:
+		return gs.Checkbox
+	case parser.StyleCheckboxChecked:
+		return gs.CheckboxChecked
+	default:
+		*number = 1
+		return ""
+	}
+}
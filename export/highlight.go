@@ -0,0 +1,93 @@
+package export
+
+import (
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// snapCoverage is the share of a highlighter stroke's points that must lie
+// on highlighted text for the stroke to be replaced by the text's rectangles
+const snapCoverage = 0.5
+
+// snappedStrokes returns the highlighter strokes of a page that mark text
+// with a glyph range, which SVGOptions.SnapHighlights draws as the glyph
+// range's rectangles instead. A stroke marks text when most of its points
+// lie within half a highlighter width of the text's rectangles in the same
+// group.
+func snappedStrokes(root *parser.Group) map[*parser.Line]bool {
+	snapped := make(map[*parser.Line]bool)
+	margin := createPen(parser.PenHighlighter2, parser.ColorHighlight, nil, 1, nil).baseWidth / 2
+
+	var walk func(group *parser.Group)
+	walk = func(group *parser.Group) {
+		if group.Children == nil {
+			return
+		}
+		var rects []parser.Rectangle
+		var strokes []*parser.Line
+		for _, item := range group.Children.Items {
+			if item.Deleted() {
+				continue
+			}
+			switch v := item.Value.(type) {
+			case *parser.Group:
+				walk(v)
+			case *parser.GlyphRange:
+				rects = append(rects, v.Rectangles...)
+			case *parser.Line:
+				if v.Tool == parser.PenHighlighter1 || v.Tool == parser.PenHighlighter2 {
+					strokes = append(strokes, v)
+				}
+			}
+		}
+		if len(rects) == 0 {
+			return
+		}
+		for _, line := range strokes {
+			if len(line.Points) > 0 && coverage(line, rects, margin) >= snapCoverage {
+				snapped[line] = true
+			}
+		}
+	}
+	walk(root)
+	return snapped
+}
+
+// coverage returns the share of a stroke's points that lie within margin of
+// any of rects
+func coverage(line *parser.Line, rects []parser.Rectangle, margin float64) float64 {
+	inside := 0
+	for _, p := range line.Points {
+		x, y := float64(p.X), float64(p.Y)
+		for _, r := range rects {
+			if x >= r.X-margin && x <= r.X+r.W+margin && y >= r.Y-margin && y <= r.Y+r.H+margin {
+				inside++
+				break
+			}
+		}
+	}
+	return float64(inside) / float64(len(line.Points))
+}
+
+// buildGlyphStroke returns the rectangles of a glyph range as a filled
+// stroke in the highlighter's color and opacity. The stroke has no Line.
+func buildGlyphStroke(glyph *parser.GlyphRange, opts *SVGOptions) Stroke {
+	pen := createPen(parser.PenHighlighter2, glyph.Color, glyph.ColorOverride, 1, opts.Palette)
+	pen.calibrate(opts.PenProfile.calibration(parser.PenHighlighter2))
+	segment := StrokeSegment{
+		Color:   pen.baseColor,
+		Opacity: pen.getSegmentOpacity(parser.Point{}, 0),
+		Fill:    true,
+	}
+	for _, r := range glyph.Rectangles {
+		x0, y0 := scale(r.X), scale(r.Y)
+		x1, y1 := scale(r.X+r.W), scale(r.Y+r.H)
+		segment.Path = append(segment.Path,
+			PathElement{Op: PathMoveTo, Points: [3]Point{{x0, y0}}},
+			PathElement{Op: PathLineTo, Points: [3]Point{{x1, y0}}},
+			PathElement{Op: PathLineTo, Points: [3]Point{{x1, y1}}},
+			PathElement{Op: PathLineTo, Points: [3]Point{{x0, y1}}},
+			PathElement{Op: PathClose},
+		)
+	}
+	return Stroke{Cap: pen.strokeLinecap, Multiply: pen.multiply, Segments: []StrokeSegment{segment}}
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ExportToHOCR writes the typed text of pages as an hOCR document, for
+// document-management systems that index text by its position. Each
+// paragraph is an ocr_par of one ocr_line with its words; the recognized
+// handwriting of pages with opts.Recognizer set is added as a paragraph of
+// its own. Bounding boxes are in points of the pages as PDF export lays them
+// out with opts. Word widths are estimated from their number of characters,
+// as text is not measured with a font. A nil opts uses DefaultSVGOptions().
+func ExportToHOCR(trees []*parser.SceneTree, w io.Writer, opts *SVGOptions) error {
+	pages, err := layoutPages(trees, opts)
+	if err != nil {
+		return err
+	}
+
+	title := "reMarkable pages"
+	if heading := PageHeading(trees[0]); heading != "" {
+		title = heading
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="en" lang="en">
+<head>
+<title>%s</title>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+<meta name="ocr-system" content="%s"/>
+<meta name="ocr-capabilities" content="ocr_page ocr_par ocr_line ocrx_word"/>
+</head>
+<body>
+`, xmlEscape(title), pdfCreator)
+
+	for i, page := range pages {
+		n := i + 1
+		fmt.Fprintf(bw, "<div class=\"ocr_page\" id=\"page_%d\" title=\"bbox 0 0 %d %d; ppageno %d\">\n",
+			n, int(math.Round(page.width)), int(math.Round(page.height)), i)
+		word := 0
+		for j, block := range page.blocks {
+			fmt.Fprintf(bw, "<p class=\"ocr_par\" id=\"par_%d_%d\" title=\"%s\">\n", n, j+1, hocrBBox(block.box))
+			for k, line := range block.lines {
+				fmt.Fprintf(bw, "<span class=\"ocr_line\" id=\"line_%d_%d_%d\" title=\"%s; x_size %g\">",
+					n, j+1, k+1, hocrBBox(line.box), math.Round(line.size*10)/10)
+				for l, wd := range line.words {
+					word++
+					if l > 0 {
+						bw.WriteString(" ")
+					}
+					fmt.Fprintf(bw, "<span class=\"ocrx_word\" id=\"word_%d_%d\" title=\"%s\">%s</span>",
+						n, word, hocrBBox(wd.box), xmlEscape(wd.text))
+				}
+				bw.WriteString("</span>\n")
+			}
+			bw.WriteString("</p>\n")
+		}
+		bw.WriteString("</div>\n")
+	}
+	bw.WriteString("</body>\n</html>\n")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write hOCR output: %w", err)
+	}
+	return nil
+}
+
+// hocrBBox returns the bbox property of a rectangle, in whole points
+func hocrBBox(r Rect) string {
+	return fmt.Sprintf("bbox %d %d %d %d",
+		int(math.Floor(r.X)), int(math.Floor(r.Y)), int(math.Ceil(r.X+r.Width)), int(math.Ceil(r.Y+r.Height)))
+}
+
+// layoutPages lays out the text of every page, as drawn with opts
+func layoutPages(trees []*parser.SceneTree, opts *SVGOptions) ([]textPage, error) {
+	if len(trees) == 0 {
+		return nil, fmt.Errorf("no scene trees provided")
+	}
+	if opts == nil {
+		opts = DefaultSVGOptions()
+	}
+
+	pages := make([]textPage, len(trees))
+	for i, tree := range trees {
+		page, err := layoutText(tree, opts)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		pages[i] = page
+	}
+	return pages, nil
+}
@@ -0,0 +1,149 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ExportToHTML exports a scene tree to a standalone HTML page. Typed text is
+// written as semantic HTML (headings, lists, checkboxes) and strokes are
+// embedded as inline SVG.
+func ExportToHTML(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToHTMLWithOptions(tree, w, nil)
+}
+
+// ExportToHTMLWithOptions exports a scene tree to a standalone HTML page, using
+// opts for the inline SVG drawing. A nil opts uses DefaultSVGOptions().
+func ExportToHTMLWithOptions(tree *parser.SceneTree, w io.Writer, opts *SVGOptions) error {
+	if tree == nil {
+		return fmt.Errorf("scene tree cannot be nil")
+	}
+
+	title := PageHeading(tree)
+	if title == "" {
+		title = "reMarkable page"
+	}
+
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>%s</title>
+	<style>
+		body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; }
+		ul.checklist { list-style: none; padding-left: 0; }
+		svg.strokes { display: block; max-width: 100%%; height: auto; }
+	</style>
+</head>
+<body>
+`, htmlEscape(title))
+
+	if tree.RootText != nil {
+		doc, err := parser.BuildTextDocument(tree.RootText)
+		if err != nil {
+			return fmt.Errorf("failed to build text document: %w", err)
+		}
+		for _, p := range doc.Paragraphs {
+			opts.linkPages(p.Spans)
+		}
+		writeHTMLText(doc, w)
+	}
+
+	// Strokes are drawn without the typed text, which is already in the page
+	var svg strings.Builder
+	if err := writeSVG(context.Background(), tree, &svg, opts, false); err != nil {
+		return err
+	}
+	fmt.Fprint(w, strings.Replace(svg.String(), "<svg ", "<svg class=\"strokes\" ", 1))
+
+	fmt.Fprintf(w, "</body>\n</html>\n")
+	return nil
+}
+
+// htmlListTag returns the list element a paragraph style belongs to, if any
+func htmlListTag(style parser.ParagraphStyle) string {
+	switch style {
+	case parser.StyleBullet, parser.StyleBullet2:
+		return "ul"
+	case parser.StyleNumbered:
+		return "ol"
+	case parser.StyleCheckbox, parser.StyleCheckboxChecked:
+		return `ul class="checklist"`
+	default:
+		return ""
+	}
+}
+
+// writeHTMLText writes paragraphs as semantic HTML, grouping consecutive
+// list paragraphs into a single list
+func writeHTMLText(doc *parser.TextDocument, w io.Writer) {
+	openList := ""
+	closeList := func() {
+		if openList != "" {
+			fmt.Fprintf(w, "</%s>\n", strings.Fields(openList)[0])
+			openList = ""
+		}
+	}
+
+	for _, p := range doc.Paragraphs {
+		if p.Text == "" {
+			closeList()
+			continue
+		}
+
+		content := formatHTMLSpans(p)
+		listTag := htmlListTag(p.Style)
+		if listTag != openList {
+			closeList()
+			if listTag != "" {
+				fmt.Fprintf(w, "<%s>\n", listTag)
+				openList = listTag
+			}
+		}
+
+		switch p.Style {
+		case parser.StyleHeading:
+			fmt.Fprintf(w, "<h1>%s</h1>\n", content)
+		case parser.StyleBold:
+			fmt.Fprintf(w, "<p><strong>%s</strong></p>\n", content)
+		case parser.StyleCheckbox:
+			fmt.Fprintf(w, "\t<li><input type=\"checkbox\" disabled> %s</li>\n", content)
+		case parser.StyleCheckboxChecked:
+			fmt.Fprintf(w, "\t<li><input type=\"checkbox\" checked disabled> %s</li>\n", content)
+		case parser.StyleBullet, parser.StyleBullet2, parser.StyleNumbered:
+			fmt.Fprintf(w, "\t<li>%s</li>\n", content)
+		default:
+			fmt.Fprintf(w, "<p>%s</p>\n", content)
+		}
+	}
+	closeList()
+}
+
+// formatHTMLSpans renders a paragraph's inline formatting as strong/em
+// elements, and its links to web addresses and pages as links
+func formatHTMLSpans(p parser.Paragraph) string {
+	if !p.HasInlineFormatting() && !p.HasLinks() {
+		return htmlEscape(p.Text)
+	}
+
+	var sb strings.Builder
+	for _, span := range p.Spans {
+		text := htmlEscape(span.Text)
+		if span.Italic {
+			text = "<em>" + text + "</em>"
+		}
+		if span.Bold {
+			text = "<strong>" + text + "</strong>"
+		}
+		if span.Link != "" {
+			text = fmt.Sprintf(`<a href="%s">%s</a>`, htmlEscape(span.Link), text)
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
@@ -0,0 +1,120 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// mergeTools returns the tools to try, in order, for merging the per-page
+// PDFs of a legacy multipage export. In auto mode the installed programs come
+// first and the built-in merger is the final fallback.
+func (o *PDFOptions) mergeTools() []PDFMergeTool {
+	switch o.MergeTool {
+	case MergeToolPdfunite:
+		// pdfunite cannot add bookmarks
+		if o.Outline {
+			return []PDFMergeTool{MergeToolGhostscript}
+		}
+		return []PDFMergeTool{MergeToolPdfunite}
+	case MergeToolGhostscript, MergeToolBuiltin:
+		return []PDFMergeTool{o.MergeTool}
+	}
+
+	var tools []PDFMergeTool
+	if _, err := lookPath("pdfunite"); err == nil && !o.Outline {
+		tools = append(tools, MergeToolPdfunite)
+	}
+	if _, err := lookPath("gs"); err == nil {
+		tools = append(tools, MergeToolGhostscript)
+	}
+	return append(tools, MergeToolBuiltin)
+}
+
+// mergePDFs merges PDF files with the given tool and returns the merged
+// document. When titles is non-nil, a bookmark is added per page. External
+// tools write their output to tempDir.
+func mergePDFs(ctx context.Context, tool PDFMergeTool, files, titles []string, tempDir string) ([]byte, error) {
+	if tool == MergeToolBuiltin {
+		return mergePDFsBuiltin(files, titles)
+	}
+
+	outputPath := filepath.Join(tempDir, "output.pdf")
+	name := "pdfunite"
+	args := append(append([]string{}, files...), outputPath)
+	if tool == MergeToolGhostscript {
+		name = "gs"
+		args = []string{"-dBATCH", "-dNOPAUSE", "-q", "-sDEVICE=pdfwrite", "-sOutputFile=" + outputPath}
+		args = append(args, files...)
+
+		// Ghostscript adds bookmarks from a pdfmark file given after the PDFs
+		if titles != nil {
+			pdfmarkPath := filepath.Join(tempDir, "outline.pdfmark")
+			if err := os.WriteFile(pdfmarkPath, buildPdfmarks(titles), 0644); err != nil {
+				return nil, fmt.Errorf("failed to write outline: %w", err)
+			}
+			args = append(args, pdfmarkPath)
+		}
+	}
+
+	if err := runCommand(ctx, nil, nil, nil, name, args...); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if tool == MergeToolPdfunite {
+			return nil, fmt.Errorf("PDF merging with pdfunite failed: %w\n  %s", err, pdfuniteHint)
+		}
+		return nil, fmt.Errorf("PDF merging with Ghostscript failed: %w\n  %s", err, ghostscriptHint)
+	}
+
+	pdfData, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merged PDF: %w", err)
+	}
+	return pdfData, nil
+}
+
+// disablePDFCPUConfig stops pdfcpu from creating a configuration directory
+// in the user's home
+var disablePDFCPUConfig sync.Once
+
+// mergePDFsBuiltin merges PDF files in-process with pdfcpu, so no external
+// merge tool is needed
+func mergePDFsBuiltin(files, titles []string) ([]byte, error) {
+	disablePDFCPUConfig.Do(api.DisableConfigDir)
+
+	readers := make([]io.ReadSeeker, len(files))
+	for i, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open page %d: %w", i+1, err)
+		}
+		defer f.Close()
+		readers[i] = f
+	}
+
+	var merged bytes.Buffer
+	if err := api.MergeRaw(readers, &merged, false, nil); err != nil {
+		return nil, fmt.Errorf("PDF merging failed: %w", err)
+	}
+	if titles == nil {
+		return merged.Bytes(), nil
+	}
+
+	bookmarks := make([]pdfcpu.Bookmark, len(titles))
+	for i, title := range titles {
+		bookmarks[i] = pdfcpu.Bookmark{Title: title, PageFrom: i + 1}
+	}
+	var out bytes.Buffer
+	if err := api.AddBookmarks(bytes.NewReader(merged.Bytes()), &out, bookmarks, true, nil); err != nil {
+		return nil, fmt.Errorf("failed to add bookmarks: %w", err)
+	}
+	return out.Bytes(), nil
+}
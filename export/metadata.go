@@ -0,0 +1,250 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// pdfCreator is the application recorded as the creator of PDFs with metadata
+const pdfCreator = "rmc-go"
+
+// PDFMetadata is the document information written to a PDF, both as the
+// document info dictionary and as XMP metadata. Empty fields are left out;
+// when every field is empty the PDF is written without metadata.
+type PDFMetadata struct {
+	Title    string
+	Author   string
+	Created  time.Time
+	Modified time.Time
+
+	// Keywords are written as the PDF keywords, e.g. a notebook's tags
+	Keywords []string
+}
+
+// IsZero reports whether no metadata is set
+func (m PDFMetadata) IsZero() bool {
+	return m.Title == "" && m.Author == "" && m.Created.IsZero() && m.Modified.IsZero() && len(m.Keywords) == 0
+}
+
+// WithNotebook returns m with the name and dates of a notebook's .metadata
+// file filled in. A title already set is kept; a nil meta changes nothing.
+func (m PDFMetadata) WithNotebook(meta *parser.Metadata) PDFMetadata {
+	if meta == nil {
+		return m
+	}
+	if m.Title == "" {
+		m.Title = meta.VisibleName
+	}
+	m.Created = meta.CreatedTime.Time
+	m.Modified = meta.LastModified.Time
+	return m
+}
+
+// WithTags returns m with the tags of a notebook's .content file, those of
+// the document and then those of its pages, added to the keywords. A nil
+// content changes nothing.
+func (m PDFMetadata) WithTags(content *parser.ContentFile) PDFMetadata {
+	if content == nil {
+		return m
+	}
+	tags := content.AllTagNames()
+	if len(tags) == 0 {
+		return m
+	}
+	m.Keywords = append(slices.Clip(m.Keywords), tags...)
+	return m
+}
+
+// addPDFMetadata writes a PDF with the metadata appended as an incremental
+// update: a new document info dictionary, an XMP metadata stream and a copy
+// of the catalog that points to it. Appending keeps the rest of the file as
+// the renderer wrote it, including the dates, which rewriting the document
+// with pdfcpu would replace.
+func addPDFMetadata(pdfData []byte, w io.Writer, meta PDFMetadata) error {
+	disablePDFCPUConfig.Do(api.DisableConfigDir)
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadContext(bytes.NewReader(pdfData), conf)
+	if err != nil {
+		return fmt.Errorf("failed to read PDF for metadata: %w", err)
+	}
+	prev, err := lastXRefOffset(pdfData)
+	if err != nil {
+		return err
+	}
+	if ctx.Root == nil || ctx.Size == nil {
+		return fmt.Errorf("failed to read PDF for metadata: missing catalog")
+	}
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("failed to read PDF catalog: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pdfData)
+	if !bytes.HasSuffix(pdfData, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	size := *ctx.Size
+	infoNr, xmpNr, rootNr := size, size+1, int(ctx.Root.ObjectNumber)
+	offsets := map[int]int{}
+
+	offsets[infoNr] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", infoNr, meta.infoDict())
+
+	xmp := meta.xmp()
+	offsets[xmpNr] = buf.Len()
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n", xmpNr, len(xmp))
+	buf.Write(xmp)
+	buf.WriteString("\nendstream\nendobj\n")
+
+	root := catalog.Clone().(types.Dict)
+	root.Update("Metadata", *types.NewIndirectRef(xmpNr, 0))
+	offsets[rootNr] = buf.Len()
+	fmt.Fprintf(&buf, "%d %d obj\n%s\nendobj\n", rootNr, ctx.Root.GenerationNumber.Value(), root.PDFString())
+
+	trailer := fmt.Sprintf("/Root %s /Info %d 0 R /Prev %d", ctx.Root.PDFString(), infoNr, prev)
+	if ctx.ID != nil {
+		trailer += " /ID " + ctx.ID.PDFString()
+	}
+	if ctx.Read.UsingXRefStreams {
+		writeXRefStream(&buf, offsets, size+2, trailer)
+	} else {
+		writeXRefTable(&buf, offsets, size+2, trailer)
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write PDF output: %w", err)
+	}
+	return nil
+}
+
+// startXRef matches the offset of the last cross-reference section
+var startXRef = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+
+// lastXRefOffset returns the offset of the newest cross-reference section
+func lastXRefOffset(pdfData []byte) (int, error) {
+	tail := pdfData[max(0, len(pdfData)-1024):]
+	m := startXRef.FindSubmatch(tail)
+	if m == nil {
+		return 0, fmt.Errorf("failed to read PDF for metadata: missing startxref")
+	}
+	return strconv.Atoi(string(m[1]))
+}
+
+// sortedObjects returns the object numbers of an update in ascending order
+func sortedObjects(offsets map[int]int) []int {
+	nrs := make([]int, 0, len(offsets))
+	for nr := range offsets {
+		nrs = append(nrs, nr)
+	}
+	sort.Ints(nrs)
+	return nrs
+}
+
+// writeXRefTable appends a classic cross-reference table and trailer
+func writeXRefTable(buf *bytes.Buffer, offsets map[int]int, size int, trailer string) {
+	start := buf.Len()
+	buf.WriteString("xref\n")
+	for _, nr := range sortedObjects(offsets) {
+		fmt.Fprintf(buf, "%d 1\n%010d 00000 n \n", nr, offsets[nr])
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", size, trailer, start)
+}
+
+// writeXRefStream appends a cross-reference stream, for files whose
+// cross-references are streams themselves. The stream is its own object,
+// numbered size and not compressed.
+func writeXRefStream(buf *bytes.Buffer, offsets map[int]int, size int, trailer string) {
+	start := buf.Len()
+	offsets[size] = start
+
+	var index bytes.Buffer
+	var rows []byte
+	for _, nr := range sortedObjects(offsets) {
+		fmt.Fprintf(&index, "%d 1 ", nr)
+		off := offsets[nr]
+		rows = append(rows, 1, byte(off>>24), byte(off>>16), byte(off>>8), byte(off), 0)
+	}
+	fmt.Fprintf(buf, "%d 0 obj\n<< /Type /XRef %s /Size %d /W [1 4 1] /Index [%s] /Length %d >>\nstream\n",
+		size, trailer, size+1, bytes.TrimSpace(index.Bytes()), len(rows))
+	buf.Write(rows)
+	fmt.Fprintf(buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+}
+
+// infoDict returns the document info dictionary
+func (m PDFMetadata) infoDict() string {
+	d := fmt.Sprintf("<< /Creator <%s>", utf16BEHex(pdfCreator))
+	if m.Title != "" {
+		d += fmt.Sprintf(" /Title <%s>", utf16BEHex(m.Title))
+	}
+	if m.Author != "" {
+		d += fmt.Sprintf(" /Author <%s>", utf16BEHex(m.Author))
+	}
+	if len(m.Keywords) > 0 {
+		d += fmt.Sprintf(" /Keywords <%s>", utf16BEHex(strings.Join(m.Keywords, ", ")))
+	}
+	if !m.Created.IsZero() {
+		d += fmt.Sprintf(" /CreationDate (%s)", types.DateString(m.Created))
+	}
+	if !m.Modified.IsZero() {
+		d += fmt.Sprintf(" /ModDate (%s)", types.DateString(m.Modified))
+	}
+	return d + " >>"
+}
+
+// xmp returns the XMP metadata packet with the same information as the
+// document info dictionary
+func (m PDFMetadata) xmp() []byte {
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">
+`)
+	if m.Title != "" {
+		fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", xmlEscape(m.Title))
+	}
+	if m.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", xmlEscape(m.Author))
+	}
+	if len(m.Keywords) > 0 {
+		b.WriteString("<dc:subject><rdf:Bag>")
+		for _, keyword := range m.Keywords {
+			fmt.Fprintf(&b, "<rdf:li>%s</rdf:li>", xmlEscape(keyword))
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+		fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>\n", xmlEscape(strings.Join(m.Keywords, ", ")))
+	}
+	if !m.Created.IsZero() {
+		fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n", m.Created.Format(time.RFC3339))
+	}
+	if !m.Modified.IsZero() {
+		fmt.Fprintf(&b, "<xmp:ModifyDate>%s</xmp:ModifyDate>\n", m.Modified.Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", pdfCreator)
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// xmlEscape escapes text for XML content
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NUp places several pages on each sheet of a PDF, in a grid of Columns by
+// Rows pages. The zero value places one page per sheet.
+type NUp struct {
+	Columns, Rows int
+}
+
+// ParseNUp parses an n-up layout given as columns x rows, e.g. "2x2" or
+// "2x1". An empty string or "1x1" is one page per sheet.
+func ParseNUp(s string) (NUp, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return NUp{}, nil
+	}
+	cols, rows, ok := strings.Cut(s, "x")
+	if !ok {
+		return NUp{}, fmt.Errorf("invalid n-up layout: %s (use columns x rows, e.g. 2x2)", s)
+	}
+	n := NUp{}
+	var err1, err2 error
+	n.Columns, err1 = strconv.Atoi(strings.TrimSpace(cols))
+	n.Rows, err2 = strconv.Atoi(strings.TrimSpace(rows))
+	if err1 != nil || err2 != nil || n.Columns <= 0 || n.Rows <= 0 {
+		return NUp{}, fmt.Errorf("invalid n-up layout: %s (use columns x rows, e.g. 2x2)", s)
+	}
+	if n.PerSheet() == 1 {
+		return NUp{}, nil
+	}
+	return n, nil
+}
+
+// PerSheet returns the number of pages on each sheet
+func (n NUp) PerSheet() int {
+	return max(1, n.Columns*n.Rows)
+}
+
+// String returns the layout as columns x rows
+func (n NUp) String() string {
+	return fmt.Sprintf("%dx%d", max(1, n.Columns), max(1, n.Rows))
+}
+
+// imposed reports whether pages are placed on sheets by NUp or Booklet
+func (o *PDFOptions) imposed() bool {
+	return o.Booklet || o.NUp.PerSheet() > 1
+}
+
+// sheetLayout returns the grid of pages on a sheet: two side by side for
+// booklets, NUp otherwise
+func (o *PDFOptions) sheetLayout() NUp {
+	if o.Booklet {
+		return NUp{Columns: 2, Rows: 1}
+	}
+	return o.NUp
+}
+
+// sheetOrder returns the index of the page drawn in each cell of the
+// sheets, in order, with -1 for blank cells. Booklets hold a multiple of
+// four pages: the outer sheet has the last and first page on its front and
+// the second and second to last on its back, and so on inwards.
+func (o *PDFOptions) sheetOrder(pages int) []int {
+	if !o.Booklet {
+		order := make([]int, pages)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+
+	padded := (pages + 3) / 4 * 4
+	order := make([]int, 0, padded)
+	for i := 0; i < padded/2; i += 2 {
+		order = append(order, padded-1-i, i, i+1, padded-2-i)
+	}
+	for i, page := range order {
+		if page >= pages {
+			order[i] = -1
+		}
+	}
+	return order
+}
+
+// nupGap is the margin around the pages of an n-up sheet and the space
+// between them, in points
+const nupGap = 18
+
+// nupGrid lays out the sheets of an n-up PDF or booklet with the given
+// number of cells. Sheets have the fixed page size of the options, or A4 if
+// none is set, turned to landscape when that lets the pages be drawn larger.
+func (o *PDFOptions) nupGrid(cells int) (sheetGrid, error) {
+	if cells == 0 {
+		return sheetGrid{}, fmt.Errorf("no scene trees provided")
+	}
+	layout := o.sheetLayout()
+	if layout.Columns <= 0 || layout.Rows <= 0 {
+		return sheetGrid{}, fmt.Errorf("invalid n-up layout: %s", layout)
+	}
+
+	// Pages have the shape of the device's screen or of the fixed page size
+	width, height := o.PageWidth, o.PageHeight
+	aspect := height / width
+	if width <= 0 || height <= 0 {
+		width, height = PageSizeA4.Dimensions()
+		aspect = float64(ScreenHeight) / ScreenWidth
+	}
+	if o.Landscape {
+		aspect = 1 / aspect
+	}
+
+	g := nupSheet(layout, width, height)
+	if turned := nupSheet(layout, height, width); nupPageScale(turned, aspect) > nupPageScale(g, aspect) {
+		g = turned
+	}
+	if g.cellWidth <= 0 || g.cellHeight <= 0 {
+		return sheetGrid{}, fmt.Errorf("sheet is too small for %s pages", layout)
+	}
+	g.sheets = (cells + layout.PerSheet() - 1) / layout.PerSheet()
+	return g, nil
+}
+
+// nupSheet divides a sheet of the given size into the cells of a layout
+func nupSheet(layout NUp, width, height float64) sheetGrid {
+	g := sheetGrid{
+		width: width, height: height,
+		columns: layout.Columns, rows: layout.Rows,
+		left: nupGap, top: nupGap, gap: nupGap,
+	}
+	g.cellWidth = (width - float64(g.columns+1)*nupGap) / float64(g.columns)
+	g.cellHeight = (height - float64(g.rows+1)*nupGap) / float64(g.rows)
+	g.rowHeight = g.cellHeight + nupGap
+	return g
+}
+
+// nupPageScale returns the width of a page of the given aspect ratio fitted
+// into a cell of the grid
+func nupPageScale(g sheetGrid, aspect float64) float64 {
+	return min(g.cellWidth, g.cellHeight/aspect)
+}
+
+// nupCellOptions returns the options that fit a page, laid out as usual,
+// into a cell of an n-up sheet, keeping its aspect ratio and centering it
+func (o *PDFOptions) nupCellOptions(g sheetGrid) SVGOptions {
+	opts := o.SVGOptions
+	opts.OutputScale = 0
+	opts.OutputWidth, opts.OutputHeight = g.cellWidth, g.cellHeight
+	return opts
+}
@@ -0,0 +1,94 @@
+//go:build cairo
+// +build cairo
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/joagonca/rmc-go/parser"
+	"github.com/ungerik/go-cairo"
+)
+
+// exportNUpPDFCairo renders a multipage PDF with several pages on each
+// sheet, or a booklet
+func exportNUpPDFCairo(ctx context.Context, trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	order := opts.sheetOrder(len(trees))
+	grid, err := opts.nupGrid(len(order))
+	if err != nil {
+		return err
+	}
+
+	fonts, err := loadCairoFonts(opts)
+	if err != nil {
+		return err
+	}
+	defer fonts.close()
+
+	stream := newCairoStream(w)
+	defer stream.close()
+	pdfSurface := newPDFStreamSurface(stream, grid.width, grid.height)
+	defer pdfSurface.Finish()
+
+	cellOpts := opts.nupCellOptions(grid)
+	sheets := make([]int, len(trees))
+	current, drawn := 0, 0
+	for i, pageIdx := range order {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Blank cells still start their sheet, e.g. the back of the last
+		// sheet of a booklet
+		sheet, x, y := grid.cell(i)
+		if sheet != current {
+			pdfSurface.ShowPage()
+			current = sheet
+		}
+		if pageIdx < 0 {
+			continue
+		}
+		drawn++
+		opts.reportProgress(drawn, len(trees), StageRender)
+
+		if err := drawNUpCell(ctx, pdfSurface, trees[pageIdx], x, y, grid, &cellOpts, fonts, pageIdx+1, len(trees)); err != nil {
+			return fmt.Errorf("page %d: %w", pageIdx+1, err)
+		}
+		sheets[pageIdx] = sheet
+
+		if status := pdfSurface.Status(); status != cairo.STATUS_SUCCESS {
+			return fmt.Errorf("page %d: cairo error: %s", pageIdx+1, status)
+		}
+	}
+
+	// Bookmarks follow the pages, which booklets put out of order
+	if opts.Outline {
+		for pageIdx, title := range pageTitles(trees, opts) {
+			addPDFOutline(pdfSurface, title, sheets[pageIdx]+1)
+		}
+	}
+
+	pdfSurface.Finish()
+	if err := stream.close(); err != nil {
+		return fmt.Errorf("failed to write PDF output: %w", err)
+	}
+	return nil
+}
+
+// drawNUpCell draws the given 1-based page of pages fitted into the cell of
+// a sheet at (x, y)
+func drawNUpCell(ctx context.Context, surface *cairo.Surface, tree *parser.SceneTree, x, y float64, grid sheetGrid, opts *SVGOptions, fonts *cairoFonts, page, pages int) error {
+	dims, err := calculatePageDimensions(tree, opts)
+	if err != nil {
+		return err
+	}
+
+	surface.Save()
+	defer surface.Restore()
+	surface.Rectangle(x, y, grid.cellWidth, grid.cellHeight)
+	surface.Clip()
+	surface.Translate(x, y)
+	return renderPageToCairo(ctx, tree, surface, dims, opts, fonts, page, pages)
+}
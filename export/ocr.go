@@ -0,0 +1,179 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// RecognitionDPI is the resolution at which pages are drawn for handwriting
+// recognition, which OCR engines read well
+const RecognitionDPI = 300
+
+// maxRecognitionPixels limits the size of the image of a very large page,
+// which is then drawn at a lower resolution
+const maxRecognitionPixels = 1 << 25
+
+// PageImage is a page drawn for handwriting recognition: its ink in black on
+// a white background, without highlighters or typed text. The image is not
+// turned for landscape pages; strokes are drawn as they are stored.
+type PageImage struct {
+	Image *image.Gray
+
+	// PixelsPerPoint is the resolution of the image: the pixel (px, py) shows
+	// the point (X + px/PixelsPerPoint, Y + py/PixelsPerPoint) of the page
+	// content
+	PixelsPerPoint float64
+
+	// X and Y are the point of the page content at the top left corner of
+	// the image
+	X, Y float64
+}
+
+// PNG returns the image encoded as PNG, for programs that read image files
+func (p *PageImage) PNG() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, p.Image); err != nil {
+		return nil, fmt.Errorf("failed to encode page image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RecognizedWord is a piece of recognized handwriting and the area it covers,
+// in pixels of the PageImage it was read from
+type RecognizedWord struct {
+	Text   string  `json:"text"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Recognizer converts the handwriting on a page into text. Recognized words are
+// rendered as an invisible text layer so exported handwriting is searchable.
+// Recognize is passed the context of the export, and should return the
+// context's error once ctx is done.
+type Recognizer interface {
+	Recognize(ctx context.Context, page *PageImage) ([]RecognizedWord, error)
+}
+
+// RecognizerFunc adapts a function to the Recognizer interface
+type RecognizerFunc func(ctx context.Context, page *PageImage) ([]RecognizedWord, error)
+
+// Recognize calls f(ctx, page)
+func (f RecognizerFunc) Recognize(ctx context.Context, page *PageImage) ([]RecognizedWord, error) {
+	return f(ctx, page)
+}
+
+// CommandRecognizer runs an external program for handwriting recognition.
+// The page is written to the program's stdin as a PNG image at
+// RecognitionDPI, and the program must print the words it finds to stdout
+// with their boxes in pixels: either as a JSON array of RecognizedWord
+// objects, or as the TSV output of tesseract ("tesseract stdin stdout tsv").
+type CommandRecognizer struct {
+	Name string
+	Args []string
+}
+
+// Recognize runs the command for a single page, killing it once ctx is done
+func (c CommandRecognizer) Recognize(ctx context.Context, page *PageImage) ([]RecognizedWord, error) {
+	data, err := page.PNG()
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(ctx, bytes.NewReader(data), &stdout, &stderr, c.Name, c.Args...); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("recognition command %s failed: %w: %s", c.Name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) > 0 && out[0] != '[' {
+		return parseTesseractTSV(out)
+	}
+	var words []RecognizedWord
+	if err := json.Unmarshal(out, &words); err != nil {
+		return nil, fmt.Errorf("failed to parse recognition output: %w", err)
+	}
+	return words, nil
+}
+
+// parseTesseractTSV reads the words of tesseract's TSV output, whose columns
+// are level, page_num, block_num, par_num, line_num, word_num, left, top,
+// width, height, conf and text. Words are the rows of level 5.
+func parseTesseractTSV(data []byte) ([]RecognizedWord, error) {
+	var words []RecognizedWord
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if i == 0 && len(fields) > 0 && fields[0] == "level" {
+			continue
+		}
+		if len(fields) < 12 || fields[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(fields[11])
+		if text == "" {
+			continue
+		}
+		var box [4]float64
+		for j := range box {
+			v, err := strconv.ParseFloat(fields[6+j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse recognition output line %d: %w", i+1, err)
+			}
+			box[j] = v
+		}
+		words = append(words, RecognizedWord{Text: text, X: box[0], Y: box[1], Width: box[2], Height: box[3]})
+	}
+	return words, nil
+}
+
+// recognitionImage draws the ink of a page for a Recognizer, covering the
+// content shown on the page
+func recognitionImage(ctx context.Context, tree *parser.SceneTree, layout pageLayout, anchorPos map[parser.CrdtID]float64, opts *SVGOptions) (*PageImage, error) {
+	page := Page{
+		View:    Rect{layout.viewX, layout.viewY, layout.viewWidth, layout.viewHeight},
+		Rotated: layout.rotated,
+	}
+	area := page.contentRect()
+	pixelsPerPoint := RecognitionDPI / 72.0
+	if pixels := area.Width * area.Height * pixelsPerPoint * pixelsPerPoint; pixels > maxRecognitionPixels {
+		pixelsPerPoint *= math.Sqrt(maxRecognitionPixels / pixels)
+	}
+
+	plain := *opts
+	plain.Recognizer = nil
+	r := newRasterRenderer(area, pixelsPerPoint)
+	if err := renderPage(ctx, tree, r, layout, anchorPos, &plain); err != nil {
+		return nil, err
+	}
+	return &PageImage{Image: r.image(), PixelsPerPoint: pixelsPerPoint, X: area.X, Y: area.Y}, nil
+}
+
+// recognizedFontSize returns the font size in points that fits a word of
+// the given height in points
+func recognizedFontSize(height float64) float64 {
+	return height * 0.8
+}
+
+// drawRecognizedText writes recognized words as invisible but selectable SVG text
+func drawRecognizedText(words []TextWord, w io.Writer, indent string) {
+	fmt.Fprintf(w, "%s<g class=\"ocr-text\" fill-opacity=\"0\">\n", indent)
+	for _, word := range words {
+		fmt.Fprintf(w, "%s\t<text x=\"%.3f\" y=\"%.3f\" textLength=\"%.3f\" lengthAdjust=\"spacingAndGlyphs\" style=\"font: %.1fpt sans-serif\">%s</text>\n",
+			indent, word.X, word.Y, word.Width, word.Size, htmlEscape(word.Text))
+	}
+	fmt.Fprintf(w, "%s</g>\n", indent)
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// circle is a disc in output coordinates
+type circle struct {
+	center vec
+	radius float64
+}
+
+// strokeOutline is the filled shape of a variable-width stroke: a disc at
+// every point joined by quadrilaterals. All parts wind clockwise so they fill
+// as a single shape under the nonzero rule, without darker overlaps.
+type strokeOutline struct {
+	circles []circle
+	quads   [][4]vec
+	color   RGB
+	opacity float64
+}
+
+// hasVariableWidth reports whether the pen's width follows pressure, speed or
+// tilt, so its strokes benefit from being drawn as outlines
+func (p *pen) hasVariableWidth() bool {
+	switch p.name {
+	case "Ballpoint", "Marker", "Pencil", "Brush", "Calligraphy":
+		return true
+	default:
+		return false
+	}
+}
+
+// outlineStroke computes the filled outline of a stroke with the width
+// evaluated at every point, so it changes continuously instead of once per
+// segment. Color and opacity are averaged over the stroke. Coordinates are in
+// output units.
+func outlineStroke(line *parser.Line, pen *pen, strokeScale float64) strokeOutline {
+	var outline strokeOutline
+	var r, g, b, opacity float64
+
+	lastWidth := 0.0
+	var prev circle
+	for i, point := range line.Points {
+		color := pen.getSegmentColorRGB(point, lastWidth)
+		r, g, b = r+float64(color.R), g+float64(color.G), b+float64(color.B)
+		opacity += pen.getSegmentOpacity(point, lastWidth)
+
+		width := pen.getSegmentWidth(point, strokeHeading(line.Points, i), lastWidth)
+		lastWidth = width
+
+		c := circle{
+			center: vec{scale(float64(point.X)), scale(float64(point.Y))},
+			radius: math.Max(scale(width)*strokeScale/2, 0),
+		}
+		if i > 0 && c.center == prev.center {
+			// Keep the wider disc for repeated points
+			if c.radius > prev.radius {
+				outline.circles[len(outline.circles)-1] = c
+				prev = c
+			}
+			continue
+		}
+
+		outline.circles = append(outline.circles, c)
+		if i > 0 {
+			outline.quads = append(outline.quads, joinCircles(prev, c))
+		}
+		prev = c
+	}
+
+	if n := float64(len(line.Points)); n > 0 {
+		outline.color = RGB{R: int(r / n), G: int(g / n), B: int(b / n)}
+		outline.opacity = opacity / n
+	}
+	return outline
+}
+
+// joinCircles returns the quadrilateral spanning the diameters of two discs
+// perpendicular to the line between their centers, wound clockwise
+func joinCircles(a, b circle) [4]vec {
+	dir := b.center.sub(a.center).normalize()
+	normal := vec{-dir.y, dir.x}
+
+	return clockwise([4]vec{
+		a.center.add(normal.mul(a.radius)),
+		b.center.add(normal.mul(b.radius)),
+		b.center.sub(normal.mul(b.radius)),
+		a.center.sub(normal.mul(a.radius)),
+	})
+}
+
+// signedArea returns the shoelace area of a polygon, positive when it winds
+// clockwise on screen (y down)
+func signedArea(points []vec) float64 {
+	area := 0.0
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		area += p.x*q.y - q.x*p.y
+	}
+	return area / 2
+}
@@ -0,0 +1,174 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// PageSize names a fixed output page size
+type PageSize string
+
+const (
+	// PageSizeAuto sizes each page to its content (default)
+	PageSizeAuto PageSize = "auto"
+	// PageSizeDevice uses the reMarkable screen size
+	PageSizeDevice PageSize = "device"
+	// PageSizeA4 uses ISO A4 (210 x 297 mm)
+	PageSizeA4 PageSize = "a4"
+	// PageSizeLetter uses US Letter (8.5 x 11 in)
+	PageSizeLetter PageSize = "letter"
+)
+
+// ParsePageSize parses a page size name (auto, device, a4 or letter)
+func ParsePageSize(name string) (PageSize, error) {
+	ps := PageSize(strings.ToLower(name))
+	switch ps {
+	case "":
+		return PageSizeAuto, nil
+	case PageSizeAuto, PageSizeDevice, PageSizeA4, PageSizeLetter:
+		return ps, nil
+	default:
+		return "", fmt.Errorf("unknown page size: %s (supported: auto, device, a4, letter)", name)
+	}
+}
+
+// Dimensions returns the page width and height in points.
+// Both are zero for PageSizeAuto.
+func (ps PageSize) Dimensions() (float64, float64) {
+	switch ps {
+	case PageSizeDevice:
+		return scale(ScreenWidth), scale(ScreenHeight)
+	case PageSizeA4:
+		return 595.28, 841.89
+	case PageSizeLetter:
+		return 612, 792
+	default:
+		return 0, 0
+	}
+}
+
+// lengthUnits maps the units accepted by ParseLength to points
+var lengthUnits = map[string]float64{
+	"pt": 1,
+	"mm": 72 / 25.4,
+	"cm": 72 / 2.54,
+	"in": 72,
+}
+
+// ParseLength parses a non-negative length such as "10", "10pt", "5mm" or
+// "0.25in" and returns it in points. A number without a unit is in points.
+func ParseLength(length string) (float64, error) {
+	s := strings.ToLower(strings.TrimSpace(length))
+	factor := 1.0
+	for unit, f := range lengthUnits {
+		if strings.HasSuffix(s, unit) {
+			s, factor = strings.TrimSpace(strings.TrimSuffix(s, unit)), f
+			break
+		}
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || !(v >= 0) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid length: %s (use a number of pt, mm, cm or in)", length)
+	}
+	return v * factor, nil
+}
+
+// pageLayout describes how the content region maps onto the output page (in points)
+type pageLayout struct {
+	width, height                       float64 // output page size
+	viewX, viewY, viewWidth, viewHeight float64 // content region including margin
+	rotated                             bool    // content is turned a quarter turn clockwise
+}
+
+// computePageLayout determines the content region and output page size for a tree
+func computePageLayout(tree *parser.SceneTree, anchorPos map[parser.CrdtID]float64, opts *SVGOptions) pageLayout {
+	region := getPageBounds(tree, anchorPos, opts.CropToContent, opts)
+
+	l := pageLayout{
+		viewX:      scale(region.xMin) - opts.Margin,
+		viewY:      scale(region.yMin) - opts.Margin,
+		viewWidth:  scale(region.xMax-region.xMin+1) + 2*opts.Margin,
+		viewHeight: scale(region.yMax-region.yMin+1) + 2*opts.Margin,
+	}
+
+	if opts.Landscape {
+		// Turning the page maps (x, y) to (-y, x), so the region is mirrored
+		// across the diagonal and its width and height are swapped
+		l.viewX, l.viewY = -(l.viewY + l.viewHeight), l.viewX
+		l.viewWidth, l.viewHeight = l.viewHeight, l.viewWidth
+		l.rotated = true
+	}
+
+	l.width, l.height = l.viewWidth, l.viewHeight
+	if opts.PageWidth > 0 && opts.PageHeight > 0 {
+		l.width, l.height = opts.PageWidth, opts.PageHeight
+		if opts.Landscape && l.width < l.height {
+			l.width, l.height = l.height, l.width
+		}
+	}
+
+	// Resizing the page scales the content with it, since it is fitted to
+	// the page
+	factor := 1.0
+	switch {
+	case opts.OutputWidth > 0 && opts.OutputHeight > 0:
+		l.width, l.height = opts.OutputWidth, opts.OutputHeight
+	case opts.OutputWidth > 0:
+		factor = opts.OutputWidth / l.width
+	case opts.OutputHeight > 0:
+		factor = opts.OutputHeight / l.height
+	case opts.OutputScale > 0:
+		factor = opts.OutputScale
+	}
+	l.width *= factor
+	l.height *= factor
+
+	return l
+}
+
+// fit returns the scale factor and offset that fit the content region onto
+// the page, centered and preserving aspect ratio (like SVG's xMidYMid meet)
+func (l pageLayout) fit() (factor, offsetX, offsetY float64) {
+	factor = math.Min(l.width/l.viewWidth, l.height/l.viewHeight)
+	offsetX = (l.width - l.viewWidth*factor) / 2
+	offsetY = (l.height - l.viewHeight*factor) / 2
+	return factor, offsetX, offsetY
+}
+
+// namedColors maps the CSS color names most likely to be used for backgrounds
+var namedColors = map[string]RGB{
+	"white": {255, 255, 255},
+	"black": {0, 0, 0},
+	"gray":  {128, 128, 128},
+	"grey":  {128, 128, 128},
+}
+
+// parseCSSColor parses a #rgb, #rrggbb or basic named CSS color for the
+// non-SVG backends. Returns false for empty, "none" or unsupported values.
+func parseCSSColor(s string) (RGB, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	if !strings.HasPrefix(s, "#") {
+		return RGB{}, false
+	}
+
+	hex := s[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return RGB{}, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return RGB{}, false
+	}
+	return RGB{R: int(v >> 16 & 0xFF), G: int(v >> 8 & 0xFF), B: int(v & 0xFF)}, true
+}
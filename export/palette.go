@@ -0,0 +1,95 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ParsePalette parses a JSON palette that maps pen color names (such as
+// "blue" or "highlight-yellow") or numeric color IDs to CSS colors in #rgb or
+// #rrggbb form:
+//
+//	{"blue": "#1a4f9c", "red": "#c00"}
+//
+// The key "*" sets every pen color; other entries take precedence over it.
+func ParsePalette(data []byte) (map[parser.PenColor]RGB, error) {
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse palette: %w", err)
+	}
+	return PaletteFromMap(entries)
+}
+
+// ParseColor parses a #rgb, #rrggbb or basic named CSS color, as used in
+// palettes
+func ParseColor(s string) (RGB, error) {
+	rgb, ok := parseCSSColor(s)
+	if !ok {
+		return RGB{}, fmt.Errorf("invalid color: %q (use #rgb, #rrggbb, white, black or gray)", s)
+	}
+	return rgb, nil
+}
+
+// PaletteFromMap builds a palette from pen color names or IDs mapped to CSS
+// colors, as read from a JSON palette or a config file (see ParsePalette)
+func PaletteFromMap(entries map[string]string) (map[parser.PenColor]RGB, error) {
+	palette := make(map[parser.PenColor]RGB)
+	if value, ok := entries["*"]; ok {
+		rgb, ok := parseCSSColor(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid color for \"*\": %q", value)
+		}
+		for _, color := range parser.PenColors() {
+			palette[color] = rgb
+		}
+	}
+
+	for name, value := range entries {
+		if name == "*" {
+			continue
+		}
+		color, err := parsePenColorName(name)
+		if err != nil {
+			return nil, err
+		}
+		rgb, ok := parseCSSColor(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid color for %q: %q", name, value)
+		}
+		palette[color] = rgb
+	}
+
+	return palette, nil
+}
+
+// ReadPaletteFile reads a JSON palette file (see ParsePalette)
+func ReadPaletteFile(path string) (map[parser.PenColor]RGB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read palette file: %w", err)
+	}
+	return ParsePalette(data)
+}
+
+// parsePenColorName resolves a pen color name or numeric color ID
+func parsePenColorName(name string) (parser.PenColor, error) {
+	if color, ok := parser.ParsePenColor(strings.ToLower(name)); ok {
+		return color, nil
+	}
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 {
+		return parser.PenColor(id), nil
+	}
+
+	names := make([]string, 0, len(parser.PenColors()))
+	for _, c := range parser.PenColors() {
+		names = append(names, c.String())
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown pen color: %s (supported: %s)", name, strings.Join(names, ", "))
+}
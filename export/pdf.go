@@ -2,37 +2,204 @@ package export
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
+	"unicode/utf16"
 
 	"github.com/joagonca/rmc-go/parser"
 )
 
+// PDFOptions controls PDF output
+type PDFOptions struct {
+	// SVGOptions holds the page layout options shared with SVG export
+	SVGOptions
+
+	// UseLegacy converts SVG output with Inkscape or rsvg-convert instead of
+	// rendering with Cairo
+	UseLegacy bool
+
+	// Outline adds a bookmark for every page of a multipage PDF
+	Outline bool
+
+	// NUp places several pages on each sheet, scaled to fit a grid with
+	// margins, for compact printing (Cairo renderer only). Sheets have the
+	// fixed page size, or A4 if none is set, in the orientation that draws
+	// the pages larger. Bookmarks lead to the sheet of their page.
+	NUp NUp
+
+	// Booklet arranges the pages for a saddle-stitched booklet: two pages
+	// side by side on each sheet, in the order that puts them in sequence
+	// once the sheets are printed on both sides (flipping on the short
+	// edge), stacked, folded and stapled. Blank pages are added at the end
+	// to make a multiple of four. It takes precedence over NUp (Cairo
+	// renderer only).
+	Booklet bool
+
+	// PageTitles overrides the bookmark titles by page index. Pages without a
+	// title use their first heading, or "Page N" if they have none.
+	PageTitles []string
+
+	// Contents prepends a table of contents to a multipage PDF, listing
+	// every page with its first heading or line of typed text and its tags,
+	// each linking to its page (Cairo renderer only). The contents pages
+	// have the fixed page size, or A4 if none is set.
+	Contents bool
+
+	// PageTags are the tags of the pages by page index, listed in the table
+	// of contents
+	PageTags [][]string
+
+	// TextLayer embeds a TrueType font for typed text so it can be selected,
+	// searched and copied in PDF viewers (Cairo renderer only)
+	TextLayer bool
+
+	// FontFile is the TrueType/OpenType font embedded for the text layer.
+	// When empty a common system font is used if one can be found.
+	FontFile string
+
+	// Profile selects a PDF conformance profile such as PDF/A-2b.
+	// Profiles other than the default require Ghostscript.
+	Profile PDFProfile
+
+	// Metadata is written to the PDF's document info and XMP metadata.
+	// When empty the PDF carries no title, author or dates.
+	Metadata PDFMetadata
+
+	// InkscapePath is the Inkscape executable used by the legacy renderer
+	// (default: "inkscape" from PATH)
+	InkscapePath string
+
+	// Converter selects the program that converts SVG for the legacy
+	// renderer (default: Inkscape)
+	Converter SVGConverter
+
+	// MergeTool selects the program that merges legacy multipage PDFs.
+	// Bookmarks cannot be added by pdfunite; Ghostscript is used instead.
+	MergeTool PDFMergeTool
+
+	// TempDir is where the legacy renderer, PDF merging and PDF profile
+	// conversion keep the files they pass to external programs (default:
+	// os.TempDir()). Cairo rendering writes no temporary files.
+	TempDir string
+
+	// Progress is called as multipage export works through the pages, with
+	// the 1-based page number, the page count and the stage (StageRender or
+	// StageMerge). Single pages are not reported.
+	Progress func(page, total int, stage string)
+}
+
+// Stages of a multipage conversion reported to progress callbacks
+const (
+	StageParse  = "parse"  // Reading a page's .rm data
+	StageRender = "render" // Drawing a page
+	StageMerge  = "merge"  // Combining the pages into one PDF
+)
+
+// reportProgress calls opts.Progress if it is set
+func (opts *PDFOptions) reportProgress(page, total int, stage string) {
+	if opts.Progress != nil {
+		opts.Progress(page, total, stage)
+	}
+}
+
+// DefaultPDFOptions returns the options used by ExportToPDF
+func DefaultPDFOptions() *PDFOptions {
+	return &PDFOptions{SVGOptions: *DefaultSVGOptions()}
+}
+
 // ExportToPDF exports a scene tree to PDF format
 // If useLegacy is true, uses Inkscape via SVG conversion. Otherwise uses Cairo directly (default).
 func ExportToPDF(tree *parser.SceneTree, w io.Writer, useLegacy bool) error {
+	opts := DefaultPDFOptions()
+	opts.UseLegacy = useLegacy
+	return ExportToPDFWithOptions(tree, w, opts)
+}
+
+// ExportToPDFWithOptions exports a scene tree to PDF format with control over
+// the renderer and page layout. A nil opts uses DefaultPDFOptions().
+func ExportToPDFWithOptions(tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return ExportToPDFContext(context.Background(), tree, w, opts)
+}
+
+// ExportToPDFContext is like ExportToPDFWithOptions, but stops and returns
+// the context's error once ctx is done, killing any Inkscape, rsvg-convert or
+// Ghostscript process it started
+func ExportToPDFContext(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	if opts == nil {
+		opts = DefaultPDFOptions()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Render as usual first, then replace the dates and identifiers taken
+	// from the clock
+	if opts.Deterministic {
+		plain := *opts
+		plain.Deterministic = false
+		pdfBuf := &bytes.Buffer{}
+		if err := ExportToPDFContext(ctx, tree, pdfBuf, &plain); err != nil {
+			return err
+		}
+		return deterministicPDF(pdfBuf.Bytes(), w, opts.Metadata)
+	}
+
+	// Render a plain PDF first, then convert it to the requested profile
+	if opts.Profile != PDFProfileDefault {
+		plain := *opts
+		plain.Profile = PDFProfileDefault
+		pdfBuf := &bytes.Buffer{}
+		if err := ExportToPDFContext(ctx, tree, pdfBuf, &plain); err != nil {
+			return err
+		}
+		return convertPDFProfile(ctx, pdfBuf.Bytes(), w, opts.Profile, opts.TempDir)
+	}
+
+	// Render without metadata first, then append it
+	if !opts.Metadata.IsZero() {
+		plain := *opts
+		plain.Metadata = PDFMetadata{}
+		pdfBuf := &bytes.Buffer{}
+		if err := ExportToPDFContext(ctx, tree, pdfBuf, &plain); err != nil {
+			return err
+		}
+		return addPDFMetadata(pdfBuf.Bytes(), w, opts.Metadata)
+	}
+
+	// A page placed on a sheet is a multipage PDF of one page
+	if opts.imposed() {
+		return ExportToMultipagePDFContext(ctx, []*parser.SceneTree{tree}, w, opts)
+	}
+
 	// Use legacy Inkscape renderer if requested
-	if useLegacy {
-		return exportToPDFInkscape(tree, w)
+	if opts.UseLegacy {
+		return exportViaSVG(ctx, tree, w, opts, "pdf")
 	}
 
 	// Otherwise use native Cairo-based export (default)
-	return ExportToPDFCairo(tree, w)
+	return exportToPDFCairo(ctx, tree, w, opts)
 }
 
-// exportToPDFInkscape exports a scene tree to PDF format via SVG conversion using Inkscape
-func exportToPDFInkscape(tree *parser.SceneTree, w io.Writer) error {
+// exportViaSVG exports a scene tree as PDF or EPS (ext "pdf" or "eps") by
+// converting its SVG with Inkscape or rsvg-convert
+func exportViaSVG(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions, ext string) error {
+	name := strings.ToUpper(ext)
+	if err := requireTool(opts.converter()); err != nil {
+		return err
+	}
+
 	// Create temporary SVG
 	svgBuf := &bytes.Buffer{}
-	if err := ExportToSVG(tree, svgBuf); err != nil {
+	if err := ExportToSVGContext(ctx, tree, svgBuf, &opts.SVGOptions); err != nil {
 		return fmt.Errorf("failed to generate SVG: %w", err)
 	}
 
 	// Create temp files
-	svgFile, err := os.CreateTemp("", "rmc-*.svg")
+	svgFile, err := os.CreateTemp(opts.TempDir, "rmc-*.svg")
 	if err != nil {
 		return fmt.Errorf("failed to create temp SVG file: %w", err)
 	}
@@ -47,32 +214,30 @@ func exportToPDFInkscape(tree *parser.SceneTree, w io.Writer) error {
 	}
 	svgFile.Close()
 
-	pdfFile, err := os.CreateTemp("", "rmc-*.pdf")
+	outFile, err := os.CreateTemp(opts.TempDir, "rmc-*."+ext)
 	if err != nil {
-		return fmt.Errorf("failed to create temp PDF file: %w", err)
+		return fmt.Errorf("failed to create temp %s file: %w", name, err)
 	}
-	pdfName := pdfFile.Name()
-	pdfFile.Close()
-	// Remove PDF temp file after we're done reading it
-	defer os.Remove(pdfName)
+	outName := outFile.Name()
+	outFile.Close()
+	// Remove output temp file after we're done reading it
+	defer os.Remove(outName)
 
-	// Convert with inkscape
-	cmd := exec.Command("inkscape", svgFile.Name(), "--export-filename", pdfName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("inkscape conversion failed: %w\n"+
-			"  Ensure 'inkscape' is installed and available in PATH\n"+
-			"  Install: https://inkscape.org/release/\n"+
-			"  Or use SVG output with: -t svg", err)
+	if err := opts.convertSVG(ctx, svgFile.Name(), outName, ext); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w\n  Or use SVG output with: -t svg", err)
 	}
 
-	// Read and write PDF
-	pdfData, err := os.ReadFile(pdfName)
+	// Read and write output
+	data, err := os.ReadFile(outName)
 	if err != nil {
-		return fmt.Errorf("failed to read PDF: %w", err)
+		return fmt.Errorf("failed to read %s: %w", name, err)
 	}
 
-	if _, err := w.Write(pdfData); err != nil {
-		return fmt.Errorf("failed to write PDF: %w", err)
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
 	}
 
 	return nil
@@ -81,23 +246,102 @@ func exportToPDFInkscape(tree *parser.SceneTree, w io.Writer) error {
 // ExportToMultipagePDF exports multiple scene trees to a multipage PDF format
 // If useLegacy is true, uses Inkscape via SVG conversion. Otherwise uses Cairo directly (default).
 func ExportToMultipagePDF(trees []*parser.SceneTree, w io.Writer, useLegacy bool) error {
+	opts := DefaultPDFOptions()
+	opts.UseLegacy = useLegacy
+	return ExportToMultipagePDFWithOptions(trees, w, opts)
+}
+
+// ExportToMultipagePDFWithOptions exports multiple scene trees to a multipage PDF
+// with control over the renderer and page layout. A nil opts uses DefaultPDFOptions().
+// Set a fixed page size in opts to get uniformly sized pages.
+func ExportToMultipagePDFWithOptions(trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return ExportToMultipagePDFContext(context.Background(), trees, w, opts)
+}
+
+// ExportToMultipagePDFContext is like ExportToMultipagePDFWithOptions, but
+// stops between pages and returns the context's error once ctx is done,
+// killing any external converter or merge tool it started
+func ExportToMultipagePDFContext(ctx context.Context, trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
 	if len(trees) == 0 {
 		return fmt.Errorf("no scene trees provided")
 	}
+	if opts == nil {
+		opts = DefaultPDFOptions()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	// Use legacy Inkscape renderer if requested
-	if useLegacy {
-		return exportToMultipagePDFInkscape(trees, w)
+	// Render as usual first, then replace the dates and identifiers taken
+	// from the clock
+	if opts.Deterministic {
+		plain := *opts
+		plain.Deterministic = false
+		pdfBuf := &bytes.Buffer{}
+		if err := ExportToMultipagePDFContext(ctx, trees, pdfBuf, &plain); err != nil {
+			return err
+		}
+		return deterministicPDF(pdfBuf.Bytes(), w, opts.Metadata)
+	}
+
+	// Render a plain PDF first, then convert it to the requested profile
+	if opts.Profile != PDFProfileDefault {
+		plain := *opts
+		plain.Profile = PDFProfileDefault
+		pdfBuf := &bytes.Buffer{}
+		if err := ExportToMultipagePDFContext(ctx, trees, pdfBuf, &plain); err != nil {
+			return err
+		}
+		return convertPDFProfile(ctx, pdfBuf.Bytes(), w, opts.Profile, opts.TempDir)
+	}
+
+	// Render without metadata first, then append it
+	if !opts.Metadata.IsZero() {
+		plain := *opts
+		plain.Metadata = PDFMetadata{}
+		pdfBuf := &bytes.Buffer{}
+		if err := ExportToMultipagePDFContext(ctx, trees, pdfBuf, &plain); err != nil {
+			return err
+		}
+		return addPDFMetadata(pdfBuf.Bytes(), w, opts.Metadata)
+	}
+
+	// Use legacy SVG conversion if requested
+	if opts.UseLegacy {
+		if opts.imposed() {
+			return fmt.Errorf("n-up and booklet layouts require the Cairo renderer")
+		}
+		if opts.Contents {
+			return fmt.Errorf("a table of contents requires the Cairo renderer")
+		}
+		return exportToMultipagePDFViaSVG(ctx, trees, w, opts)
 	}
 
 	// Otherwise use native Cairo-based export (default)
-	return ExportToMultipagePDFCairo(trees, w)
+	return exportToMultipagePDFCairo(ctx, trees, w, opts)
 }
 
-// exportToMultipagePDFInkscape exports multiple scene trees to a multipage PDF via SVG conversion using Inkscape
-func exportToMultipagePDFInkscape(trees []*parser.SceneTree, w io.Writer) error {
+// exportToMultipagePDFViaSVG exports multiple scene trees to a multipage PDF
+// by converting each page's SVG with Inkscape or rsvg-convert
+func exportToMultipagePDFViaSVG(ctx context.Context, trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	// Check the tool chain before converting any pages
+	if err := requireTool(opts.converter()); err != nil {
+		return err
+	}
+	tools := opts.mergeTools()
+	switch tools[0] {
+	case MergeToolPdfunite:
+		if err := requireTool("pdfunite", pdfuniteHint); err != nil {
+			return err
+		}
+	case MergeToolGhostscript:
+		if err := requireTool("gs", ghostscriptHint); err != nil {
+			return err
+		}
+	}
+
 	// Create temporary directory for intermediate files
-	tempDir, err := os.MkdirTemp("", "rmc-multipage-*")
+	tempDir, err := os.MkdirTemp(opts.TempDir, "rmc-multipage-*")
 	if err != nil {
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
@@ -106,9 +350,11 @@ func exportToMultipagePDFInkscape(trees []*parser.SceneTree, w io.Writer) error
 	// Generate SVG and PDF for each page
 	var pdfFiles []string
 	for i, tree := range trees {
+		opts.reportProgress(i+1, len(trees), StageRender)
+
 		// Generate SVG
 		svgBuf := &bytes.Buffer{}
-		if err := ExportToSVG(tree, svgBuf); err != nil {
+		if err := ExportToSVGContext(ctx, tree, svgBuf, &opts.SVGOptions); err != nil {
 			return fmt.Errorf("failed to generate SVG for page %d: %w", i+1, err)
 		}
 
@@ -118,53 +364,94 @@ func exportToMultipagePDFInkscape(trees []*parser.SceneTree, w io.Writer) error
 			return fmt.Errorf("failed to write temp SVG for page %d: %w", i+1, err)
 		}
 
-		// Convert SVG to PDF using Inkscape
+		// Convert SVG to PDF
 		pdfPath := filepath.Join(tempDir, fmt.Sprintf("page_%03d.pdf", i))
-		cmd := exec.Command("inkscape", svgPath, "--export-filename", pdfPath)
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("inkscape conversion failed for page %d: %w\n"+
-				"  Ensure 'inkscape' is installed and available in PATH\n"+
-				"  Install: https://inkscape.org/release/", i+1, err)
+		if err := opts.convertSVG(ctx, svgPath, pdfPath, "pdf"); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("page %d: %w", i+1, err)
 		}
 
 		pdfFiles = append(pdfFiles, pdfPath)
 	}
 
-	// Merge PDFs using pdfunite (part of poppler-utils)
-	// Alternative: gs (Ghostscript) if pdfunite is not available
-	outputPdfPath := filepath.Join(tempDir, "output.pdf")
+	// Merge the pages, falling back to the next tool if one fails
+	opts.reportProgress(len(trees), len(trees), StageMerge)
+	var titles []string
+	if opts.Outline {
+		titles = pageTitles(trees, opts)
+	}
+	var pdfData []byte
+	for _, tool := range tools {
+		pdfData, err = mergePDFs(ctx, tool, pdfFiles, titles, tempDir)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(pdfData); err != nil {
+		return fmt.Errorf("failed to write PDF output: %w", err)
+	}
 
-	// Try pdfunite first
-	args := append([]string{}, pdfFiles...)
-	args = append(args, outputPdfPath)
-	cmd := exec.Command("pdfunite", args...)
-	err = cmd.Run()
+	return nil
+}
 
-	if err != nil {
-		// Try Ghostscript as fallback
-		gsArgs := []string{
-			"-dBATCH", "-dNOPAUSE", "-q", "-sDEVICE=pdfwrite",
-			"-sOutputFile=" + outputPdfPath,
+// pageTitles returns the bookmark title for every page
+func pageTitles(trees []*parser.SceneTree, opts *PDFOptions) []string {
+	titles := make([]string, len(trees))
+	for i, tree := range trees {
+		if i < len(opts.PageTitles) && opts.PageTitles[i] != "" {
+			titles[i] = opts.PageTitles[i]
+			continue
 		}
-		gsArgs = append(gsArgs, pdfFiles...)
-		cmd = exec.Command("gs", gsArgs...)
-		if err = cmd.Run(); err != nil {
-			return fmt.Errorf("PDF merging failed (install pdfunite or ghostscript): %w\n"+
-				"  Ubuntu/Debian: sudo apt-get install poppler-utils\n"+
-				"  macOS: brew install poppler\n"+
-				"  Or: brew install ghostscript", err)
+		titles[i] = PageHeading(tree)
+		if titles[i] == "" {
+			titles[i] = fmt.Sprintf("Page %d", i+1)
 		}
 	}
+	return titles
+}
+
+// PageHeading returns the text of the first heading paragraph on a page,
+// or an empty string if the page has no heading
+func PageHeading(tree *parser.SceneTree) string {
+	if tree == nil || tree.RootText == nil {
+		return ""
+	}
 
-	// Read merged PDF and write to output
-	pdfData, err := os.ReadFile(outputPdfPath)
+	doc, err := parser.BuildTextDocument(tree.RootText)
 	if err != nil {
-		return fmt.Errorf("failed to read merged PDF: %w", err)
+		return ""
 	}
 
-	if _, err := w.Write(pdfData); err != nil {
-		return fmt.Errorf("failed to write PDF output: %w", err)
+	for _, p := range doc.Paragraphs {
+		if p.Style == parser.StyleHeading && strings.TrimSpace(p.Text) != "" {
+			return strings.TrimSpace(p.Text)
+		}
 	}
+	return ""
+}
 
-	return nil
+// buildPdfmarks creates a Ghostscript pdfmark program with one bookmark per page
+func buildPdfmarks(titles []string) []byte {
+	var buf bytes.Buffer
+	for i, title := range titles {
+		fmt.Fprintf(&buf, "[/Title <%s> /Page %d /OUT pdfmark\n", utf16BEHex(title), i+1)
+	}
+	return buf.Bytes()
+}
+
+// utf16BEHex encodes a string as a hex PDF text string (UTF-16BE with BOM)
+// so that non-ASCII titles survive
+func utf16BEHex(s string) string {
+	var sb strings.Builder
+	sb.WriteString("FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&sb, "%04X", u)
+	}
+	return sb.String()
 }
@@ -11,20 +11,28 @@ package export
 import "C"
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math"
-	"os"
+	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/joagonca/rmc-go/parser"
 	"github.com/ungerik/go-cairo"
 )
 
-// pageDimensions holds the calculated dimensions and anchor positions for a page
+// CairoVersion returns the version of the linked Cairo library and whether
+// the binary was built with Cairo support
+func CairoVersion() (string, bool) {
+	return C.GoString(C.cairo_version_string()), true
+}
+
+// pageDimensions holds the calculated layout and anchor positions for a page
 type pageDimensions struct {
 	width, height float64
-	xMin, yMin    float64
+	layout        pageLayout
 	anchorPos     map[parser.CrdtID]float64
 }
 
@@ -35,372 +43,615 @@ func setPDFPageSize(surface *cairo.Surface, width, height float64) {
 	C.cairo_pdf_surface_set_size((*C.cairo_surface_t)(unsafe.Pointer(surfacePtr)), C.double(width), C.double(height))
 }
 
-// calculatePageDimensions computes the bounding box and dimensions for a scene tree
-func calculatePageDimensions(tree *parser.SceneTree) (pageDimensions, error) {
-	if tree == nil || tree.Root == nil {
-		return pageDimensions{}, fmt.Errorf("scene tree or root cannot be nil")
-	}
+// addPDFOutline adds a top-level bookmark pointing at the given 1-based page
+// This wraps the cairo_pdf_surface_add_outline C function (cairo >= 1.16) that isn't exposed in go-cairo
+func addPDFOutline(surface *cairo.Surface, title string, page int) {
+	surfacePtr, _ := surface.Native()
 
-	// Build anchor positions (including text-based anchors)
-	anchorPos := buildAnchorPos(tree.RootText)
+	cTitle := C.CString(title)
+	defer C.free(unsafe.Pointer(cTitle))
+	cLink := C.CString(fmt.Sprintf("page=%d", page))
+	defer C.free(unsafe.Pointer(cLink))
 
-	// Calculate bounding box using the anchor positions
-	xMin, xMax, yMin, yMax := getBoundingBox(tree.Root, anchorPos)
-
-	// Include text area in bounding box calculation
-	if tree.RootText != nil {
-		textMinX := tree.RootText.PosX
-		textMaxX := tree.RootText.PosX + float64(tree.RootText.Width)
-
-		// Calculate text Y range by going through all paragraphs
-		doc, err := parser.BuildTextDocument(tree.RootText)
-		if err == nil && len(doc.Paragraphs) > 0 {
-			yOffset := TextTopY
-			textMinY := math.MaxFloat64
-			textMaxY := -math.MaxFloat64
-
-			for _, p := range doc.Paragraphs {
-				lineHeight := lineHeights[p.Style]
-				if lineHeight == 0 {
-					lineHeight = 70
-				}
-				yOffset += lineHeight
-				yPos := tree.RootText.PosY + yOffset
-
-				textMinY = math.Min(textMinY, yPos)
-				textMaxY = math.Max(textMaxY, yPos)
-			}
+	C.cairo_pdf_surface_add_outline((*C.cairo_surface_t)(unsafe.Pointer(surfacePtr)),
+		C.CAIRO_PDF_OUTLINE_ROOT, cTitle, cLink, C.cairo_pdf_outline_flags_t(0))
+}
 
-			xMin = math.Min(xMin, textMinX)
-			xMax = math.Max(xMax, textMaxX)
-			yMin = math.Min(yMin, textMinY)
-			yMax = math.Max(yMax, textMaxY)
-		}
-	}
+// cairoTagLink is the name of Cairo's link tag (CAIRO_TAG_LINK)
+const cairoTagLink = "Link"
 
-	width := scale(xMax - xMin + 1)
-	height := scale(yMax - yMin + 1)
+// cairoTagDest is the name of Cairo's destination tag (CAIRO_TAG_DEST)
+const cairoTagDest = "cairo.dest"
 
-	return pageDimensions{
-		width:     width,
-		height:    height,
-		xMin:      xMin,
-		yMin:      yMin,
-		anchorPos: anchorPos,
-	}, nil
+// pageDestName returns the name of the destination of a 1-based page
+func pageDestName(page int) string {
+	return fmt.Sprintf("page-%d", page)
 }
 
-// renderPageToCairo renders a scene tree to a Cairo surface
-func renderPageToCairo(tree *parser.SceneTree, surface *cairo.Surface, dims pageDimensions) error {
-	// Set up coordinate system
-	surface.Save()
-	defer surface.Restore()
+// addPageDestCairo adds the named destination of the given 1-based page at
+// the origin of the current user space, which links to the page point at.
+// This wraps cairo_tag_begin (cairo >= 1.16), which isn't exposed in go-cairo.
+func addPageDestCairo(surface *cairo.Surface, page int) {
+	_, contextPtr := surface.Native()
+	cr := (*C.cairo_t)(unsafe.Pointer(contextPtr))
+
+	x, y := surface.UserToDevice(0, 0)
+	cName := C.CString(cairoTagDest)
+	defer C.free(unsafe.Pointer(cName))
+	cAttrs := C.CString(fmt.Sprintf("name='%s' x=%g y=%g", pageDestName(page), x, y))
+	defer C.free(unsafe.Pointer(cAttrs))
+
+	C.cairo_tag_begin(cr, cName, cAttrs)
+	C.cairo_tag_end(cr, cName)
+}
 
-	surface.Translate(-scale(dims.xMin), -scale(dims.yMin))
+// beginLinkCairo starts a link to a web address around the text drawn until
+// endLinkCairo. PDF surfaces turn it into a link annotation; other surfaces
+// ignore it.
+// This wraps cairo_tag_begin (cairo >= 1.16), which isn't exposed in go-cairo.
+func beginLinkCairo(surface *cairo.Surface, url string) {
+	_, contextPtr := surface.Native()
+
+	// Single quotes and backslashes are escaped in tag attribute strings
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(url)
+	cName := C.CString(cairoTagLink)
+	defer C.free(unsafe.Pointer(cName))
+	cAttrs := C.CString(fmt.Sprintf("uri='%s'", escaped))
+	defer C.free(unsafe.Pointer(cAttrs))
+
+	C.cairo_tag_begin((*C.cairo_t)(unsafe.Pointer(contextPtr)), cName, cAttrs)
+}
 
-	// Draw text first (if it exists)
-	if tree.RootText != nil {
-		if err := drawTextCairo(tree.RootText, surface); err != nil {
-			return fmt.Errorf("failed to draw root text: %w", err)
-		}
-	}
+// beginPageLinkCairo starts a link to the given 1-based page of the PDF
+// around the text drawn until endLinkCairo. The page must have a destination
+// added by addPageDestCairo, which may come later in the document.
+// This wraps cairo_tag_begin (cairo >= 1.16), which isn't exposed in go-cairo.
+func beginPageLinkCairo(surface *cairo.Surface, page int) {
+	_, contextPtr := surface.Native()
 
-	// Draw strokes/groups
-	if err := drawGroupCairo(tree.Root, surface, dims.anchorPos); err != nil {
-		return fmt.Errorf("failed to draw group: %w", err)
-	}
+	cName := C.CString(cairoTagLink)
+	defer C.free(unsafe.Pointer(cName))
+	cAttrs := C.CString(fmt.Sprintf("dest='%s'", pageDestName(page)))
+	defer C.free(unsafe.Pointer(cAttrs))
 
-	return nil
+	C.cairo_tag_begin((*C.cairo_t)(unsafe.Pointer(contextPtr)), cName, cAttrs)
 }
 
-// ExportToPDFCairo exports a scene tree directly to PDF using Cairo
-func ExportToPDFCairo(tree *parser.SceneTree, w io.Writer) error {
-	// Calculate page dimensions
-	dims, err := calculatePageDimensions(tree)
+// endLinkCairo ends the link started by beginLinkCairo or beginPageLinkCairo
+func endLinkCairo(surface *cairo.Surface) {
+	_, contextPtr := surface.Native()
+	cName := C.CString(cairoTagLink)
+	defer C.free(unsafe.Pointer(cName))
+	C.cairo_tag_end((*C.cairo_t)(unsafe.Pointer(contextPtr)), cName)
+}
+
+// linkPageCairo makes a rectangle of the current page a link to the given
+// 1-based page of the PDF, which may come later in the document.
+// This wraps cairo_tag_begin (cairo >= 1.16), which isn't exposed in go-cairo.
+func linkPageCairo(surface *cairo.Surface, page int, x, y, width, height float64) {
+	_, contextPtr := surface.Native()
+	cr := (*C.cairo_t)(unsafe.Pointer(contextPtr))
+
+	cName := C.CString(cairoTagLink)
+	defer C.free(unsafe.Pointer(cName))
+	cAttrs := C.CString(fmt.Sprintf("rect=[%g %g %g %g] page=%d", x, y, width, height, page))
+	defer C.free(unsafe.Pointer(cAttrs))
+
+	C.cairo_tag_begin(cr, cName, cAttrs)
+	C.cairo_tag_end(cr, cName)
+}
+
+// cairoFonts holds fonts loaded from disk so Cairo embeds them in the PDF
+type cairoFonts struct {
+	ft      cairo.Cairo_freetype
+	regular *cairo.FontFace
+}
+
+// loadCairoFonts loads the font used for the selectable text layer.
+// Without a text layer the system fonts are selected by name instead.
+func loadCairoFonts(opts *PDFOptions) (*cairoFonts, error) {
+	fonts := &cairoFonts{}
+	if !opts.TextLayer {
+		return fonts, nil
+	}
+
+	path := opts.FontFile
+	if path == "" {
+		path = findSystemFont()
+	}
+	if path == "" {
+		// Fall back to the fonts Cairo picks by name
+		return fonts, nil
+	}
+
+	ft, err := cairo.InitFreeType()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to initialize FreeType: %w", err)
 	}
+	fonts.ft = ft
 
-	// Create a temporary file for PDF output
-	// Cairo requires a file path, so we write to temp and then copy
-	tmpFile, err := os.CreateTemp("", "rmc-cairo-*.pdf")
+	face, err := ft.FtNewFace(path)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		ft.DoneFreeType()
+		return nil, fmt.Errorf("failed to load font %s: %w", path, err)
 	}
-	tmpPath := tmpFile.Name()
-	tmpFile.Close()
-	defer os.Remove(tmpPath)
+	fonts.regular = face
 
-	// Create a Cairo PDF surface with the temp file
-	pdfSurface := cairo.NewPDFSurface(tmpPath, dims.width, dims.height, cairo.PDF_VERSION_1_5)
-	defer pdfSurface.Finish()
+	return fonts, nil
+}
 
-	// Render the page
-	if err := renderPageToCairo(tree, pdfSurface, dims); err != nil {
-		return err
+// close releases the loaded fonts. Must be called after the surface is finished.
+func (f *cairoFonts) close() {
+	if f.regular != nil {
+		f.regular.FtDoneFace()
+		f.regular = nil
+		f.ft.DoneFreeType()
 	}
+}
 
-	// Finish the surface to flush all drawing operations
-	pdfSurface.Finish()
+// calculatePageDimensions computes the page layout for a scene tree
+func calculatePageDimensions(tree *parser.SceneTree, opts *SVGOptions) (pageDimensions, error) {
+	if tree == nil || tree.Root == nil {
+		return pageDimensions{}, fmt.Errorf("scene tree or root cannot be nil")
+	}
 
-	// Read the temporary PDF file and write to the output
-	pdfData, err := os.ReadFile(tmpPath)
-	if err != nil {
-		return fmt.Errorf("failed to read generated PDF: %w", err)
+	// Build anchor positions (including text-based anchors)
+	anchorPos := buildAnchorPos(tree.RootText)
+
+	// Calculate the page layout using the anchor positions, including text
+	layout := computePageLayout(tree, anchorPos, opts)
+
+	return pageDimensions{
+		width:     layout.width,
+		height:    layout.height,
+		layout:    layout,
+		anchorPos: anchorPos,
+	}, nil
+}
+
+// renderPageToCairo renders a scene tree to a Cairo surface as the given
+// 1-based page of a document of pages, all of which get a destination that
+// links to pages point at. Both are zero outside documents of pages, such as
+// contact sheets.
+func renderPageToCairo(ctx context.Context, tree *parser.SceneTree, surface *cairo.Surface, dims pageDimensions, opts *SVGOptions, fonts *cairoFonts, page, pages int) error {
+	r := &cairoRenderer{surface: surface, fonts: fonts, page: page, pages: pages}
+	return renderPage(ctx, tree, r, dims.layout, dims.anchorPos, opts)
+}
+
+// cairoRenderer draws a page on a Cairo surface
+type cairoRenderer struct {
+	surface *cairo.Surface
+	fonts   *cairoFonts
+	page    int // Number of the page in the document, if it has a destination
+	pages   int // Number of pages with a destination in the document
+}
+
+func (r *cairoRenderer) BeginPage(page Page) error {
+	surface := r.surface
+	if r.page > 0 {
+		addPageDestCairo(surface, r.page)
 	}
+	surface.Save()
 
-	if _, err := w.Write(pdfData); err != nil {
-		return fmt.Errorf("failed to write PDF output: %w", err)
+	// Fill the whole page before any transform is applied
+	if bg, ok := parseCSSColor(page.Background); ok {
+		surface.SetSourceRGB(float64(bg.R)/255.0, float64(bg.G)/255.0, float64(bg.B)/255.0)
+		surface.Rectangle(0, 0, page.Width, page.Height)
+		surface.Fill()
+	}
+
+	// Fit the content region onto the page
+	factor, offsetX, offsetY := page.Fit()
+	surface.Translate(offsetX, offsetY)
+	surface.Scale(factor, factor)
+	surface.Translate(-page.View.X, -page.View.Y)
+	if page.Rotated {
+		surface.Rotate(math.Pi / 2)
 	}
+	return nil
+}
 
+func (r *cairoRenderer) EndPage() error {
+	r.surface.Restore()
 	return nil
 }
 
-func drawGroupCairo(group *parser.Group, surface *cairo.Surface, anchorPos map[parser.CrdtID]float64) error {
-	surface.Save()
+func (r *cairoRenderer) BeginGroup(group Group) error {
+	r.surface.Save()
+	r.surface.Translate(group.X, group.Y)
+	return nil
+}
 
-	anchorX, anchorY := getAnchor(group, anchorPos)
-	surface.Translate(scale(anchorX), scale(anchorY))
+func (r *cairoRenderer) EndGroup() error {
+	r.surface.Restore()
+	return nil
+}
 
-	if group.Children != nil {
-		for _, item := range group.Children.Items {
-			if item.Value == nil {
-				continue
-			}
+func (r *cairoRenderer) DrawStroke(stroke Stroke) error {
+	surface := r.surface
+	if stroke.Multiply {
+		// Let highlights tint what is underneath instead of covering it
+		surface.SetOperator(cairo.OPERATOR_MULTIPLY)
+		defer surface.SetOperator(cairo.OPERATOR_OVER)
+	}
 
-			switch v := item.Value.(type) {
-			case *parser.Group:
-				if err := drawGroupCairo(v, surface, anchorPos); err != nil {
-					return err
-				}
-			case *parser.Line:
-				drawStrokeCairo(v, surface)
-			case *parser.Text:
-				if err := drawTextCairo(v, surface); err != nil {
-					return err
-				}
+	switch stroke.Cap {
+	case "round":
+		surface.SetLineCap(cairo.LINE_CAP_ROUND)
+	case "square":
+		surface.SetLineCap(cairo.LINE_CAP_SQUARE)
+	default:
+		surface.SetLineCap(cairo.LINE_CAP_BUTT)
+	}
+	surface.SetLineJoin(cairo.LINE_JOIN_ROUND)
+	surface.SetFillRule(cairo.FILL_RULE_WINDING)
+
+	for _, segment := range stroke.Segments {
+		surface.SetSourceRGBA(
+			float64(segment.Color.R)/255.0,
+			float64(segment.Color.G)/255.0,
+			float64(segment.Color.B)/255.0,
+			segment.Opacity,
+		)
+
+		for _, e := range segment.Path {
+			p := e.Points
+			switch e.Op {
+			case PathMoveTo:
+				surface.MoveTo(p[0].X, p[0].Y)
+			case PathLineTo:
+				surface.LineTo(p[0].X, p[0].Y)
+			case PathCurveTo:
+				surface.CurveTo(p[0].X, p[0].Y, p[1].X, p[1].Y, p[2].X, p[2].Y)
+			case PathClose:
+				surface.ClosePath()
+			case PathCircle:
+				surface.NewSubPath()
+				surface.Arc(p[0].X, p[0].Y, e.Radius, 0, 2*math.Pi)
+				surface.ClosePath()
 			}
 		}
-	}
 
-	surface.Restore()
+		if segment.Fill {
+			surface.Fill()
+		} else {
+			surface.SetLineWidth(segment.Width)
+			surface.Stroke()
+		}
+	}
 	return nil
 }
 
-func drawStrokeCairo(line *parser.Line, surface *cairo.Surface) {
-	pen := createPen(line.Tool, line.Color, line.ColorOverride, line.ThicknessScale)
-
-	lastSegmentWidth := 0.0
+func (r *cairoRenderer) DrawText(text Text) error {
+	surface := r.surface
+	if len(text.Words) > 0 {
+		drawRecognizedTextCairo(text.Words, surface)
+		return nil
+	}
 
-	for i, point := range line.Points {
-		xPos := float64(point.X)
-		yPos := float64(point.Y)
+	// Set text color (black)
+	surface.SetSourceRGB(0, 0, 0)
+	for _, p := range text.Paragraphs {
+		// Draw prefix followed by each span; ShowText advances the current point
+		surface.MoveTo(p.X, p.Y)
+		if p.Prefix != "" {
+			setTextFontCairo(surface, p.Style, false, false, r.fonts)
+			surface.ShowText(p.Prefix)
+		}
+		for _, span := range p.Spans {
+			setTextFontCairo(surface, p.Style, span.Bold, span.Italic, r.fonts)
+			linked := r.beginLink(span.Link)
+			surface.ShowText(span.Text)
+			if linked {
+				endLinkCairo(surface)
+			}
+		}
+	}
+	return nil
+}
 
-		if i%pen.segmentLength == 0 {
-			// Start new segment with updated properties
-			segmentColor := pen.getSegmentColorRGB(point, lastSegmentWidth)
-			segmentWidth := pen.getSegmentWidth(point, lastSegmentWidth)
-			segmentOpacity := pen.getSegmentOpacity(point, lastSegmentWidth)
+// beginLink starts a link to a web address or a page of the document, and
+// reports whether it did. Links to pages without a destination in the
+// document are left out, as Cairo fails on them.
+func (r *cairoRenderer) beginLink(link string) bool {
+	if link == "" {
+		return false
+	}
+	if page, ok := linkedPage(link); ok {
+		if page > r.pages {
+			return false
+		}
+		beginPageLinkCairo(r.surface, page)
+		return true
+	}
+	beginLinkCairo(r.surface, link)
+	return true
+}
 
-			// Set color with opacity
-			surface.SetSourceRGBA(
-				float64(segmentColor.R)/255.0,
-				float64(segmentColor.G)/255.0,
-				float64(segmentColor.B)/255.0,
-				segmentOpacity,
-			)
+// ExportToPDFCairo exports a scene tree directly to PDF using Cairo
+func ExportToPDFCairo(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToPDFCairoWithOptions(tree, w, nil)
+}
 
-			// Set line width
-			surface.SetLineWidth(scale(segmentWidth))
+// ExportToPDFCairoWithOptions exports a scene tree directly to PDF using Cairo
+// with the given page layout options. A nil opts uses DefaultPDFOptions().
+func ExportToPDFCairoWithOptions(tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return exportToPDFCairo(context.Background(), tree, w, opts)
+}
 
-			// Set line cap
-			if pen.strokeLinecap == "round" {
-				surface.SetLineCap(cairo.LINE_CAP_ROUND)
-			} else if pen.strokeLinecap == "square" {
-				surface.SetLineCap(cairo.LINE_CAP_SQUARE)
-			} else {
-				surface.SetLineCap(cairo.LINE_CAP_BUTT)
-			}
+// exportToPDFCairo exports a scene tree to PDF using Cairo, passing ctx to
+// the recognizer
+func exportToPDFCairo(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return exportPageCairo(ctx, tree, w, opts, newPDFStreamSurface)
+}
 
-			surface.SetLineJoin(cairo.LINE_JOIN_ROUND)
+// ExportToEPSCairo exports a scene tree directly to Encapsulated PostScript
+// using Cairo. A nil opts uses DefaultPDFOptions().
+func ExportToEPSCairo(tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return exportToEPSCairo(context.Background(), tree, w, opts)
+}
 
-			// Start new path
-			if i == 0 {
-				surface.MoveTo(scale(xPos), scale(yPos))
-			}
+// exportToEPSCairo exports a scene tree to EPS using Cairo, passing ctx to
+// the recognizer
+func exportToEPSCairo(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return exportPageCairo(ctx, tree, w, opts, newEPSStreamSurface)
+}
 
-			lastSegmentWidth = segmentWidth
-		}
+// ExportToPNGCairo rasterizes a scene tree to PNG using a Cairo image surface.
+// A nil opts uses DefaultPNGOptions().
+func ExportToPNGCairo(tree *parser.SceneTree, w io.Writer, opts *PNGOptions) error {
+	if opts == nil {
+		opts = DefaultPNGOptions()
+	}
+	svgOpts, pixelScale := rasterOptions(opts)
+	dims, err := calculatePageDimensions(tree, &svgOpts)
+	if err != nil {
+		return err
+	}
 
-		if i > 0 {
-			surface.LineTo(scale(xPos), scale(yPos))
-		}
+	width := int(math.Ceil(dims.width*pixelScale - pixelEpsilon))
+	height := int(math.Ceil(dims.height*pixelScale - pixelEpsilon))
+	surface := cairo.NewSurface(cairo.FORMAT_ARGB32, width, height)
+	defer surface.Finish()
 
-		// Stroke at segment boundaries
-		if i > 0 && (i+1)%pen.segmentLength == 0 {
-			surface.Stroke()
-			// Move to current position to continue
-			surface.MoveTo(scale(xPos), scale(yPos))
-		}
+	surface.Scale(pixelScale, pixelScale)
+	if err := renderPageToCairo(context.Background(), tree, surface, dims, &svgOpts, &cairoFonts{}, 0, 0); err != nil {
+		return err
 	}
+	surface.Flush()
 
-	// Stroke any remaining path
-	surface.Stroke()
+	stream := newCairoStream(w)
+	defer stream.close()
+	if err := writePNGStream(surface, stream); err != nil {
+		return fmt.Errorf("failed to write PNG output: %w", err)
+	}
+	return nil
 }
 
-func drawTextCairo(text *parser.Text, surface *cairo.Surface) error {
-	// Convert text to TextDocument
-	doc, err := parser.BuildTextDocument(text)
-	if err != nil {
-		return fmt.Errorf("failed to build text document: %w", err)
+// renderReplayFrames draws the frames of a replay and passes them to frame
+func renderReplayFrames(tree *parser.SceneTree, opts *ReplayOptions, frame frameFunc) error {
+	svgOpts, pixelScale := rasterOptions(&opts.PNGOptions)
+	svgOpts.Animate = opts.Duration
+	if svgOpts.Background == "" {
+		svgOpts.Background = "white"
 	}
 
-	// Iterate through paragraphs
-	yOffset := TextTopY
-	bulletNumber := 1
-	for _, p := range doc.Paragraphs {
-		// Get line height for this style
-		lineHeight := lineHeights[p.Style]
-		if lineHeight == 0 {
-			lineHeight = 70
+	dims, err := calculatePageDimensions(tree, &svgOpts)
+	if err != nil {
+		return err
+	}
+	width := int(math.Ceil(dims.width*pixelScale - pixelEpsilon))
+	height := int(math.Ceil(dims.height*pixelScale - pixelEpsilon))
+
+	interval := time.Duration(float64(time.Second) / opts.FPS)
+	times := opts.frameTimes()
+	for i, at := range times {
+		surface := cairo.NewSurface(cairo.FORMAT_ARGB32, width, height)
+		surface.Scale(pixelScale, pixelScale)
+		svgOpts.replayAt = at
+		err := renderPageToCairo(context.Background(), tree, surface, dims, &svgOpts, &cairoFonts{}, 0, 0)
+		if err == nil {
+			surface.Flush()
+			delay := interval
+			if i == len(times)-1 {
+				delay += opts.Hold
+			}
+			err = frame(surface.GetImage(), delay)
+		}
+		surface.Finish()
+		if err != nil {
+			return err
 		}
-		yOffset += lineHeight
+	}
+	return nil
+}
 
-		// Calculate position
-		xPos := text.PosX
-		yPos := text.PosY + yOffset
+// exportPageCairo renders a single page to a Cairo surface created by
+// newSurface, which streams the output to w
+func exportPageCairo(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions,
+	newSurface func(stream *cairoStream, width, height float64) *cairo.Surface) error {
+	if opts == nil {
+		opts = DefaultPDFOptions()
+	}
 
-		// Skip empty lines
-		trimmedText := p.Text
-		if trimmedText == "" {
-			continue
-		}
+	// Calculate page dimensions
+	dims, err := calculatePageDimensions(tree, &opts.SVGOptions)
+	if err != nil {
+		return err
+	}
 
-		// Add appropriate prefix based on style
-		prefix := getParagraphPrefix(p.Style, &bulletNumber)
-		displayText := prefix + trimmedText
+	fonts, err := loadCairoFonts(opts)
+	if err != nil {
+		return err
+	}
+	defer fonts.close()
 
-		// Set font based on style
-		setTextFontCairo(surface, p.Style)
+	// Create a Cairo surface that writes to w
+	stream := newCairoStream(w)
+	defer stream.close()
+	surface := newSurface(stream, dims.width, dims.height)
+	defer surface.Finish()
 
-		// Set text color (black)
-		surface.SetSourceRGB(0, 0, 0)
+	// Render the page
+	if err := renderPageToCairo(ctx, tree, surface, dims, &opts.SVGOptions, fonts, 1, 1); err != nil {
+		return err
+	}
 
-		// Draw text
-		surface.MoveTo(scale(xPos), scale(yPos))
-		surface.ShowText(displayText)
+	// Finish the surface to write out all drawing operations
+	surface.Finish()
+	if err := stream.close(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
 	}
 
 	return nil
 }
 
-func setTextFontCairo(surface *cairo.Surface, style parser.ParagraphStyle) {
-	switch style {
-	case parser.StyleHeading:
-		surface.SelectFontFace("serif", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_NORMAL)
-		surface.SetFontSize(14.0)
-	case parser.StyleBold:
-		surface.SelectFontFace("sans-serif", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_BOLD)
-		surface.SetFontSize(8.0)
-	default:
-		surface.SelectFontFace("sans-serif", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_NORMAL)
-		surface.SetFontSize(7.0)
+// drawRecognizedTextCairo draws recognized words fully transparent so they
+// can be selected and searched without being visible
+func drawRecognizedTextCairo(words []TextWord, surface *cairo.Surface) {
+	surface.Save()
+	defer surface.Restore()
+
+	surface.SetSourceRGBA(0, 0, 0, 0)
+	surface.SelectFontFace("sans-serif", cairo.FONT_SLANT_NORMAL, cairo.FONT_WEIGHT_NORMAL)
+	for _, word := range words {
+		surface.SetFontSize(word.Size)
+		surface.MoveTo(word.X, word.Y)
+		surface.ShowText(word.Text)
+	}
+}
+
+func setTextFontCairo(surface *cairo.Surface, style parser.ParagraphStyle, bold, italic bool, fonts *cairoFonts) {
+	// The embedded font only has a regular face; bold and italic runs use the system fonts
+	family, size, boldStyle := paragraphFont(style)
+	if fonts.regular != nil && !bold && !italic && !boldStyle {
+		surface.SetFontFace(fonts.regular)
+		surface.SetFontSize(size)
+		return
 	}
+
+	slant := cairo.FONT_SLANT_NORMAL
+	if italic {
+		slant = cairo.FONT_SLANT_ITALIC
+	}
+	weight := cairo.FONT_WEIGHT_NORMAL
+	if bold || boldStyle {
+		weight = cairo.FONT_WEIGHT_BOLD
+	}
+
+	surface.SelectFontFace(family, slant, weight)
+	surface.SetFontSize(size)
 }
 
 // ExportToMultipagePDFCairo exports multiple scene trees directly to a multipage PDF using Cairo
 func ExportToMultipagePDFCairo(trees []*parser.SceneTree, w io.Writer) error {
+	return ExportToMultipagePDFCairoWithOptions(trees, w, nil)
+}
+
+// ExportToMultipagePDFCairoWithOptions exports multiple scene trees directly to a
+// multipage PDF using Cairo with the given options.
+// A nil opts uses DefaultPDFOptions().
+func ExportToMultipagePDFCairoWithOptions(trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return exportToMultipagePDFCairo(context.Background(), trees, w, opts)
+}
+
+// exportToMultipagePDFCairo renders a multipage PDF with Cairo. All pages are
+// drawn on a single PDF surface, so no external merge tool is needed. It
+// returns the context's error if ctx is done before all pages are rendered.
+func exportToMultipagePDFCairo(ctx context.Context, trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
 	if len(trees) == 0 {
 		return fmt.Errorf("no scene trees provided")
 	}
+	if opts == nil {
+		opts = DefaultPDFOptions()
+	}
+	if opts.imposed() {
+		if opts.Contents {
+			return fmt.Errorf("a table of contents cannot be combined with n-up or booklet layouts")
+		}
+		return exportNUpPDFCairo(ctx, trees, w, opts)
+	}
 
 	// Calculate dimensions for the first page to initialize the PDF surface
-	firstDims, err := calculatePageDimensions(trees[0])
+	firstDims, err := calculatePageDimensions(trees[0], &opts.SVGOptions)
 	if err != nil {
 		return fmt.Errorf("page 1: %w", err)
 	}
 
-	// Create a temporary file for PDF output
-	// Cairo requires a file path, so we write to temp and then copy
-	tmpFile, err := os.CreateTemp("", "rmc-cairo-multipage-*.pdf")
+	fonts, err := loadCairoFonts(opts)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return err
 	}
-	tmpPath := tmpFile.Name()
-	tmpFile.Close()
-	defer os.Remove(tmpPath)
+	defer fonts.close()
 
-	// Create PDF surface with first page dimensions
-	pdfSurface := cairo.NewPDFSurface(tmpPath, firstDims.width, firstDims.height, cairo.PDF_VERSION_1_5)
+	// Create a PDF surface that writes to w, with first page dimensions
+	stream := newCairoStream(w)
+	defer stream.close()
+	pdfSurface := newPDFStreamSurface(stream, firstDims.width, firstDims.height)
 	defer pdfSurface.Finish()
 
+	var titles []string
+	if opts.Outline {
+		titles = pageTitles(trees, opts)
+	}
+
+	// The table of contents comes first, so the pages follow it
+	first := 1
+	if opts.Contents {
+		layout := opts.contentsLayout(len(trees))
+		drawContentsCairo(pdfSurface, contentsEntries(trees, opts), layout)
+		if opts.Outline {
+			addPDFOutline(pdfSurface, contentsTitle, 1)
+		}
+		first += layout.pages
+	}
+
 	// Render each page
 	for pageIdx, tree := range trees {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts.reportProgress(pageIdx+1, len(trees), StageRender)
+
 		// Calculate dimensions for this page
 		var dims pageDimensions
-		if pageIdx == 0 {
+		if pageIdx == 0 && !opts.Contents {
 			dims = firstDims
 		} else {
-			dims, err = calculatePageDimensions(tree)
+			dims, err = calculatePageDimensions(tree, &opts.SVGOptions)
 			if err != nil {
 				return fmt.Errorf("page %d: %w", pageIdx+1, err)
 			}
-			// Set the page size for this page (pages after the first)
+			// Set the page size for this page (pages after the first or the contents)
 			setPDFPageSize(pdfSurface, dims.width, dims.height)
 		}
 
 		// Render the page
-		if err := renderPageToCairo(tree, pdfSurface, dims); err != nil {
+		if err := renderPageToCairo(ctx, tree, pdfSurface, dims, &opts.SVGOptions, fonts, pageIdx+1, len(trees)); err != nil {
 			return fmt.Errorf("page %d: %w", pageIdx+1, err)
 		}
 
+		// Bookmark the page
+		if opts.Outline {
+			addPDFOutline(pdfSurface, titles[pageIdx], first+pageIdx)
+		}
+
 		// Show the page (this finalizes the current page and prepares for next)
 		if pageIdx < len(trees)-1 {
 			pdfSurface.ShowPage()
 		}
-	}
 
-	// Finish the surface to flush all drawing operations
-	pdfSurface.Finish()
-
-	// Read the temporary PDF file and write to the output
-	pdfData, err := os.ReadFile(tmpPath)
-	if err != nil {
-		return fmt.Errorf("failed to read generated PDF: %w", err)
+		// Stop at the first drawing error rather than emitting a broken document
+		if status := pdfSurface.Status(); status != cairo.STATUS_SUCCESS {
+			return fmt.Errorf("page %d: cairo error: %s", pageIdx+1, status)
+		}
 	}
 
-	if _, err := w.Write(pdfData); err != nil {
+	// Finish the surface to write out all drawing operations
+	pdfSurface.Finish()
+	if err := stream.close(); err != nil {
 		return fmt.Errorf("failed to write PDF output: %w", err)
 	}
 
 	return nil
 }
-
-// Helper method to get RGB color for Cairo (instead of CSS string)
-func (p *pen) getSegmentColorRGB(point parser.Point, lastWidth float64) RGB {
-	switch p.name {
-	case "Ballpoint":
-		speed := float64(point.Speed) / 4.0
-		pressure := float64(point.Pressure) / 255.0
-		intensity := (0.1 * -(speed / 35.0)) + (1.2 * pressure) + 0.5
-		intensity = clamp(intensity)
-		factor := math.Min(math.Abs(intensity-1), 0.235)
-		r := int(float64(p.baseColor.R) * (1 - factor))
-		g := int(float64(p.baseColor.G) * (1 - factor))
-		b := int(float64(p.baseColor.B) * (1 - factor))
-		return RGB{R: r, G: g, B: b}
-
-	case "Brush":
-		speed := float64(point.Speed) / 4.0
-		pressure := float64(point.Pressure) / 255.0
-		intensity := math.Pow(pressure, 1.5) - 0.2*(speed/50.0)
-		intensity = clamp(intensity)
-		r := int(float64(p.baseColor.R) * intensity)
-		g := int(float64(p.baseColor.G) * intensity)
-		b := int(float64(p.baseColor.B) * intensity)
-		return RGB{R: r, G: g, B: b}
-
-	default:
-		return p.baseColor
-	}
-}
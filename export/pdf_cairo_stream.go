@@ -0,0 +1,104 @@
+//go:build cairo
+// +build cairo
+
+package export
+
+/*
+#cgo pkg-config: cairo
+#include <stdlib.h>
+#include <cairo.h>
+#include <cairo-pdf.h>
+#include <cairo-ps.h>
+
+cairo_status_t cairoStreamWrite(void *closure, unsigned char *data, unsigned int length);
+*/
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"runtime/cgo"
+	"unsafe"
+
+	"github.com/ungerik/go-cairo"
+)
+
+// cairoStream passes the output of a Cairo surface to an io.Writer as it is
+// produced, so no temporary files are needed
+type cairoStream struct {
+	w       io.Writer
+	err     error
+	closure unsafe.Pointer // C memory holding the stream's cgo.Handle, passed to Cairo
+}
+
+// newCairoStream creates a stream writing to w. Call close when the surface
+// using it is finished.
+func newCairoStream(w io.Writer) *cairoStream {
+	s := &cairoStream{w: w}
+	// Cairo keeps the closure pointer, so it must not point into Go memory
+	s.closure = C.malloc(C.size_t(unsafe.Sizeof(uintptr(0))))
+	*(*uintptr)(s.closure) = uintptr(cgo.NewHandle(s))
+	return s
+}
+
+// close releases the stream and returns the first write error
+func (s *cairoStream) close() error {
+	if s.closure != nil {
+		cgo.Handle(*(*uintptr)(s.closure)).Delete()
+		C.free(s.closure)
+		s.closure = nil
+	}
+	return s.err
+}
+
+//export cairoStreamWrite
+func cairoStreamWrite(closure unsafe.Pointer, data *C.uchar, length C.uint) C.cairo_status_t {
+	s := cgo.Handle(*(*uintptr)(closure)).Value().(*cairoStream)
+	if s.err != nil {
+		return C.cairo_status_t(C.CAIRO_STATUS_WRITE_ERROR)
+	}
+	if _, err := s.w.Write(unsafe.Slice((*byte)(unsafe.Pointer(data)), int(length))); err != nil {
+		s.err = err
+		return C.cairo_status_t(C.CAIRO_STATUS_WRITE_ERROR)
+	}
+	return C.cairo_status_t(C.CAIRO_STATUS_SUCCESS)
+}
+
+// writeFunc returns the C callback that writes to a cairoStream
+func (s *cairoStream) writeFunc() C.cairo_write_func_t {
+	return C.cairo_write_func_t(C.cairoStreamWrite)
+}
+
+// wrapSurface wraps a C surface in a go-cairo Surface with a new context
+func wrapSurface(surface *C.cairo_surface_t) *cairo.Surface {
+	context := C.cairo_create(surface)
+	return cairo.NewSurfaceFromC(cairo.Cairo_surface(unsafe.Pointer(surface)), cairo.Cairo_context(unsafe.Pointer(context)))
+}
+
+// newPDFStreamSurface creates a PDF surface writing to the stream
+func newPDFStreamSurface(s *cairoStream, width, height float64) *cairo.Surface {
+	surface := C.cairo_pdf_surface_create_for_stream(s.writeFunc(), s.closure, C.double(width), C.double(height))
+	C.cairo_pdf_surface_restrict_to_version(surface, C.cairo_pdf_version_t(C.CAIRO_PDF_VERSION_1_5))
+	return wrapSurface(surface)
+}
+
+// newEPSStreamSurface creates an Encapsulated PostScript surface writing to the stream
+func newEPSStreamSurface(s *cairoStream, width, height float64) *cairo.Surface {
+	surface := C.cairo_ps_surface_create_for_stream(s.writeFunc(), s.closure, C.double(width), C.double(height))
+	C.cairo_ps_surface_restrict_to_level(surface, C.cairo_ps_level_t(C.CAIRO_PS_LEVEL_3))
+	C.cairo_ps_surface_set_eps(surface, 1)
+	return wrapSurface(surface)
+}
+
+// writePNGStream encodes an image surface as PNG to the stream
+func writePNGStream(surface *cairo.Surface, s *cairoStream) error {
+	surfacePtr, _ := surface.Native()
+	status := C.cairo_surface_write_to_png_stream((*C.cairo_surface_t)(unsafe.Pointer(surfacePtr)), s.writeFunc(), s.closure)
+	if status != C.CAIRO_STATUS_SUCCESS {
+		if s.err != nil {
+			return s.err
+		}
+		return fmt.Errorf("failed to write PNG: %s", cairo.Status(status))
+	}
+	return nil
+}
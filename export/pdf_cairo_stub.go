@@ -3,22 +3,78 @@
 package export
 
 import (
+	"context"
 	"fmt"
 	"io"
 
 	"github.com/joagonca/rmc-go/parser"
 )
 
+// CairoVersion reports that the binary was built without Cairo support
+func CairoVersion() (string, bool) {
+	return "", false
+}
+
 // ExportToPDFCairo is a stub when Cairo is not available
 func ExportToPDFCairo(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToPDFCairoWithOptions(tree, w, nil)
+}
+
+// ExportToPDFCairoWithOptions is a stub when Cairo is not available
+func ExportToPDFCairoWithOptions(tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
 	return fmt.Errorf("native PDF export not available: binary was not built with Cairo support\n" +
 		"To use --native flag, rebuild with: make build-cairo\n" +
 		"Or use the default Inkscape-based export without --native flag")
 }
 
+// ExportToEPSCairo is a stub when Cairo is not available
+func ExportToEPSCairo(tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return fmt.Errorf("native EPS export not available: binary was not built with Cairo support\n" +
+		"To use native export, rebuild with: make build-cairo\n" +
+		"Or use the Inkscape-based export with --legacy")
+}
+
+// ExportToPNGCairo is a stub when Cairo is not available
+func ExportToPNGCairo(tree *parser.SceneTree, w io.Writer, opts *PNGOptions) error {
+	return fmt.Errorf("PNG export not available: binary was not built with Cairo support\n" +
+		"To enable it, rebuild with: make build-cairo")
+}
+
 // ExportToMultipagePDFCairo is a stub when Cairo is not available
 func ExportToMultipagePDFCairo(trees []*parser.SceneTree, w io.Writer) error {
+	return ExportToMultipagePDFCairoWithOptions(trees, w, nil)
+}
+
+// ExportToMultipagePDFCairoWithOptions is a stub when Cairo is not available
+func ExportToMultipagePDFCairoWithOptions(trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
 	return fmt.Errorf("native multipage PDF export not available: binary was not built with Cairo support\n" +
 		"To use --native flag, rebuild with: make build-cairo\n" +
 		"Or use the default Inkscape-based export without --native flag")
 }
+
+// exportToMultipagePDFCairo is a stub when Cairo is not available
+func exportToMultipagePDFCairo(ctx context.Context, trees []*parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return ExportToMultipagePDFCairoWithOptions(trees, w, opts)
+}
+
+// exportToPDFCairo is a stub when Cairo is not available
+func exportToPDFCairo(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return ExportToPDFCairoWithOptions(tree, w, opts)
+}
+
+// exportToEPSCairo is a stub when Cairo is not available
+func exportToEPSCairo(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	return ExportToEPSCairo(tree, w, opts)
+}
+
+// renderReplayFrames is a stub when Cairo is not available
+func renderReplayFrames(tree *parser.SceneTree, opts *ReplayOptions, frame frameFunc) error {
+	return fmt.Errorf("animated export not available: binary was not built with Cairo support\n" +
+		"To enable it, rebuild with: make build-cairo")
+}
+
+// exportContactSheetCairo is a stub when Cairo is not available
+func exportContactSheetCairo(trees []*parser.SceneTree, w io.Writer, opts *ContactSheetOptions, grid sheetGrid, raster bool) error {
+	return fmt.Errorf("contact sheet export not available: binary was not built with Cairo support\n" +
+		"To enable it, rebuild with: make build-cairo")
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PDFProfile names a PDF conformance profile
+type PDFProfile string
+
+const (
+	// PDFProfileDefault produces a plain PDF (default)
+	PDFProfileDefault PDFProfile = ""
+	// PDFProfilePDFA2B produces PDF/A-2b for archiving: all fonts are
+	// embedded, XMP metadata is included and an sRGB output intent is set
+	PDFProfilePDFA2B PDFProfile = "pdfa-2b"
+)
+
+// ParsePDFProfile parses a PDF profile name (none or pdfa-2b)
+func ParsePDFProfile(name string) (PDFProfile, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return PDFProfileDefault, nil
+	case string(PDFProfilePDFA2B):
+		return PDFProfilePDFA2B, nil
+	default:
+		return "", fmt.Errorf("unknown PDF profile: %s (supported: none, pdfa-2b)", name)
+	}
+}
+
+// pdfaDefinition is the Ghostscript prologue that declares the PDF/A output
+// intent, using the sRGB profile built into Ghostscript
+const pdfaDefinition = `%!
+/ICCProfile (%rom%iccprofiles/srgb.icc) def
+[/_objdef {icc_PDFA} /type /stream /OBJ pdfmark
+[{icc_PDFA} << /N 3 >> /PUT pdfmark
+[{icc_PDFA} ICCProfile (r) file /PUT pdfmark
+[/_objdef {OutputIntent_PDFA} /type /dict /OBJ pdfmark
+[{OutputIntent_PDFA} <<
+  /Type /OutputIntent
+  /S /GTS_PDFA1
+  /DestOutputProfile {icc_PDFA}
+  /OutputConditionIdentifier (sRGB)
+>> /PUT pdfmark
+[{Catalog} << /OutputIntents [ {OutputIntent_PDFA} ] >> /PUT pdfmark
+`
+
+// convertPDFProfile rewrites a PDF to conform to the given profile using
+// Ghostscript, keeping its files in a new directory under tempDir
+func convertPDFProfile(ctx context.Context, pdfData []byte, w io.Writer, profile PDFProfile, tempDir string) error {
+	if profile == PDFProfileDefault {
+		_, err := w.Write(pdfData)
+		return err
+	}
+
+	if err := requireTool("gs", ghostscriptHint); err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp(tempDir, "rmc-pdfa-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputPath := filepath.Join(tempDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdfData, 0644); err != nil {
+		return fmt.Errorf("failed to write temp PDF: %w", err)
+	}
+
+	defPath := filepath.Join(tempDir, "PDFA_def.ps")
+	if err := os.WriteFile(defPath, []byte(pdfaDefinition), 0644); err != nil {
+		return fmt.Errorf("failed to write PDF/A definition: %w", err)
+	}
+
+	outputPath := filepath.Join(tempDir, "output.pdf")
+	err = runCommand(ctx, nil, nil, nil, "gs",
+		"-dPDFA=2", "-dBATCH", "-dNOPAUSE", "-q", "-dNOOUTERSAVE",
+		"-sDEVICE=pdfwrite", "-sColorConversionStrategy=RGB",
+		"-dPDFACompatibilityPolicy=1",
+		"-sOutputFile="+outputPath,
+		defPath, inputPath)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("PDF/A conversion failed: %w\n"+
+			"  Ensure 'gs' (Ghostscript) is installed and available in PATH\n"+
+			"  Ubuntu/Debian: sudo apt-get install ghostscript\n"+
+			"  macOS: brew install ghostscript", err)
+	}
+
+	pdfaData, err := os.ReadFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read PDF/A output: %w", err)
+	}
+
+	if _, err := w.Write(pdfaData); err != nil {
+		return fmt.Errorf("failed to write PDF output: %w", err)
+	}
+
+	return nil
+}
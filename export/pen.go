@@ -1,7 +1,6 @@
 package export
 
 import (
-	"fmt"
 	"math"
 
 	"github.com/joagonca/rmc-go/parser"
@@ -26,7 +25,16 @@ var rmPalette = map[parser.PenColor]RGB{
 	parser.ColorCyan:        {139, 208, 229},
 	parser.ColorMagenta:     {183, 130, 205},
 	parser.ColorYellow2:     {247, 232, 81},
-	// Note: Highlight and shader color variants are now read directly from .rm files as RGBA overrides
+	// Shader tints, for shader strokes that carry no color of their own
+	parser.ColorShaderGray:    {200, 200, 200},
+	parser.ColorShaderOrange:  {255, 196, 140},
+	parser.ColorShaderMagenta: {240, 160, 220},
+	parser.ColorShaderBlue:    {150, 190, 240},
+	parser.ColorShaderRed:     {240, 150, 150},
+	parser.ColorShaderGreen:   {170, 220, 160},
+	parser.ColorShaderYellow:  {250, 235, 140},
+	parser.ColorShaderCyan:    {150, 225, 235},
+	// Note: Highlight color variants are read directly from .rm files as RGBA overrides
 }
 
 type pen struct {
@@ -38,13 +46,20 @@ type pen struct {
 	strokeLinecap  string
 	strokeOpacity  float64
 	thicknessScale float64
+	multiply       bool // blend with what is underneath like ink, instead of covering it
+	cal            PenCalibration
 }
 
-func createPen(penType parser.Pen, color parser.PenColor, colorOverride *parser.RGBA, thicknessScale float64) *pen {
+// createPen creates the pen for a stroke, calibrated with the default pen
+// profile. A palette entry for the color replaces both the device palette
+// and the color stored in the file.
+func createPen(penType parser.Pen, color parser.PenColor, colorOverride *parser.RGBA, thicknessScale float64, palette map[parser.PenColor]RGB) *pen {
 	var baseColor RGB
 
 	// Use color override if available (for highlights/shaders), otherwise use palette
-	if colorOverride != nil {
+	if custom, ok := palette[color]; ok {
+		baseColor = custom
+	} else if colorOverride != nil {
 		baseColor = RGB{
 			R: int(colorOverride.R),
 			G: int(colorOverride.G),
@@ -60,7 +75,6 @@ func createPen(penType parser.Pen, color parser.PenColor, colorOverride *parser.
 
 	p := &pen{
 		baseColor:      baseColor,
-		segmentLength:  1000,
 		baseOpacity:    1.0,
 		strokeLinecap:  "round",
 		strokeOpacity:  1.0,
@@ -71,18 +85,15 @@ func createPen(penType parser.Pen, color parser.PenColor, colorOverride *parser.
 	case parser.PenBallpoint1, parser.PenBallpoint2:
 		p.name = "Ballpoint"
 		p.baseWidth = thicknessScale
-		p.segmentLength = 5
 	case parser.PenFineliner1, parser.PenFineliner2:
 		p.name = "Fineliner"
 		p.baseWidth = thicknessScale * 1.8
 	case parser.PenMarker1, parser.PenMarker2:
 		p.name = "Marker"
 		p.baseWidth = thicknessScale
-		p.segmentLength = 3
 	case parser.PenPencil1, parser.PenPencil2:
 		p.name = "Pencil"
 		p.baseWidth = thicknessScale
-		p.segmentLength = 2
 	case parser.PenMechanicalPencil1, parser.PenMechanicalPencil2:
 		p.name = "MechanicalPencil"
 		p.baseWidth = thicknessScale * thicknessScale
@@ -90,7 +101,6 @@ func createPen(penType parser.Pen, color parser.PenColor, colorOverride *parser.
 	case parser.PenPaintbrush1, parser.PenPaintbrush2:
 		p.name = "Brush"
 		p.baseWidth = thicknessScale
-		p.segmentLength = 2
 		p.strokeLinecap = "round"
 	case parser.PenHighlighter1, parser.PenHighlighter2:
 		p.name = "Highlighter"
@@ -98,6 +108,7 @@ func createPen(penType parser.Pen, color parser.PenColor, colorOverride *parser.
 		p.strokeLinecap = "square"
 		p.baseOpacity = 0.3
 		p.strokeOpacity = 0.2
+		p.multiply = true
 	case parser.PenEraser:
 		p.name = "Eraser"
 		p.baseWidth = thicknessScale * 2
@@ -111,55 +122,80 @@ func createPen(penType parser.Pen, color parser.PenColor, colorOverride *parser.
 	case parser.PenCalligraphy:
 		p.name = "Calligraphy"
 		p.baseWidth = thicknessScale
-		p.segmentLength = 2
+		p.strokeLinecap = "butt"
 	case parser.PenShader:
 		p.name = "Shader"
 		p.baseWidth = 12
 		p.strokeLinecap = "round"
 		p.baseOpacity = 0.1
+		p.multiply = true
 	default:
 		p.name = "Unknown"
 		p.baseWidth = thicknessScale
 	}
 
+	p.calibrate(defaultPenProfile.calibration(penType))
 	return p
 }
 
-func (p *pen) getSegmentColor(point parser.Point, lastWidth float64) string {
+// calibrate sets the pen's calibration
+func (p *pen) calibrate(c PenCalibration) {
+	p.cal = c
+	p.segmentLength = c.SegmentLength
+}
+
+// inputs returns the calibrated pressure (0 to 1), speed and tilt of a point
+func (p *pen) inputs(point parser.Point) (pressure, speed, tilt float64) {
+	pressure = float64(point.Pressure) / 255.0
+	if p.cal.PressureCurve != 1 {
+		pressure = math.Pow(pressure, p.cal.PressureCurve)
+	}
+	speed = float64(point.Speed) / 4.0 * p.cal.Speed
+	tilt = directionToTilt(point.Direction) * p.cal.Tilt
+	return pressure, speed, tilt
+}
+
+// getSegmentColorRGB returns the color of the segment starting at point
+func (p *pen) getSegmentColorRGB(point parser.Point, lastWidth float64) RGB {
 	switch p.name {
 	case "Ballpoint":
-		speed := float64(point.Speed) / 4.0
-		pressure := float64(point.Pressure) / 255.0
+		pressure, speed, _ := p.inputs(point)
 		intensity := (0.1 * -(speed / 35.0)) + (1.2 * pressure) + 0.5
 		intensity = clamp(intensity)
-		// Apply intensity to the base color instead of always using gray
-		factor := math.Min(math.Abs(intensity-1), 0.235) // max darkening of ~60/255
+		factor := math.Min(math.Abs(intensity-1), 0.235)
 		r := int(float64(p.baseColor.R) * (1 - factor))
 		g := int(float64(p.baseColor.G) * (1 - factor))
 		b := int(float64(p.baseColor.B) * (1 - factor))
-		return fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+		return RGB{R: r, G: g, B: b}
 
 	case "Brush":
-		speed := float64(point.Speed) / 4.0
-		pressure := float64(point.Pressure) / 255.0
+		pressure, speed, _ := p.inputs(point)
 		intensity := math.Pow(pressure, 1.5) - 0.2*(speed/50.0)
 		intensity = clamp(intensity)
-		// Apply intensity to darken/lighten the base color
 		r := int(float64(p.baseColor.R) * intensity)
 		g := int(float64(p.baseColor.G) * intensity)
 		b := int(float64(p.baseColor.B) * intensity)
-		return fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+		return RGB{R: r, G: g, B: b}
 
 	default:
-		return fmt.Sprintf("rgb(%d,%d,%d)", p.baseColor.R, p.baseColor.G, p.baseColor.B)
+		return p.baseColor
 	}
 }
 
-func (p *pen) getSegmentWidth(point parser.Point, lastWidth float64) float64 {
-	speed := float64(point.Speed) / 4.0
-	pressure := float64(point.Pressure) / 255.0
+// getSegmentWidth returns the width of the segment starting at point, scaled
+// by the calibration. heading is the direction of travel at point (see
+// strokeHeading).
+func (p *pen) getSegmentWidth(point parser.Point, heading, lastWidth float64) float64 {
+	if p.cal.Width == 0 {
+		return 0
+	}
+	return p.modelWidth(point, heading, lastWidth/p.cal.Width) * p.cal.Width
+}
+
+// modelWidth returns the uncalibrated width of the segment starting at point
+func (p *pen) modelWidth(point parser.Point, heading, lastWidth float64) float64 {
+	pressure, speed, tilt := p.inputs(point)
 	width := float64(point.Width) / 4.0
-	tilt := directionToTilt(point.Direction)
 
 	switch p.name {
 	case "Ballpoint":
@@ -181,27 +217,51 @@ func (p *pen) getSegmentWidth(point parser.Point, lastWidth float64) float64 {
 		return 0.7 * (((1 + (1.4 * pressure)) * width) - (0.5 * tilt) - (speed / 50.0))
 
 	case "Calligraphy":
-		return 0.9*(((1+pressure)*width)-(0.3*tilt)) + (0.1 * lastWidth)
+		nib := ((1 + pressure) * width) - (0.3 * tilt)
+		return 0.9*nib*nibFactor(heading) + (0.1 * lastWidth)
 
 	default:
 		return p.baseWidth
 	}
 }
 
+// getSegmentOpacity returns the opacity of the segment starting at point
 func (p *pen) getSegmentOpacity(point parser.Point, lastWidth float64) float64 {
-	speed := float64(point.Speed) / 4.0
-	pressure := float64(point.Pressure) / 255.0
+	pressure, speed, _ := p.inputs(point)
 
 	switch p.name {
 	case "Pencil":
 		opacity := (0.1 * -(speed / 35.0)) + pressure
-		return clamp(opacity) - 0.1
+		return (clamp(opacity) - 0.1) * p.cal.Opacity
 
 	default:
-		return p.baseOpacity
+		return p.baseOpacity * p.cal.Opacity
 	}
 }
 
+// calligraphyNibAngle is the angle of the calligraphy pen's nib, in radians
+// from the x axis of the screen (whose y axis points down): rising to the
+// right at 45 degrees, as a right-handed writer holds a broad nib
+const calligraphyNibAngle = -math.Pi / 4
+
+// calligraphyHairline is the width of a calligraphy stroke drawn along the
+// nib, as a fraction of its width across the nib
+const calligraphyHairline = 0.2
+
+// nibFactor returns the fraction of the calligraphy nib's width that a
+// stroke heading in a direction (see strokeHeading) covers: all of it when
+// drawn across the nib, a hairline when drawn along it
+func nibFactor(heading float64) float64 {
+	return calligraphyHairline + (1-calligraphyHairline)*math.Abs(math.Sin(heading-calligraphyNibAngle))
+}
+
+// strokeHeading returns the direction of travel at point i of a stroke, in
+// radians from the x axis, from the points on either side of it
+func strokeHeading(points []parser.Point, i int) float64 {
+	a, b := points[max(i-1, 0)], points[min(i+1, len(points)-1)]
+	return math.Atan2(float64(b.Y-a.Y), float64(b.X-a.X))
+}
+
 func directionToTilt(direction uint8) float64 {
 	return float64(direction) * (math.Pi * 2) / 255.0
 }
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// pencilGradientStep is the longest piece of a pencil stroke drawn with one
+// opacity and width by PencilGradient, in reMarkable screen units
+const pencilGradientStep = 1.0
+
+// pencilGradientSegments splits a pencil stroke into straight pieces of at
+// most pencilGradientStep, whose opacity and width are interpolated between
+// the values at the stroke's points, so pressure shades the stroke smoothly
+// instead of in steps of pen.segmentLength points. The pieces are drawn with
+// butt caps (see buildStroke), so they meet without overlapping and
+// darkening where they join.
+func pencilGradientSegments(line *parser.Line, pen *pen, strokeScale float64) []StrokeSegment {
+	points := line.Points
+	widths := make([]float64, len(points))
+	opacities := make([]float64, len(points))
+	lastWidth := 0.0
+	for i, point := range points {
+		widths[i] = pen.getSegmentWidth(point, strokeHeading(points, i), lastWidth)
+		opacities[i] = pen.getSegmentOpacity(point, lastWidth)
+		lastWidth = widths[i]
+	}
+
+	var segments []StrokeSegment
+	for i := 1; i < len(points); i++ {
+		a, b := pointVec(points[i-1]), pointVec(points[i])
+		n := max(int(math.Ceil(a.dist(b)/pencilGradientStep)), 1)
+		color := pen.getSegmentColorRGB(points[i], widths[i-1])
+		for k := 0; k < n; k++ {
+			t0, t1 := float64(k)/float64(n), float64(k+1)/float64(n)
+			tm := (t0 + t1) / 2
+			p0, p1 := a.add(b.sub(a).mul(t0)), a.add(b.sub(a).mul(t1))
+			segments = append(segments, StrokeSegment{
+				Color:   color,
+				Opacity: opacities[i-1] + (opacities[i]-opacities[i-1])*tm,
+				Width:   scale(widths[i-1]+(widths[i]-widths[i-1])*tm) * strokeScale,
+				Path: []PathElement{
+					{Op: PathMoveTo, Points: [3]Point{{scale(p0.x), scale(p0.y)}}},
+					{Op: PathLineTo, Points: [3]Point{{scale(p1.x), scale(p1.y)}}},
+				},
+			})
+		}
+	}
+	return segments
+}
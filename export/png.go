@@ -0,0 +1,72 @@
+package export
+
+import (
+	"context"
+	"io"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// pixelEpsilon keeps rounding errors from adding a pixel to images whose
+// size was given in pixels
+const pixelEpsilon = 1e-6
+
+// PNGOptions controls raster output
+type PNGOptions struct {
+	// SVGOptions holds the page layout options shared with SVG export
+	SVGOptions
+
+	// Scale is the number of pixels per point (default: 2, about 144 DPI)
+	Scale float64
+}
+
+// DefaultPNGOptions returns the options used by ExportToPNG: twice the
+// point size on a white background
+func DefaultPNGOptions() *PNGOptions {
+	opts := &PNGOptions{SVGOptions: *DefaultSVGOptions(), Scale: 2}
+	opts.Background = "white"
+	return opts
+}
+
+// ExportToPNG exports a scene tree to a PNG image (requires a Cairo build)
+func ExportToPNG(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToPNGWithOptions(tree, w, nil)
+}
+
+// ExportToPNGWithOptions exports a scene tree to a PNG image with control
+// over the resolution and page layout. A nil opts uses DefaultPNGOptions().
+func ExportToPNGWithOptions(tree *parser.SceneTree, w io.Writer, opts *PNGOptions) error {
+	if opts == nil {
+		opts = DefaultPNGOptions()
+	}
+	return ExportToPNGCairo(tree, w, opts)
+}
+
+// ExportToPNGViaSVG exports a scene tree to a PNG image by converting its
+// SVG with Inkscape or rsvg-convert, like the legacy PDF renderer, so it
+// works without a Cairo build. The image has the converter's default
+// resolution of 96 DPI. A nil opts uses DefaultPDFOptions().
+func ExportToPNGViaSVG(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *PDFOptions) error {
+	if opts == nil {
+		opts = DefaultPDFOptions()
+	}
+	return exportViaSVG(ctx, tree, w, opts, "png")
+}
+
+// rasterOptions returns the page options used to draw a raster image, and
+// the number of pixels per point
+func rasterOptions(opts *PNGOptions) (SVGOptions, float64) {
+	pixelScale := opts.Scale
+	if pixelScale <= 0 {
+		pixelScale = 1
+	}
+
+	// Invisible text has no use in an image
+	svgOpts := opts.SVGOptions
+	svgOpts.Recognizer = nil
+
+	// The output size is given in pixels
+	svgOpts.OutputWidth /= pixelScale
+	svgOpts.OutputHeight /= pixelScale
+	return svgOpts, pixelScale
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// rasterJoinSides is the number of sides of the polygons that round the
+// joins and ends of rasterized lines
+const rasterJoinSides = 12
+
+// rasterRenderer draws the ink of a page into an alpha mask, in pure Go so
+// that it works in every build. Strokes are drawn fully opaque whatever
+// their color, and highlighters and typed text are left out, since the mask
+// is meant for reading handwriting, and for thumbnails where Cairo is not
+// available, rather than looking at closely.
+type rasterRenderer struct {
+	z              *vector.Rasterizer
+	origin         Point   // Content point at the top left corner of the mask
+	pixelsPerPoint float64 // Resolution of the mask
+}
+
+func newRasterRenderer(area Rect, pixelsPerPoint float64) *rasterRenderer {
+	w := max(int(math.Ceil(area.Width*pixelsPerPoint)), 1)
+	h := max(int(math.Ceil(area.Height*pixelsPerPoint)), 1)
+	return &rasterRenderer{
+		z:              vector.NewRasterizer(w, h),
+		origin:         Point{area.X, area.Y},
+		pixelsPerPoint: pixelsPerPoint,
+	}
+}
+
+func (r *rasterRenderer) BeginPage(page Page) error { return nil }
+
+func (r *rasterRenderer) EndPage() error { return nil }
+
+func (r *rasterRenderer) DrawText(text Text) error { return nil }
+
+func (r *rasterRenderer) DrawStroke(stroke Stroke) error {
+	if stroke.Multiply {
+		return nil
+	}
+	for _, segment := range stroke.Segments {
+		if segment.Fill {
+			r.fill(segment.Path)
+			continue
+		}
+		half := segment.Width / 2
+		for _, line := range flattenPath(segment.Path) {
+			for i, p := range line {
+				r.polygon(circlePolygon(p, half))
+				if i > 0 {
+					r.polygon(linePolygon(line[i-1], p, half))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fill adds the subpaths and circles of a filled path
+func (r *rasterRenderer) fill(path []PathElement) {
+	for _, e := range path {
+		if e.Op == PathCircle {
+			r.polygon(circlePolygon(e.Points[0], e.Radius))
+		}
+	}
+	for _, subpath := range flattenPath(path) {
+		r.polygon(subpath)
+	}
+}
+
+// polygon adds a closed polygon in content coordinates. Coverage adds up
+// where shapes of the same winding overlap, so every polygon is wound the
+// same way for overlapping shapes to join instead of cancelling out.
+func (r *rasterRenderer) polygon(points []Point) {
+	if len(points) < 3 {
+		return
+	}
+	reverse := signedArea(pointVecs(points)) < 0
+	for i := range points {
+		p := points[i]
+		if reverse {
+			p = points[len(points)-1-i]
+		}
+		x := float32((p.X - r.origin.X) * r.pixelsPerPoint)
+		y := float32((p.Y - r.origin.Y) * r.pixelsPerPoint)
+		if i == 0 {
+			r.z.MoveTo(x, y)
+		} else {
+			r.z.LineTo(x, y)
+		}
+	}
+	r.z.ClosePath()
+}
+
+// image returns the drawn ink as black on a white background
+func (r *rasterRenderer) image() *image.Gray {
+	mask := image.NewAlpha(r.z.Bounds())
+	r.z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	gray := image.NewGray(mask.Bounds())
+	for i, a := range mask.Pix {
+		gray.Pix[i] = 255 - a
+	}
+	return gray
+}
+
+// linePolygon returns the rectangle covered by a straight line of the given
+// half width from a to b
+func linePolygon(a, b Point, half float64) []Point {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := math.Hypot(dx, dy)
+	if length == 0 || half <= 0 {
+		return nil
+	}
+	nx, ny := -dy/length*half, dx/length*half
+	return []Point{
+		{a.X + nx, a.Y + ny},
+		{b.X + nx, b.Y + ny},
+		{b.X - nx, b.Y - ny},
+		{a.X - nx, a.Y - ny},
+	}
+}
+
+// circlePolygon returns a regular polygon approximating a circle
+func circlePolygon(center Point, radius float64) []Point {
+	if radius <= 0 {
+		return nil
+	}
+	points := make([]Point, rasterJoinSides)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / rasterJoinSides
+		points[i] = Point{center.X + radius*math.Cos(angle), center.Y + radius*math.Sin(angle)}
+	}
+	return points
+}
+
+// pointVecs converts points for the vector helpers
+func pointVecs(points []Point) []vec {
+	vs := make([]vec, len(points))
+	for i, p := range points {
+		vs[i] = vec{p.X, p.Y}
+	}
+	return vs
+}
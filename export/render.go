@@ -0,0 +1,607 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// Renderer draws pages onto a canvas. Render walks a scene tree and calls
+// BeginPage, then DrawText and DrawStroke for every item in drawing order,
+// then EndPage. Implement it to draw pages with a graphics library of your
+// choice; the SVG and Cairo exports are built on it.
+//
+// All coordinates are in points, in the content coordinates described by
+// Page. Pen styles, smoothing, simplification and variable width are already
+// applied, so a renderer only draws paths and text.
+type Renderer interface {
+	BeginPage(page Page) error
+	DrawStroke(stroke Stroke) error
+	DrawText(text Text) error
+	EndPage() error
+}
+
+// GroupRenderer is implemented by renderers that keep the group structure of
+// a page, such as SVG. Render then calls BeginGroup and EndGroup around the
+// items of every group, and gives their coordinates relative to the group's
+// origin instead of the page.
+type GroupRenderer interface {
+	Renderer
+	BeginGroup(group Group) error
+	EndGroup() error
+}
+
+// Page describes the page being drawn
+type Page struct {
+	// Width and Height are the output page size in points
+	Width, Height float64
+
+	// View is the content region, in content coordinates. Use Fit to map it
+	// onto the page.
+	View Rect
+
+	// Rotated turns the content a quarter turn clockwise about the origin,
+	// before the view is mapped onto the page
+	Rotated bool
+
+	// Background is a CSS color to fill the whole page with, or empty
+	Background string
+}
+
+// Fit returns the scale factor and offset that fit the view onto the page,
+// centered and preserving aspect ratio: a content point (x, y) is drawn at
+// (offsetX + (x-View.X)*factor, offsetY + (y-View.Y)*factor).
+func (p Page) Fit() (factor, offsetX, offsetY float64) {
+	return p.layout().fit()
+}
+
+// Map returns the position on the page of a point in content coordinates,
+// turned first if the page is rotated and then fitted with Fit
+func (p Page) Map(x, y float64) (float64, float64) {
+	if p.Rotated {
+		x, y = -y, x
+	}
+	factor, offsetX, offsetY := p.Fit()
+	return offsetX + (x-p.View.X)*factor, offsetY + (y-p.View.Y)*factor
+}
+
+// contentRect returns the region of content coordinates shown on the page,
+// which for a rotated page is the view turned back
+func (p Page) contentRect() Rect {
+	if !p.Rotated {
+		return p.View
+	}
+	// A content point (x, y) is viewed at (-y, x)
+	return Rect{X: p.View.Y, Y: -(p.View.X + p.View.Width), Width: p.View.Height, Height: p.View.Width}
+}
+
+// layout converts the page back to the layout it was made from
+func (p Page) layout() pageLayout {
+	return pageLayout{
+		width: p.Width, height: p.Height,
+		viewX: p.View.X, viewY: p.View.Y, viewWidth: p.View.Width, viewHeight: p.View.Height,
+		rotated: p.Rotated,
+	}
+}
+
+// Rect is a rectangle in points
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// Group is a group of items whose coordinates are relative to its origin
+type Group struct {
+	ID    parser.CrdtID
+	X, Y  float64 // Origin relative to the enclosing group
+	Layer bool    // The group is a layer of the page, directly below the root group
+	Label string  // Name of the layer as shown on the device, if any
+}
+
+// Point is a position in points
+type Point struct {
+	X, Y float64
+}
+
+// Stroke is a pen stroke broken into segments that each have one color,
+// width and opacity
+type Stroke struct {
+	Line     *parser.Line    // Stroke being drawn, after simplification; nil for highlighted text (see SVGOptions.SnapHighlights)
+	Cap      string          // Line cap: "round", "square" or "butt"
+	Multiply bool            // Blend by multiplying with what is underneath, like a highlighter
+	Segments []StrokeSegment // Segments in drawing order
+
+	// Begin and Duration place the stroke in the replay of an animated page
+	// (see SVGOptions.Animate), in seconds from its start. Both are zero when
+	// the page is not animated.
+	Begin, Duration float64
+}
+
+// StrokeSegment is a part of a stroke drawn with a single style. It is either
+// stroked with Width or, when Fill is set, filled using the nonzero rule.
+type StrokeSegment struct {
+	Color   RGB
+	Opacity float64
+	Width   float64 // Line width in points, zero for filled segments
+	Fill    bool
+	Path    []PathElement
+}
+
+// PathOp is the kind of a path element
+type PathOp int
+
+const (
+	PathMoveTo  PathOp = iota // Start a subpath at Points[0]
+	PathLineTo                // Line to Points[0]
+	PathCurveTo               // Cubic Bezier curve with control points Points[0] and Points[1] to Points[2]
+	PathClose                 // Close the current subpath
+	PathCircle                // Closed clockwise circle around Points[0] with Radius
+)
+
+// PathElement is one operation of a path
+type PathElement struct {
+	Op     PathOp
+	Points [3]Point
+	Radius float64
+}
+
+// Text is either a block of typed text or the recognized handwriting of a
+// page, which should be drawn invisibly so it can be searched
+type Text struct {
+	Paragraphs []TextParagraph
+	Words      []TextWord
+}
+
+// TextParagraph is a non-empty paragraph of typed text
+type TextParagraph struct {
+	X, Y   float64 // Start of the baseline
+	Width  float64 // Width of the text box holding the paragraph
+	Style  parser.ParagraphStyle
+	Prefix string // Bullet, number or checkbox drawn before the text
+	Text   string
+	Spans  []parser.TextSpan // Text split by inline formatting and links
+}
+
+// TextWord is a recognized handwritten word
+type TextWord struct {
+	Text  string
+	X, Y  float64 // Start of the baseline
+	Width float64 // Width the word covers
+	Size  float64 // Font size in points
+}
+
+// Render draws a scene tree as a single page with r.
+// A nil opts uses DefaultSVGOptions().
+func Render(tree *parser.SceneTree, r Renderer, opts *SVGOptions) error {
+	return RenderContext(context.Background(), tree, r, opts)
+}
+
+// RenderContext is like Render, but passes ctx to opts.Recognizer, which may
+// stop and return the context's error once ctx is done
+func RenderContext(ctx context.Context, tree *parser.SceneTree, r Renderer, opts *SVGOptions) error {
+	if tree == nil {
+		return fmt.Errorf("scene tree cannot be nil")
+	}
+	if tree.Root == nil {
+		return fmt.Errorf("scene tree root cannot be nil")
+	}
+	if opts == nil {
+		opts = DefaultSVGOptions()
+	}
+
+	anchorPos := buildAnchorPos(tree.RootText)
+	return renderPage(ctx, tree, r, computePageLayout(tree, anchorPos, opts), anchorPos, opts)
+}
+
+// renderPage draws a scene tree whose layout has already been computed
+func renderPage(ctx context.Context, tree *parser.SceneTree, r Renderer, layout pageLayout, anchorPos map[parser.CrdtID]float64, opts *SVGOptions) error {
+	page := Page{
+		Width:      layout.width,
+		Height:     layout.height,
+		View:       Rect{layout.viewX, layout.viewY, layout.viewWidth, layout.viewHeight},
+		Rotated:    layout.rotated,
+		Background: opts.Background,
+	}
+	if err := r.BeginPage(page); err != nil {
+		return err
+	}
+
+	w := &pageWalker{r: r, anchorPos: anchorPos, opts: opts, opacity: 1}
+	w.groups, _ = r.(GroupRenderer)
+	if !opts.KeepErasers {
+		w.erased = applyErasers(tree.Root)
+	}
+	if opts.SnapHighlights {
+		w.snapped = snappedStrokes(tree.Root)
+	}
+	if opts.Animate > 0 {
+		w.timeline = strokeTimeline(tree, w, opts.Animate.Seconds())
+	}
+
+	// Typed text is drawn below the strokes
+	if tree.RootText != nil {
+		if err := w.drawText(tree.RootText, Point{}); err != nil {
+			return fmt.Errorf("failed to draw root text: %w", err)
+		}
+	}
+
+	if err := w.drawGroup(tree.Root, Point{}, 0); err != nil {
+		return fmt.Errorf("failed to draw group: %w", err)
+	}
+
+	// Recognized handwriting goes on top of the strokes
+	if opts.Recognizer != nil {
+		image, err := recognitionImage(ctx, tree, layout, anchorPos, opts)
+		if err != nil {
+			return fmt.Errorf("failed to draw page for handwriting recognition: %w", err)
+		}
+		words, err := opts.Recognizer.Recognize(ctx, image)
+		if err != nil {
+			return fmt.Errorf("handwriting recognition failed: %w", err)
+		}
+		if len(words) > 0 {
+			if err := r.DrawText(recognizedText(words, image)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.EndPage()
+}
+
+// pageWalker calls a renderer for every item of a page
+type pageWalker struct {
+	r         Renderer
+	groups    GroupRenderer // r, if it keeps groups
+	anchorPos map[parser.CrdtID]float64
+	opts      *SVGOptions
+	erased    map[*parser.Line][]*parser.Line // What is left of erased strokes
+	timeline  map[*parser.Line]timeSpan       // When strokes are drawn in an animated page
+	snapped   map[*parser.Line]bool           // Highlighter strokes drawn as the text they mark
+	opacity   float64                         // Opacity of the strokes of the current group (see SVGOptions.GroupOpacity)
+}
+
+// drawGroup draws a group and its children, depth levels below the root
+// group. Renderers without groups get coordinates offset by the origin of
+// the enclosing groups.
+func (w *pageWalker) drawGroup(group *parser.Group, origin Point, depth int) error {
+	anchorX, anchorY := getAnchor(group, w.anchorPos)
+	g := Group{ID: group.NodeID, X: scale(anchorX), Y: scale(anchorY), Layer: depth == 1, Label: group.Label.Value}
+	if w.groups != nil {
+		if err := w.groups.BeginGroup(g); err != nil {
+			return err
+		}
+	} else {
+		origin = Point{origin.X + g.X, origin.Y + g.Y}
+	}
+	if opacity, ok := w.opts.GroupOpacity[group.NodeID]; ok {
+		defer func(outer float64) { w.opacity = outer }(w.opacity)
+		w.opacity *= opacity
+	}
+
+	if group.Children != nil {
+		for _, item := range group.Children.Items {
+			if item.Value == nil || item.Deleted() {
+				continue
+			}
+
+			switch v := item.Value.(type) {
+			case *parser.Group:
+				if err := w.drawGroup(v, origin, depth+1); err != nil {
+					return err
+				}
+			case *parser.Line:
+				if err := w.drawLine(v, origin); err != nil {
+					return err
+				}
+			case *parser.Text:
+				if err := w.drawText(v, origin); err != nil {
+					return err
+				}
+			case *parser.GlyphRange:
+				if err := w.drawGlyphRange(v, origin); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if w.groups != nil {
+		return w.groups.EndGroup()
+	}
+	return nil
+}
+
+// drawLine draws a stroke in the style selected by the options. Erased
+// strokes are drawn as the parts that are left, if any, and strokes left out
+// by the stroke filters not at all.
+func (w *pageWalker) drawLine(line *parser.Line, origin Point) error {
+	if !w.opts.drawsLine(line) || w.snapped[line] {
+		return nil
+	}
+	span := w.timeline[line]
+	if pieces, ok := w.erased[line]; ok {
+		lengths := make([]float64, len(pieces))
+		for i, piece := range pieces {
+			lengths[i] = lineLength(piece)
+		}
+		spans := span.split(lengths)
+		for i, piece := range pieces {
+			if err := w.drawStroke(piece, origin, spans[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.drawStroke(line, origin, span)
+}
+
+// drawStroke draws a single stroke, during span of an animated page
+func (w *pageWalker) drawStroke(line *parser.Line, origin Point, span timeSpan) error {
+	// A frame of a raster replay shows what has been drawn so far
+	if at := w.opts.replayAt; at > 0 {
+		if line = span.drawnPart(line, at); line == nil {
+			return nil
+		}
+		span = timeSpan{}
+	}
+
+	stroke := buildStroke(line, w.opts)
+	stroke.Begin, stroke.Duration = span.begin, span.duration
+	if origin != (Point{}) {
+		stroke.translate(origin)
+	}
+	w.fade(&stroke)
+	return w.r.DrawStroke(stroke)
+}
+
+// drawGlyphRange draws highlighted text as rectangles when highlights are
+// snapped to text, and nothing otherwise
+func (w *pageWalker) drawGlyphRange(glyph *parser.GlyphRange, origin Point) error {
+	if !w.opts.SnapHighlights || len(glyph.Rectangles) == 0 || !w.opts.drawsInk(parser.PenHighlighter2, glyph.Color) {
+		return nil
+	}
+	stroke := buildGlyphStroke(glyph, w.opts)
+	if origin != (Point{}) {
+		stroke.translate(origin)
+	}
+	w.fade(&stroke)
+	return w.r.DrawStroke(stroke)
+}
+
+// fade applies the opacity of the current group to a stroke
+func (w *pageWalker) fade(stroke *Stroke) {
+	if w.opacity == 1 {
+		return
+	}
+	for i := range stroke.Segments {
+		stroke.Segments[i].Opacity *= w.opacity
+	}
+}
+
+// drawText draws a block of typed text
+func (w *pageWalker) drawText(text *parser.Text, origin Point) error {
+	t, err := buildText(text, w.opts.glyphs())
+	if err != nil {
+		return err
+	}
+	for i := range t.Paragraphs {
+		w.opts.linkPages(t.Paragraphs[i].Spans)
+	}
+	if origin != (Point{}) {
+		for i := range t.Paragraphs {
+			t.Paragraphs[i].X += origin.X
+			t.Paragraphs[i].Y += origin.Y
+		}
+	}
+	return w.r.DrawText(t)
+}
+
+// pageLinkPrefix starts the Link of spans that link to an exported page,
+// followed by its 1-based number
+const pageLinkPrefix = "#page="
+
+// linkPages sets the Link of spans that link to a page made on the device to
+// the page's number among PageIDs. Spans linking to pages that are not
+// exported keep an empty Link.
+func (opts *SVGOptions) linkPages(spans []parser.TextSpan) {
+	for i, span := range spans {
+		if span.PageID == "" || opts == nil {
+			continue
+		}
+		if n := slices.Index(opts.PageIDs, span.PageID); n >= 0 {
+			spans[i].Link = pageLinkPrefix + strconv.Itoa(n+1)
+		}
+	}
+}
+
+// linkedPage returns the 1-based page number a link set by linkPages points
+// to, or false for a web address
+func linkedPage(link string) (int, bool) {
+	number, ok := strings.CutPrefix(link, pageLinkPrefix)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(number)
+	return n, err == nil && n > 0
+}
+
+// buildStroke resolves a stroke into segments in the style selected by the options
+func buildStroke(line *parser.Line, opts *SVGOptions) Stroke {
+	line = simplifyLine(line, opts.SimplifyTolerance)
+	line = thinLine(line, opts.MaxStrokePoints)
+
+	pen := createPen(line.Tool, line.Color, line.ColorOverride, line.ThicknessScale, opts.Palette)
+	pen.calibrate(opts.PenProfile.calibration(line.Tool))
+	stroke := Stroke{Line: line, Cap: pen.strokeLinecap, Multiply: pen.multiply}
+	switch {
+	case line.Tool == parser.PenShader:
+		// Shaders tint the region they cover evenly, however often the
+		// stroke crosses itself, like on the device
+		stroke.Segments = outlineSegments(line, pen, opts.StrokeScale)
+	case opts.PencilGradient && pen.name == "Pencil" && len(line.Points) > 1:
+		stroke.Cap = "butt"
+		stroke.Segments = pencilGradientSegments(line, pen, opts.StrokeScale)
+	case opts.ChiselMarker && pen.name == "Marker":
+		stroke.Segments = chiselSegments(line, pen, opts.StrokeScale)
+	case opts.VariableWidth && pen.hasVariableWidth():
+		stroke.Segments = outlineSegments(line, pen, opts.StrokeScale)
+	case opts.Smooth:
+		stroke.Segments = smoothSegments(line, pen, opts.StrokeScale)
+	default:
+		stroke.Segments = polylineSegments(line, pen, opts.StrokeScale)
+	}
+	return stroke
+}
+
+// polylineSegments splits a stroke into polylines of pen.segmentLength
+// points. Every segment after the first starts at the last point of the one
+// before, so the stroke has no gaps.
+func polylineSegments(line *parser.Line, pen *pen, strokeScale float64) []StrokeSegment {
+	var segments []StrokeSegment
+	lastSegmentWidth := 0.0
+
+	for i, point := range line.Points {
+		p := Point{scale(float64(point.X)), scale(float64(point.Y))}
+
+		if i%pen.segmentLength == 0 {
+			segmentWidth := pen.getSegmentWidth(point, strokeHeading(line.Points, i), lastSegmentWidth)
+			segment := StrokeSegment{
+				Color:   pen.getSegmentColorRGB(point, lastSegmentWidth),
+				Opacity: pen.getSegmentOpacity(point, lastSegmentWidth),
+				Width:   scale(segmentWidth) * strokeScale,
+			}
+			lastSegmentWidth = segmentWidth
+
+			if i > 0 {
+				last := segments[len(segments)-1].Path
+				segment.Path = append(segment.Path, PathElement{Op: PathMoveTo, Points: [3]Point{last[len(last)-1].Points[0]}})
+			}
+			segments = append(segments, segment)
+		}
+
+		current := &segments[len(segments)-1]
+		op := PathLineTo
+		if len(current.Path) == 0 {
+			op = PathMoveTo
+		}
+		current.Path = append(current.Path, PathElement{Op: op, Points: [3]Point{p}})
+	}
+
+	return segments
+}
+
+// smoothSegments fits each segment of a stroke with Bezier curves, with the
+// same colors and widths as polylineSegments
+func smoothSegments(line *parser.Line, pen *pen, strokeScale float64) []StrokeSegment {
+	var segments []StrokeSegment
+	lastSegmentWidth := 0.0
+
+	for _, s := range smoothStroke(line.Points, pen.segmentLength) {
+		segmentWidth := pen.getSegmentWidth(s.settings, strokeHeading(line.Points, s.start), lastSegmentWidth)
+		segment := StrokeSegment{
+			Color:   pen.getSegmentColorRGB(s.settings, lastSegmentWidth),
+			Opacity: pen.getSegmentOpacity(s.settings, lastSegmentWidth),
+			Width:   scale(segmentWidth) * strokeScale,
+		}
+		lastSegmentWidth = segmentWidth
+
+		start := s.curves[0].p0
+		segment.Path = append(segment.Path, PathElement{Op: PathMoveTo, Points: [3]Point{{scale(start.x), scale(start.y)}}})
+		for _, c := range s.curves {
+			segment.Path = append(segment.Path, PathElement{Op: PathCurveTo, Points: [3]Point{
+				{scale(c.p1.x), scale(c.p1.y)}, {scale(c.p2.x), scale(c.p2.y)}, {scale(c.p3.x), scale(c.p3.y)},
+			}})
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments
+}
+
+// outlineSegments returns the filled outline of a stroke whose width follows
+// every point, as a single segment
+func outlineSegments(line *parser.Line, pen *pen, strokeScale float64) []StrokeSegment {
+	outline := outlineStroke(line, pen, strokeScale)
+	segment := StrokeSegment{Color: outline.color, Opacity: outline.opacity, Fill: true}
+
+	for _, c := range outline.circles {
+		if c.radius == 0 {
+			continue
+		}
+		segment.Path = append(segment.Path, PathElement{Op: PathCircle, Points: [3]Point{{c.center.x, c.center.y}}, Radius: c.radius})
+	}
+	for _, q := range outline.quads {
+		segment.Path = append(segment.Path, PathElement{Op: PathMoveTo, Points: [3]Point{{q[0].x, q[0].y}}})
+		for _, v := range q[1:] {
+			segment.Path = append(segment.Path, PathElement{Op: PathLineTo, Points: [3]Point{{v.x, v.y}}})
+		}
+		segment.Path = append(segment.Path, PathElement{Op: PathClose})
+	}
+
+	return []StrokeSegment{segment}
+}
+
+// translate moves every point of the stroke by offset
+func (s *Stroke) translate(offset Point) {
+	for i := range s.Segments {
+		path := s.Segments[i].Path
+		for j := range path {
+			for k := range path[j].Points {
+				path[j].Points[k].X += offset.X
+				path[j].Points[k].Y += offset.Y
+			}
+		}
+	}
+}
+
+// buildText lays out the non-empty paragraphs of a text block, with the
+// prefixes of glyphs before list paragraphs
+func buildText(text *parser.Text, glyphs GlyphSet) (Text, error) {
+	doc, err := parser.BuildTextDocument(text)
+	if err != nil {
+		return Text{}, fmt.Errorf("failed to build text document: %w", err)
+	}
+
+	var t Text
+	yOffset := TextTopY
+	bulletNumber := 1 // Counter for numbered list items (StyleNumbered)
+	for _, p := range doc.Paragraphs {
+		// Empty paragraphs only add spacing
+		yOffset += lineHeight(p.Style)
+		if p.Text == "" {
+			continue
+		}
+
+		t.Paragraphs = append(t.Paragraphs, TextParagraph{
+			X:      scale(text.PosX),
+			Y:      scale(text.PosY + yOffset),
+			Width:  scale(float64(text.Width)),
+			Style:  p.Style,
+			Prefix: glyphs.Prefix(p.Style, &bulletNumber),
+			Text:   p.Text,
+			Spans:  p.Spans,
+		})
+	}
+	return t, nil
+}
+
+// recognizedText converts words recognized in the pixels of image to text
+// positioned in points
+func recognizedText(words []RecognizedWord, image *PageImage) Text {
+	t := Text{Words: make([]TextWord, len(words))}
+	for i, word := range words {
+		height := word.Height / image.PixelsPerPoint
+		t.Words[i] = TextWord{
+			Text:  word.Text,
+			X:     image.X + word.X/image.PixelsPerPoint,
+			Y:     image.Y + (word.Y+word.Height)/image.PixelsPerPoint,
+			Width: word.Width / image.PixelsPerPoint,
+			Size:  recognizedFontSize(height),
+		}
+	}
+	return t
+}
@@ -0,0 +1,219 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ReplayOptions controls animated raster output that replays the strokes of
+// a page as they were drawn (see SVGOptions.Animate for the order)
+type ReplayOptions struct {
+	// PNGOptions holds the page layout and resolution. Every frame is drawn
+	// like a PNG; a transparent background is drawn white.
+	PNGOptions
+
+	// Duration is how long drawing the strokes takes (default: 10s)
+	Duration time.Duration
+
+	// FPS is the number of frames per second (default: 10)
+	FPS float64
+
+	// Hold is how long the finished page stays on screen at the end
+	// (default: 2s)
+	Hold time.Duration
+}
+
+// DefaultReplayOptions returns the options used by ExportToGIF: ten frames
+// per second for ten seconds, at the point size
+func DefaultReplayOptions() *ReplayOptions {
+	opts := &ReplayOptions{
+		PNGOptions: *DefaultPNGOptions(),
+		Duration:   10 * time.Second,
+		FPS:        10,
+		Hold:       2 * time.Second,
+	}
+	opts.Scale = 1
+	return opts
+}
+
+// frameTimes returns the moments of the replay shown by each frame, in
+// seconds. The last frame shows the finished page.
+func (o *ReplayOptions) frameTimes() []float64 {
+	duration := o.Duration.Seconds()
+	n := max(1, int(math.Ceil(duration*o.FPS-pixelEpsilon)))
+	times := make([]float64, n)
+	for i := range times {
+		times[i] = math.Min(float64(i+1)/o.FPS, duration)
+	}
+	return times
+}
+
+// validate checks the frame rate and durations
+func (o *ReplayOptions) validate() error {
+	if o.FPS <= 0 {
+		return fmt.Errorf("frame rate must be positive")
+	}
+	if o.Duration <= 0 || o.Hold < 0 {
+		return fmt.Errorf("replay duration must be positive and hold must not be negative")
+	}
+	return nil
+}
+
+// frameFunc receives the frames of a replay with how long each is shown.
+// The image is only valid during the call.
+type frameFunc func(img image.Image, delay time.Duration) error
+
+// ExportToGIF exports a scene tree as an animated GIF of the page being
+// drawn (requires a Cairo build)
+func ExportToGIF(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToGIFWithOptions(tree, w, nil)
+}
+
+// ExportToGIFWithOptions exports a scene tree as an animated GIF with control
+// over the timing, resolution and page layout. A nil opts uses
+// DefaultReplayOptions().
+func ExportToGIFWithOptions(tree *parser.SceneTree, w io.Writer, opts *ReplayOptions) error {
+	if opts == nil {
+		opts = DefaultReplayOptions()
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	anim := &gif.GIF{}
+	var last *image.Paletted
+	err := renderReplayFrames(tree, opts, func(img image.Image, delay time.Duration) error {
+		frame := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.Draw(frame, frame.Rect, img, img.Bounds().Min, draw.Src)
+
+		// Store only the part that changed, over the previous frame
+		stored := frame
+		if last != nil {
+			changed := changedRect(last, frame)
+			if changed.Empty() {
+				anim.Delay[len(anim.Delay)-1] += gifDelay(delay)
+				return nil
+			}
+			stored = frame.SubImage(changed).(*image.Paletted)
+		}
+		last = frame
+		anim.Image = append(anim.Image, stored)
+		anim.Delay = append(anim.Delay, gifDelay(delay))
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("failed to write GIF output: %w", err)
+	}
+	return nil
+}
+
+// gifDelay converts a frame duration to GIF delay units of 10ms
+func gifDelay(d time.Duration) int {
+	return max(1, int(math.Round(d.Seconds()*100)))
+}
+
+// changedRect returns the smallest rectangle outside which two frames of
+// the same size are the same
+func changedRect(a, b *image.Paletted) image.Rectangle {
+	changed := image.Rectangle{}
+	r := a.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := a.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if a.Pix[row+x-r.Min.X] != b.Pix[row+x-r.Min.X] {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return changed
+}
+
+// ExportToMP4 exports a scene tree as an MP4 video of the page being drawn
+// (requires a Cairo build and ffmpeg)
+func ExportToMP4(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToMP4WithOptions(tree, w, nil)
+}
+
+// ExportToMP4WithOptions exports a scene tree as an MP4 video with control
+// over the timing, resolution and page layout. The frames are encoded as
+// H.264 by ffmpeg. A nil opts uses DefaultReplayOptions().
+func ExportToMP4WithOptions(tree *parser.SceneTree, w io.Writer, opts *ReplayOptions) error {
+	return ExportToMP4Context(context.Background(), tree, w, opts)
+}
+
+// ExportToMP4Context is ExportToMP4WithOptions with a context that stops
+// ffmpeg when it is cancelled
+func ExportToMP4Context(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *ReplayOptions) error {
+	if opts == nil {
+		opts = DefaultReplayOptions()
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if err := requireTool("ffmpeg", ffmpegHint); err != nil {
+		return err
+	}
+
+	// Stream the frames as PNG, and write a fragmented MP4 so the output can
+	// be a pipe. H.264 needs even dimensions.
+	args := []string{"-loglevel", "error",
+		"-f", "image2pipe", "-framerate", strconv.FormatFloat(opts.FPS, 'f', -1, 64), "-i", "-",
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2:color=white",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p",
+		"-movflags", "frag_keyframe+empty_moov"}
+	if opts.Deterministic {
+		// Leave out the encoder versions, which differ between installations
+		args = append(args, "-fflags", "+bitexact", "-flags:v", "+bitexact")
+	}
+	var stderr bytes.Buffer
+	stdin, wait, err := startCommand(ctx, w, &stderr, "ffmpeg", append(args, "-f", "mp4", "-")...)
+	if err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	frameInterval := time.Duration(float64(time.Second) / opts.FPS)
+	var buf bytes.Buffer
+	renderErr := renderReplayFrames(tree, opts, func(img image.Image, delay time.Duration) error {
+		buf.Reset()
+		if err := png.Encode(&buf, img); err != nil {
+			return fmt.Errorf("failed to encode frame: %w", err)
+		}
+		// A frame shown for longer is repeated
+		for range max(1, int(math.Round(float64(delay)/float64(frameInterval)))) {
+			if _, err := stdin.Write(buf.Bytes()); err != nil {
+				return fmt.Errorf("failed to write frame to ffmpeg: %w", err)
+			}
+		}
+		return nil
+	})
+	stdin.Close()
+	waitErr := wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if renderErr != nil {
+		return renderErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("ffmpeg failed: %w\n  %s", waitErr, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
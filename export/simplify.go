@@ -0,0 +1,100 @@
+package export
+
+import (
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// simplifyLine returns a copy of the line with its points reduced by
+// simplifyPoints. The line itself is returned when nothing is removed.
+func simplifyLine(line *parser.Line, tolerance float64) *parser.Line {
+	if tolerance <= 0 || len(line.Points) < 3 {
+		return line
+	}
+
+	points := simplifyPoints(line.Points, tolerance)
+	if len(points) == len(line.Points) {
+		return line
+	}
+
+	simplified := *line
+	simplified.Points = points
+	return &simplified
+}
+
+// simplifyPoints removes points that lie within tolerance (in reMarkable
+// screen units) of the polyline through the remaining points, using the
+// Ramer-Douglas-Peucker algorithm. The first and last points are always
+// kept, and kept points retain their width and pressure.
+func simplifyPoints(points []parser.Point, tolerance float64) []parser.Point {
+	if tolerance <= 0 || len(points) < 3 {
+		return points
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// Use an explicit stack so dense strokes cannot exhaust the call stack
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		maxDist, index := 0.0, -1
+		for i := s.first + 1; i < s.last; i++ {
+			if d := segmentDistance(points[i], points[s.first], points[s.last]); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+
+		if index >= 0 && maxDist > tolerance {
+			keep[index] = true
+			stack = append(stack, span{s.first, index}, span{index, s.last})
+		}
+	}
+
+	simplified := make([]parser.Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// thinLine returns a copy of the line reduced to limit evenly spaced points,
+// including the first and last. The line itself is returned when it has no
+// more than limit points or limit is zero.
+func thinLine(line *parser.Line, limit int) *parser.Line {
+	if limit <= 0 || len(line.Points) <= limit {
+		return line
+	}
+	limit = max(limit, 2)
+
+	last := len(line.Points) - 1
+	points := make([]parser.Point, limit)
+	for i := range points {
+		points[i] = line.Points[i*last/(limit-1)]
+	}
+
+	thinned := *line
+	thinned.Points = points
+	return &thinned
+}
+
+// segmentDistance returns the distance from p to the segment from a to b
+func segmentDistance(p, a, b parser.Point) float64 {
+	px, py := float64(p.X), float64(p.Y)
+	ax, ay := float64(a.X), float64(a.Y)
+	dx, dy := float64(b.X)-ax, float64(b.Y)-ay
+
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+
+	t := math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/lengthSq))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
@@ -1,10 +1,16 @@
 package export
 
 import (
+	"compress/gzip"
+	"context"
 	"fmt"
 	"html"
 	"io"
 	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joagonca/rmc-go/parser"
 )
@@ -34,83 +40,671 @@ var lineHeights = map[parser.ParagraphStyle]float64{
 	parser.StyleNumbered:        35,
 }
 
+// paragraphFont returns the font family, size in points and weight of a
+// paragraph style
+func paragraphFont(style parser.ParagraphStyle) (family string, size float64, bold bool) {
+	switch style {
+	case parser.StyleHeading:
+		return "serif", 14, false
+	case parser.StyleBold:
+		return "sans-serif", 8, true
+	default:
+		return "sans-serif", 7, false
+	}
+}
+
+// lineHeight returns the space above a paragraph of a style, in screen units
+func lineHeight(style parser.ParagraphStyle) float64 {
+	if h, ok := lineHeights[style]; ok {
+		return h
+	}
+	return 70
+}
+
+// SVGOptions controls the canvas of SVG output. The same options are used
+// for the page layout of PDF output.
+type SVGOptions struct {
+	// PageWidth and PageHeight fix the output page size in points. The content
+	// region is scaled to fit and centered. When zero, the page is sized to the
+	// content region.
+	PageWidth  float64
+	PageHeight float64
+
+	// CropToContent uses the bounds of the drawn content as the content region
+	// instead of the reMarkable page expanded to fit the content
+	CropToContent bool
+
+	// Margin adds space around the content region, in points
+	Margin float64
+
+	// Background is a CSS color used to fill the page (empty for transparent)
+	Background string
+
+	// StrokeScale multiplies all stroke widths (default: 1)
+	StrokeScale float64
+
+	// Smooth draws strokes as cubic Bezier curves fitted to the points
+	// instead of polylines, which is smoother and usually smaller
+	Smooth bool
+
+	// Palette replaces the colors of the listed pen colors, e.g. to match a
+	// brand color or to print everything in black. Use ParsePalette to read
+	// one from JSON.
+	Palette map[parser.PenColor]RGB
+
+	// PenProfile tunes the stroke model of each pen type, e.g. to match
+	// strokes to the device. Nil uses the built-in profile. Use
+	// ParsePenProfile to read one from JSON.
+	PenProfile PenProfile
+
+	// VariableWidth draws pens whose width follows pressure (ballpoint,
+	// marker, pencil, brush and calligraphy) as filled outlines, so the width
+	// changes continuously along the stroke
+	VariableWidth bool
+
+	// ChiselMarker draws marker strokes with a chisel tip turned by the
+	// direction the pen leans, as filled shapes that are broad across the
+	// tip and narrow along it, instead of round-capped lines
+	ChiselMarker bool
+
+	// PencilGradient draws pencil strokes in short pieces whose opacity and
+	// width follow the pressure smoothly along the stroke, for sketches that
+	// keep their shading, at the cost of larger output. It takes precedence
+	// over VariableWidth for pencils.
+	PencilGradient bool
+
+	// SimplifyTolerance removes stroke points that deviate less than this
+	// distance (in reMarkable screen units) from the simplified stroke, which
+	// keeps dense pages small. Zero keeps every point.
+	SimplifyTolerance float64
+
+	// MaxStrokePoints thins strokes with more points than this to this many
+	// evenly spaced points, keeping their ends, to bound the drawing time of
+	// dense pages, e.g. for thumbnails. Zero keeps every point.
+	MaxStrokePoints int
+
+	// Landscape turns the page a quarter turn clockwise, for notebooks written
+	// in landscape orientation. Fixed page sizes are used in landscape too.
+	Landscape bool
+
+	// OutputScale multiplies the size of the output page and everything on
+	// it, e.g. 2 for a poster or 0.25 for a thumbnail. Zero means 1.
+	OutputScale float64
+
+	// OutputWidth and OutputHeight resize the output page and everything on
+	// it to this width or height in points (pixels for PNG). When only one is
+	// set the other follows the aspect ratio; when both are set the content
+	// is scaled to fit and centered. They take precedence over OutputScale.
+	OutputWidth  float64
+	OutputHeight float64
+
+	// KeepErasers draws eraser strokes as white strokes and erase areas as
+	// invisible ones instead of removing the ink they cover. By default,
+	// erased parts of the strokes drawn before an eraser in the same layer
+	// are left out, as on the device.
+	KeepErasers bool
+
+	// StrokeTimes draws only the strokes created in a time range, e.g. to
+	// export what was written today. Strokes whose file has no creation
+	// times are left out when it is set; typed text is always drawn. Erasers
+	// outside the range still remove the ink they cover.
+	StrokeTimes parser.TimeRange
+
+	// OnlyTools draws only the strokes of these pen types, e.g. the
+	// highlighter for an overlay of the highlights; empty draws every pen.
+	// A pen matches both its older and newer tool IDs. Highlighted PDF or
+	// EPUB text drawn by SnapHighlights counts as highlighter ink. Erasers
+	// still remove the ink they cover.
+	OnlyTools []parser.Pen
+
+	// ExcludeColors leaves out the strokes drawn in these colors, e.g.
+	// yellow highlighter marks when printing
+	ExcludeColors []parser.PenColor
+
+	// GroupOpacity multiplies the opacity of the strokes in the groups with
+	// these IDs and their subgroups, e.g. to fade the layers of a page drawn
+	// under another one (see CompositeTree). Typed text is drawn opaque.
+	GroupOpacity map[parser.CrdtID]float64
+
+	// Animate makes SVG and HTML output replay the strokes as they were
+	// drawn, taking this long in total, using SMIL animation. Strokes are
+	// drawn in the order they were created when the file records it, and in
+	// drawing order otherwise. Filled outlines (see VariableWidth and
+	// ChiselMarker) and shader strokes appear whole. Zero draws a still page.
+	Animate time.Duration
+
+	// SnapHighlights draws text highlighted in a PDF or EPUB as clean
+	// rectangles over the highlighted text, like the device does, instead of
+	// the freehand highlighter strokes that mark it. Strokes that mark no
+	// text are drawn as usual.
+	SnapHighlights bool
+
+	// Deterministic makes identical input give byte-identical output: SVG
+	// groups are numbered in document order instead of named after their
+	// scene IDs, no negative zeros are written, and PDF and EPS output get
+	// fixed dates and identifiers (those of PDFOptions.Metadata, or the Unix
+	// epoch) instead of the time of the conversion
+	Deterministic bool
+
+	// Compact writes smaller SVG and HTML: strokes are paths with relative
+	// coordinates rounded to Precision decimals, consecutive segments of a
+	// stroke with the same style are joined, and styles are shared through
+	// CSS classes instead of being repeated on every element
+	Compact bool
+
+	// Precision is the number of decimals of coordinates in compact SVG
+	// (default: 2)
+	Precision int
+
+	// Glyphs are drawn before list and checkbox paragraphs, e.g. ASCIIGlyphs
+	// for fonts without bullets and ballot boxes. Nil uses the package-level
+	// Glyphs, which is UnicodeGlyphs unless changed.
+	Glyphs *GlyphSet
+
+	// PageIDs are the IDs of the exported pages by page index, to which links
+	// to pages made on the device point: to the page in PDFs drawn with
+	// Cairo, and to #page=N in SVG and HTML. Links to other pages are drawn
+	// as plain text.
+	PageIDs []string
+
+	// replayAt draws the page as it is this many seconds into the Animate
+	// replay, for the frames of a raster replay. Zero draws every stroke.
+	replayAt float64
+
+	// Recognizer, when set, adds an invisible text layer of recognized handwriting
+	Recognizer Recognizer
+}
+
+// DefaultSVGOptions returns the options used by ExportToSVG
+func DefaultSVGOptions() *SVGOptions {
+	return &SVGOptions{
+		StrokeScale: 1.0,
+	}
+}
+
 // ExportToSVG exports a scene tree to SVG format
 func ExportToSVG(tree *parser.SceneTree, w io.Writer) error {
-	if tree == nil {
-		return fmt.Errorf("scene tree cannot be nil")
+	return ExportToSVGWithOptions(tree, w, nil)
+}
+
+// ExportToSVGWithOptions exports a scene tree to SVG format with control over
+// the output canvas. A nil opts uses DefaultSVGOptions().
+func ExportToSVGWithOptions(tree *parser.SceneTree, w io.Writer, opts *SVGOptions) error {
+	return ExportToSVGContext(context.Background(), tree, w, opts)
+}
+
+// ExportToSVGContext is like ExportToSVGWithOptions, but passes ctx to
+// opts.Recognizer
+func ExportToSVGContext(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *SVGOptions) error {
+	return writeSVG(ctx, tree, w, opts, true)
+}
+
+// ExportToSVGZWithOptions exports a scene tree to gzip-compressed SVG
+// (.svgz). A nil opts uses DefaultSVGOptions().
+func ExportToSVGZWithOptions(tree *parser.SceneTree, w io.Writer, opts *SVGOptions) error {
+	zw := gzip.NewWriter(w)
+	if err := ExportToSVGWithOptions(tree, zw, opts); err != nil {
+		return err
 	}
-	if tree.Root == nil {
-		return fmt.Errorf("scene tree root cannot be nil")
+	return zw.Close()
+}
+
+// writeSVG renders a scene tree as an SVG document. When standalone is false
+// the XML declaration and typed text are left out so the SVG can be embedded
+// in a document that renders the text itself.
+func writeSVG(ctx context.Context, tree *parser.SceneTree, w io.Writer, opts *SVGOptions, standalone bool) error {
+	r := &svgRenderer{w: w, standalone: standalone, deterministic: opts != nil && opts.Deterministic}
+	if opts != nil && opts.Compact {
+		r.compact = newCompactSVG(opts.Precision)
 	}
+	return RenderContext(ctx, tree, r, opts)
+}
 
-	// Build anchor positions (including text-based anchors)
-	anchorPos := buildAnchorPos(tree.RootText)
+// svgRenderer writes a page as an SVG document, keeping its groups
+type svgRenderer struct {
+	w             io.Writer
+	standalone    bool
+	depth         int         // Number of open groups
+	deterministic bool        // See SVGOptions.Deterministic
+	groups        int         // Number of groups written
+	compact       *compactSVG // Set for SVGOptions.Compact
+	buf           svgBuffer   // Markup of the element being written
+}
 
-	// Calculate bounding box using the anchor positions
-	xMin, xMax, yMin, yMax := getBoundingBox(tree.Root, anchorPos)
+// shapes returns the writer for elements without text: with deterministic
+// output, numbers rounded to zero from below are written without the sign
+func (r *svgRenderer) shapes() io.Writer {
+	if r.deterministic {
+		return negativeZeroWriter{r.w}
+	}
+	return r.w
+}
 
-	// Include text area in bounding box calculation
-	if tree.RootText != nil {
-		textMinX := tree.RootText.PosX
-		textMaxX := tree.RootText.PosX + float64(tree.RootText.Width)
-
-		// Calculate text Y range by going through all paragraphs
-		// This matches the actual rendering logic in drawText()
-		doc, err := parser.BuildTextDocument(tree.RootText)
-		if err == nil && len(doc.Paragraphs) > 0 {
-			yOffset := TextTopY
-			textMinY := math.MaxFloat64
-			textMaxY := -math.MaxFloat64
-
-			for _, p := range doc.Paragraphs {
-				lineHeight := lineHeights[p.Style]
-				if lineHeight == 0 {
-					lineHeight = 70
-				}
-				yOffset += lineHeight
-				yPos := tree.RootText.PosY + yOffset
+// negativeZero matches numbers that were rounded to zero from below
+var negativeZero = regexp.MustCompile(`-(0\.0+)([^0-9]|$)`)
 
-				// Track min and max Y positions
-				textMinY = math.Min(textMinY, yPos)
-				textMaxY = math.Max(textMaxY, yPos)
-			}
+// negativeZeroWriter writes "-0.000" as "0.000". Each write holds whole
+// numbers, since numbers are formatted by a single Fprintf or svgBuffer call.
+type negativeZeroWriter struct {
+	w io.Writer
+}
+
+func (z negativeZeroWriter) Write(p []byte) (int, error) {
+	if _, err := z.w.Write(negativeZero.ReplaceAll(p, []byte("$1$2"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (r *svgRenderer) indent() string {
+	if r.compact != nil {
+		return ""
+	}
+	return strings.Repeat("\t", r.depth+2)
+}
+
+func (r *svgRenderer) BeginPage(page Page) error {
+	if r.compact != nil {
+		r.compact.out = r.w
+		r.w = &r.compact.body
+	}
+	w := r.shapes()
+	if r.standalone {
+		fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	}
+	// Standalone documents name the layers for editors, which need the
+	// Inkscape namespace
+	namespaces := ""
+	if r.standalone {
+		namespaces = ` xmlns:inkscape="` + inkscapeNamespace + `"`
+	}
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg"%s height="%.1f" width="%.1f" viewBox="%.1f %.1f %.1f %.1f">
+`, namespaces, page.Height, page.Width, page.View.X, page.View.Y, page.View.Width, page.View.Height)
+
+	if page.Background != "" {
+		// The viewBox is scaled to fit and centered, so cover the whole visible page
+		factor, offsetX, offsetY := page.Fit()
+		fmt.Fprintf(w, "\t<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"%s\"/>\n",
+			page.View.X-offsetX/factor, page.View.Y-offsetY/factor,
+			page.Width/factor, page.Height/factor, htmlEscape(page.Background))
+	}
+
+	// Inkscape only shows layers whose enclosing groups are layers too, so
+	// the page group is one in standalone documents
+	pageAttrs := ""
+	if r.standalone {
+		pageAttrs = ` inkscape:groupmode="layer" inkscape:label="Page"`
+	}
+	if page.Rotated {
+		fmt.Fprintf(w, "\t<g id=\"p1\"%s style=\"display:inline\" transform=\"rotate(90)\">\n", pageAttrs)
+	} else {
+		fmt.Fprintf(w, "\t<g id=\"p1\"%s style=\"display:inline\">\n", pageAttrs)
+	}
+	if r.compact != nil {
+		r.compact.headerLen = r.compact.body.Len()
+	}
+	return nil
+}
+
+func (r *svgRenderer) EndPage() error {
+	fmt.Fprintf(r.w, "\t</g>\n")
+	fmt.Fprintf(r.w, "</svg>\n")
+	if r.compact != nil {
+		r.w = r.compact.out
+		return r.compact.finish()
+	}
+	return nil
+}
+
+func (r *svgRenderer) BeginGroup(group Group) error {
+	id := group.ID.String()
+	if r.deterministic {
+		r.groups++
+		id = fmt.Sprintf("g%d", r.groups)
+	}
+	fmt.Fprintf(r.w, "%s<g id=\"%s\"%s", r.indent(), id, r.layerAttributes(group))
+	switch {
+	case r.compact == nil:
+		fmt.Fprintf(r.shapes(), " transform=\"translate(%.3f, %.3f)\">\n", group.X, group.Y)
+	case group.X == 0 && group.Y == 0:
+		fmt.Fprintf(r.w, ">\n")
+	default:
+		fmt.Fprintf(r.w, " transform=\"translate(%s %s)\">\n",
+			r.compact.number(group.X), r.compact.number(group.Y))
+	}
+	r.depth++
+	return nil
+}
+
+// inkscapeNamespace is the XML namespace of Inkscape's editor attributes
+const inkscapeNamespace = "http://www.inkscape.org/namespaces/inkscape"
+
+// layerAttributes returns the attributes that make Inkscape show a layer of
+// a standalone document as an editable layer, with its name from the device.
+// The root group holding the layers is marked as a layer as well, as Inkscape
+// ignores layers inside plain groups.
+func (r *svgRenderer) layerAttributes(group Group) string {
+	if !r.standalone {
+		return ""
+	}
+	switch {
+	case r.depth == 0:
+		return ` inkscape:groupmode="layer" inkscape:label="Layers"`
+	case !group.Layer:
+		return ""
+	}
+	attrs := ` inkscape:groupmode="layer"`
+	if group.Label != "" {
+		attrs += ` inkscape:label="` + xmlEscape(group.Label) + `"`
+	}
+	return attrs
+}
+
+func (r *svgRenderer) EndGroup() error {
+	r.depth--
+	fmt.Fprintf(r.w, "%s</g>\n", r.indent())
+	return nil
+}
 
-			xMin = math.Min(xMin, textMinX)
-			xMax = math.Max(xMax, textMaxX)
-			yMin = math.Min(yMin, textMinY)
-			yMax = math.Max(yMax, textMaxY)
+func (r *svgRenderer) DrawStroke(stroke Stroke) error {
+	blend := ""
+	if stroke.Multiply {
+		blend = "; mix-blend-mode:multiply"
+	}
+
+	// Animated strokes are drawn one segment after the other
+	var spans []timeSpan
+	if stroke.Duration > 0 {
+		lengths := make([]float64, len(stroke.Segments))
+		for i, segment := range stroke.Segments {
+			lengths[i] = pathLength(segment.Path)
+		}
+		spans = timeSpan{begin: stroke.Begin, duration: stroke.Duration}.split(lengths)
+	}
+
+	if r.compact != nil {
+		r.compact.drawStroke(r.w, stroke, spans)
+		return nil
+	}
+
+	for i, segment := range stroke.Segments {
+		var span *timeSpan
+		if spans != nil {
+			span = &spans[i]
+		}
+		if segment.Fill {
+			drawFilledPath(r.shapes(), &r.buf, segment, blend, r.indent(), span)
+		} else {
+			drawStrokedPath(r.shapes(), &r.buf, segment, stroke.Cap, blend, r.indent(), span)
 		}
 	}
+	return nil
+}
+
+// svgBuffer collects the markup of an element before it is written in one
+// go. Numbers are formatted with strconv, which unlike fmt does not allocate,
+// and the buffer is reused from one element to the next.
+type svgBuffer struct {
+	b []byte
+}
+
+func (s *svgBuffer) str(v string) {
+	s.b = append(s.b, v...)
+}
 
-	width := scale(xMax - xMin + 1)
-	height := scale(yMax - yMin + 1)
+// num writes a number with three decimals, like %.3f
+func (s *svgBuffer) num(v float64) {
+	s.b = strconv.AppendFloat(s.b, v, 'f', 3, 64)
+}
+
+// point writes a point as "x,y"
+func (s *svgBuffer) point(p Point) {
+	s.num(p.X)
+	s.b = append(s.b, ',')
+	s.num(p.Y)
+}
 
-	// Write SVG header
-	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
-<svg xmlns="http://www.w3.org/2000/svg" height="%.1f" width="%.1f" viewBox="%.1f %.1f %.1f %.1f">
-`, height, width, scale(xMin), scale(yMin), width, height)
+// flush writes the buffer to w and empties it
+func (s *svgBuffer) flush(w io.Writer) {
+	w.Write(s.b)
+	s.b = s.b[:0]
+}
+
+// drawStrokedPath writes a stroked segment, as a polyline when it has no
+// curves. With a span, the segment is drawn during that part of the replay.
+func drawStrokedPath(w io.Writer, buf *svgBuffer, segment StrokeSegment, linecap, blend, indent string, span *timeSpan) {
+	polyline := true
+	for _, e := range segment.Path {
+		if e.Op != PathMoveTo && e.Op != PathLineTo {
+			polyline = false
+		}
+	}
 
-	fmt.Fprintf(w, "\t<g id=\"p1\" style=\"display:inline\">\n")
+	element := "path"
+	if polyline {
+		element = "polyline"
+	}
+	buf.b = fmt.Appendf(buf.b, "%s<%s style=\"fill:none; stroke:rgb(%d,%d,%d); stroke-width:%.3f; opacity:%.3f%s\" stroke-linecap=\"%s\" ",
+		indent, element, segment.Color.R, segment.Color.G, segment.Color.B, segment.Width, segment.Opacity, blend, linecap)
+	if span != nil {
+		buf.str(revealAttrs(true))
+	}
 
-	// Render RootText if it exists
-	if tree.RootText != nil {
-		if err := drawText(tree.RootText, w, "\t\t"); err != nil {
-			return fmt.Errorf("failed to draw root text: %w", err)
+	if polyline {
+		buf.str("points=\"")
+		for _, e := range segment.Path {
+			buf.point(e.Points[0])
+			buf.str(" ")
 		}
+	} else {
+		buf.str("d=\"")
+		writePathData(buf, segment.Path)
+	}
+	closeElement(w, buf, element, indent, span, true)
+}
+
+// drawFilledPath writes a filled segment. With a span, the segment appears
+// at the start of that part of the replay.
+func drawFilledPath(w io.Writer, buf *svgBuffer, segment StrokeSegment, blend, indent string, span *timeSpan) {
+	buf.b = fmt.Appendf(buf.b, "%s<path style=\"fill:rgb(%d,%d,%d); stroke:none; opacity:%.3f%s\" ",
+		indent, segment.Color.R, segment.Color.G, segment.Color.B, segment.Opacity, blend)
+	if span != nil {
+		buf.str(revealAttrs(false))
+	}
+	buf.str("d=\"")
+	writePathData(buf, segment.Path)
+	closeElement(w, buf, "path", indent, span, false)
+}
+
+// closeElement ends the path data of a segment element, writes the element
+// and closes it, with the animation that reveals it when there is a span
+func closeElement(w io.Writer, buf *svgBuffer, element, indent string, span *timeSpan, stroked bool) {
+	if span == nil {
+		buf.str("\" />\n")
+		buf.flush(w)
+		return
 	}
+	buf.str("\">\n")
+	buf.flush(w)
+	writeReveal(w, *span, stroked, indent)
+	fmt.Fprintf(w, "%s</%s>\n", indent, element)
+}
 
-	// Draw content (use anchor positions without text for strokes)
-	if err := drawGroup(tree.Root, w, anchorPos, "\t\t"); err != nil {
-		return fmt.Errorf("failed to draw group: %w", err)
+// writePathData writes path elements as SVG path data. Subpaths are
+// separated by spaces, and consecutive lines share one L command.
+func writePathData(buf *svgBuffer, path []PathElement) {
+	var last PathOp = -1
+	for i, e := range path {
+		p := e.Points
+		switch e.Op {
+		case PathMoveTo:
+			buf.str("M")
+			buf.point(p[0])
+		case PathLineTo:
+			if last == PathLineTo {
+				buf.str(" ")
+			} else {
+				buf.str(" L")
+			}
+			buf.point(p[0])
+		case PathCurveTo:
+			buf.str(" C")
+			buf.point(p[0])
+			buf.str(" ")
+			buf.point(p[1])
+			buf.str(" ")
+			buf.point(p[2])
+		case PathClose:
+			buf.str(" Z ")
+		case PathCircle:
+			// Two clockwise half circles
+			if i > 0 && last != PathClose && last != PathCircle {
+				buf.str(" ")
+			}
+			c, r := p[0], e.Radius
+			buf.b = fmt.Appendf(buf.b, "M%.3f,%.3f a%.3f,%.3f 0 1,1 %.3f,0 a%.3f,%.3f 0 1,1 %.3f,0 Z ",
+				c.X-r, c.Y, r, r, 2*r, r, r, -2*r)
+		}
+		last = e.Op
 	}
+}
 
-	// Close
-	fmt.Fprintf(w, "\t</g>\n")
-	fmt.Fprintf(w, "</svg>\n")
+func (r *svgRenderer) DrawText(text Text) error {
+	if len(text.Words) > 0 {
+		drawRecognizedText(text.Words, r.w, r.indent())
+		return nil
+	}
 
+	// Typed text outside groups is left to the document embedding the SVG
+	if !r.standalone && r.depth == 0 {
+		return nil
+	}
+	drawText(text.Paragraphs, r.w, r.indent())
 	return nil
 }
 
+// bounds is a rectangle in reMarkable screen coordinates
+type bounds struct {
+	xMin, xMax, yMin, yMax float64
+}
+
+// emptyBounds returns bounds that contain nothing and grow as points are added
+func emptyBounds() bounds {
+	return bounds{xMin: math.MaxFloat64, xMax: -math.MaxFloat64, yMin: math.MaxFloat64, yMax: -math.MaxFloat64}
+}
+
+func (b bounds) isEmpty() bool {
+	return b.xMin > b.xMax || b.yMin > b.yMax
+}
+
+func (b *bounds) include(x, y float64) {
+	b.xMin = math.Min(b.xMin, x)
+	b.xMax = math.Max(b.xMax, x)
+	b.yMin = math.Min(b.yMin, y)
+	b.yMax = math.Max(b.yMax, y)
+}
+
+func (b *bounds) union(o bounds) {
+	if o.isEmpty() {
+		return
+	}
+	b.include(o.xMin, o.yMin)
+	b.include(o.xMax, o.yMax)
+}
+
+// getPageBounds returns the region of the page to render. By default this is the
+// reMarkable screen expanded to fit the content; with cropToContent only the
+// drawn content (text, and the strokes selected by the options) is included.
+func getPageBounds(tree *parser.SceneTree, anchorPos map[parser.CrdtID]float64, cropToContent bool, opts *SVGOptions) bounds {
+	var b bounds
+	if cropToContent {
+		b = getContentBounds(tree.Root, anchorPos, opts)
+	} else {
+		b.xMin, b.xMax, b.yMin, b.yMax = getBoundingBox(tree.Root, anchorPos)
+	}
+
+	// Include text area in bounding box calculation
+	b.union(getTextBounds(tree.RootText))
+
+	if b.isEmpty() {
+		// Nothing drawn, fall back to the device page
+		return bounds{xMin: -float64(ScreenWidth) / 2, xMax: float64(ScreenWidth) / 2, yMax: float64(ScreenHeight)}
+	}
+	return b
+}
+
+// getTextBounds returns the area covered by a text block
+func getTextBounds(text *parser.Text) bounds {
+	b := emptyBounds()
+	if text == nil {
+		return b
+	}
+
+	// Calculate text Y range by going through all paragraphs
+	// This matches the actual rendering logic in drawText()
+	doc, err := parser.BuildTextDocument(text)
+	if err != nil || len(doc.Paragraphs) == 0 {
+		return b
+	}
+
+	yOffset := TextTopY
+	for _, p := range doc.Paragraphs {
+		yOffset += lineHeight(p.Style)
+		yPos := text.PosY + yOffset
+
+		b.include(text.PosX, yPos)
+		b.include(text.PosX+float64(text.Width), yPos)
+	}
+
+	return b
+}
+
+// getContentBounds returns the tight bounds of the text boxes in a group and
+// of the strokes selected by the options
+func getContentBounds(group *parser.Group, anchorPos map[parser.CrdtID]float64, opts *SVGOptions) bounds {
+	b := emptyBounds()
+	if group.Children == nil {
+		return b
+	}
+
+	for _, item := range group.Children.Items {
+		if item.Deleted() {
+			continue
+		}
+
+		switch v := item.Value.(type) {
+		case *parser.Group:
+			anchorX, anchorY := getAnchor(v, anchorPos)
+			child := getContentBounds(v, anchorPos, opts)
+			if !child.isEmpty() {
+				b.include(child.xMin+anchorX, child.yMin+anchorY)
+				b.include(child.xMax+anchorX, child.yMax+anchorY)
+			}
+
+		case *parser.Line:
+			// Erasers leave no ink of their own
+			if v.Tool == parser.PenEraser || v.Tool == parser.PenEraserArea || !opts.drawsLine(v) {
+				continue
+			}
+
+			// Leave room for the width of the stroke, so it is not cut in half
+			// at the edges
+			half := createPen(v.Tool, v.Color, v.ColorOverride, v.ThicknessScale, nil).baseWidth / 2
+			for _, p := range v.Points {
+				r := math.Max(half, float64(p.Width)/8)
+				b.include(float64(p.X)-r, float64(p.Y)-r)
+				b.include(float64(p.X)+r, float64(p.Y)+r)
+			}
+
+		case *parser.Text:
+			// Text boxes placed in a layer are always drawn, like the root text
+			b.union(getTextBounds(v))
+		}
+	}
+
+	return b
+}
+
 func scale(v float64) float64 {
 	return v * Scale
 }
@@ -129,7 +723,7 @@ func buildAnchorPos(text *parser.Text) map[parser.CrdtID]float64 {
 
 		// Process each text item
 		for _, item := range text.Items.Items {
-			if item.DeletedLength > 0 || item.Value == nil {
+			if item.Deleted() || item.Value == nil {
 				continue
 			}
 
@@ -157,11 +751,7 @@ func buildAnchorPos(text *parser.Text) map[parser.CrdtID]float64 {
 				// Only increment on newlines (not on the first character)
 				if ch == '\n' {
 					// Get line height for current style
-					lineHeight := lineHeights[currentStyle]
-					if lineHeight == 0 {
-						lineHeight = 70
-					}
-					yOffset += lineHeight
+					yOffset += lineHeight(currentStyle)
 
 					// Map this character's ID to its Y position
 					anchorPos[charID] = text.PosY + yOffset
@@ -188,7 +778,7 @@ func getBoundingBox(group *parser.Group, anchorPos map[parser.CrdtID]float64) (f
 	}
 
 	for _, item := range group.Children.Items {
-		if item.Value == nil {
+		if item.Value == nil || item.Deleted() {
 			continue
 		}
 
@@ -208,6 +798,12 @@ func getBoundingBox(group *parser.Group, anchorPos map[parser.CrdtID]float64) (f
 				yMin = math.Min(yMin, float64(p.Y))
 				yMax = math.Max(yMax, float64(p.Y))
 			}
+
+		case *parser.Text:
+			if t := getTextBounds(v); !t.isEmpty() {
+				xMin, xMax = math.Min(xMin, t.xMin), math.Max(xMax, t.xMax)
+				yMin, yMax = math.Min(yMin, t.yMin), math.Max(yMax, t.yMax)
+			}
 		}
 	}
 
@@ -228,125 +824,26 @@ func getAnchor(group *parser.Group, anchorPos map[parser.CrdtID]float64) (float6
 	return anchorX, anchorY
 }
 
-func drawGroup(group *parser.Group, w io.Writer, anchorPos map[parser.CrdtID]float64, indent string) error {
-	anchorX, anchorY := getAnchor(group, anchorPos)
-	fmt.Fprintf(w, "%s<g id=\"%s\" transform=\"translate(%.3f, %.3f)\">\n",
-		indent, group.NodeID, scale(anchorX), scale(anchorY))
-
-	if group.Children != nil {
-		for _, item := range group.Children.Items {
-			if item.Value == nil {
-				continue
-			}
-
-			switch v := item.Value.(type) {
-			case *parser.Group:
-				if err := drawGroup(v, w, anchorPos, indent+"\t"); err != nil {
-					return err
-				}
-			case *parser.Line:
-				drawStroke(v, w, indent+"\t")
-			case *parser.Text:
-				if err := drawText(v, w, indent+"\t"); err != nil {
-					return err
-				}
-			}
-		}
-	}
-
-	fmt.Fprintf(w, "%s</g>\n", indent)
-	return nil
-}
-
-func drawStroke(line *parser.Line, w io.Writer, indent string) {
-	pen := createPen(line.Tool, line.Color, line.ColorOverride, line.ThicknessScale)
-
-	lastXPos := -1.0
-	lastYPos := -1.0
-	lastSegmentWidth := 0.0
-
-	for i, point := range line.Points {
-		xPos := float64(point.X)
-		yPos := float64(point.Y)
-
-		if i%pen.segmentLength == 0 {
-			// End previous segment
-			if lastXPos != -1.0 {
-				fmt.Fprintf(w, "\"/>\n")
-			}
-
-			segmentColor := pen.getSegmentColor(point, lastSegmentWidth)
-			segmentWidth := pen.getSegmentWidth(point, lastSegmentWidth)
-			segmentOpacity := pen.getSegmentOpacity(point, lastSegmentWidth)
-
-			fmt.Fprintf(w, "%s<polyline ", indent)
-			fmt.Fprintf(w, "style=\"fill:none; stroke:%s; stroke-width:%.3f; opacity:%.3f\" ",
-				segmentColor, scale(segmentWidth), segmentOpacity)
-			fmt.Fprintf(w, "stroke-linecap=\"%s\" ", pen.strokeLinecap)
-			fmt.Fprintf(w, "points=\"")
-
-			if lastXPos != -1.0 {
-				fmt.Fprintf(w, "%.3f,%.3f ", scale(lastXPos), scale(lastYPos))
-			}
-
-			lastSegmentWidth = segmentWidth
-		}
-
-		lastXPos = xPos
-		lastYPos = yPos
-
-		fmt.Fprintf(w, "%.3f,%.3f ", scale(xPos), scale(yPos))
-	}
-
-	fmt.Fprintf(w, "\" />\n")
-}
-
-func drawText(text *parser.Text, w io.Writer, indent string) error {
-	// Convert text to TextDocument
-	doc, err := parser.BuildTextDocument(text)
-	if err != nil {
-		return fmt.Errorf("failed to build text document: %w", err)
-	}
-
+// drawText writes the paragraphs of a text block as SVG text elements
+func drawText(paragraphs []TextParagraph, w io.Writer, indent string) {
 	// Write opening group tag
 	fmt.Fprintf(w, "%s<g class=\"root-text\" style=\"display:inline\">\n", indent)
 
 	// Write CSS style block
 	writeTextStyles(w, indent+"\t")
 
-	// Iterate through paragraphs
-	yOffset := TextTopY
-	bulletNumber := 1 // Counter for numbered bullets (StyleBullet2)
-	for _, p := range doc.Paragraphs {
-		// Get line height for this style
-		lineHeight := lineHeights[p.Style]
-		if lineHeight == 0 {
-			lineHeight = 70 // default
+	for _, p := range paragraphs {
+		displayText := htmlEscape(p.Prefix + p.Text)
+		if para := (parser.Paragraph{Spans: p.Spans}); para.HasInlineFormatting() || para.HasLinks() {
+			displayText = htmlEscape(p.Prefix) + formatSpans(p.Spans)
 		}
-		yOffset += lineHeight
-
-		// Calculate position
-		xPos := text.PosX
-		yPos := text.PosY + yOffset
 
-		// Get CSS class name
-		className := getStyleClassName(p.Style)
-
-		// Write text element (skip empty lines as they just add spacing)
-		trimmedText := p.Text // Don't trim - preserve spacing
-		if trimmedText != "" {
-			// Add appropriate prefix based on style
-			prefix := getParagraphPrefix(p.Style, &bulletNumber)
-			displayText := prefix + trimmedText
-
-			fmt.Fprintf(w, "%s<text x=\"%.3f\" y=\"%.3f\" class=\"%s\">%s</text>\n",
-				indent+"\t", scale(xPos), scale(yPos), className, htmlEscape(displayText))
-		}
+		fmt.Fprintf(w, "%s<text x=\"%.3f\" y=\"%.3f\" class=\"%s\">%s</text>\n",
+			indent+"\t", p.X, p.Y, getStyleClassName(p.Style), displayText)
 	}
 
 	// Close group
 	fmt.Fprintf(w, "%s</g>\n", indent)
-	return nil
 }
 
 func writeTextStyles(w io.Writer, indent string) {
@@ -385,32 +882,35 @@ func getStyleClassName(style parser.ParagraphStyle) string {
 	}
 }
 
+// formatSpans renders inline formatted spans as escaped SVG tspan elements
+func formatSpans(spans []parser.TextSpan) string {
+	var sb strings.Builder
+	for _, span := range spans {
+		if span.Link != "" {
+			fmt.Fprintf(&sb, `<a href="%s">`, htmlEscape(span.Link))
+		}
+		if !span.Bold && !span.Italic {
+			sb.WriteString(htmlEscape(span.Text))
+		} else {
+			sb.WriteString("<tspan")
+			if span.Bold {
+				sb.WriteString(` font-weight="bold"`)
+			}
+			if span.Italic {
+				sb.WriteString(` font-style="italic"`)
+			}
+			sb.WriteString(">")
+			sb.WriteString(htmlEscape(span.Text))
+			sb.WriteString("</tspan>")
+		}
+		if span.Link != "" {
+			sb.WriteString("</a>")
+		}
+	}
+	return sb.String()
+}
+
 func htmlEscape(s string) string {
 	// Use standard library for proper HTML escaping
 	return html.EscapeString(s)
 }
-
-// getParagraphPrefix returns the appropriate prefix for a paragraph based on its style
-func getParagraphPrefix(style parser.ParagraphStyle, bulletNumber *int) string {
-	switch style {
-	case parser.StyleBullet:
-		// Regular bullet point
-		return "• "
-	case parser.StyleBullet2:
-		// Alternative bullet (could also be numbered in some contexts)
-		return "• "
-	case parser.StyleNumbered:
-		// Numbered list
-		prefix := fmt.Sprintf("%d. ", *bulletNumber)
-		*bulletNumber++
-		return prefix
-	case parser.StyleCheckbox:
-		// Unchecked checkbox - using ballot box Unicode character
-		return "☐ "
-	case parser.StyleCheckboxChecked:
-		// Checked checkbox - using ballot box with check Unicode character
-		return "☑ "
-	default:
-		return ""
-	}
-}
@@ -0,0 +1,260 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// defaultPrecision is the number of decimals of compact SVG coordinates
+// when SVGOptions.Precision is zero
+const defaultPrecision = 2
+
+// compactSVG collects a page of compact SVG, whose stroke styles are shared
+// through CSS classes written once the whole page is known
+type compactSVG struct {
+	out       io.Writer    // Writer of the finished page
+	body      bytes.Buffer // Page being written
+	headerLen int          // Length of the body up to the page group
+	precision int
+	classes   map[string]string // Class name by style
+	styles    []string          // Styles in the order their classes were named
+}
+
+func newCompactSVG(precision int) *compactSVG {
+	if precision <= 0 {
+		precision = defaultPrecision
+	}
+	return &compactSVG{precision: precision, classes: make(map[string]string)}
+}
+
+// class returns the class name of a style, naming it s0, s1, ... in the
+// order styles are first used
+func (c *compactSVG) class(style string) string {
+	name, ok := c.classes[style]
+	if !ok {
+		name = "s" + strconv.Itoa(len(c.styles))
+		c.classes[style] = name
+		c.styles = append(c.styles, style)
+	}
+	return name
+}
+
+// finish writes the page with its style sheet at the start of the page group
+func (c *compactSVG) finish() error {
+	page := c.body.Bytes()
+	var styles strings.Builder
+	styles.WriteString("<style>\n")
+	for _, style := range c.styles {
+		fmt.Fprintf(&styles, ".%s{%s}\n", c.classes[style], style)
+	}
+	styles.WriteString("</style>\n")
+
+	for _, part := range [][]byte{page[:c.headerLen], []byte(styles.String()), page[c.headerLen:]} {
+		if _, err := c.out.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// number formats a value with the page's precision, without trailing zeros
+// or a leading zero
+func (c *compactSVG) number(v float64) string {
+	return formatCompact(int64(math.Round(v*math.Pow10(c.precision))), c.precision)
+}
+
+// segmentStyle returns the CSS of a segment. Opacity 1 is left out.
+func (c *compactSVG) segmentStyle(segment StrokeSegment, linecap string, multiply bool) string {
+	color := fmt.Sprintf("#%02x%02x%02x", segment.Color.R, segment.Color.G, segment.Color.B)
+	var style string
+	if segment.Fill {
+		style = "fill:" + color
+	} else {
+		style = fmt.Sprintf("fill:none;stroke:%s;stroke-width:%s;stroke-linecap:%s", color, c.number(segment.Width), linecap)
+	}
+	if opacity := formatCompact(int64(math.Round(segment.Opacity*1000)), 3); opacity != "1" {
+		style += ";opacity:" + opacity
+	}
+	if multiply {
+		style += ";mix-blend-mode:multiply"
+	}
+	return style
+}
+
+// drawStroke writes the segments of a stroke as paths with a class per
+// style. Consecutive segments with the same style are joined into one path,
+// except when animated, where each segment is revealed on its own.
+func (c *compactSVG) drawStroke(w io.Writer, stroke Stroke, spans []timeSpan) {
+	var path *compactPath
+	var class string
+	flush := func() {
+		if path != nil {
+			fmt.Fprintf(w, "<path class=\"%s\" d=\"%s\"/>\n", class, path.b.String())
+			path = nil
+		}
+	}
+
+	for i, segment := range stroke.Segments {
+		segmentClass := c.class(c.segmentStyle(segment, stroke.Cap, stroke.Multiply))
+		if spans != nil {
+			path = &compactPath{scale: math.Pow10(c.precision), precision: c.precision}
+			path.write(segment.Path)
+			fmt.Fprintf(w, "<path class=\"%s\" %sd=\"%s\">\n", segmentClass, revealAttrs(!segment.Fill), path.b.String())
+			writeReveal(w, spans[i], !segment.Fill, "")
+			fmt.Fprintf(w, "</path>\n")
+			path = nil
+			continue
+		}
+		if path == nil || segmentClass != class {
+			flush()
+			path = &compactPath{scale: math.Pow10(c.precision), precision: c.precision}
+			class = segmentClass
+		}
+		path.write(segment.Path)
+	}
+	flush()
+}
+
+// compactPath writes path data with relative coordinates, rounded to whole
+// multiples of the precision so that rounding errors do not add up
+type compactPath struct {
+	b         strings.Builder
+	scale     float64 // 10^precision
+	precision int
+	x, y      int64 // Current point, in multiples of the precision
+	startX    int64 // Start of the current subpath
+	startY    int64
+	command   byte // Last command written
+}
+
+func (p *compactPath) quantize(pt Point) (int64, int64) {
+	return int64(math.Round(pt.X * p.scale)), int64(math.Round(pt.Y * p.scale))
+}
+
+// command starts a command, unless it repeats the previous one
+func (p *compactPath) startCommand(command byte) {
+	if command != p.command || command == 'm' || command == 'M' {
+		p.b.WriteByte(command)
+		p.command = command
+	}
+}
+
+// coordinate writes a number, separated from the previous one by a space
+// unless it starts with a minus sign or follows a command
+func (p *compactPath) coordinate(v int64) {
+	s := formatCompact(v, p.precision)
+	last := p.b.String()[p.b.Len()-1]
+	if s[0] != '-' && !(last >= 'a' && last <= 'z' || last >= 'A' && last <= 'Z') {
+		p.b.WriteByte(' ')
+	}
+	p.b.WriteString(s)
+}
+
+// relative writes a point relative to the current point and moves there
+func (p *compactPath) relative(pt Point) {
+	x, y := p.quantize(pt)
+	p.coordinate(x - p.x)
+	p.coordinate(y - p.y)
+}
+
+func (p *compactPath) moveTo(pt Point) {
+	x, y := p.quantize(pt)
+	switch {
+	case p.b.Len() == 0:
+		p.startCommand('M')
+		p.coordinate(x)
+		p.coordinate(y)
+	case x == p.x && y == p.y && p.command != 'z':
+		// A segment continuing where the previous one ended
+		p.startX, p.startY = x, y
+		return
+	default:
+		p.startCommand('m')
+		p.relative(pt)
+	}
+	p.x, p.y = x, y
+	p.startX, p.startY = x, y
+	// Lines after a move need their own command
+	p.command = 'm'
+}
+
+func (p *compactPath) lineTo(pt Point) {
+	p.startCommand('l')
+	p.relative(pt)
+	p.x, p.y = p.quantize(pt)
+}
+
+func (p *compactPath) curveTo(c1, c2, end Point) {
+	p.startCommand('c')
+	p.relative(c1)
+	p.relative(c2)
+	p.relative(end)
+	p.x, p.y = p.quantize(end)
+}
+
+func (p *compactPath) close() {
+	p.b.WriteByte('z')
+	p.command = 'z'
+	p.x, p.y = p.startX, p.startY
+}
+
+// circle writes a circle as two clockwise half circles
+func (p *compactPath) circle(center Point, radius float64) {
+	p.moveTo(Point{center.X - radius, center.Y})
+	r := int64(math.Round(radius * p.scale))
+	for _, dx := range []int64{2 * r, -2 * r} {
+		p.b.WriteByte('a')
+		p.command = 'a'
+		p.coordinate(r)
+		p.coordinate(r)
+		p.b.WriteString(" 0 1 1")
+		p.coordinate(dx)
+		p.b.WriteString(" 0")
+	}
+	p.close()
+}
+
+func (p *compactPath) write(path []PathElement) {
+	for _, e := range path {
+		switch e.Op {
+		case PathMoveTo:
+			p.moveTo(e.Points[0])
+		case PathLineTo:
+			p.lineTo(e.Points[0])
+		case PathCurveTo:
+			p.curveTo(e.Points[0], e.Points[1], e.Points[2])
+		case PathClose:
+			p.close()
+		case PathCircle:
+			p.circle(e.Points[0], e.Radius)
+		}
+	}
+}
+
+// formatCompact formats v / 10^precision with as few characters as
+// possible: no trailing zeros, no leading zero and no negative zero
+func formatCompact(v int64, precision int) string {
+	if v == 0 {
+		return "0"
+	}
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	digits := strconv.FormatInt(v, 10)
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-precision], strings.TrimRight(digits[len(digits)-precision:], "0")
+	if whole == "0" {
+		whole = ""
+	}
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
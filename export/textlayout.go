@@ -0,0 +1,164 @@
+package export
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// averageCharWidth is the advance of an average character, relative to the
+// font size. Text is not measured with a font, so the widths of lines and
+// words are estimated from their number of characters.
+const averageCharWidth = 0.5
+
+// textBlock is a paragraph of typed text, or the recognized handwriting of
+// a page, with the bounding boxes of its lines in page points
+type textBlock struct {
+	box   Rect
+	lines []textLine
+}
+
+// textLine is a line of text and its words
+type textLine struct {
+	box   Rect
+	size  float64 // Font size in points
+	words []textWord
+}
+
+// textWord is a word of a line
+type textWord struct {
+	text string
+	box  Rect
+}
+
+// textPage is the text of a page laid out on the output page
+type textPage struct {
+	width, height float64
+	blocks        []textBlock
+}
+
+// textCollector is a Renderer that keeps the text drawn on a page
+type textCollector struct {
+	page  Page
+	texts []Text
+}
+
+func (c *textCollector) BeginPage(page Page) error {
+	c.page = page
+	return nil
+}
+
+func (c *textCollector) DrawStroke(stroke Stroke) error {
+	return nil
+}
+
+func (c *textCollector) DrawText(text Text) error {
+	c.texts = append(c.texts, text)
+	return nil
+}
+
+func (c *textCollector) EndPage() error {
+	return nil
+}
+
+// layoutText lays out the typed text and recognized handwriting of a page on
+// the page drawn with opts, with each paragraph as a block of one line of
+// its text
+func layoutText(tree *parser.SceneTree, opts *SVGOptions) (textPage, error) {
+	var c textCollector
+	if err := Render(tree, &c, opts); err != nil {
+		return textPage{}, err
+	}
+
+	page := textPage{width: c.page.Width, height: c.page.Height}
+	for _, text := range c.texts {
+		for _, p := range text.Paragraphs {
+			// Bullets, numbers and checkboxes are left out, but take their
+			// place before the text
+			_, size, _ := paragraphFont(p.Style)
+			indent := float64(utf8.RuneCountInString(p.Prefix)) * size * averageCharWidth
+			width := float64(utf8.RuneCountInString(p.Text)) * size * averageCharWidth
+			if p.Width > 0 {
+				width = math.Max(0, math.Min(width, p.Width-indent))
+			}
+			line := c.line(p.Text, p.X+indent, p.Y, width, size)
+			page.blocks = append(page.blocks, textBlock{box: line.box, lines: []textLine{line}})
+		}
+		if block, ok := c.wordBlock(text.Words); ok {
+			page.blocks = append(page.blocks, block)
+		}
+	}
+	return page, nil
+}
+
+// wordBlock gathers recognized words into lines of words that share a
+// baseline, as a single block
+func (c *textCollector) wordBlock(words []TextWord) (textBlock, bool) {
+	if len(words) == 0 {
+		return textBlock{}, false
+	}
+
+	var block textBlock
+	var line textLine
+	flush := func() {
+		block.lines = append(block.lines, line)
+		block.box = unionRect(block.box, line.box, len(block.lines) == 1)
+	}
+	for i, w := range words {
+		word := textWord{text: w.Text, box: c.box(w.X, w.Y, w.Width, w.Size)}
+		if i > 0 && math.Abs(w.Y-words[i-1].Y) > w.Size/2 {
+			flush()
+			line = textLine{}
+		}
+		line.words = append(line.words, word)
+		line.box = unionRect(line.box, word.box, len(line.words) == 1)
+		line.size = math.Max(line.size, w.Size)
+	}
+	flush()
+	return block, true
+}
+
+// line lays out a line of text starting on the baseline at (x, y), splitting
+// its width among the words by their number of characters
+func (c *textCollector) line(text string, x, y, width, size float64) textLine {
+	line := textLine{box: c.box(x, y, width, size), size: size}
+	chars := float64(utf8.RuneCountInString(text))
+	if chars == 0 {
+		return line
+	}
+
+	offset := 0
+	for _, word := range strings.Fields(text) {
+		start := strings.Index(text[offset:], word) + offset
+		offset = start + len(word)
+		before := float64(utf8.RuneCountInString(text[:start]))
+		length := float64(utf8.RuneCountInString(word))
+		line.words = append(line.words, textWord{
+			text: word,
+			box:  c.box(x+width*before/chars, y, width*length/chars, size),
+		})
+	}
+	return line
+}
+
+// box returns the bounding box on the page of text of the given font size
+// and width whose baseline starts at (x, y) in content coordinates. The box
+// reaches from the ascent above the baseline to the descent below it.
+func (c *textCollector) box(x, y, width, size float64) Rect {
+	x0, y0 := c.page.Map(x, y-0.8*size)
+	x1, y1 := c.page.Map(x+width, y+0.2*size)
+	return Rect{X: math.Min(x0, x1), Y: math.Min(y0, y1), Width: math.Abs(x1 - x0), Height: math.Abs(y1 - y0)}
+}
+
+// unionRect returns the smallest rectangle holding a and b, or b if first
+// is set
+func unionRect(a, b Rect, first bool) Rect {
+	if first {
+		return b
+	}
+	x0, y0 := math.Min(a.X, b.X), math.Min(a.Y, b.Y)
+	x1, y1 := math.Max(a.X+a.Width, b.X+b.Width), math.Max(a.Y+a.Height, b.Y+b.Height)
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
@@ -0,0 +1,117 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ThumbnailOptions controls the previews drawn by ExportThumbnail
+type ThumbnailOptions struct {
+	// SVGOptions holds the page layout options shared with SVG export. The
+	// output size is set from MaxDim; OutputScale, OutputWidth and
+	// OutputHeight are ignored.
+	SVGOptions
+
+	// MaxDim is the length in pixels of the longer side of the image
+	// (default: 256)
+	MaxDim int
+}
+
+// DefaultThumbnailOptions returns the options used by ExportThumbnail: 256
+// pixels on a white background, with at most 64 points per stroke
+func DefaultThumbnailOptions() *ThumbnailOptions {
+	opts := &ThumbnailOptions{SVGOptions: *DefaultSVGOptions(), MaxDim: 256}
+	opts.Background = "white"
+	opts.MaxStrokePoints = 64
+	return opts
+}
+
+// ExportThumbnail draws a small PNG preview of a page, for gallery views of
+// many notebooks. The longer side of the image is opts.MaxDim pixels. Unless
+// SimplifyTolerance is set, strokes lose the points that make less than half
+// a pixel of difference, and MaxStrokePoints bounds what is left, so dense
+// pages are drawn quickly. Builds without Cairo draw the strokes in black on
+// white, without highlighters and typed text. A nil opts uses
+// DefaultThumbnailOptions().
+func ExportThumbnail(tree *parser.SceneTree, w io.Writer, opts *ThumbnailOptions) error {
+	if opts == nil {
+		opts = DefaultThumbnailOptions()
+	}
+	if opts.MaxDim <= 0 {
+		return fmt.Errorf("thumbnail size must be positive, got %d", opts.MaxDim)
+	}
+	if tree == nil || tree.Root == nil {
+		return fmt.Errorf("scene tree or root cannot be nil")
+	}
+
+	pngOpts := &PNGOptions{SVGOptions: opts.SVGOptions, Scale: 1}
+	pngOpts.OutputScale, pngOpts.OutputWidth, pngOpts.OutputHeight = 0, 0, 0
+
+	// Fit the longer side of the page to the thumbnail
+	anchorPos := buildAnchorPos(tree.RootText)
+	layout := computePageLayout(tree, anchorPos, &pngOpts.SVGOptions)
+	size := float64(opts.MaxDim)
+	if layout.width >= layout.height {
+		pngOpts.OutputWidth = size
+	} else {
+		pngOpts.OutputHeight = size
+	}
+
+	if pngOpts.SimplifyTolerance == 0 {
+		pngOpts.SimplifyTolerance = previewTolerance(size / math.Max(layout.width, layout.height))
+	}
+	if _, ok := CairoVersion(); !ok {
+		return rasterThumbnail(tree, w, anchorPos, &pngOpts.SVGOptions)
+	}
+	return ExportToPNGWithOptions(tree, w, pngOpts)
+}
+
+// rasterThumbnail draws a thumbnail with the pure-Go rasterRenderer, for
+// builds without Cairo. The image holds the content region, one pixel per
+// point of the output page, turned like the page.
+func rasterThumbnail(tree *parser.SceneTree, w io.Writer, anchorPos map[parser.CrdtID]float64, opts *SVGOptions) error {
+	layout := computePageLayout(tree, anchorPos, opts)
+	page := Page{
+		View:    Rect{layout.viewX, layout.viewY, layout.viewWidth, layout.viewHeight},
+		Rotated: layout.rotated,
+	}
+	factor, _, _ := layout.fit()
+
+	r := newRasterRenderer(page.contentRect(), factor)
+	if err := renderPage(context.Background(), tree, r, layout, anchorPos, opts); err != nil {
+		return err
+	}
+	img := r.image()
+	if page.Rotated {
+		img = rotateGray(img)
+	}
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("failed to write PNG: %w", err)
+	}
+	return nil
+}
+
+// rotateGray returns an image turned a quarter turn clockwise
+func rotateGray(src *image.Gray) *image.Gray {
+	b := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.SetGray(b.Max.Y-1-y, x-b.Min.X, src.GrayAt(x, y))
+		}
+	}
+	return dst
+}
+
+// previewTolerance returns the SimplifyTolerance, in screen units, that
+// drops the stroke points making less than half a unit of difference to a
+// page drawn at factor output units (pixels or points) per point
+func previewTolerance(factor float64) float64 {
+	return 0.5 / (factor * Scale)
+}
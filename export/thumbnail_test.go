@@ -0,0 +1,44 @@
+package export
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// TestExportThumbnail checks that thumbnails are drawn in every build, with
+// the longer side of the page MaxDim pixels long
+func TestExportThumbnail(t *testing.T) {
+	data, err := os.ReadFile("../tests/marker_and_brush.rm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := parser.ReadSceneTree(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, landscape := range []bool{false, true} {
+		opts := DefaultThumbnailOptions()
+		opts.Landscape = landscape
+		var buf bytes.Buffer
+		if err := ExportThumbnail(tree, &buf, opts); err != nil {
+			t.Fatalf("landscape %v: %v", landscape, err)
+		}
+		img, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatalf("landscape %v: %v", landscape, err)
+		}
+
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+		if landscape {
+			width, height = height, width
+		}
+		if height != opts.MaxDim || width >= height {
+			t.Errorf("landscape %v: got a %dx%d image, want %d pixels along the page", landscape, img.Bounds().Dx(), img.Bounds().Dy(), opts.MaxDim)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// ExportToTikZ exports a scene tree as a TikZ picture, for embedding
+// sketches in LaTeX documents as vector graphics
+func ExportToTikZ(tree *parser.SceneTree, w io.Writer) error {
+	return ExportToTikZWithOptions(tree, w, nil)
+}
+
+// ExportToTikZWithOptions exports a scene tree as a tikzpicture environment
+// with the page layout of opts, to be \input into a document that loads the
+// tikz package. Strokes become \draw and \fill paths with their widths,
+// colors and opacities, in big points (1/72 inch) of the page. Typed text
+// becomes nodes set in the document's fonts; bullets and checkboxes from
+// the default glyphs need a Unicode engine such as LuaLaTeX, or
+// &ASCIIGlyphs. Recognized handwriting is left out. A nil opts uses
+// DefaultSVGOptions().
+func ExportToTikZWithOptions(tree *parser.SceneTree, w io.Writer, opts *SVGOptions) error {
+	bw := bufio.NewWriter(w)
+	r := &tikzRenderer{w: bw}
+	if err := Render(tree, r, opts); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write TikZ output: %w", err)
+	}
+	return nil
+}
+
+// tikzRenderer writes a page as a TikZ picture. TikZ's y axis points up, so
+// points are mapped onto the page and turned upside down.
+type tikzRenderer struct {
+	w    *bufio.Writer
+	page Page
+}
+
+func (r *tikzRenderer) BeginPage(page Page) error {
+	r.page = page
+	fmt.Fprintf(r.w, "%% Created by %s; requires \\usepackage{tikz}\n", pdfCreator)
+	r.w.WriteString("\\begin{tikzpicture}[x=1bp, y=1bp, line join=round]\n")
+	fmt.Fprintf(r.w, "\\useasboundingbox (0,0) rectangle (%s,%s);\n", tikzNum(page.Width), tikzNum(page.Height))
+	if color, ok := parseCSSColor(page.Background); ok {
+		fmt.Fprintf(r.w, "\\fill[%s] (0,0) rectangle (%s,%s);\n", tikzColor(color), tikzNum(page.Width), tikzNum(page.Height))
+	}
+	return nil
+}
+
+func (r *tikzRenderer) EndPage() error {
+	r.w.WriteString("\\end{tikzpicture}\n")
+	return nil
+}
+
+func (r *tikzRenderer) DrawStroke(stroke Stroke) error {
+	factor, _, _ := r.page.Fit()
+	for _, segment := range stroke.Segments {
+		options := []string{tikzColor(segment.Color)}
+		command := "\\fill"
+		if !segment.Fill {
+			command = "\\draw"
+			options = append(options, "line width="+tikzNum(segment.Width*factor)+"bp", "line cap="+tikzCap(stroke.Cap))
+		}
+		if segment.Opacity < 1 {
+			options = append(options, "opacity="+tikzNum(segment.Opacity))
+		}
+		if stroke.Multiply {
+			options = append(options, "blend mode=multiply")
+		}
+		fmt.Fprintf(r.w, "%s[%s] %s;\n", command, strings.Join(options, ", "), r.path(segment.Path, factor))
+	}
+	return nil
+}
+
+// path returns the TikZ path of a segment's elements
+func (r *tikzRenderer) path(path []PathElement, factor float64) string {
+	var b strings.Builder
+	for i, e := range path {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		switch e.Op {
+		case PathMoveTo:
+			b.WriteString(r.point(e.Points[0]))
+		case PathLineTo:
+			b.WriteString("-- " + r.point(e.Points[0]))
+		case PathCurveTo:
+			fmt.Fprintf(&b, ".. controls %s and %s .. %s", r.point(e.Points[0]), r.point(e.Points[1]), r.point(e.Points[2]))
+		case PathClose:
+			b.WriteString("-- cycle")
+		case PathCircle:
+			fmt.Fprintf(&b, "%s circle[radius=%s]", r.point(e.Points[0]), tikzNum(e.Radius*factor))
+		}
+	}
+	return b.String()
+}
+
+// point returns a coordinate of the page for a point in content coordinates
+func (r *tikzRenderer) point(p Point) string {
+	x, y := r.page.Map(p.X, p.Y)
+	return "(" + tikzNum(x) + "," + tikzNum(r.page.Height-y) + ")"
+}
+
+func (r *tikzRenderer) DrawText(text Text) error {
+	factor, _, _ := r.page.Fit()
+	rotate := ""
+	if r.page.Rotated {
+		rotate = ", rotate=-90"
+	}
+	for _, p := range text.Paragraphs {
+		_, size, bold := paragraphFont(p.Style)
+		size *= factor
+		font := fmt.Sprintf("\\fontsize{%s}{%s}\\selectfont", tikzNum(size), tikzNum(size*1.2))
+		if bold {
+			font += "\\bfseries"
+		}
+
+		var content strings.Builder
+		content.WriteString(tikzEscape(p.Prefix))
+		for _, span := range p.Spans {
+			text := tikzEscape(span.Text)
+			switch {
+			case span.Bold && span.Italic:
+				text = "\\textbf{\\textit{" + text + "}}"
+			case span.Bold:
+				text = "\\textbf{" + text + "}"
+			case span.Italic:
+				text = "\\textit{" + text + "}"
+			}
+			content.WriteString(text)
+		}
+		if len(p.Spans) == 0 {
+			content.WriteString(tikzEscape(p.Text))
+		}
+
+		fmt.Fprintf(r.w, "\\node[anchor=base west, inner sep=0, font=%s%s] at %s {%s};\n",
+			font, rotate, r.point(Point{X: p.X, Y: p.Y}), content.String())
+	}
+	return nil
+}
+
+// tikzNum formats a number with at most two decimals
+func tikzNum(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// tikzColor returns the color option of an RGB color, in xcolor's syntax
+func tikzColor(c RGB) string {
+	return fmt.Sprintf("color={rgb,255:red,%d; green,%d; blue,%d}", c.R, c.G, c.B)
+}
+
+// tikzCap returns the TikZ line cap of a stroke's cap
+func tikzCap(linecap string) string {
+	if linecap == "square" {
+		return "rect"
+	}
+	if linecap == "butt" {
+		return "butt"
+	}
+	return "round"
+}
+
+// tikzSpecial escapes the characters LaTeX treats specially in text
+var tikzSpecial = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`, `}`, `\}`,
+	`#`, `\#`, `$`, `\$`, `%`, `\%`, `&`, `\&`, `_`, `\_`,
+	`~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+)
+
+// tikzEscape escapes text for a TikZ node
+func tikzEscape(s string) string {
+	return tikzSpecial.Replace(s)
+}
@@ -0,0 +1,190 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PDFMergeTool names the program used to merge the per-page PDFs produced by
+// the legacy Inkscape renderer
+type PDFMergeTool string
+
+const (
+	// MergeToolAuto tries pdfunite, then Ghostscript, then the built-in
+	// merger (default)
+	MergeToolAuto PDFMergeTool = ""
+	// MergeToolPdfunite merges with pdfunite from poppler-utils
+	MergeToolPdfunite PDFMergeTool = "pdfunite"
+	// MergeToolGhostscript merges with Ghostscript
+	MergeToolGhostscript PDFMergeTool = "gs"
+	// MergeToolBuiltin merges in-process, without external programs
+	MergeToolBuiltin PDFMergeTool = "builtin"
+)
+
+// ParsePDFMergeTool parses a merge tool name (auto, pdfunite, gs or builtin)
+func ParsePDFMergeTool(name string) (PDFMergeTool, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return MergeToolAuto, nil
+	case string(MergeToolPdfunite):
+		return MergeToolPdfunite, nil
+	case string(MergeToolGhostscript), "ghostscript":
+		return MergeToolGhostscript, nil
+	case string(MergeToolBuiltin):
+		return MergeToolBuiltin, nil
+	default:
+		return "", fmt.Errorf("unknown PDF merge tool: %s (supported: auto, pdfunite, gs, builtin)", name)
+	}
+}
+
+// SVGConverter names the program the legacy renderer uses to convert SVG to
+// PDF or EPS
+type SVGConverter string
+
+const (
+	// ConverterInkscape converts with Inkscape (default)
+	ConverterInkscape SVGConverter = ""
+	// ConverterRsvg converts with rsvg-convert from librsvg, which is much
+	// smaller than Inkscape and suits headless servers
+	ConverterRsvg SVGConverter = "rsvg"
+)
+
+// ParseSVGConverter parses a converter name (inkscape or rsvg)
+func ParseSVGConverter(name string) (SVGConverter, error) {
+	switch strings.ToLower(name) {
+	case "", "inkscape":
+		return ConverterInkscape, nil
+	case string(ConverterRsvg), "rsvg-convert":
+		return ConverterRsvg, nil
+	default:
+		return "", fmt.Errorf("unknown SVG converter: %s (supported: inkscape, rsvg)", name)
+	}
+}
+
+// Install hints shown when an external program is missing
+const (
+	inkscapeHint    = "Ensure 'inkscape' is installed and available in PATH (or set the Inkscape path)\n  Install: https://inkscape.org/release/"
+	pdfuniteHint    = "Install poppler-utils: sudo apt-get install poppler-utils, or brew install poppler"
+	ghostscriptHint = "Install Ghostscript: sudo apt-get install ghostscript, or brew install ghostscript"
+	rsvgHint        = "Install librsvg: sudo apt-get install librsvg2-bin, or brew install librsvg"
+	ffmpegHint      = "Install ffmpeg: sudo apt-get install ffmpeg, or brew install ffmpeg"
+)
+
+// ToolStatus reports whether an external program is available
+type ToolStatus struct {
+	Name    string // Program name, e.g. "inkscape"
+	Path    string // Resolved executable path, empty if not found
+	Version string // First line of the program's version output
+	Err     error  // Why the program cannot be used, nil if available
+}
+
+// Available reports whether the tool was found and runs
+func (t ToolStatus) Available() bool {
+	return t.Err == nil
+}
+
+// CheckTool looks up an executable and runs it with versionArgs to read its version
+func CheckTool(name, executable string, versionArgs ...string) ToolStatus {
+	return CheckToolContext(context.Background(), name, executable, versionArgs...)
+}
+
+// CheckToolContext is like CheckTool, but kills the executable once ctx is
+// done and reports the context's error
+func CheckToolContext(ctx context.Context, name, executable string, versionArgs ...string) ToolStatus {
+	status := ToolStatus{Name: name}
+
+	path, err := lookPath(executable)
+	if err != nil {
+		status.Err = fmt.Errorf("%s not found in PATH", executable)
+		return status
+	}
+	status.Path = path
+
+	var out bytes.Buffer
+	if err := runCommand(ctx, nil, &out, &out, path, versionArgs...); err != nil {
+		if ctx.Err() != nil {
+			status.Err = ctx.Err()
+			return status
+		}
+		status.Err = fmt.Errorf("failed to run %s: %w", path, err)
+		return status
+	}
+	status.Version, _, _ = strings.Cut(strings.TrimSpace(out.String()), "\n")
+	return status
+}
+
+// CheckTools reports the availability of the external programs used by the
+// export paths, honouring the executable paths configured in opts.
+// A nil opts uses DefaultPDFOptions().
+func CheckTools(opts *PDFOptions) []ToolStatus {
+	return CheckToolsContext(context.Background(), opts)
+}
+
+// CheckToolsContext is like CheckTools, but stops running the programs once
+// ctx is done
+func CheckToolsContext(ctx context.Context, opts *PDFOptions) []ToolStatus {
+	if opts == nil {
+		opts = DefaultPDFOptions()
+	}
+
+	cairo := ToolStatus{Name: "cairo"}
+	if version, ok := CairoVersion(); ok {
+		cairo.Version = version
+	} else {
+		cairo.Err = fmt.Errorf("not built with Cairo support (rebuild with: make build-cairo)")
+	}
+
+	return []ToolStatus{
+		cairo,
+		CheckToolContext(ctx, "inkscape", opts.inkscape(), "--version"),
+		CheckToolContext(ctx, "rsvg", "rsvg-convert", "--version"),
+		CheckToolContext(ctx, "pdfunite", "pdfunite", "-v"),
+		CheckToolContext(ctx, "gs", "gs", "--version"),
+		CheckToolContext(ctx, "ffmpeg", "ffmpeg", "-version"),
+	}
+}
+
+// inkscape returns the Inkscape executable to run
+func (o *PDFOptions) inkscape() string {
+	if o.InkscapePath != "" {
+		return o.InkscapePath
+	}
+	return "inkscape"
+}
+
+// converter returns the executable and install hint of the SVG converter
+func (o *PDFOptions) converter() (executable, hint string) {
+	if o.Converter == ConverterRsvg {
+		return "rsvg-convert", rsvgHint
+	}
+	return o.inkscape(), inkscapeHint
+}
+
+// convertSVG converts an SVG file to PDF or EPS (format "pdf" or "eps")
+// with the configured converter
+func (o *PDFOptions) convertSVG(ctx context.Context, svgPath, outPath, format string) error {
+	executable, hint := o.converter()
+	args := []string{svgPath, "--export-filename", outPath} // Inkscape picks the format from the file extension
+	if o.Converter == ConverterRsvg {
+		args = []string{"--format", format, "--output", outPath, svgPath}
+	}
+	if err := runCommand(ctx, nil, nil, nil, executable, args...); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s conversion failed: %w\n  %s", filepath.Base(executable), err, hint)
+	}
+	return nil
+}
+
+// requireTool fails early with an install hint when an executable is missing,
+// rather than part way through a conversion
+func requireTool(executable, hint string) error {
+	if _, err := lookPath(executable); err != nil {
+		return fmt.Errorf("%s not found: %w\n  %s", executable, err, hint)
+	}
+	return nil
+}
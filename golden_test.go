@@ -0,0 +1,240 @@
+package rmc
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// update rewrites the golden files instead of comparing with them:
+//
+//	go test -run TestGolden -update .
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenDir holds the golden output of the pages in testdata/corpus
+var goldenDir = filepath.Join("testdata", "golden")
+
+// Golden images may differ by goldenTolerance in a color channel (out of
+// 255) per pixel, which absorbs anti-aliasing differences, and in up to
+// goldenMaxDiff percent of their pixels
+const (
+	goldenTolerance = 16
+	goldenMaxDiff   = 0.1
+)
+
+// TestGoldenSVG compares the SVG of every corpus page with its golden file,
+// byte for byte, so any change to the drawn output shows up
+func TestGoldenSVG(t *testing.T) {
+	opts := export.DefaultSVGOptions()
+	opts.Deterministic = true
+
+	for _, page := range readCorpus(t) {
+		t.Run(page.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := export.ExportToSVGWithOptions(page.tree, &buf, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(goldenDir, page.name+".svg")
+			if *update {
+				writeGolden(t, path, buf.Bytes())
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (create it with -update)", err)
+			}
+			if line, ok := firstDifference(buf.Bytes(), want); !ok {
+				t.Errorf("SVG differs from %s at line %d (rerun with -update if the change is intended)", path, line)
+			}
+		})
+	}
+}
+
+// goldenRasterizer draws a page as an image for the golden images
+type goldenRasterizer struct {
+	name   string
+	render func(tree *parser.SceneTree, w io.Writer) error
+}
+
+// goldenRasterizers returns the rasterizers available in this build and
+// system: the Cairo renderer used for PDF, and the SVG converter
+func goldenRasterizers() []goldenRasterizer {
+	var rasterizers []goldenRasterizer
+	if _, ok := export.CairoVersion(); ok {
+		rasterizers = append(rasterizers, goldenRasterizer{"cairo", func(tree *parser.SceneTree, w io.Writer) error {
+			return export.ExportToPNGWithOptions(tree, w, nil)
+		}})
+	}
+
+	opts := export.DefaultPDFOptions()
+	opts.Background = "white"
+	for _, tool := range export.CheckTools(opts) {
+		if !tool.Available() {
+			continue
+		}
+		switch tool.Name {
+		case "rsvg":
+			opts.Converter = export.ConverterRsvg
+		case "inkscape":
+		default:
+			continue
+		}
+		rasterizers = append(rasterizers, goldenRasterizer{tool.Name, func(tree *parser.SceneTree, w io.Writer) error {
+			return export.ExportToPNGViaSVG(context.Background(), tree, w, opts)
+		}})
+		break
+	}
+	return rasterizers
+}
+
+// TestGoldenImages rasterizes every corpus page with each available
+// rasterizer and compares the images pixel by pixel with golden images.
+// Golden images depend on the rasterizer and its version, so they are not
+// kept in the repository: save them with -update on the main branch first.
+// A failing image is written next to its golden image as
+// <name>.<rasterizer>.diff.png, with the differing pixels in red.
+func TestGoldenImages(t *testing.T) {
+	rasterizers := goldenRasterizers()
+	if len(rasterizers) == 0 {
+		t.Skip("no rasterizer: needs a Cairo build, rsvg-convert or Inkscape")
+	}
+
+	for _, page := range readCorpus(t) {
+		for _, r := range rasterizers {
+			name := page.name + "." + r.name
+			t.Run(name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := r.render(page.tree, &buf); err != nil {
+					t.Fatal(err)
+				}
+
+				path := filepath.Join(goldenDir, name+".png")
+				diffPath := filepath.Join(goldenDir, name+".diff.png")
+				if *update {
+					writeGolden(t, path, buf.Bytes())
+					os.Remove(diffPath)
+					return
+				}
+				want, err := readPNG(path)
+				if os.IsNotExist(err) {
+					t.Skipf("no golden image %s (save it with -update)", path)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := png.Decode(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				diff := compareImages(got, want, goldenTolerance)
+				if diff.image == nil {
+					t.Fatalf("size %v, golden image %v", got.Bounds().Size(), want.Bounds().Size())
+				}
+				percent := 100 * float64(diff.pixels) / float64(diff.total)
+				if percent <= goldenMaxDiff {
+					os.Remove(diffPath)
+					return
+				}
+				var out bytes.Buffer
+				if err := png.Encode(&out, diff.image); err != nil {
+					t.Fatal(err)
+				}
+				writeGolden(t, diffPath, out.Bytes())
+				t.Errorf("%.3f%% of pixels differ from %s, see %s", percent, path, diffPath)
+			})
+		}
+	}
+}
+
+// writeGolden writes a golden file, creating its folder
+func writeGolden(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// firstDifference reports whether got and want are equal, and otherwise
+// the first line on which they differ
+func firstDifference(got, want []byte) (int, bool) {
+	if bytes.Equal(got, want) {
+		return 0, true
+	}
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := range min(len(gotLines), len(wantLines)) {
+		if gotLines[i] != wantLines[i] {
+			return i + 1, false
+		}
+	}
+	return min(len(gotLines), len(wantLines)) + 1, false
+}
+
+// imageDiff is the result of comparing an image with its golden image
+type imageDiff struct {
+	pixels int         // Pixels that differ by more than the tolerance
+	total  int         // Pixels in the image
+	image  *image.RGBA // The image faded, with differing pixels in red; nil when the sizes differ
+}
+
+// compareImages compares two images pixel by pixel. A pixel differs when
+// one of its channels differs by more than tolerance (out of 255).
+func compareImages(got, want image.Image, tolerance int) imageDiff {
+	bounds := got.Bounds()
+	if bounds.Size() != want.Bounds().Size() {
+		return imageDiff{}
+	}
+
+	diff := imageDiff{total: bounds.Dx() * bounds.Dy(), image: image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))}
+	offset := want.Bounds().Min.Sub(bounds.Min)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+			b := color.NRGBAModel.Convert(want.At(x+offset.X, y+offset.Y)).(color.NRGBA)
+			px, py := x-bounds.Min.X, y-bounds.Min.Y
+			if channelDiff(a.R, b.R) > tolerance || channelDiff(a.G, b.G) > tolerance ||
+				channelDiff(a.B, b.B) > tolerance || channelDiff(a.A, b.A) > tolerance {
+				diff.pixels++
+				diff.image.Set(px, py, color.NRGBA{R: 255, A: 255})
+				continue
+			}
+			// Fade unchanged pixels so the differences stand out
+			gray := color.GrayModel.Convert(a).(color.Gray).Y
+			faded := uint8(255 - (255-int(gray))*int(a.A)/255/4)
+			diff.image.Set(px, py, color.Gray{Y: faded})
+		}
+	}
+	return diff
+}
+
+func channelDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
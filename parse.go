@@ -0,0 +1,136 @@
+package rmc
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// Page is a parsed .rm file with flat views of its content, for
+// applications that want the data rather than a rendering. Coordinates are
+// those stored in the file; strokes in groups anchored to text are not
+// moved with it.
+type Page struct {
+	// Tree is the parsed scene tree the views are taken from
+	Tree *parser.SceneTree
+
+	// Version is the format version of the file (3, 5 or 6)
+	Version int
+
+	// Warnings are the non-fatal problems found while parsing
+	Warnings []parser.Warning
+
+	// Layers are the layers of the page, bottom to top
+	Layers []Layer
+
+	// Strokes are the strokes of the page in drawing order
+	Strokes []Stroke
+
+	// Text is the typed text of the page, which has no paragraphs when
+	// there is none
+	Text *parser.TextDocument
+}
+
+// Layer is a layer of a page
+type Layer struct {
+	Group   *parser.Group
+	Label   string
+	Visible bool
+	Strokes int // Strokes in the layer and the groups in it
+}
+
+// Stroke is a stroke of a page
+type Stroke struct {
+	*parser.Line
+
+	// Layer is the index in Page.Layers of the layer the stroke is in, or
+	// -1 for a stroke outside of layers
+	Layer int
+}
+
+// Parse reads a reMarkable .rm file of any supported version and returns
+// its content. Non-fatal problems are also reported to logger; a nil logger
+// uses slog.Default().
+//
+// Example:
+//
+//	page, err := rmc.Parse(input, nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, s := range page.Strokes {
+//	    fmt.Println(s.Tool, s.Color, len(s.Points))
+//	}
+func Parse(input io.Reader, logger *slog.Logger) (*Page, error) {
+	result, err := parser.ReadScene(input, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .rm file: %w", err)
+	}
+	return newPage(result)
+}
+
+// ParseFile is like Parse, for a file on disk
+func ParseFile(path string, logger *slog.Logger) (*Page, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+	return Parse(f, logger)
+}
+
+// newPage builds the views of a parsed file
+func newPage(result *parser.ParseResult) (*Page, error) {
+	page := &Page{Tree: result.Tree, Version: result.Version, Warnings: result.Warnings}
+
+	text, err := parser.BuildTextDocument(result.Tree.RootText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text: %w", err)
+	}
+	page.Text = text
+
+	if result.Tree.Root == nil || result.Tree.Root.Children == nil {
+		return page, nil
+	}
+	for _, item := range result.Tree.Root.Children.Items {
+		if item.Deleted() {
+			continue
+		}
+		switch v := item.Value.(type) {
+		case *parser.Group:
+			index := len(page.Layers)
+			before := len(page.Strokes)
+			page.addStrokes(v, index)
+			page.Layers = append(page.Layers, Layer{
+				Group:   v,
+				Label:   v.Label.Value,
+				Visible: v.Visible.Value,
+				Strokes: len(page.Strokes) - before,
+			})
+		case *parser.Line:
+			page.Strokes = append(page.Strokes, Stroke{Line: v, Layer: -1})
+		}
+	}
+	return page, nil
+}
+
+// addStrokes adds the strokes of a group and its subgroups
+func (p *Page) addStrokes(group *parser.Group, layer int) {
+	if group.Children == nil {
+		return
+	}
+	for _, item := range group.Children.Items {
+		if item.Deleted() {
+			continue
+		}
+		switch v := item.Value.(type) {
+		case *parser.Group:
+			p.addStrokes(v, layer)
+		case *parser.Line:
+			p.Strokes = append(p.Strokes, Stroke{Line: v, Layer: layer})
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"log/slog"
+	"sync"
 )
 
 // BlockInfo contains metadata about a block
@@ -15,28 +17,127 @@ type BlockInfo struct {
 	CurrentVersion uint8
 }
 
+// Warning describes a non-fatal problem encountered while parsing
+type Warning struct {
+	Offset    int64 // File offset of the block the warning relates to, 0 for the file as a whole
+	BlockType uint8
+	Message   string
+}
+
+func (w Warning) String() string {
+	if w.Offset == 0 {
+		return w.Message
+	}
+	return fmt.Sprintf("block 0x%02X at offset %d: %s", w.BlockType, w.Offset, w.Message)
+}
+
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// blockReaderPool holds the buffered readers of block data, which are only
+// needed from ReadBlock to EndBlock
+var blockReaderPool = sync.Pool{New: func() any { return bufio.NewReaderSize(nil, 4096) }}
+
+// pointSlabSize is the number of points allocated at once for the lines of
+// a file
+const pointSlabSize = 1024
+
 // TaggedBlockReader reads tagged blocks from a remarkable v6 file
 type TaggedBlockReader struct {
 	baseReader    *bufio.Reader
+	counter       *countingReader
 	data          *DataStream
-	reader        *bufio.Reader
+	reader        blockSource
 	currentBlock  *BlockInfo
 	limitedReader *LimitedBufReader
+	logger        *slog.Logger
+	warnings      []Warning
+	strict        bool // Check consistency, for ReadSceneStrict
+
+	// Streams and limit reused from block to block
+	baseData  DataStream
+	blockData DataStream
+	block     LimitedBufReader
+	points    []Point // Unused part of the current point slab
+
+	// Random access, for readers created by NewTaggedBlockReaderAt
+	at         io.ReaderAt
+	size       int64       // Size of the file
+	pos        int64       // Offset of the next block
+	file       []byte      // The whole file, when it is in memory
+	slice      sliceReader // Data of the current block
+	blockBytes []byte      // Block data read with ReadAt, reused
+}
+
+// blockSource is what the values of a block are read from: a buffered
+// stream, or a slice of a file read by offset
+type blockSource interface {
+	io.Reader
+	io.ByteReader
+	Peek(n int) ([]byte, error)
+	Buffered() int
 }
 
 // NewTaggedBlockReader creates a new TaggedBlockReader
 func NewTaggedBlockReader(r io.Reader) *TaggedBlockReader {
-	br := bufio.NewReader(r)
-	return &TaggedBlockReader{
+	counter := &countingReader{reader: r}
+	br := bufio.NewReader(counter)
+	tbr := &TaggedBlockReader{
 		baseReader: br,
-		data:       NewDataStream(br),
+		counter:    counter,
 		reader:     br,
+		logger:     slog.Default(),
 	}
+	tbr.baseData.reader = br
+	tbr.data = &tbr.baseData
+	return tbr
+}
+
+// SetLogger sets the logger used to report non-fatal parsing problems
+func (tbr *TaggedBlockReader) SetLogger(logger *slog.Logger) {
+	tbr.logger = logger
+}
+
+// Warnings returns the non-fatal problems recorded so far
+func (tbr *TaggedBlockReader) Warnings() []Warning {
+	return tbr.warnings
+}
+
+// warn records a warning against the current block and logs it
+func (tbr *TaggedBlockReader) warn(format string, args ...any) {
+	w := Warning{Message: fmt.Sprintf(format, args...)}
+	if tbr.currentBlock != nil {
+		w.Offset = tbr.currentBlock.Offset
+		w.BlockType = tbr.currentBlock.BlockType
+	}
+	tbr.warnings = append(tbr.warnings, w)
+	tbr.logger.Warn(w.Message, "block", fmt.Sprintf("0x%02X", w.BlockType), "offset", w.Offset)
+}
+
+// offset returns the current position in the underlying file
+func (tbr *TaggedBlockReader) offset() int64 {
+	if tbr.at != nil {
+		return tbr.pos
+	}
+	return tbr.counter.count - int64(tbr.baseReader.Buffered())
 }
 
 // ReadHeader reads the file header
 func (tbr *TaggedBlockReader) ReadHeader() error {
-	return tbr.data.ReadHeader()
+	if err := tbr.data.ReadHeader(); err != nil {
+		return err
+	}
+	tbr.pos = int64(len(HeaderV6))
+	return nil
 }
 
 // ReadBlock reads a top-level block header
@@ -44,6 +145,11 @@ func (tbr *TaggedBlockReader) ReadBlock() (*BlockInfo, error) {
 	if tbr.currentBlock != nil {
 		return nil, fmt.Errorf("already in a block")
 	}
+	if tbr.at != nil {
+		return tbr.readBlockAt()
+	}
+
+	offset := tbr.offset()
 
 	blockLength, err := tbr.data.ReadUint32()
 	if err == io.EOF {
@@ -53,40 +159,38 @@ func (tbr *TaggedBlockReader) ReadBlock() (*BlockInfo, error) {
 		return nil, err
 	}
 
-	unknown, err := tbr.data.ReadUint8()
-	if err != nil {
-		return nil, err
-	}
-	_ = unknown // Always 0 in known files, but don't enforce
-
-	minVersion, err := tbr.data.ReadUint8()
-	if err != nil {
+	if err := checkLimit("block size", uint64(blockLength), MaxBlockSize); err != nil {
 		return nil, err
 	}
 
-	currentVersion, err := tbr.data.ReadUint8()
-	if err != nil {
-		return nil, err
+	// The rest of the header: a byte that is always 0 in known files (but
+	// isn't enforced), the minimum and current version and the block type.
+	// A file that ends within it is truncated.
+	header, err := tbr.data.readFixed(4)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
 	}
-
-	blockType, err := tbr.data.ReadUint8()
 	if err != nil {
 		return nil, err
 	}
+	minVersion, currentVersion, blockType := header[1], header[2], header[3]
 
 	tbr.currentBlock = &BlockInfo{
+		Offset:         offset,
 		Size:           blockLength,
 		BlockType:      blockType,
 		MinVersion:     minVersion,
 		CurrentVersion: currentVersion,
 	}
 
-	// Create a limited reader for this block
-	tbr.limitedReader = NewLimitedBufReader(tbr.baseReader, int64(blockLength))
-	// Create a fresh buffered reader for this block's data
-	// Use a buffer size equal to the block size to avoid issues
-	tbr.reader = bufio.NewReaderSize(tbr.limitedReader, 4096)
-	tbr.data = NewDataStream(tbr.reader)
+	// Limit reading to this block, through a buffered reader of its own
+	tbr.block = LimitedBufReader{reader: tbr.baseReader, remaining: int64(blockLength)}
+	tbr.limitedReader = &tbr.block
+	br := blockReaderPool.Get().(*bufio.Reader)
+	br.Reset(tbr.limitedReader)
+	tbr.reader = br
+	tbr.blockData.reader = br
+	tbr.data = &tbr.blockData
 
 	return tbr.currentBlock, nil
 }
@@ -96,17 +200,26 @@ func (tbr *TaggedBlockReader) EndBlock() error {
 	if tbr.currentBlock == nil {
 		return nil
 	}
+	if tbr.at != nil {
+		return tbr.endBlockAt()
+	}
 
 	// Skip any remaining data
 	if tbr.limitedReader != nil {
-		if err := tbr.limitedReader.Skip(); err != nil {
+		if err := tbr.limitedReader.Skip(); err == io.EOF {
+			// The file ends within the block
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
 			return err
 		}
 	}
 
 	// Reset to base reader
-	tbr.reader = bufio.NewReader(tbr.baseReader)
-	tbr.data = NewDataStream(tbr.baseReader)
+	br := tbr.reader.(*bufio.Reader)
+	br.Reset(nil)
+	blockReaderPool.Put(br)
+	tbr.reader = tbr.baseReader
+	tbr.data = &tbr.baseData
 	tbr.currentBlock = nil
 	tbr.limitedReader = nil
 
@@ -124,16 +237,27 @@ func (tbr *TaggedBlockReader) ReadSubblock(index int) (uint32, error) {
 		return 0, err
 	}
 
+	// A subblock cannot be larger than what is left of its block
+	if tbr.currentBlock != nil {
+		if err := checkLimit("subblock length", uint64(length), uint64(tbr.RemainingInBlock())); err != nil {
+			return 0, err
+		}
+	}
+
 	return length, nil
 }
 
 // HasSubblock checks if a subblock with the given index exists
 func (tbr *TaggedBlockReader) HasSubblock(index int) bool {
-	// Peek at the next bytes
-	peek, err := tbr.reader.Peek(10) // enough to read a varuint tag
-	if err != nil {
-		return false
-	}
+	return tbr.HasTag(index, TagTypeLength4)
+}
+
+// HasTag checks if the next value has the given index and type, without
+// consuming it. Optional values are read only when it returns true.
+func (tbr *TaggedBlockReader) HasTag(index int, tagType TagType) bool {
+	// Peek at the next bytes. Fewer are left at the end of a block, where
+	// the last value may be a short one.
+	peek, _ := tbr.reader.Peek(10) // enough to read a varuint tag
 
 	// Parse the tag from peeked bytes
 	var result uint64
@@ -143,14 +267,10 @@ func (tbr *TaggedBlockReader) HasSubblock(index int) bool {
 		result |= uint64(b&0x7F) << shift
 		shift += 7
 		if (b & 0x80) == 0 {
-			break
+			return int(result>>4) == index && TagType(result&0xF) == tagType
 		}
 	}
-
-	tagIndex := int(result >> 4)
-	tagType := TagType(result & 0xF)
-
-	return tagIndex == index && tagType == TagTypeLength4
+	return false
 }
 
 // ReadID reads a tagged CRDT ID
@@ -169,8 +289,8 @@ func (tbr *TaggedBlockReader) ReadBool(index int) (bool, error) {
 	return tbr.data.ReadBool()
 }
 
-// ReadByte reads a tagged byte
-func (tbr *TaggedBlockReader) ReadByte(index int) (uint8, error) {
+// ReadUint8 reads a tagged byte
+func (tbr *TaggedBlockReader) ReadUint8(index int) (uint8, error) {
 	if err := tbr.data.ReadTag(index, TagTypeByte1); err != nil {
 		return 0, err
 	}
@@ -227,7 +347,7 @@ func (tbr *TaggedBlockReader) ReadLwwBool(index int) (LwwValue[bool], error) {
 
 // ReadLwwByte reads a last-write-wins byte
 func (tbr *TaggedBlockReader) ReadLwwByte(index int) (LwwValue[uint8], error) {
-	return readLww(tbr, index, tbr.ReadByte)
+	return readLww(tbr, index, tbr.ReadUint8)
 }
 
 // ReadLwwFloat reads a last-write-wins float
@@ -254,7 +374,46 @@ func (tbr *TaggedBlockReader) ReadString(index int) (string, error) {
 	return tbr.data.ReadString()
 }
 
-// RemainingInBlock returns the number of bytes remaining in the current block
+// ReadStringWithFormat reads a string block that may carry a trailing
+// formatting code (used by text items for inline bold/italic markers)
+func (tbr *TaggedBlockReader) ReadStringWithFormat(index int) (string, *uint32, error) {
+	length, err := tbr.ReadSubblock(index)
+	if err != nil {
+		return "", nil, err
+	}
+
+	start := tbr.RemainingInBlock()
+	str, err := tbr.data.ReadString()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Anything left in the subblock after the string is the format code
+	if start-tbr.RemainingInBlock() < int64(length) {
+		format, err := tbr.ReadInt(2)
+		if err != nil {
+			return "", nil, err
+		}
+		return str, &format, nil
+	}
+
+	return str, nil, nil
+}
+
+// allocPoints returns a slice for the n points of a line. Slices are cut
+// from larger slabs, so that not every line needs an allocation of its own.
+func (tbr *TaggedBlockReader) allocPoints(n int) []Point {
+	if n > cap(tbr.points) {
+		tbr.points = make([]Point, max(n, pointSlabSize))
+	}
+	points := tbr.points[:n:n]
+	tbr.points = tbr.points[n:]
+	return points
+}
+
+// RemainingInBlock returns the number of bytes of the current block that
+// have not been read yet, or 0 outside a block. Readers of blocks use it to
+// tell whether optional values follow.
 func (tbr *TaggedBlockReader) RemainingInBlock() int64 {
 	if tbr.limitedReader == nil {
 		return 0
@@ -264,3 +423,29 @@ func (tbr *TaggedBlockReader) RemainingInBlock() int64 {
 	remaining := tbr.limitedReader.Remaining() + buffered
 	return remaining
 }
+
+// BlockBytes reads the rest of the current block and returns it as a new
+// slice, for decoding data the tagged reads don't cover, such as blocks of
+// types this package doesn't know. EndBlock must still be called.
+func (tbr *TaggedBlockReader) BlockBytes() ([]byte, error) {
+	if tbr.currentBlock == nil {
+		return nil, fmt.Errorf("not in a block")
+	}
+	data, err := readGrowing(tbr.data.reader, nil, int(tbr.RemainingInBlock()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block data: %w", err)
+	}
+	return data, nil
+}
+
+// SkipToEnd skips the rest of the current block without reading it, so
+// that RemainingInBlock returns 0. EndBlock must still be called.
+func (tbr *TaggedBlockReader) SkipToEnd() error {
+	if tbr.currentBlock == nil {
+		return fmt.Errorf("not in a block")
+	}
+	if _, err := io.CopyN(io.Discard, tbr.reader, tbr.RemainingInBlock()); err != nil {
+		return fmt.Errorf("failed to skip block data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the tree, which can be changed without
+// affecting the original, e.g. to delete layers for one export while other
+// goroutines export the original. The values decoded by block handlers
+// (Extras) are shared, as their types are not known.
+func (st *SceneTree) Clone() *SceneTree {
+	c := treeCloner{groups: make(map[*Group]*Group, len(st.Nodes))}
+	clone := &SceneTree{
+		Root:     c.group(st.Root),
+		RootText: c.text(st.RootText),
+		Nodes:    make(map[CrdtID]*Group, len(st.Nodes)),
+		Extras:   slices.Clone(st.Extras),
+	}
+	for id, group := range st.Nodes {
+		clone.Nodes[id] = c.group(group)
+	}
+	for _, block := range st.UnknownBlocks {
+		block.Data = bytes.Clone(block.Data)
+		clone.UnknownBlocks = append(clone.UnknownBlocks, block)
+	}
+	return clone
+}
+
+// treeCloner copies every group of a tree once, so that a group is the same
+// in the copied Nodes as in its parent's children
+type treeCloner struct {
+	groups map[*Group]*Group
+}
+
+func (c *treeCloner) group(group *Group) *Group {
+	if group == nil {
+		return nil
+	}
+	if clone, ok := c.groups[group]; ok {
+		return clone
+	}
+
+	clone := *group
+	c.groups[group] = &clone
+	clone.AnchorID = clonePointer(group.AnchorID)
+	clone.AnchorType = clonePointer(group.AnchorType)
+	clone.AnchorThreshold = clonePointer(group.AnchorThreshold)
+	clone.AnchorOriginX = clonePointer(group.AnchorOriginX)
+	clone.Children = c.sequence(group.Children)
+	return &clone
+}
+
+func (c *treeCloner) sequence(seq *CrdtSequence) *CrdtSequence {
+	if seq == nil {
+		return nil
+	}
+
+	clone := &CrdtSequence{Items: make([]CrdtSequenceItem, len(seq.Items))}
+	for i, item := range seq.Items {
+		switch v := item.Value.(type) {
+		case *Group:
+			item.Value = c.group(v)
+		case *Line:
+			line := *v
+			line.Points = slices.Clone(v.Points)
+			line.ColorOverride = clonePointer(v.ColorOverride)
+			line.MoveID = clonePointer(v.MoveID)
+			item.Value = &line
+		case *Text:
+			item.Value = c.text(v)
+		case *GlyphRange:
+			glyph := *v
+			glyph.Start = clonePointer(v.Start)
+			glyph.ColorOverride = clonePointer(v.ColorOverride)
+			glyph.Rectangles = slices.Clone(v.Rectangles)
+			item.Value = &glyph
+		}
+		clone.Items[i] = item
+	}
+	return clone
+}
+
+func (c *treeCloner) text(text *Text) *Text {
+	if text == nil {
+		return nil
+	}
+
+	clone := *text
+	clone.Items = c.sequence(text.Items)
+	clone.Styles = maps.Clone(text.Styles)
+	return &clone
+}
+
+// clonePointer returns a pointer to a copy of *p, or nil if p is nil
+func clonePointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -17,6 +17,32 @@ type ContentPage struct {
 		Value     string `json:"value"`
 	} `json:"idx"`
 	Modified string `json:"modifed"` // Note: typo in reMarkable format
+	Deleted  struct {
+		Timestamp string `json:"timestamp"`
+		Value     int    `json:"value"`
+	} `json:"deleted"`
+	Redir struct {
+		Timestamp string `json:"timestamp"`
+		Value     *int   `json:"value"`
+	} `json:"redir"`
+}
+
+// IsDeleted reports whether the page has been deleted on the tablet
+func (p *ContentPage) IsDeleted() bool {
+	return p.Deleted.Value != 0
+}
+
+// Tag represents a tag attached to a whole document
+type Tag struct {
+	Name      string `json:"name"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// PageTag represents a tag attached to a single page
+type PageTag struct {
+	Name      string `json:"name"`
+	PageID    string `json:"pageId"`
+	Timestamp int64  `json:"timestamp"`
 }
 
 // ContentPages represents the cPages section of a .content file
@@ -32,11 +58,21 @@ type ContentPages struct {
 	Pages []ContentPage `json:"pages"`
 }
 
-// ContentFile represents a reMarkable .content file
+// ContentFile represents a reMarkable .content file. Two layouts exist:
+// format version 2 lists the pages in cPages, while version 1, written by
+// firmware before 3.0, has a flat list of page IDs in pages.
 type ContentFile struct {
-	CPages    ContentPages `json:"cPages"`
-	PageCount int          `json:"pageCount"`
-	FileType  string       `json:"fileType"`
+	FormatVersion int          `json:"formatVersion"` // 1 or 2; 0 in the oldest files, which use the version 1 layout
+	CPages        ContentPages `json:"cPages"`
+	PageCount     int          `json:"pageCount"`
+	FileType      string       `json:"fileType"`
+	// Orientation is "portrait" or "landscape"
+	Orientation string `json:"orientation"`
+	// Pages and RedirectionPageMap are used by content files written before cPages
+	Pages              []string  `json:"pages"`
+	RedirectionPageMap []int     `json:"redirectionPageMap"`
+	PageTags           []PageTag `json:"pageTags"`
+	Tags               []Tag     `json:"tags"`
 }
 
 // ReadContentFile reads and parses a reMarkable .content file
@@ -46,23 +82,225 @@ func ReadContentFile(path string) (*ContentFile, error) {
 		return nil, fmt.Errorf("failed to read content file: %w", err)
 	}
 
-	var content ContentFile
-	if err := json.Unmarshal(data, &content); err != nil {
+	return ParseContent(data)
+}
+
+// ParseContent parses the JSON data of a reMarkable .content file in either
+// format version
+func ParseContent(data []byte) (*ContentFile, error) {
+	// The pages key is read separately, as some files list objects instead
+	// of IDs there
+	type contentFile ContentFile
+	var raw struct {
+		contentFile
+		Pages json.RawMessage `json:"pages"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("failed to parse content file: %w", err)
 	}
 
+	content := ContentFile(raw.contentFile)
+	pages, err := parsePageList(raw.Pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content file pages: %w", err)
+	}
+	content.Pages = pages
 	return &content, nil
 }
 
-// GetPageIDs returns the page IDs in the correct order from the content file
+// parsePageList reads the flat page list of a version 1 content file: page
+// IDs, or objects with an id
+func parsePageList(data json.RawMessage) ([]string, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err == nil {
+		return ids, nil
+	}
+
+	var pages []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &pages); err != nil {
+		return nil, err
+	}
+	ids = make([]string, 0, len(pages))
+	for _, page := range pages {
+		if page.ID != "" {
+			ids = append(ids, page.ID)
+		}
+	}
+	return ids, nil
+}
+
+// IsLandscape reports whether the document is laid out in landscape orientation
+func (c *ContentFile) IsLandscape() bool {
+	return c.Orientation == "landscape"
+}
+
+// GetPageIDs returns the page IDs in the correct order from the content file.
+// Deleted pages are left out. Pages in cPages are ordered by their index
+// values, which sort as strings; the flat list of older files is in order.
 func (c *ContentFile) GetPageIDs() []string {
-	ids := make([]string, 0, len(c.CPages.Pages))
+	if len(c.CPages.Pages) == 0 {
+		return append([]string(nil), c.Pages...)
+	}
+
+	pages := make([]ContentPage, 0, len(c.CPages.Pages))
 	for _, page := range c.CPages.Pages {
-		ids = append(ids, page.ID)
+		if !page.IsDeleted() {
+			pages = append(pages, page)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Idx.Value < pages[j].Idx.Value
+	})
+
+	ids := make([]string, len(pages))
+	for i, page := range pages {
+		ids[i] = page.ID
 	}
 	return ids
 }
 
+// DeletedPageIDs returns the IDs of the pages marked deleted in the content file
+func (c *ContentFile) DeletedPageIDs() []string {
+	var ids []string
+	for _, page := range c.CPages.Pages {
+		if page.IsDeleted() {
+			ids = append(ids, page.ID)
+		}
+	}
+	return ids
+}
+
+// IsDeleted reports whether the content file marks the given page as deleted
+func (c *ContentFile) IsDeleted(pageID string) bool {
+	for _, page := range c.CPages.Pages {
+		if page.ID == pageID {
+			return page.IsDeleted()
+		}
+	}
+	return false
+}
+
+// Redirection returns the index of the page of the original document (the
+// PDF or EPUB a notebook annotates) that the given page is drawn over. The
+// boolean is false for pages that were inserted on the tablet.
+func (c *ContentFile) Redirection(pageID string) (int, bool) {
+	for _, page := range c.CPages.Pages {
+		if page.ID == pageID {
+			if page.Redir.Value == nil || *page.Redir.Value < 0 {
+				return 0, false
+			}
+			return *page.Redir.Value, true
+		}
+	}
+
+	for i, id := range c.Pages {
+		if id == pageID && i < len(c.RedirectionPageMap) {
+			if c.RedirectionPageMap[i] < 0 {
+				return 0, false
+			}
+			return c.RedirectionPageMap[i], true
+		}
+	}
+	return 0, false
+}
+
+// PageTagNames returns the names of the tags attached to the given page
+func (c *ContentFile) PageTagNames(pageID string) []string {
+	var names []string
+	for _, tag := range c.PageTags {
+		if tag.PageID == pageID {
+			names = append(names, tag.Name)
+		}
+	}
+	return names
+}
+
+// TagNames returns the names of the tags attached to the document
+func (c *ContentFile) TagNames() []string {
+	var names []string
+	for _, tag := range c.Tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+// AllTagNames returns the names of the document's tags followed by those of
+// its page tags, each once, in the order they first appear
+func (c *ContentFile) AllTagNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			names = append(names, name)
+		}
+	}
+	for _, tag := range c.Tags {
+		add(tag.Name)
+	}
+	for _, tag := range c.PageTags {
+		add(tag.Name)
+	}
+	return names
+}
+
+// HasTag reports whether the document carries a tag with the given name,
+// compared without regard to case
+func (c *ContentFile) HasTag(name string) bool {
+	for _, tag := range c.Tags {
+		if strings.EqualFold(tag.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPageTag reports whether the given page carries a tag with the given
+// name, compared without regard to case
+func (c *ContentFile) HasPageTag(pageID, name string) bool {
+	for _, tag := range c.PageTags {
+		if tag.PageID == pageID && strings.EqualFold(tag.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderPageIDs sorts page IDs into the order recorded in the content file.
+// Deleted pages are dropped; IDs the content file does not mention are
+// returned separately, in their original order.
+func (c *ContentFile) OrderPageIDs(ids []string) (ordered, unknown []string) {
+	present := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		present[id] = true
+	}
+
+	known := make(map[string]bool)
+	for _, id := range c.GetPageIDs() {
+		known[id] = true
+		if present[id] {
+			ordered = append(ordered, id)
+			present[id] = false
+		}
+	}
+	for _, id := range c.DeletedPageIDs() {
+		known[id] = true
+	}
+
+	for _, id := range ids {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	return ordered, unknown
+}
+
 // OrderFilesByContent orders .rm files according to a .content file
 // Returns the ordered files and a boolean indicating if the content file was used
 func OrderFilesByContent(files []string, contentPath string) ([]string, bool) {
@@ -72,49 +310,34 @@ func OrderFilesByContent(files []string, contentPath string) ([]string, bool) {
 		return files, false
 	}
 
-	// Get page IDs in order
-	pageIDs := content.GetPageIDs()
-	if len(pageIDs) == 0 {
-		return files, false
-	}
-
 	// Create a map of page ID to file path
 	fileMap := make(map[string]string)
+	ids := make([]string, 0, len(files))
 	for _, file := range files {
 		// Extract the base name without extension
 		baseName := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
 		fileMap[baseName] = file
+		ids = append(ids, baseName)
 	}
 
-	// Build ordered list based on content file
-	orderedFiles := make([]string, 0, len(pageIDs))
-	matchedCount := 0
-
-	for _, pageID := range pageIDs {
-		if file, ok := fileMap[pageID]; ok {
-			orderedFiles = append(orderedFiles, file)
-			matchedCount++
-		}
-	}
+	// Build ordered list based on content file, skipping deleted pages
+	orderedIDs, unmatchedIDs := content.OrderPageIDs(ids)
 
 	// If we didn't match any files, return original list
-	if matchedCount == 0 {
+	if len(orderedIDs) == 0 {
 		return files, false
 	}
 
-	// If we matched some but not all files, add unmatched files at the end
-	// sorted by modification time
-	if matchedCount < len(files) {
-		unmatchedFiles := make([]string, 0)
-		matchedSet := make(map[string]bool)
-		for _, f := range orderedFiles {
-			matchedSet[f] = true
-		}
+	orderedFiles := make([]string, 0, len(files))
+	for _, id := range orderedIDs {
+		orderedFiles = append(orderedFiles, fileMap[id])
+	}
 
-		for _, file := range files {
-			if !matchedSet[file] {
-				unmatchedFiles = append(unmatchedFiles, file)
-			}
+	// Add unmatched files at the end sorted by modification time
+	if len(unmatchedIDs) > 0 {
+		unmatchedFiles := make([]string, 0, len(unmatchedIDs))
+		for _, id := range unmatchedIDs {
+			unmatchedFiles = append(unmatchedFiles, fileMap[id])
 		}
 
 		// Sort unmatched by modification time
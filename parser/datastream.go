@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 const HeaderV6 = "reMarkable .lines file, version=6          "
 
 // DataStream provides low-level reading of remarkable v6 file format
 type DataStream struct {
-	reader io.Reader
+	reader  io.Reader
+	scratch [8]byte // Bytes of the fixed-size value being read
 }
 
 // NewDataStream creates a new DataStream
@@ -19,6 +23,34 @@ func NewDataStream(r io.Reader) *DataStream {
 	return &DataStream{reader: r}
 }
 
+// headerPrefix is the part of the file header shared by all versions
+const headerPrefix = "reMarkable .lines file, version="
+
+// DetectVersion reads the file header from r and returns the .rm format
+// version (3, 5 or 6). It consumes the header; use ReadScene to detect the
+// version and parse in one step.
+func DetectVersion(r io.Reader) (int, error) {
+	header := make([]byte, len(HeaderV6))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	if !strings.HasPrefix(string(header), headerPrefix) {
+		return 0, fmt.Errorf("not a reMarkable .rm file: invalid header %q", string(header))
+	}
+
+	versionText := strings.TrimSpace(string(header[len(headerPrefix):]))
+	version, err := strconv.Atoi(versionText)
+	if err != nil {
+		return 0, fmt.Errorf("invalid header version: %q", versionText)
+	}
+	switch version {
+	case 3, 5, 6:
+		return version, nil
+	default:
+		return 0, fmt.Errorf("unsupported .rm file version %d (supported: 3, 5, 6)", version)
+	}
+}
+
 // ReadHeader reads and validates the file header
 func (ds *DataStream) ReadHeader() error {
 	header := make([]byte, len(HeaderV6))
@@ -40,6 +72,41 @@ func (ds *DataStream) ReadBytes(n int) ([]byte, error) {
 	return buf, nil
 }
 
+// readChunkSize is the most readGrowing reads at once while its buffer is
+// small
+const readChunkSize = 64 << 10
+
+// readGrowing reads n bytes from r into buf, growing it as the data arrives
+// instead of to n up front. Sizes declared in a file then only cost memory
+// for the data that is actually there. It returns the bytes read and
+// io.ErrUnexpectedEOF if r ends first.
+func readGrowing(r io.Reader, buf []byte, n int) ([]byte, error) {
+	buf = buf[:0]
+	for len(buf) < n {
+		chunk := min(n-len(buf), max(len(buf), readChunkSize))
+		buf = slices.Grow(buf, chunk)
+		read, err := io.ReadFull(r, buf[len(buf):len(buf)+chunk])
+		buf = buf[:len(buf)+read]
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// readFixed reads n bytes, at most 8, into the stream's scratch space. The
+// bytes are only valid until the next read.
+func (ds *DataStream) readFixed(n int) ([]byte, error) {
+	buf := ds.scratch[:n]
+	if _, err := io.ReadFull(ds.reader, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
 // ReadBool reads a boolean value
 func (ds *DataStream) ReadBool() (bool, error) {
 	b, err := ds.ReadUint8()
@@ -48,7 +115,10 @@ func (ds *DataStream) ReadBool() (bool, error) {
 
 // ReadUint8 reads a uint8
 func (ds *DataStream) ReadUint8() (uint8, error) {
-	buf, err := ds.ReadBytes(1)
+	if br, ok := ds.reader.(io.ByteReader); ok {
+		return br.ReadByte()
+	}
+	buf, err := ds.readFixed(1)
 	if err != nil {
 		return 0, err
 	}
@@ -57,7 +127,7 @@ func (ds *DataStream) ReadUint8() (uint8, error) {
 
 // ReadUint16 reads a little-endian uint16
 func (ds *DataStream) ReadUint16() (uint16, error) {
-	buf, err := ds.ReadBytes(2)
+	buf, err := ds.readFixed(2)
 	if err != nil {
 		return 0, err
 	}
@@ -66,7 +136,7 @@ func (ds *DataStream) ReadUint16() (uint16, error) {
 
 // ReadUint32 reads a little-endian uint32
 func (ds *DataStream) ReadUint32() (uint32, error) {
-	buf, err := ds.ReadBytes(4)
+	buf, err := ds.readFixed(4)
 	if err != nil {
 		return 0, err
 	}
@@ -75,7 +145,7 @@ func (ds *DataStream) ReadUint32() (uint32, error) {
 
 // ReadFloat32 reads a little-endian float32
 func (ds *DataStream) ReadFloat32() (float32, error) {
-	buf, err := ds.ReadBytes(4)
+	buf, err := ds.readFixed(4)
 	if err != nil {
 		return 0, err
 	}
@@ -85,7 +155,7 @@ func (ds *DataStream) ReadFloat32() (float32, error) {
 
 // ReadFloat64 reads a little-endian float64
 func (ds *DataStream) ReadFloat64() (float64, error) {
-	buf, err := ds.ReadBytes(8)
+	buf, err := ds.readFixed(8)
 	if err != nil {
 		return 0, err
 	}
@@ -103,6 +173,9 @@ func (ds *DataStream) ReadVarUint() (uint64, error) {
 		if err != nil {
 			return 0, err
 		}
+		if shift >= 64 {
+			return 0, fmt.Errorf("varuint longer than 64 bits")
+		}
 
 		result |= uint64(b&0x7F) << shift
 		shift += 7
@@ -136,6 +209,9 @@ func (ds *DataStream) ReadString() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if err := checkLimit("string length", length, MaxStringLength); err != nil {
+		return "", err
+	}
 
 	// Read the "is_ascii" flag
 	isAscii, err := ds.ReadBool()
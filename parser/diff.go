@@ -0,0 +1,84 @@
+package parser
+
+import "slices"
+
+// PageDiff lists how the strokes of a page changed between two versions of
+// it. Strokes are matched by the CRDT IDs of their items, so a stroke that
+// keeps its ID but whose points changed, e.g. by being moved, is changed
+// rather than removed and added again.
+type PageDiff struct {
+	// Added are the strokes of the new version that the old one doesn't have
+	Added []*Line
+
+	// Removed are the strokes of the old version that were deleted or erased
+	Removed []*Line
+
+	// Changed are the strokes of both versions whose points differ, as in
+	// the new version
+	Changed []*Line
+
+	// Unchanged are the strokes that are the same in both versions, as in
+	// the new version
+	Unchanged []*Line
+}
+
+// DiffPages compares two versions of a page. Eraser strokes are left out;
+// typed text is not compared.
+func DiffPages(oldTree, newTree *SceneTree) *PageDiff {
+	oldLines := treeLines(oldTree)
+	newLines := treeLines(newTree)
+
+	diff := &PageDiff{}
+	for _, id := range newLines.order {
+		line := newLines.byID[id]
+		prev, ok := oldLines.byID[id]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, line)
+		case !slices.Equal(prev.Points, line.Points):
+			diff.Changed = append(diff.Changed, line)
+		default:
+			diff.Unchanged = append(diff.Unchanged, line)
+		}
+	}
+	for _, id := range oldLines.order {
+		if _, ok := newLines.byID[id]; !ok {
+			diff.Removed = append(diff.Removed, oldLines.byID[id])
+		}
+	}
+	return diff
+}
+
+// lineIndex holds the strokes of a tree by item ID, in drawing order
+type lineIndex struct {
+	byID  map[CrdtID]*Line
+	order []CrdtID
+}
+
+// treeLines indexes the strokes of a tree, leaving out erasers and deleted
+// items
+func treeLines(tree *SceneTree) lineIndex {
+	index := lineIndex{byID: make(map[CrdtID]*Line)}
+	if tree != nil && tree.Root != nil {
+		index.addGroup(tree.Root)
+	}
+	return index
+}
+
+func (index *lineIndex) addGroup(group *Group) {
+	for _, item := range children(group) {
+		if item.Deleted() {
+			continue
+		}
+		switch v := item.Value.(type) {
+		case *Group:
+			index.addGroup(v)
+		case *Line:
+			if v.Tool == PenEraser || v.Tool == PenEraserArea {
+				continue
+			}
+			index.byID[item.ItemID] = v
+			index.order = append(index.order, item.ItemID)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+// maxDumpTagIndex is the largest tag index DecodeFields accepts. Known blocks
+// use small indices, so larger ones mean the data is not tagged.
+const maxDumpTagIndex = 64
+
+// RawBlock is a top-level block of a v6 file with its undecoded payload
+type RawBlock struct {
+	BlockInfo
+	Data []byte
+}
+
+// ReadRawBlocks reads the header and every top-level block of a v6 file
+// without interpreting the block contents. On error, the blocks read so far
+// are returned along with it.
+func ReadRawBlocks(r io.Reader) ([]RawBlock, error) {
+	reader := NewTaggedBlockReader(r)
+	if err := reader.ReadHeader(); err != nil {
+		return nil, err
+	}
+
+	var blocks []RawBlock
+	for {
+		info, err := reader.ReadBlock()
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return blocks, fmt.Errorf("failed to read block header: %w", err)
+		}
+
+		// Read through a limit so a corrupt size cannot allocate more than the file holds
+		data, err := io.ReadAll(io.LimitReader(reader.reader, int64(info.Size)))
+		if err == nil && len(data) < int(info.Size) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return blocks, fmt.Errorf("failed to read block 0x%02X at offset %d: %w", info.BlockType, info.Offset, err)
+		}
+		blocks = append(blocks, RawBlock{BlockInfo: *info, Data: data})
+
+		if err := reader.EndBlock(); err != nil {
+			return blocks, fmt.Errorf("failed to end block: %w", err)
+		}
+	}
+}
+
+// Field is a tagged value decoded from block data without knowing the
+// block's layout
+type Field struct {
+	Offset   int     // Position of the tag within the decoded data
+	Index    int     // Tag index
+	Type     TagType // Tag type
+	Value    string  // Human-readable value
+	Children []Field // Fields of a subblock whose contents are tagged
+	Raw      []byte  // Contents of a subblock that is not tagged
+}
+
+// DecodeFields decodes data as a sequence of tagged values. Subblocks are
+// decoded recursively when their contents are tagged, and shown as strings
+// when they hold one. Decoding stops at the first byte that does not start a
+// valid tag; the undecoded remainder is returned as rest.
+func DecodeFields(data []byte) (fields []Field, rest []byte) {
+	return decodeFields(data, 0)
+}
+
+func decodeFields(data []byte, base int) ([]Field, []byte) {
+	var fields []Field
+	pos := 0
+	for pos < len(data) {
+		field, n, ok := decodeField(data[pos:], base+pos)
+		if !ok {
+			break
+		}
+		fields = append(fields, field)
+		pos += n
+	}
+	return fields, data[pos:]
+}
+
+// decodeField decodes the tagged value at the start of data and returns it
+// with the number of bytes it took
+func decodeField(data []byte, offset int) (Field, int, bool) {
+	tag, n := binary.Uvarint(data)
+	if n <= 0 || tag>>4 > maxDumpTagIndex {
+		return Field{}, 0, false
+	}
+	field := Field{Offset: offset, Index: int(tag >> 4), Type: TagType(tag & 0xF)}
+	value := data[n:]
+
+	switch field.Type {
+	case TagTypeID:
+		if len(value) < 2 {
+			return Field{}, 0, false
+		}
+		part2, m := binary.Uvarint(value[1:])
+		if m <= 0 {
+			return Field{}, 0, false
+		}
+		field.Value = CrdtID{Part1: uint(value[0]), Part2: part2}.String()
+		return field, n + 1 + m, true
+
+	case TagTypeByte1:
+		if len(value) < 1 {
+			return Field{}, 0, false
+		}
+		field.Value = fmt.Sprintf("%d", value[0])
+		return field, n + 1, true
+
+	case TagTypeByte4:
+		if len(value) < 4 {
+			return Field{}, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(value)
+		field.Value = fmt.Sprintf("%d (float %g)", bits, math.Float32frombits(bits))
+		return field, n + 4, true
+
+	case TagTypeByte8:
+		if len(value) < 8 {
+			return Field{}, 0, false
+		}
+		field.Value = fmt.Sprintf("%g", math.Float64frombits(binary.LittleEndian.Uint64(value)))
+		return field, n + 8, true
+
+	case TagTypeLength4:
+		if len(value) < 4 {
+			return Field{}, 0, false
+		}
+		length := int(binary.LittleEndian.Uint32(value))
+		if length > len(value)-4 {
+			return Field{}, 0, false
+		}
+		contents := value[4 : 4+length]
+		field.Value = fmt.Sprintf("subblock, %d bytes", length)
+
+		if s, ok := decodeString(contents); ok {
+			field.Value = fmt.Sprintf("string %q", s)
+		} else if children, rest := decodeFields(contents, offset+n+4); len(rest) == 0 && len(children) > 0 {
+			field.Children = children
+		} else {
+			field.Raw = contents
+		}
+		return field, n + 4 + length, true
+
+	default:
+		return Field{}, 0, false
+	}
+}
+
+// decodeString decodes data as a string subblock: a length, an ASCII flag
+// and exactly that many bytes of UTF-8 text
+func decodeString(data []byte) (string, bool) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || len(data) <= n || uint64(len(data)-n-1) != length || data[n] > 1 {
+		return "", false
+	}
+	s := data[n+1:]
+	if !utf8.Valid(s) || bytes.ContainsFunc(s, func(r rune) bool { return r < 0x20 && r != '\n' && r != '\t' }) {
+		return "", false
+	}
+	return string(s), true
+}
@@ -0,0 +1,222 @@
+package parser
+
+// editAuthor is the CRDT author index used for IDs of items added by the
+// editing methods
+const editAuthor = 1
+
+// Layers returns the top-level groups of the tree in drawing order
+func (st *SceneTree) Layers() []*Group {
+	var layers []*Group
+	for _, item := range children(st.Root) {
+		if layer, ok := item.Value.(*Group); ok && !item.Deleted() {
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
+
+// AddLayer adds an empty, visible layer on top of the existing ones and
+// returns it
+func (st *SceneTree) AddLayer(label string) *Group {
+	layer := NewEmptyGroup(st.newID())
+	layer.Label.Value = label
+	st.Nodes[layer.NodeID] = layer
+	st.appendItem(st.Root, layer)
+	return layer
+}
+
+// AddLine adds a stroke on top of the other items of a group, which must be
+// part of the tree. It returns the ID of the new item.
+func (st *SceneTree) AddLine(group *Group, line *Line) CrdtID {
+	return st.appendItem(group, line)
+}
+
+// AddPage adds a copy of the strokes and text boxes of another page on top
+// of the existing layers, as a single layer named label that holds the
+// page's own layers, and returns it. The copied groups and items get new
+// IDs, so pages read from different files can be combined. The typed text
+// of the other page is not copied.
+func (st *SceneTree) AddPage(page *SceneTree, label string) *Group {
+	layer := page.Clone().Root
+	ids := renumbering{next: st.newID().Part2, ids: make(map[CrdtID]CrdtID)}
+	ids.group(layer)
+	layer.Label.Value = label
+	layer.Visible.Value = true
+
+	st.addNodes(layer)
+	st.appendItem(st.Root, layer)
+	return layer
+}
+
+// DeleteLayer removes a group and everything in it from the tree. It reports
+// whether the group was found. The root group cannot be deleted.
+func (st *SceneTree) DeleteLayer(id CrdtID) bool {
+	group, ok := st.Nodes[id]
+	if !ok || group == st.Root {
+		return false
+	}
+
+	removed := st.Root.removeItems(func(item CrdtSequenceItem) bool { return item.Value == group })
+	if removed == 0 {
+		return false
+	}
+	st.forgetGroup(group)
+	return true
+}
+
+// RemoveLines removes the strokes for which match returns true from the
+// whole tree, and returns how many were removed. For example, to drop eraser
+// strokes before export:
+//
+//	tree.RemoveLines(func(l *parser.Line) bool { return l.Tool == parser.PenEraser })
+func (st *SceneTree) RemoveLines(match func(*Line) bool) int {
+	return st.Root.RemoveLines(match)
+}
+
+// RemoveLines removes the strokes for which match returns true from the group
+// and its subgroups, and returns how many were removed
+func (g *Group) RemoveLines(match func(*Line) bool) int {
+	return g.removeItems(func(item CrdtSequenceItem) bool {
+		line, ok := item.Value.(*Line)
+		return ok && match(line)
+	})
+}
+
+// Translate moves every stroke and text block in the group and its
+// subgroups by (dx, dy) screen units
+func (g *Group) Translate(dx, dy float32) {
+	g.Transform(func(x, y float32) (float32, float32) {
+		return x + dx, y + dy
+	})
+}
+
+// Transform maps the position of every stroke point and text block in the
+// group and its subgroups through fn. Stroke widths are left unchanged.
+func (g *Group) Transform(fn func(x, y float32) (float32, float32)) {
+	for _, item := range children(g) {
+		switch v := item.Value.(type) {
+		case *Group:
+			v.Transform(fn)
+		case *Line:
+			for i := range v.Points {
+				v.Points[i].X, v.Points[i].Y = fn(v.Points[i].X, v.Points[i].Y)
+			}
+		case *Text:
+			x, y := fn(float32(v.PosX), float32(v.PosY))
+			v.PosX, v.PosY = float64(x), float64(y)
+		}
+	}
+}
+
+// removeItems removes the items for which match returns true from the group
+// and its subgroups, and returns how many were removed
+func (g *Group) removeItems(match func(CrdtSequenceItem) bool) int {
+	if g.Children == nil {
+		return 0
+	}
+
+	removed := 0
+	items := g.Children.Items[:0]
+	for _, item := range g.Children.Items {
+		if item.Value != nil && match(item) {
+			removed++
+			continue
+		}
+		if child, ok := item.Value.(*Group); ok {
+			removed += child.removeItems(match)
+		}
+		items = append(items, item)
+	}
+	g.Children.Items = items
+	return removed
+}
+
+// appendItem adds a value at the end of a group's children, linked after
+// the current last item, and returns the new item's ID
+func (st *SceneTree) appendItem(group *Group, value interface{}) CrdtID {
+	if group.Children == nil {
+		group.Children = NewCrdtSequence()
+	}
+
+	item := CrdtSequenceItem{ItemID: st.newID(), Value: value}
+	if n := len(group.Children.Items); n > 0 {
+		item.LeftID = group.Children.Items[n-1].ItemID
+	}
+	group.Children.Add(item)
+	return item.ItemID
+}
+
+// newID returns an ID that is not used by any group or item in the tree
+func (st *SceneTree) newID() CrdtID {
+	var last uint64
+	for id, group := range st.Nodes {
+		last = max(last, id.Part2)
+		for _, item := range children(group) {
+			last = max(last, item.ItemID.Part2)
+		}
+	}
+	return CrdtID{Part1: editAuthor, Part2: last + 1}
+}
+
+// addNodes adds a group and its subgroups to the node index
+func (st *SceneTree) addNodes(group *Group) {
+	st.Nodes[group.NodeID] = group
+	for _, item := range children(group) {
+		if child, ok := item.Value.(*Group); ok {
+			st.addNodes(child)
+		}
+	}
+}
+
+// renumbering gives the groups and items of a copied page IDs that follow
+// those of the tree it is added to
+type renumbering struct {
+	next uint64
+	ids  map[CrdtID]CrdtID
+}
+
+// id returns the new ID of an old one
+func (r *renumbering) id(old CrdtID) CrdtID {
+	if id, ok := r.ids[old]; ok {
+		return id
+	}
+	id := CrdtID{Part1: editAuthor, Part2: r.next}
+	r.next++
+	r.ids[old] = id
+	return id
+}
+
+// group renumbers a group and everything in it. The neighbors of items are
+// renumbered once every item of the sequence has its new ID.
+func (r *renumbering) group(group *Group) {
+	group.NodeID = r.id(group.NodeID)
+	if group.Children == nil {
+		return
+	}
+
+	items := group.Children.Items
+	for i := range items {
+		items[i].ItemID = r.id(items[i].ItemID)
+		if child, ok := items[i].Value.(*Group); ok {
+			r.group(child)
+		}
+	}
+	for i := range items {
+		if id, ok := r.ids[items[i].LeftID]; ok {
+			items[i].LeftID = id
+		}
+		if id, ok := r.ids[items[i].RightID]; ok {
+			items[i].RightID = id
+		}
+	}
+}
+
+// forgetGroup removes a group and its subgroups from the node index
+func (st *SceneTree) forgetGroup(group *Group) {
+	delete(st.Nodes, group.NodeID)
+	for _, item := range children(group) {
+		if child, ok := item.Value.(*Group); ok {
+			st.forgetGroup(child)
+		}
+	}
+}
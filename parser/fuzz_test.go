@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzReadScene feeds arbitrary files to every reader of .rm files, which
+// must return an error rather than panic or hang on anything they are given.
+// It is seeded with the sample files in tests/:
+//
+//	go test -fuzz FuzzReadScene ./parser
+func FuzzReadScene(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("..", "tests", "*.rm"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if result, err := ReadScene(bytes.NewReader(data), logger); err == nil && result.Tree == nil {
+			t.Error("ReadScene returned no tree and no error")
+		}
+		if result, err := ReadSceneBytes(data, logger); err == nil && result.Tree == nil {
+			t.Error("ReadSceneBytes returned no tree and no error")
+		}
+		if result, err := ReadSceneStrict(bytes.NewReader(data), logger); err == nil && result.Tree == nil {
+			t.Error("ReadSceneStrict returned no tree and no error")
+		}
+		ReadRawBlocks(bytes.NewReader(data))
+	})
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"sync"
+)
+
+// BlockHandler decodes a block of a type registered with
+// RegisterBlockHandler. The reader is positioned at the start of the block's
+// data; whatever the handler leaves unread is skipped. A value other than
+// nil is added to SceneTree.Extras. An error is reported as a warning, like
+// other blocks that fail to parse.
+type BlockHandler func(reader *TaggedBlockReader, info *BlockInfo) (any, error)
+
+// Extra is a value decoded from a block by a registered BlockHandler
+type Extra struct {
+	Block BlockInfo
+	Value any
+}
+
+var (
+	blockHandlersMu sync.RWMutex
+	blockHandlers   = make(map[uint8]BlockHandler)
+)
+
+// decodedBlockTypes are the block types the parser reads itself, which
+// can't have a handler
+var decodedBlockTypes = map[uint8]bool{
+	BlockTypeSceneTree:      true,
+	BlockTypeTreeNode:       true,
+	BlockTypeSceneGlyphItem: true,
+	BlockTypeSceneGroupItem: true,
+	BlockTypeSceneLineItem:  true,
+	BlockTypeSceneTextItem:  true,
+	BlockTypeRootText:       true,
+	BlockTypeSceneTombstone: true,
+}
+
+// RegisterBlockHandler sets the decoder of a block type the parser doesn't
+// read itself, such as one added by a firmware update or one it skips, like
+// BlockTypePageInfo. The values it decodes are collected in the Extras of
+// the scene tree, in file order. A nil handler removes the one registered
+// before. It panics for the block types the parser reads. Handlers apply to
+// every file parsed afterwards, so register them at startup:
+//
+//	func init() {
+//		parser.RegisterBlockHandler(0x0E, func(r *parser.TaggedBlockReader, info *parser.BlockInfo) (any, error) {
+//			return r.BlockBytes()
+//		})
+//	}
+func RegisterBlockHandler(blockType uint8, handler BlockHandler) {
+	if decodedBlockTypes[blockType] {
+		panic(fmt.Sprintf("parser: block type 0x%02X is read by the parser", blockType))
+	}
+	blockHandlersMu.Lock()
+	defer blockHandlersMu.Unlock()
+	if handler == nil {
+		delete(blockHandlers, blockType)
+		return
+	}
+	blockHandlers[blockType] = handler
+}
+
+// blockHandler returns the handler registered for a block type, if any
+func blockHandler(blockType uint8) (BlockHandler, bool) {
+	blockHandlersMu.RLock()
+	defer blockHandlersMu.RUnlock()
+	handler, ok := blockHandlers[blockType]
+	return handler, ok
+}
+
+// readExtraBlock decodes a block with its registered handler
+func (st *SceneTree) readExtraBlock(reader *TaggedBlockReader, blockInfo *BlockInfo, handler BlockHandler) error {
+	value, err := handler(reader, blockInfo)
+	if err != nil {
+		return fmt.Errorf("block handler failed: %w", err)
+	}
+	if value != nil {
+		st.Extras = append(st.Extras, Extra{Block: *blockInfo, Value: value})
+	}
+	return nil
+}
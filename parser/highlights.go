@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"math"
+)
+
+// Highlight is a passage marked on a page: a range of PDF or EPUB text
+// selected with the highlighter, or a highlighter stroke drawn freehand
+type Highlight struct {
+	Page          int    // 1-based page number
+	Text          string // Highlighted text; empty for strokes
+	Color         PenColor
+	ColorOverride *RGBA     // Exact color written by newer firmware, if any
+	Bounds        Rectangle // Area covered, in the coordinates stored in the file
+	Stroke        bool      // Drawn freehand, so the text underneath is unknown
+}
+
+// CollectHighlights returns the highlights of each page of a notebook in
+// page order, and in drawing order within a page. Pages are numbered from 1
+// in the order given.
+func CollectHighlights(trees []*SceneTree) []Highlight {
+	var highlights []Highlight
+	for i, tree := range trees {
+		highlights = append(highlights, PageHighlights(tree, i+1)...)
+	}
+	return highlights
+}
+
+// PageHighlights returns the highlights of one page, numbered page
+func PageHighlights(tree *SceneTree, page int) []Highlight {
+	if tree == nil || tree.Root == nil {
+		return nil
+	}
+	var highlights []Highlight
+	var collect func(group *Group)
+	collect = func(group *Group) {
+		for _, item := range children(group) {
+			if item.Deleted() {
+				continue
+			}
+			switch v := item.Value.(type) {
+			case *Group:
+				collect(v)
+			case *GlyphRange:
+				highlights = append(highlights, Highlight{
+					Page:          page,
+					Text:          v.Text,
+					Color:         v.Color,
+					ColorOverride: v.ColorOverride,
+					Bounds:        rectanglesBounds(v.Rectangles),
+				})
+			case *Line:
+				if (v.Tool != PenHighlighter1 && v.Tool != PenHighlighter2) || len(v.Points) == 0 {
+					continue
+				}
+				highlights = append(highlights, Highlight{
+					Page:          page,
+					Color:         v.Color,
+					ColorOverride: v.ColorOverride,
+					Bounds:        pointsBounds(v.Points),
+					Stroke:        true,
+				})
+			}
+		}
+	}
+	collect(tree.Root)
+	return highlights
+}
+
+// rectanglesBounds returns the smallest rectangle around all of rects
+func rectanglesBounds(rects []Rectangle) Rectangle {
+	if len(rects) == 0 {
+		return Rectangle{}
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, r := range rects {
+		minX, maxX = math.Min(minX, r.X), math.Max(maxX, r.X+r.W)
+		minY, maxY = math.Min(minY, r.Y), math.Max(maxY, r.Y+r.H)
+	}
+	return Rectangle{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}
+}
+
+// pointsBounds returns the smallest rectangle around the points of a stroke
+func pointsBounds(points []Point) Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		x, y := float64(p.X), float64(p.Y)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return Rectangle{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+)
+
+// Headers of the legacy .lines formats written by software versions before 3.0
+const (
+	HeaderV3 = "reMarkable .lines file, version=3          "
+	HeaderV5 = "reMarkable .lines file, version=5          "
+)
+
+// Limits guarding against corrupt counts in legacy files
+const (
+	maxLegacyLayers  = 1 << 10
+	maxLegacyStrokes = 1 << 20
+)
+
+// legacyXOffset converts legacy x coordinates, which start at the left edge
+// of the page, to v6 coordinates, which are centered on the page
+const legacyXOffset = 1404 / 2
+
+// ReadLegacySceneTree reads a v3 or v5 .lines file into a scene tree with one
+// group per layer, so it can be exported like a v6 file.
+//
+// Legacy files have a fixed binary layout: a layer count, then for each layer a
+// stroke count, and for each stroke its pen, color, width and points.
+func ReadLegacySceneTree(r io.Reader) (*SceneTree, error) {
+	ds := NewDataStream(r)
+
+	header, err := ds.ReadBytes(len(HeaderV6))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	var version int
+	switch string(header) {
+	case HeaderV3:
+		version = 3
+	case HeaderV5:
+		version = 5
+	default:
+		return nil, fmt.Errorf("invalid legacy header: %q", string(header))
+	}
+
+	numLayers, err := ds.ReadUint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer count: %w", err)
+	}
+	if err := checkLimit("layer count", uint64(numLayers), maxLegacyLayers); err != nil {
+		return nil, err
+	}
+
+	tree := NewSceneTree()
+	// Item IDs only need to be unique within the tree
+	nextID := uint64(2)
+	newID := func() CrdtID {
+		id := CrdtID{Part1: 0, Part2: nextID}
+		nextID++
+		return id
+	}
+
+	for layer := uint32(0); layer < numLayers; layer++ {
+		group := NewEmptyGroup(newID())
+		group.Label.Value = fmt.Sprintf("Layer %d", layer+1)
+		tree.Nodes[group.NodeID] = group
+		tree.Root.Children.Add(CrdtSequenceItem{ItemID: newID(), Value: group})
+
+		numStrokes, err := ds.ReadUint32()
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: failed to read stroke count: %w", layer+1, err)
+		}
+		if err := checkLimit("stroke count", uint64(numStrokes), maxLegacyStrokes); err != nil {
+			return nil, fmt.Errorf("layer %d: %w", layer+1, err)
+		}
+
+		for stroke := uint32(0); stroke < numStrokes; stroke++ {
+			line, err := readLegacyLine(ds, version)
+			if err != nil {
+				return nil, fmt.Errorf("layer %d, stroke %d: %w", layer+1, stroke+1, err)
+			}
+			group.Children.Add(CrdtSequenceItem{ItemID: newID(), Value: line})
+		}
+	}
+
+	return tree, nil
+}
+
+// readLegacyLine reads one stroke of a v3 or v5 file
+func readLegacyLine(ds *DataStream, version int) (*Line, error) {
+	tool, err := ds.ReadUint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pen: %w", err)
+	}
+	color, err := ds.ReadUint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read color: %w", err)
+	}
+	// Unused field
+	if _, err := ds.ReadUint32(); err != nil {
+		return nil, err
+	}
+	width, err := ds.ReadFloat32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read width: %w", err)
+	}
+	if version >= 5 {
+		// Unused field added in v5
+		if _, err := ds.ReadUint32(); err != nil {
+			return nil, err
+		}
+	}
+
+	numPoints, err := ds.ReadUint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read point count: %w", err)
+	}
+	if err := checkLimit("point count", uint64(numPoints), MaxPointsPerLine); err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, numPoints)
+	for i := uint32(0); i < numPoints; i++ {
+		// Legacy points use the same float layout as v1 line points
+		point, err := readPoint(ds, 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read point %d: %w", i+1, err)
+		}
+		point.X -= legacyXOffset
+		points = append(points, point)
+	}
+
+	return &Line{
+		Color:          PenColor(color),
+		Tool:           Pen(tool),
+		Points:         points,
+		ThicknessScale: float64(width),
+	}, nil
+}
@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Limits on sizes and counts read from a file. Real files stay far below
+// them; they keep a corrupt or malicious file from making the parser
+// allocate large amounts of memory or recurse without bound.
+const (
+	MaxBlockSize       = 64 << 20 // Bytes in one top-level block
+	MaxPointsPerLine   = 1 << 20  // Points in one stroke
+	MaxStringLength    = 1 << 20  // Bytes in one string
+	MaxGroupDepth      = 256      // Nesting of groups within groups
+	MaxGlyphRectangles = 1 << 16  // Rectangles covered by one text highlight
+
+	// Limits on notebook archives, checked while decompressing so that a
+	// small archive cannot expand into more memory than they allow
+	MaxArchiveFileSize = 128 << 20 // Bytes in one file of an archive, decompressed
+	MaxArchiveSize     = 512 << 20 // Bytes in all files read from an archive, decompressed
+)
+
+// ErrLimitExceeded is matched by every *LimitError, for use with errors.Is
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError reports a size or count in the file that is larger than the
+// parser accepts
+type LimitError struct {
+	What  string // What was being read, e.g. "block size"
+	Value uint64 // Value found in the file
+	Limit uint64 // Largest accepted value
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %d exceeds limit of %d", e.What, e.Value, e.Limit)
+}
+
+// Is reports whether target is ErrLimitExceeded
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// checkLimit returns a *LimitError if value is larger than limit
+func checkLimit(what string, value, limit uint64) error {
+	if value > limit {
+		return &LimitError{What: what, Value: value, Limit: limit}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"runtime"
+	"testing"
+)
+
+// le32 encodes a little-endian uint32
+func le32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+// varUint encodes a variable-length unsigned integer
+func varUint(v uint64) []byte {
+	var b []byte
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// tag encodes the tag of a value
+func tag(index int, tagType TagType) []byte {
+	return varUint(uint64(index)<<4 | uint64(tagType))
+}
+
+// join concatenates byte slices
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// v6File returns a v6 file made of a block of the given type whose header
+// declares size bytes, followed by body
+func v6File(blockType uint8, size uint32, body []byte) []byte {
+	return join([]byte(HeaderV6), le32(size), []byte{0, 1, 2, blockType}, body)
+}
+
+// blockReader returns a reader positioned in the block of v6File
+func blockReader(t *testing.T, size uint32, body []byte) *TaggedBlockReader {
+	t.Helper()
+	reader := NewTaggedBlockReader(bytes.NewReader(v6File(0x05, size, body)))
+	reader.SetLogger(slog.New(slog.DiscardHandler))
+	if err := reader.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ReadBlock(); err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+// legacyFile returns a v3 file with the given layer and stroke counts and a
+// stroke of numPoints points, of which none are present
+func legacyFile(numLayers, numStrokes, numPoints uint32) []byte {
+	stroke := join(le32(0), le32(0), le32(0), le32(0), le32(numPoints))
+	return join([]byte(HeaderV3), le32(numLayers), le32(numStrokes), stroke)
+}
+
+// zeros reads as an endless run of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// TestLimits checks that every size and count above its limit is reported
+// as a *LimitError before anything of that size is allocated or read. The
+// inputs declare sizes just above the limits but hold little or no data.
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		what  string
+		limit uint64
+		read  func(t *testing.T) error
+	}{
+		{"block size", "block size", MaxBlockSize, func(t *testing.T) error {
+			_, err := ReadScene(bytes.NewReader(v6File(0x05, MaxBlockSize+1, nil)), nil)
+			return err
+		}},
+		{"block size at offset", "block size", MaxBlockSize, func(t *testing.T) error {
+			_, err := ReadSceneBytes(v6File(0x05, MaxBlockSize+1, nil), nil)
+			return err
+		}},
+		{"block size of raw blocks", "block size", MaxBlockSize, func(t *testing.T) error {
+			_, err := ReadRawBlocks(bytes.NewReader(v6File(0x05, MaxBlockSize+1, nil)))
+			return err
+		}},
+		{"subblock length", "subblock length", 5, func(t *testing.T) error {
+			reader := blockReader(t, 10, join(tag(1, TagTypeLength4), le32(1000)))
+			_, err := reader.ReadSubblock(1)
+			return err
+		}},
+		{"string length", "string length", MaxStringLength, func(t *testing.T) error {
+			_, err := NewDataStream(bytes.NewReader(varUint(MaxStringLength + 1))).ReadString()
+			return err
+		}},
+		{"points per line", "point count", MaxPointsPerLine, func(t *testing.T) error {
+			length := uint32(MaxPointsPerLine+1) * PointSizeV2
+			reader := blockReader(t, MaxBlockSize, join(tag(5, TagTypeLength4), le32(length)))
+			_, err := readLinePoints(reader, 2)
+			return err
+		}},
+		{"glyph rectangles", "rectangle count", MaxGlyphRectangles, func(t *testing.T) error {
+			body := join(
+				tag(3, TagTypeByte4), le32(4),
+				tag(4, TagTypeByte4), le32(0),
+				tag(5, TagTypeLength4), le32(6), varUint(4), []byte{1}, []byte("text"),
+				tag(6, TagTypeLength4), le32(8), varUint(MaxGlyphRectangles+1),
+			)
+			_, err := readGlyphRange(blockReader(t, MaxBlockSize, body))
+			return err
+		}},
+		{"legacy layers", "layer count", maxLegacyLayers, func(t *testing.T) error {
+			_, err := ReadLegacySceneTree(bytes.NewReader(legacyFile(maxLegacyLayers+1, 0, 0)))
+			return err
+		}},
+		{"legacy strokes", "stroke count", maxLegacyStrokes, func(t *testing.T) error {
+			_, err := ReadLegacySceneTree(bytes.NewReader(legacyFile(1, maxLegacyStrokes+1, 0)))
+			return err
+		}},
+		{"legacy points per line", "point count", MaxPointsPerLine, func(t *testing.T) error {
+			_, err := ReadScene(bytes.NewReader(legacyFile(1, 1, MaxPointsPerLine+1)), nil)
+			return err
+		}},
+		{"archive size", "archive size", MaxArchiveSize, func(t *testing.T) error {
+			total := uint64(MaxArchiveSize - 1)
+			_, err := readArchiveFile(io.LimitReader(zeros{}, 2), &total)
+			return err
+		}},
+		{"archive file size", "archive file size", MaxArchiveFileSize, func(t *testing.T) error {
+			if testing.Short() {
+				t.Skip("reads a file of MaxArchiveFileSize bytes")
+			}
+			var total uint64
+			_, err := readArchiveFile(zeros{}, &total)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.read(t)
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("got error %v, want a limit error", err)
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("error %v is not a *LimitError", err)
+			}
+			if limitErr.What != tt.what || limitErr.Limit != tt.limit || limitErr.Value <= limitErr.Limit {
+				t.Errorf("got %q value %d limit %d, want %q above limit %d",
+					limitErr.What, limitErr.Value, limitErr.Limit, tt.what, tt.limit)
+			}
+		})
+	}
+}
+
+// TestDeclaredSizes checks that sizes a file declares within the limits
+// only cost memory for the data that is actually there. Each input declares
+// tens of megabytes and holds a few bytes.
+func TestDeclaredSizes(t *testing.T) {
+	const maxAlloc = 1 << 20
+	discard := slog.New(slog.DiscardHandler)
+	unknownBlock := v6File(0x7E, MaxBlockSize, make([]byte, 10))
+
+	tests := []struct {
+		name string
+		read func(t *testing.T)
+	}{
+		{"unknown block", func(t *testing.T) {
+			ReadScene(bytes.NewReader(unknownBlock), discard)
+		}},
+		{"unknown block, strict", func(t *testing.T) {
+			ReadSceneStrict(bytes.NewReader(unknownBlock), discard)
+		}},
+		{"unknown block data", func(t *testing.T) {
+			if _, err := blockReader(t, MaxBlockSize, make([]byte, 10)).BlockBytes(); err == nil {
+				t.Error("read a truncated block without error")
+			}
+		}},
+		{"points", func(t *testing.T) {
+			length := uint32(MaxPointsPerLine) * PointSizeV2
+			reader := blockReader(t, MaxBlockSize, join(tag(5, TagTypeLength4), le32(length)))
+			if _, err := readLinePoints(reader, 2); err == nil {
+				t.Error("read truncated points without error")
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			tt.read(t)
+			runtime.ReadMemStats(&after)
+			if alloc := after.TotalAlloc - before.TotalAlloc; alloc > maxAlloc {
+				t.Errorf("allocated %d bytes for a few bytes of data", alloc)
+			}
+		})
+	}
+}
+
+// TestGroupDepthLimit checks that groups nested deeper than MaxGroupDepth
+// are dropped from the tree with a warning, so walking it stays bounded
+func TestGroupDepthLimit(t *testing.T) {
+	tree := NewSceneTree()
+	parent := tree.Root
+	for i := range MaxGroupDepth + 10 {
+		group := NewEmptyGroup(CrdtID{Part1: 1, Part2: uint64(i + 1)})
+		parent.Children.Add(CrdtSequenceItem{ItemID: group.NodeID, Value: group})
+		parent = group
+	}
+
+	reader := NewTaggedBlockReader(bytes.NewReader(nil))
+	reader.SetLogger(slog.New(slog.DiscardHandler))
+	tree.pruneGroups(tree.Root, make(map[*Group]bool), 0, reader)
+
+	depth := 0
+	for group := tree.Root; len(group.Children.Items) > 0; depth++ {
+		group = group.Children.Items[0].Value.(*Group)
+	}
+	if depth != MaxGroupDepth {
+		t.Errorf("got groups nested %d deep, want %d", depth, MaxGroupDepth)
+	}
+	if len(reader.Warnings()) != 1 {
+		t.Errorf("got warnings %v, want one for the dropped group", reader.Warnings())
+	}
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// webAddress matches web addresses written in typed text. Punctuation that
+// ends a sentence is trimmed by FindLinks.
+var webAddress = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// TextLink is a web address found in typed text
+type TextLink struct {
+	Start, End int    // Byte offsets of the address in the text
+	URL        string // Address to open, with https:// added to www. addresses
+}
+
+// FindLinks returns the web addresses written in a text, such as
+// https://example.com or www.example.com, in order. Links made on the
+// device are read from the formatting of the text instead (see
+// TextLinkStart).
+func FindLinks(text string) []TextLink {
+	var links []TextLink
+	for _, m := range webAddress.FindAllStringIndex(text, -1) {
+		start, end := m[0], m[1]
+		end = start + len(strings.TrimRight(text[start:end], ".,;:!?'\")]}"))
+		// Keep a closing bracket that belongs to the address, as in
+		// https://en.wikipedia.org/wiki/Go_(programming_language)
+		if end < m[1] && text[end] == ')' && strings.Count(text[start:end], "(") > strings.Count(text[start:end], ")") {
+			end++
+		}
+
+		url := text[start:end]
+		if strings.HasPrefix(strings.ToLower(url), "www.") {
+			url = "https://" + url
+		}
+		if len(url) > len("https://") {
+			links = append(links, TextLink{Start: start, End: end, URL: url})
+		}
+	}
+	return links
+}
+
+// linkSpans splits the spans of a line at the web addresses written in it
+// and sets the Link of the spans that are part of one, unless they are part
+// of a link made on the device
+func linkSpans(spans []TextSpan) []TextSpan {
+	var line strings.Builder
+	for _, span := range spans {
+		line.WriteString(span.Text)
+	}
+	links := FindLinks(line.String())
+	if len(links) == 0 {
+		return spans
+	}
+
+	var linked []TextSpan
+	pos := 0
+	for _, span := range spans {
+		end := pos + len(span.Text)
+		for pos < end {
+			piece := span
+			cut := end
+			for _, link := range links {
+				switch {
+				case pos >= link.Start && pos < link.End:
+					if span.Link == "" && span.PageID == "" {
+						piece.Link = link.URL
+					}
+					cut = min(cut, link.End)
+				case link.Start > pos:
+					cut = min(cut, link.Start)
+				}
+			}
+			piece.Text = line.String()[pos:cut]
+			linked = append(linked, piece)
+			pos = cut
+		}
+	}
+	return linked
+}
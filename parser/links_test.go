@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// textOf returns a text block made of the given item values, in order
+func textOf(values ...any) *Text {
+	items := NewCrdtSequence()
+	for i, value := range values {
+		items.Add(CrdtSequenceItem{ItemID: CrdtID{Part1: 1, Part2: uint64(i + 1)}, Value: value})
+	}
+	return &Text{Items: items, Styles: map[CrdtID]LwwValue[ParagraphStyle]{}}
+}
+
+// TestTextLinks checks the spans of links made on the device and of web
+// addresses written in the text
+func TestTextLinks(t *testing.T) {
+	const pageID = "0b1f2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+	tests := []struct {
+		name string
+		text *Text
+		want []TextSpan
+	}{
+		{"written address", textOf("see www.example.com."), []TextSpan{
+			{Text: "see "},
+			{Text: "www.example.com", Link: "https://www.example.com"},
+			{Text: "."},
+		}},
+		{"web link", textOf("read ", TextLinkStart{Target: "https://go.dev/doc"}, "the docs", FormatLinkEnd, " first"), []TextSpan{
+			{Text: "read "},
+			{Text: "the docs", Link: "https://go.dev/doc"},
+			{Text: " first"},
+		}},
+		{"page link", textOf("see ", TextLinkStart{Target: pageID}, "page two", FormatLinkEnd), []TextSpan{
+			{Text: "see "},
+			{Text: "page two", PageID: pageID},
+		}},
+		{"bold page link", textOf(FormatBoldStart, TextLinkStart{Target: pageID}, "next", FormatBoldEnd, " page", FormatLinkEnd), []TextSpan{
+			{Text: "next", Bold: true, PageID: pageID},
+			{Text: " page", PageID: pageID},
+		}},
+		{"address in a page link", textOf(TextLinkStart{Target: pageID}, "notes on www.example.com", FormatLinkEnd), []TextSpan{
+			{Text: "notes on ", PageID: pageID},
+			{Text: "www.example.com", PageID: pageID},
+		}},
+		{"unterminated link", textOf(TextLinkStart{Target: "www.example.com"}, "home"), []TextSpan{
+			{Text: "home", Link: "https://www.example.com"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := BuildTextDocument(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Paragraphs) != 1 {
+				t.Fatalf("got %d paragraphs, want 1", len(doc.Paragraphs))
+			}
+			if got := doc.Paragraphs[0].Spans; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got spans %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadLinkItem checks that a text item carrying FormatLinkStart is read
+// with the link's target
+func TestReadLinkItem(t *testing.T) {
+	target := "https://example.com"
+	value := join(varUint(uint64(len(target))), []byte{1}, []byte(target), tag(2, TagTypeByte4), le32(uint32(FormatLinkStart)))
+	body := join(
+		tag(2, TagTypeID), []byte{1}, varUint(5),
+		tag(3, TagTypeID), []byte{0}, varUint(0),
+		tag(4, TagTypeID), []byte{0}, varUint(0),
+		tag(5, TagTypeByte4), le32(0),
+		tag(6, TagTypeLength4), le32(uint32(len(value))), value,
+	)
+	item := join(tag(0, TagTypeLength4), le32(uint32(len(body))), body)
+
+	got, err := readTextItem(blockReader(t, uint32(len(item)), item))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (TextLinkStart{Target: target}); got.Value != want {
+		t.Errorf("got value %#v, want %#v", got.Value, want)
+	}
+}
@@ -0,0 +1,394 @@
+package parser
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata represents a reMarkable .metadata file
+type Metadata struct {
+	VisibleName string `json:"visibleName"`
+	Type        string `json:"type"` // "DocumentType" or "CollectionType"
+	Parent      string `json:"parent"`
+	Deleted     bool   `json:"deleted"`
+
+	LastModified MillisTime `json:"lastModified"`
+	CreatedTime  MillisTime `json:"createdTime"` // Zero for documents from older firmware
+}
+
+// MillisTime is a time stored in .metadata as milliseconds since the Unix
+// epoch, written as a string by the tablet and as a number by some tools
+type MillisTime struct {
+	time.Time
+}
+
+// UnmarshalJSON parses milliseconds given as a string or a number. Zero and
+// empty values leave the time zero.
+func (t *MillisTime) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" || text == "0" {
+		t.Time = time.Time{}
+		return nil
+	}
+	ms, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %s: %w", data, err)
+	}
+	t.Time = time.UnixMilli(ms)
+	return nil
+}
+
+// ParseMetadata parses the JSON data of a reMarkable .metadata file
+func ParseMetadata(data []byte) (*Metadata, error) {
+	var meta Metadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata file: %w", err)
+	}
+	return &meta, nil
+}
+
+// Notebook holds the files of a document in the tablet's storage layout:
+// <id>.content and <id>.metadata next to an <id>/ directory of .rm pages
+type Notebook struct {
+	ID       string
+	Metadata *Metadata         // nil if the notebook has no .metadata file
+	Content  *ContentFile      // nil if the notebook has no .content file
+	Pages    map[string][]byte // .rm data by page ID
+
+	modTimes map[string]time.Time // page modification times, for ordering without a .content file
+}
+
+// NewNotebook creates an empty notebook with the given document ID
+func NewNotebook(id string) *Notebook {
+	return &Notebook{ID: id, Pages: make(map[string][]byte), modTimes: make(map[string]time.Time)}
+}
+
+// AddFile adds a file by its path relative to the storage directory.
+// Files that are not part of the notebook's pages, content or metadata are ignored.
+func (n *Notebook) AddFile(name string, data []byte) error {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+
+	switch {
+	case name == n.ID+".content":
+		content, err := ParseContent(data)
+		if err != nil {
+			return err
+		}
+		n.Content = content
+	case name == n.ID+".metadata":
+		meta, err := ParseMetadata(data)
+		if err != nil {
+			return err
+		}
+		n.Metadata = meta
+	case path.Dir(name) == n.ID && path.Ext(name) == ".rm":
+		n.Pages[strings.TrimSuffix(path.Base(name), ".rm")] = data
+	}
+	return nil
+}
+
+// Name returns the notebook's visible name, or its ID if it has no metadata
+func (n *Notebook) Name() string {
+	if n.Metadata != nil && n.Metadata.VisibleName != "" {
+		return n.Metadata.VisibleName
+	}
+	return n.ID
+}
+
+// OrderedPages returns the .rm data of every page in the order recorded in
+// the .content file, leaving out pages marked deleted. Pages missing from the
+// content file follow, oldest first when modification times are known and by
+// ID otherwise.
+func (n *Notebook) OrderedPages() [][]byte {
+	return n.pageData(n.OrderedPageIDs())
+}
+
+// TaggedPages returns the .rm data of the pages carrying any of the given
+// tags, in the order of OrderedPages. Tags are compared without regard to
+// case, and a tag of the whole document is carried by all of its pages.
+func (n *Notebook) TaggedPages(tags ...string) [][]byte {
+	return n.pageData(n.TaggedPageIDs(tags...))
+}
+
+// TaggedPageIDs returns the IDs of the pages carrying any of the given tags,
+// in the order of OrderedPageIDs
+func (n *Notebook) TaggedPageIDs(tags ...string) []string {
+	if n.Content == nil {
+		return nil
+	}
+	ids := n.OrderedPageIDs()
+	for _, tag := range tags {
+		if n.Content.HasTag(tag) {
+			return ids
+		}
+	}
+
+	var tagged []string
+	for _, id := range ids {
+		for _, tag := range tags {
+			if n.Content.HasPageTag(id, tag) {
+				tagged = append(tagged, id)
+				break
+			}
+		}
+	}
+	return tagged
+}
+
+// pageData returns the .rm data of the pages with the given IDs
+func (n *Notebook) pageData(ids []string) [][]byte {
+	pages := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		pages = append(pages, n.Pages[id])
+	}
+	return pages
+}
+
+// OrderedPageIDs returns the IDs of the pages in the order of OrderedPages
+func (n *Notebook) OrderedPageIDs() []string {
+	ids := make([]string, 0, len(n.Pages))
+	for id := range n.Pages {
+		ids = append(ids, id)
+	}
+
+	var ordered, rest []string
+	if n.Content != nil {
+		ordered, rest = n.Content.OrderPageIDs(ids)
+	} else {
+		rest = ids
+	}
+
+	sort.Slice(rest, func(i, j int) bool {
+		ti, tj := n.modTimes[rest[i]], n.modTimes[rest[j]]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return rest[i] < rest[j]
+	})
+
+	return append(ordered, rest...)
+}
+
+// ReadNotebookDir reads a notebook from a storage directory laid out like
+// the tablet's: <dir>/<id>.content, <dir>/<id>.metadata and <dir>/<id>/*.rm.
+// The content and metadata files are optional.
+func ReadNotebookDir(dir, id string) (*Notebook, error) {
+	nb := NewNotebook(id)
+
+	for _, name := range []string{id + ".content", id + ".metadata"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if err := nb.AddFile(name, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notebook directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".rm" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, id, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %s: %w", entry.Name(), err)
+		}
+		pageID := strings.TrimSuffix(entry.Name(), ".rm")
+		nb.Pages[pageID] = data
+		if info, err := entry.Info(); err == nil {
+			nb.modTimes[pageID] = info.ModTime()
+		}
+	}
+
+	return nb, nil
+}
+
+// ReadNotebookArchive reads a notebook from a zip archive of its files: an
+// .rmdoc exported by the reMarkable app, or a zip of the raw notebook folder.
+// The document ID is taken from the .content file if there is one, and all
+// .rm files in the archive are treated as its pages. Without a .content file
+// pages are ordered by their modification time in the archive.
+func ReadNotebookArchive(r io.ReaderAt, size int64) (*Notebook, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open notebook archive: %w", err)
+	}
+
+	// Find the document ID from the .content file
+	nb := NewNotebook("")
+	var contentFiles []string
+	for _, f := range zr.File {
+		if path.Ext(f.Name) == ".content" {
+			contentFiles = append(contentFiles, f.Name)
+			nb.ID = strings.TrimSuffix(path.Base(f.Name), ".content")
+		}
+	}
+	if len(contentFiles) > 1 {
+		return nil, fmt.Errorf("archive contains %d notebooks (%s), expected one",
+			len(contentFiles), strings.Join(contentFiles, ", "))
+	}
+
+	var total uint64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		base := path.Base(f.Name)
+		isPage := path.Ext(base) == ".rm"
+		if !isPage && base != nb.ID+".content" && base != nb.ID+".metadata" {
+			continue
+		}
+
+		data, err := readZipFile(f, &total)
+		if err != nil {
+			return nil, err
+		}
+		if isPage {
+			pageID := strings.TrimSuffix(base, ".rm")
+			nb.Pages[pageID] = data
+			nb.modTimes[pageID] = f.Modified
+		} else if err := nb.AddFile(base, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+
+	if len(nb.Pages) == 0 {
+		return nil, fmt.Errorf("no .rm pages found in notebook archive")
+	}
+	return nb, nil
+}
+
+// ReadNotebookTar reads a notebook from a tar stream of its files, such as
+// the output of `tar c <id>.content <id>/` run in the tablet's storage
+// directory. Like ReadNotebookArchive, the document ID is taken from the
+// .content file, or from the directory of the pages without one, and all .rm
+// files are treated as pages. The stream is read to the end.
+func ReadNotebookTar(r io.Reader) (*Notebook, error) {
+	type tarFile struct {
+		name    string
+		data    []byte
+		modTime time.Time
+	}
+
+	// The .content file can come after the pages, so collect the files first
+	var files []tarFile
+	var contentFiles []string
+	var total uint64
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notebook archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		switch path.Ext(name) {
+		case ".content":
+			contentFiles = append(contentFiles, name)
+		case ".rm", ".metadata":
+		default:
+			continue
+		}
+
+		data, err := readArchiveFile(tr, &total)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		files = append(files, tarFile{name: name, data: data, modTime: hdr.ModTime})
+	}
+	if len(contentFiles) > 1 {
+		return nil, fmt.Errorf("archive contains %d notebooks (%s), expected one",
+			len(contentFiles), strings.Join(contentFiles, ", "))
+	}
+
+	nb := NewNotebook("")
+	if len(contentFiles) == 1 {
+		nb.ID = strings.TrimSuffix(path.Base(contentFiles[0]), ".content")
+	}
+	for _, f := range files {
+		base := path.Base(f.name)
+		if path.Ext(base) != ".rm" {
+			continue
+		}
+		if dir := path.Base(path.Dir(f.name)); nb.ID == "" && dir != "." {
+			nb.ID = dir
+		}
+		pageID := strings.TrimSuffix(base, ".rm")
+		nb.Pages[pageID] = f.data
+		nb.modTimes[pageID] = f.modTime
+	}
+	if len(nb.Pages) == 0 {
+		return nil, fmt.Errorf("no .rm pages found in notebook archive")
+	}
+
+	for _, f := range files {
+		base := path.Base(f.name)
+		if base != nb.ID+".content" && base != nb.ID+".metadata" {
+			continue
+		}
+		if err := nb.AddFile(base, f.data); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nb, nil
+}
+
+// readZipFile reads the contents of a file in a zip archive, adding its
+// size to total
+func readZipFile(f *zip.File, total *uint64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := readArchiveFile(rc, total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return data, nil
+}
+
+// readArchiveFile reads a file of an archive and adds its size to total,
+// the bytes read from the archive so far. It stops reading as soon as the
+// file exceeds MaxArchiveFileSize or total exceeds MaxArchiveSize, as the
+// sizes an archive declares for its files cannot be trusted.
+func readArchiveFile(r io.Reader, total *uint64) ([]byte, error) {
+	limit := min(uint64(MaxArchiveFileSize), MaxArchiveSize-*total)
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	size := uint64(len(data))
+	if err := checkLimit("archive file size", size, MaxArchiveFileSize); err != nil {
+		return nil, err
+	}
+	*total += size
+	if err := checkLimit("archive size", *total, MaxArchiveSize); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageRange is a run of 1-based page numbers. A Last of zero runs to the
+// end of the notebook.
+type PageRange struct {
+	First int
+	Last  int
+}
+
+// PageRanges selects pages of a notebook. An empty selection selects every
+// page.
+type PageRanges []PageRange
+
+// ParsePageRanges parses a comma-separated list of page numbers and ranges,
+// like "1-5,8,10-". An empty string selects every page.
+func ParsePageRanges(s string) (PageRanges, error) {
+	var ranges PageRanges
+	if strings.TrimSpace(s) == "" {
+		return ranges, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+
+		var r PageRange
+		var err error
+		if r.First, err = parsePageNumber(first); err != nil {
+			return nil, fmt.Errorf("invalid page range %q: %w", part, err)
+		}
+		switch {
+		case !isRange:
+			r.Last = r.First
+		case strings.TrimSpace(last) != "":
+			if r.Last, err = parsePageNumber(last); err != nil {
+				return nil, fmt.Errorf("invalid page range %q: %w", part, err)
+			}
+			if r.Last < r.First {
+				return nil, fmt.Errorf("invalid page range %q: ends before it starts", part)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// parsePageNumber parses a 1-based page number
+func parsePageNumber(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("page numbers start at 1")
+	}
+	return n, nil
+}
+
+// Contains reports whether the 1-based page number is selected
+func (pr PageRanges) Contains(page int) bool {
+	if len(pr) == 0 {
+		return true
+	}
+	for _, r := range pr {
+		if page >= r.First && (r.Last == 0 || page <= r.Last) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectPages returns the selected pages, keeping their order. Page numbers
+// past the end of pages are ignored.
+func SelectPages[T any](pages []T, pr PageRanges) []T {
+	if len(pr) == 0 {
+		return pages
+	}
+
+	selected := make([]T, 0, len(pages))
+	for i, page := range pages {
+		if pr.Contains(i + 1) {
+			selected = append(selected, page)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// blockHeaderSize is the size of a top-level block header: length, an
+// unknown byte, minimum and current version, and block type
+const blockHeaderSize = 8
+
+// NewTaggedBlockReaderAt creates a TaggedBlockReader that reads each block
+// of a file of the given size with a single ReadAt, instead of through a
+// buffered stream. Blocks can be visited in any order with SeekBlock.
+func NewTaggedBlockReaderAt(r io.ReaderAt, size int64) *TaggedBlockReader {
+	tbr := &TaggedBlockReader{
+		at:     r,
+		size:   size,
+		logger: slog.Default(),
+	}
+	tbr.baseData.reader = io.NewSectionReader(r, 0, size)
+	tbr.data = &tbr.baseData
+	tbr.reader = &tbr.slice
+	return tbr
+}
+
+// NewTaggedBlockReaderBytes creates a TaggedBlockReader for a file held in
+// memory, such as a memory-mapped file. Blocks are sliced from data rather
+// than copied, so data must not change while the reader is in use.
+func NewTaggedBlockReaderBytes(data []byte) *TaggedBlockReader {
+	tbr := NewTaggedBlockReaderAt(&sliceReader{data: data}, int64(len(data)))
+	tbr.file = data
+	return tbr
+}
+
+// SeekBlock moves a reader created by NewTaggedBlockReaderAt or
+// NewTaggedBlockReaderBytes to the block at offset, as returned in
+// BlockInfo.Offset, so the next ReadBlock reads that block. The header must
+// have been read first.
+func (tbr *TaggedBlockReader) SeekBlock(offset int64) error {
+	if tbr.at == nil {
+		return fmt.Errorf("seeking requires a reader created by NewTaggedBlockReaderAt")
+	}
+	if tbr.currentBlock != nil {
+		return fmt.Errorf("already in a block")
+	}
+	if offset < int64(len(HeaderV6)) || offset > tbr.size {
+		return fmt.Errorf("block offset %d outside of file of %d bytes", offset, tbr.size)
+	}
+	tbr.pos = offset
+	return nil
+}
+
+// readBlockHeaderAt reads the header of the block at the current position
+func (tbr *TaggedBlockReader) readBlockHeaderAt() (*BlockInfo, error) {
+	var header [blockHeaderSize]byte
+	n, err := tbr.at.ReadAt(header[:], tbr.pos)
+	if n == 0 && (err == io.EOF || tbr.pos >= tbr.size) {
+		return nil, io.EOF
+	}
+	if n < len(header) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	blockLength := binary.LittleEndian.Uint32(header[0:])
+	if err := checkLimit("block size", uint64(blockLength), MaxBlockSize); err != nil {
+		return nil, err
+	}
+	// header[4] is always 0 in known files, but isn't enforced
+	return &BlockInfo{
+		Offset:         tbr.pos,
+		Size:           blockLength,
+		MinVersion:     header[5],
+		CurrentVersion: header[6],
+		BlockType:      header[7],
+	}, nil
+}
+
+// readBlockAt reads the header and data of the block at the current
+// position. A block cut short by the end of the file is read as far as it
+// goes, and reported by EndBlock.
+func (tbr *TaggedBlockReader) readBlockAt() (*BlockInfo, error) {
+	info, err := tbr.readBlockHeaderAt()
+	if err != nil {
+		return nil, err
+	}
+
+	start := info.Offset + blockHeaderSize
+	end := min(start+int64(info.Size), tbr.size)
+	var data []byte
+	if tbr.file != nil {
+		data = tbr.file[start:end]
+	} else {
+		if int64(cap(tbr.blockBytes)) < end-start {
+			tbr.blockBytes = make([]byte, end-start)
+		}
+		n, err := tbr.at.ReadAt(tbr.blockBytes[:end-start], start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = tbr.blockBytes[:n]
+	}
+
+	tbr.currentBlock = info
+	tbr.slice = sliceReader{data: data}
+	tbr.block = LimitedBufReader{}
+	tbr.limitedReader = &tbr.block
+	tbr.blockData.reader = &tbr.slice
+	tbr.data = &tbr.blockData
+	return info, nil
+}
+
+// blockReader returns a reader positioned at the start of the data of a
+// block read earlier, for reading it again. Warnings are recorded on the new
+// reader only.
+func (tbr *TaggedBlockReader) blockReader(info *BlockInfo, data []byte) *TaggedBlockReader {
+	block := &TaggedBlockReader{
+		at:           &sliceReader{data: data},
+		size:         int64(len(data)),
+		file:         data,
+		logger:       slog.New(slog.DiscardHandler),
+		currentBlock: info,
+		slice:        sliceReader{data: data},
+	}
+	block.limitedReader = &block.block
+	block.reader = &block.slice
+	block.blockData.reader = &block.slice
+	block.data = &block.blockData
+	return block
+}
+
+// endBlockAt moves past the current block
+func (tbr *TaggedBlockReader) endBlockAt() error {
+	info := tbr.currentBlock
+	truncated := len(tbr.slice.data) < int(info.Size)
+
+	tbr.pos = info.Offset + blockHeaderSize + int64(info.Size)
+	tbr.slice = sliceReader{}
+	tbr.data = &tbr.baseData
+	tbr.currentBlock = nil
+	tbr.limitedReader = nil
+
+	if truncated {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// readRaw returns the next n bytes of the current block, or as many as
+// there are along with an error. In-memory blocks are sliced without
+// copying; otherwise the bytes are read into buf, which is grown as needed.
+func (tbr *TaggedBlockReader) readRaw(n int, buf *[]byte) ([]byte, error) {
+	if tbr.at != nil {
+		return tbr.slice.next(n)
+	}
+	raw, err := readGrowing(tbr.data.reader, *buf, n)
+	*buf = raw
+	return raw, err
+}
+
+// IndexBlocks returns the top-level blocks of a v6 file without reading
+// their data, for tools that inspect single blocks. Pass an offset to
+// SeekBlock of a reader from NewTaggedBlockReaderAt to read a block.
+func IndexBlocks(r io.ReaderAt, size int64) ([]BlockInfo, error) {
+	tbr := NewTaggedBlockReaderAt(r, size)
+	if err := tbr.ReadHeader(); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	var blocks []BlockInfo
+	for {
+		info, err := tbr.readBlockHeaderAt()
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return blocks, fmt.Errorf("failed to read block header at offset %d: %w", tbr.pos, err)
+		}
+		blocks = append(blocks, *info)
+		tbr.pos += blockHeaderSize + int64(info.Size)
+	}
+}
+
+// ReadSceneAt is like ReadScene for a file of the given size that can be
+// read by offset, such as an *os.File. Blocks of v6 files are each read with
+// a single ReadAt.
+func ReadSceneAt(r io.ReaderAt, size int64, logger *slog.Logger) (*ParseResult, error) {
+	return readSceneAt(NewTaggedBlockReaderAt(r, size), logger)
+}
+
+// ReadSceneBytes is like ReadScene for a file held in memory. Blocks of v6
+// files are sliced from data rather than copied, which suits memory-mapped
+// files; data must not change during the call.
+func ReadSceneBytes(data []byte, logger *slog.Logger) (*ParseResult, error) {
+	return readSceneAt(NewTaggedBlockReaderBytes(data), logger)
+}
+
+func readSceneAt(reader *TaggedBlockReader, logger *slog.Logger) (*ParseResult, error) {
+	version, err := DetectVersion(io.NewSectionReader(reader.at, 0, reader.size))
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ParseResult
+	switch version {
+	case 3, 5:
+		tree, err := ReadLegacySceneTree(io.NewSectionReader(reader.at, 0, reader.size))
+		if err != nil {
+			return nil, err
+		}
+		result = &ParseResult{Tree: tree}
+	default:
+		if logger != nil {
+			reader.SetLogger(logger)
+		}
+		result, err = readBlocksV6(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Version = version
+	return result, nil
+}
+
+// sliceReader reads a block held in memory. Peek and Buffered behave like
+// those of bufio.Reader over the same data.
+type sliceReader struct {
+	data []byte
+	pos  int
+}
+
+func (s *sliceReader) Read(p []byte) (int, error) {
+	if s.pos >= len(s.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data[s.pos:])
+	s.pos += n
+	return n, nil
+}
+
+func (s *sliceReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(s.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (s *sliceReader) ReadByte() (byte, error) {
+	if s.pos >= len(s.data) {
+		return 0, io.EOF
+	}
+	b := s.data[s.pos]
+	s.pos++
+	return b, nil
+}
+
+func (s *sliceReader) Peek(n int) ([]byte, error) {
+	rest := s.data[s.pos:]
+	if len(rest) < n {
+		return rest, io.EOF
+	}
+	return rest[:n], nil
+}
+
+func (s *sliceReader) Buffered() int {
+	return len(s.data) - s.pos
+}
+
+// next returns the next n bytes without copying them, or as many as there
+// are along with an error like that of io.ReadFull
+func (s *sliceReader) next(n int) ([]byte, error) {
+	rest := s.data[s.pos:]
+	switch {
+	case len(rest) >= n:
+		s.pos += n
+		return rest[:n], nil
+	case len(rest) == 0:
+		return nil, io.EOF
+	default:
+		s.pos = len(s.data)
+		return rest, io.ErrUnexpectedEOF
+	}
+}
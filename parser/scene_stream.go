@@ -1,9 +1,15 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
+	"sync"
 )
 
 const (
@@ -25,11 +31,27 @@ const (
 	PointSizeV1 = 0x18 // 24 bytes per point (version 1)
 )
 
-// SceneTree represents the complete scene with all layers and content
+// SceneTree represents the complete scene with all layers and content.
+//
+// Exporting a tree only reads it, so several goroutines can export the same
+// tree at once. A tree must not be changed, with the editing methods or
+// directly, while other goroutines use it; change a Clone instead.
 type SceneTree struct {
 	Root     *Group
 	RootText *Text
 	Nodes    map[CrdtID]*Group
+
+	// Extras holds the values decoded by handlers registered with
+	// RegisterBlockHandler, in file order
+	Extras []Extra
+
+	// UnknownBlocks holds the blocks of types the parser doesn't know and no
+	// handler decodes, and those of a newer version than it supports that
+	// could not be read, as they were in the file, so that they can be
+	// reported or written back unchanged
+	UnknownBlocks []RawBlock
+
+	treeNodes map[CrdtID]bool // Groups with a tree node block, when reading strictly
 }
 
 // NewSceneTree creates a new empty scene tree
@@ -43,15 +65,185 @@ func NewSceneTree() *SceneTree {
 	}
 }
 
-// ReadSceneTree reads a complete scene tree from a reader
+// ParseResult holds a parsed scene tree together with any non-fatal
+// warnings (unknown blocks, extra bytes, unsupported versions)
+type ParseResult struct {
+	Tree     *SceneTree
+	Warnings []Warning
+	Version  int // File format version (3, 5 or 6)
+
+	// BlockCounts counts the blocks of each type in a v6 file
+	BlockCounts map[uint8]int
+}
+
+// blockTypeNames holds the names of the known block types
+var blockTypeNames = map[uint8]string{
+	BlockTypeMigrationInfo:  "MigrationInfo",
+	BlockTypeSceneTree:      "SceneTree",
+	BlockTypeTreeNode:       "TreeNode",
+	BlockTypeSceneGlyphItem: "SceneGlyphItem",
+	BlockTypeSceneGroupItem: "SceneGroupItem",
+	BlockTypeSceneLineItem:  "SceneLineItem",
+	BlockTypeSceneTextItem:  "SceneTextItem",
+	BlockTypeRootText:       "RootText",
+	BlockTypeSceneTombstone: "SceneTombstone",
+	BlockTypeAuthorIDs:      "AuthorIDs",
+	BlockTypePageInfo:       "PageInfo",
+	BlockTypeSceneInfo:      "SceneInfo",
+}
+
+// BlockTypeName returns the name of a block type, or its hex value if unknown
+func BlockTypeName(blockType uint8) string {
+	if name, ok := blockTypeNames[blockType]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(0x%02X)", blockType)
+}
+
+// IsKnownBlockType reports whether the block type is part of the known v6 format
+func IsKnownBlockType(blockType uint8) bool {
+	_, ok := blockTypeNames[blockType]
+	return ok
+}
+
+// supportedBlockVersions lists the newest version of each block type we know how to read
+var supportedBlockVersions = map[uint8]uint8{
+	BlockTypeMigrationInfo:  1,
+	BlockTypeSceneTree:      1,
+	BlockTypeTreeNode:       2,
+	BlockTypeSceneGlyphItem: 1,
+	BlockTypeSceneGroupItem: 1,
+	BlockTypeSceneLineItem:  2,
+	BlockTypeSceneTextItem:  1,
+	BlockTypeRootText:       1,
+	BlockTypeSceneTombstone: 1,
+	BlockTypeAuthorIDs:      1,
+	BlockTypePageInfo:       1,
+	BlockTypeSceneInfo:      1,
+}
+
+// ReadSceneTree reads a complete scene tree from a reader.
+// Non-fatal problems are reported to slog.Default().
 func ReadSceneTree(r io.Reader) (*SceneTree, error) {
+	return ReadSceneTreeWithLogger(r, nil)
+}
+
+// ReadSceneTreeWithLogger reads a complete scene tree from a reader, reporting
+// non-fatal problems (skipped blocks, extra bytes) to the given logger.
+// A nil logger uses slog.Default().
+func ReadSceneTreeWithLogger(r io.Reader, logger *slog.Logger) (*SceneTree, error) {
+	result, err := ReadSceneTreeWithDiagnostics(r, logger)
+	if err != nil {
+		return nil, err
+	}
+	return result.Tree, nil
+}
+
+// ReadSceneTreeWithDiagnostics reads a complete scene tree from a reader and returns it along
+// with the list of non-fatal warnings found while parsing. Warnings are also
+// reported to the given logger; a nil logger uses slog.Default().
+// It is equivalent to ReadScene.
+func ReadSceneTreeWithDiagnostics(r io.Reader, logger *slog.Logger) (*ParseResult, error) {
+	return ReadScene(r, logger)
+}
+
+// ReadSceneTreeContext is like ReadSceneTreeWithLogger, but stops reading
+// and returns the context's error once ctx is done
+func ReadSceneTreeContext(ctx context.Context, r io.Reader, logger *slog.Logger) (*SceneTree, error) {
+	result, err := ReadSceneContext(ctx, r, logger)
+	if err != nil {
+		return nil, err
+	}
+	return result.Tree, nil
+}
+
+// ReadScene reads a scene tree from any supported .rm file version. The header
+// is sniffed with DetectVersion and the file is handed to the matching parser;
+// the detected version is recorded in the result. Non-fatal warnings are also
+// reported to the given logger; a nil logger uses slog.Default().
+func ReadScene(r io.Reader, logger *slog.Logger) (*ParseResult, error) {
+	return ReadSceneContext(context.Background(), r, logger)
+}
+
+// ReadSceneContext is like ReadScene, but stops reading and returns the
+// context's error once ctx is done
+func ReadSceneContext(ctx context.Context, r io.Reader, logger *slog.Logger) (*ParseResult, error) {
+	return readScene(ctx, r, logger, false)
+}
+
+// readScene reads a scene tree from any supported .rm file version, checking
+// the consistency of v6 files when strict is set
+func readScene(ctx context.Context, r io.Reader, logger *slog.Logger, strict bool) (*ParseResult, error) {
+	if ctx.Done() != nil {
+		r = &contextReader{ctx: ctx, reader: r}
+	}
+
+	br := bufio.NewReader(r)
+	header, err := br.Peek(len(HeaderV6))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	version, err := DetectVersion(bytes.NewReader(header))
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ParseResult
+	switch version {
+	case 3, 5:
+		tree, err := ReadLegacySceneTree(br)
+		if err != nil {
+			return nil, err
+		}
+		result = &ParseResult{Tree: tree}
+	default:
+		result, err = readSceneV6(br, logger, strict)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parsing may finish from buffered data without noticing the cancellation
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result.Version = version
+	return result, nil
+}
+
+// contextReader fails reads once its context is done, so parsing a large
+// or slow input stops soon after cancellation
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(p)
+}
+
+// readSceneV6 reads a v6 file made of tagged blocks
+func readSceneV6(r io.Reader, logger *slog.Logger, strict bool) (*ParseResult, error) {
 	reader := NewTaggedBlockReader(r)
+	if logger != nil {
+		reader.SetLogger(logger)
+	}
+	reader.strict = strict
+	return readBlocksV6(reader)
+}
 
+// readBlocksV6 reads the header and blocks of a v6 file into a scene tree
+func readBlocksV6(reader *TaggedBlockReader) (*ParseResult, error) {
 	if err := reader.ReadHeader(); err != nil {
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
 	tree := NewSceneTree()
+	blockCounts := make(map[uint8]int)
 
 	for {
 		blockInfo, err := reader.ReadBlock()
@@ -62,10 +254,19 @@ func ReadSceneTree(r io.Reader) (*SceneTree, error) {
 			return nil, fmt.Errorf("failed to read block: %w", err)
 		}
 
-		if err := tree.processBlock(reader, blockInfo); err != nil {
-			// Log the error but continue processing
+		blockCounts[blockInfo.BlockType]++
+
+		if maxVersion, known := supportedBlockVersions[blockInfo.BlockType]; known && blockInfo.MinVersion > maxVersion {
+			// Readers of older versions can't read this block, so its layout
+			// may have changed. Try the newest known layout on a copy of the
+			// data, and keep the data as it is when that fails.
+			tree.processNewerBlock(reader, blockInfo, maxVersion)
+		} else if err := tree.processBlock(reader, blockInfo); err != nil {
+			// Record the error but continue processing
 			// This makes the parser more robust to unknown or malformed blocks
-			fmt.Printf("Warning: failed to process block type 0x%02X: %v\n", blockInfo.BlockType, err)
+			reader.warn("failed to process block: %v", err)
+		} else if reader.strict {
+			reader.checkBlockEnd(blockInfo)
 		}
 
 		if err := reader.EndBlock(); err != nil {
@@ -73,11 +274,81 @@ func ReadSceneTree(r io.Reader) (*SceneTree, error) {
 		}
 	}
 
-	return tree, nil
+	if reader.strict {
+		tree.checkReferences(reader)
+	}
+	tree.applyDeletions()
+	tree.pruneGroups(tree.Root, make(map[*Group]bool), 0, reader)
+
+	return &ParseResult{Tree: tree, Warnings: reader.Warnings(), BlockCounts: blockCounts}, nil
+}
+
+// pruneGroups removes references to groups that are already placed elsewhere
+// in the tree, which includes any that would make a group contain itself, and
+// groups nested deeper than MaxGroupDepth. This keeps the tree a tree, so
+// code walking it always terminates.
+func (st *SceneTree) pruneGroups(group *Group, placed map[*Group]bool, depth int, reader *TaggedBlockReader) {
+	placed[group] = true
+	if group.Children == nil {
+		return
+	}
+
+	items := group.Children.Items[:0]
+	for _, item := range group.Children.Items {
+		if child, ok := item.Value.(*Group); ok {
+			if placed[child] {
+				reader.warn("dropping repeated reference to group %s", child.NodeID)
+				continue
+			}
+			if depth >= MaxGroupDepth {
+				reader.warn("dropping group %s nested deeper than %d levels", child.NodeID, MaxGroupDepth)
+				continue
+			}
+			st.pruneGroups(child, placed, depth+1, reader)
+		}
+		items = append(items, item)
+	}
+	group.Children.Items = items
+}
+
+// processNewerBlock reads a block whose minimum version is newer than
+// maxVersion, the newest the parser supports, as if it were of that version.
+// Only the items of a block that reads without error are added to the tree;
+// other blocks are kept in UnknownBlocks.
+func (st *SceneTree) processNewerBlock(reader *TaggedBlockReader, blockInfo *BlockInfo, maxVersion uint8) {
+	data, err := reader.BlockBytes()
+	if err != nil {
+		reader.warn("failed to process block: %v", err)
+		return
+	}
+
+	info := *blockInfo
+	info.CurrentVersion = maxVersion
+	block := reader.blockReader(&info, data)
+	if err := st.processBlock(block, &info); err != nil {
+		reader.warn("block version %d is newer than supported version %d and could not be read (%v); kept as raw data",
+			blockInfo.MinVersion, maxVersion, err)
+		st.UnknownBlocks = append(st.UnknownBlocks, RawBlock{BlockInfo: *blockInfo, Data: data})
+		return
+	}
+	if rest := block.RemainingInBlock(); rest > 0 {
+		reader.warn("block version %d is newer than supported version %d; read as version %d, leaving %d bytes unread",
+			blockInfo.MinVersion, maxVersion, maxVersion, rest)
+	} else {
+		reader.warn("block version %d is newer than supported version %d; read as version %d",
+			blockInfo.MinVersion, maxVersion, maxVersion)
+	}
+	for _, w := range block.warnings {
+		reader.warn("%s", w.Message)
+	}
 }
 
 // processBlock processes a single block based on its type
 func (st *SceneTree) processBlock(reader *TaggedBlockReader, blockInfo *BlockInfo) error {
+	if handler, ok := blockHandler(blockInfo.BlockType); ok {
+		return st.readExtraBlock(reader, blockInfo, handler)
+	}
+
 	switch blockInfo.BlockType {
 	case BlockTypeSceneTree:
 		return st.readSceneTreeBlock(reader)
@@ -85,8 +356,17 @@ func (st *SceneTree) processBlock(reader *TaggedBlockReader, blockInfo *BlockInf
 		return st.readTreeNodeBlock(reader)
 	case BlockTypeSceneGroupItem:
 		return st.readSceneGroupItemBlock(reader)
+	case BlockTypeSceneGlyphItem:
+		return st.readSceneGlyphItemBlock(reader)
 	case BlockTypeSceneLineItem:
-		return st.readSceneLineItemBlock(reader, blockInfo.CurrentVersion)
+		// Versions after the newest one known add to it, as readers of that
+		// version can still read them
+		version := min(blockInfo.CurrentVersion, supportedBlockVersions[BlockTypeSceneLineItem])
+		return st.readSceneLineItemBlock(reader, version)
+	case BlockTypeSceneTextItem:
+		return st.readSceneTextItemBlock(reader)
+	case BlockTypeSceneTombstone:
+		return st.readSceneTombstoneBlock(reader)
 	case BlockTypeRootText:
 		return st.readRootTextBlock(reader)
 	case BlockTypeMigrationInfo, BlockTypeAuthorIDs, BlockTypePageInfo:
@@ -97,7 +377,13 @@ func (st *SceneTree) processBlock(reader *TaggedBlockReader, blockInfo *BlockInf
 		return nil
 
 	default:
-		// Unknown block type - skip
+		// Unknown block type - keep its data, but don't interpret it
+		data, err := reader.BlockBytes()
+		if err != nil {
+			return fmt.Errorf("failed to read unknown block: %w", err)
+		}
+		st.UnknownBlocks = append(st.UnknownBlocks, RawBlock{BlockInfo: *blockInfo, Data: data})
+		reader.warn("skipping unknown block type")
 		return nil
 	}
 }
@@ -167,6 +453,13 @@ func (st *SceneTree) readTreeNodeBlock(reader *TaggedBlockReader) error {
 		return err
 	}
 
+	if reader.strict {
+		if st.treeNodes == nil {
+			st.treeNodes = make(map[CrdtID]bool)
+		}
+		st.treeNodes[nodeID] = true
+	}
+
 	node, exists := st.Nodes[nodeID]
 	if !exists {
 		// Create node if it doesn't exist
@@ -279,13 +572,23 @@ func (st *SceneTree) readSceneGroupItemBlock(reader *TaggedBlockReader) error {
 		st.Nodes[*nodeID] = childNode
 	}
 
-	parent.Children.Add(CrdtSequenceItem{
+	item := CrdtSequenceItem{
 		ItemID:        itemID,
 		LeftID:        leftID,
 		RightID:       rightID,
 		DeletedLength: deletedLength,
 		Value:         childNode,
-	})
+	}
+
+	// The scene tree block has already placed the group in its parent; give
+	// that item the IDs of the group item instead of placing it twice
+	for i, existing := range parent.Children.Items {
+		if existing.Value == childNode && existing.ItemID == childNode.NodeID {
+			parent.Children.Items[i] = item
+			return nil
+		}
+	}
+	parent.Children.Add(item)
 
 	return nil
 }
@@ -359,6 +662,285 @@ func (st *SceneTree) readSceneLineItemBlock(reader *TaggedBlockReader, version u
 	return nil
 }
 
+// readSceneTextItemBlock reads a scene text item block: a text box placed
+// in a layer, laid out like the root text
+func (st *SceneTree) readSceneTextItemBlock(reader *TaggedBlockReader) error {
+	parentID, err := reader.ReadID(1)
+	if err != nil {
+		return err
+	}
+
+	itemID, err := reader.ReadID(2)
+	if err != nil {
+		return err
+	}
+
+	leftID, err := reader.ReadID(3)
+	if err != nil {
+		return err
+	}
+
+	rightID, err := reader.ReadID(4)
+	if err != nil {
+		return err
+	}
+
+	deletedLength, err := reader.ReadInt(5)
+	if err != nil {
+		return err
+	}
+
+	var text *Text
+	if reader.HasSubblock(6) {
+		_, err := reader.ReadSubblock(6)
+		if err != nil {
+			return err
+		}
+
+		itemType, err := reader.data.ReadUint8()
+		if err != nil {
+			return err
+		}
+		_ = itemType // Should be 0x06 for text item
+
+		text, err = readText(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read text: %w", err)
+		}
+	}
+
+	if text == nil {
+		return nil
+	}
+
+	// Add to parent's children
+	parent, exists := st.Nodes[parentID]
+	if !exists {
+		// Create parent if it doesn't exist
+		parent = NewEmptyGroup(parentID)
+		st.Nodes[parentID] = parent
+	}
+
+	parent.Children.Add(CrdtSequenceItem{
+		ItemID:        itemID,
+		LeftID:        leftID,
+		RightID:       rightID,
+		DeletedLength: deletedLength,
+		Value:         text,
+	})
+
+	return nil
+}
+
+// readSceneTombstoneBlock reads a scene tombstone block: an item of a layer
+// that was deleted on the device. It is kept in the parent's sequence as an
+// item without a value until applyDeletions removes it.
+func (st *SceneTree) readSceneTombstoneBlock(reader *TaggedBlockReader) error {
+	parentID, err := reader.ReadID(1)
+	if err != nil {
+		return err
+	}
+
+	itemID, err := reader.ReadID(2)
+	if err != nil {
+		return err
+	}
+
+	leftID, err := reader.ReadID(3)
+	if err != nil {
+		return err
+	}
+
+	rightID, err := reader.ReadID(4)
+	if err != nil {
+		return err
+	}
+
+	deletedLength, err := reader.ReadInt(5)
+	if err != nil {
+		return err
+	}
+
+	parent, exists := st.Nodes[parentID]
+	if !exists {
+		// Create parent if it doesn't exist
+		parent = NewEmptyGroup(parentID)
+		st.Nodes[parentID] = parent
+	}
+
+	parent.Children.Add(CrdtSequenceItem{
+		ItemID:        itemID,
+		LeftID:        leftID,
+		RightID:       rightID,
+		DeletedLength: deletedLength,
+	})
+
+	return nil
+}
+
+// applyDeletions removes the items deleted on the device from the sequences
+// of the groups: tombstones, items marked with a deleted length, and any
+// other item with the ID of a tombstone in the same sequence, whichever
+// block comes first
+func (st *SceneTree) applyDeletions() {
+	for _, group := range st.Nodes {
+		if group.Children == nil {
+			continue
+		}
+
+		deleted := make(map[CrdtID]bool)
+		for _, item := range group.Children.Items {
+			if item.Value == nil || item.DeletedLength > 0 {
+				deleted[item.ItemID] = true
+			}
+		}
+		if len(deleted) == 0 {
+			continue
+		}
+
+		items := group.Children.Items[:0]
+		for _, item := range group.Children.Items {
+			if !deleted[item.ItemID] {
+				items = append(items, item)
+			}
+		}
+		group.Children.Items = items
+	}
+}
+
+// readSceneGlyphItemBlock reads a scene glyph item block: a range of PDF or
+// EPUB text marked with the highlighter
+func (st *SceneTree) readSceneGlyphItemBlock(reader *TaggedBlockReader) error {
+	parentID, err := reader.ReadID(1)
+	if err != nil {
+		return err
+	}
+
+	itemID, err := reader.ReadID(2)
+	if err != nil {
+		return err
+	}
+
+	leftID, err := reader.ReadID(3)
+	if err != nil {
+		return err
+	}
+
+	rightID, err := reader.ReadID(4)
+	if err != nil {
+		return err
+	}
+
+	deletedLength, err := reader.ReadInt(5)
+	if err != nil {
+		return err
+	}
+
+	var glyph *GlyphRange
+	if reader.HasSubblock(6) {
+		_, err := reader.ReadSubblock(6)
+		if err != nil {
+			return err
+		}
+
+		itemType, err := reader.data.ReadUint8()
+		if err != nil {
+			return err
+		}
+		_ = itemType // Should be 0x01 for glyph item
+
+		glyph, err = readGlyphRange(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read glyph range: %w", err)
+		}
+	}
+
+	if glyph == nil {
+		return nil
+	}
+
+	// Add to parent's children
+	parent, exists := st.Nodes[parentID]
+	if !exists {
+		// Create parent if it doesn't exist
+		parent = NewEmptyGroup(parentID)
+		st.Nodes[parentID] = parent
+	}
+
+	parent.Children.Add(CrdtSequenceItem{
+		ItemID:        itemID,
+		LeftID:        leftID,
+		RightID:       rightID,
+		DeletedLength: deletedLength,
+		Value:         glyph,
+	})
+
+	return nil
+}
+
+// readGlyphRange reads the highlighted text and the rectangles it covers
+func readGlyphRange(reader *TaggedBlockReader) (*GlyphRange, error) {
+	// Newer firmware leaves out the start offset
+	var start *uint32
+	if reader.HasTag(2, TagTypeByte4) {
+		v, err := reader.ReadInt(2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read start: %w", err)
+		}
+		start = &v
+	}
+
+	length, err := reader.ReadInt(3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read length: %w", err)
+	}
+
+	colorID, err := reader.ReadInt(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read color ID: %w", err)
+	}
+
+	text, err := reader.ReadString(5)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read text: %w", err)
+	}
+
+	if _, err := reader.ReadSubblock(6); err != nil {
+		return nil, fmt.Errorf("failed to read rectangles subblock: %w", err)
+	}
+	numRects, err := reader.data.ReadVarUint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rectangle count: %w", err)
+	}
+	if err := checkLimit("rectangle count", numRects, MaxGlyphRectangles); err != nil {
+		return nil, err
+	}
+	rects := make([]Rectangle, numRects)
+	for i := range rects {
+		var v [4]float64
+		for j := range v {
+			if v[j], err = reader.data.ReadFloat64(); err != nil {
+				return nil, fmt.Errorf("failed to read rectangle %d: %w", i, err)
+			}
+		}
+		rects[i] = Rectangle{X: v[0], Y: v[1], W: v[2], H: v[3]}
+	}
+
+	colorOverride, err := readColorOverride(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read color: %w", err)
+	}
+
+	return &GlyphRange{
+		Start:         start,
+		Length:        length,
+		Text:          text,
+		Color:         PenColor(colorID),
+		ColorOverride: colorOverride,
+		Rectangles:    rects,
+	}, nil
+}
+
 // readLineMetadata reads the basic line metadata (tool, color, thickness, length)
 func readLineMetadata(reader *TaggedBlockReader) (toolID, colorID uint32, thicknessScale float64, startingLength float32, err error) {
 	toolID, err = reader.ReadInt(1)
@@ -397,51 +979,54 @@ func readLinePoints(reader *TaggedBlockReader, version uint8) ([]Point, error) {
 	}
 
 	numPoints := int(subblockLen) / pointSize
+	if err := checkLimit("point count", uint64(numPoints), MaxPointsPerLine); err != nil {
+		return nil, err
+	}
 	extraBytesInSubblock := int(subblockLen) % pointSize
 
-	points := make([]Point, numPoints)
-	for i := 0; i < numPoints; i++ {
-		point, err := readPoint(reader.data, version)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read point %d: %w", i, err)
-		}
-		points[i] = point
+	// Read the points in one go and decode them from the raw bytes
+	bufp := pointBytesPool.Get().(*[]byte)
+	defer pointBytesPool.Put(bufp)
+	raw, err := reader.readRaw(numPoints*pointSize, bufp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read point %d: %w", len(raw)/pointSize, err)
+	}
+
+	points := reader.allocPoints(numPoints)
+	for i := range points {
+		points[i] = decodePoint(raw[i*pointSize:], version)
 	}
 
 	// Check if there are extra bytes at the end of the points subblock
 	if extraBytesInSubblock > 0 {
 		extra, _ := reader.data.ReadBytes(extraBytesInSubblock)
-		fmt.Printf("Debug: Extra bytes in points subblock: %v\n", extra)
+		reader.warn("extra bytes in points subblock: %v", extra)
 	}
 
 	return points, nil
 }
 
-// parseColorOverride checks for and parses optional RGBA color override data
-func parseColorOverride(reader *TaggedBlockReader) (*RGBA, bool) {
-	remaining := reader.RemainingInBlock()
-	if remaining < 6 {
-		return nil, false
-	}
+// colorOverrideTag is the index of the ARGB color that newer firmware
+// writes after the other values of lines and highlighted text
+const colorOverrideTag = 8
 
-	// Read 2-byte prefix
-	_, err := reader.data.ReadBytes(2)
-	if err != nil {
-		return nil, false
+// readColorOverride reads the optional ARGB color of a line or highlighted
+// text, which newer firmware writes for highlighter and shader colors. The
+// value is kept as read, whether or not it matches a known color.
+func readColorOverride(reader *TaggedBlockReader) (*RGBA, error) {
+	if !reader.HasTag(colorOverrideTag, TagTypeByte4) {
+		return nil, nil
 	}
-
-	// Read RGBA color (BGRA order in file)
-	b, errB := reader.data.ReadUint8()
-	g, errG := reader.data.ReadUint8()
-	r, errR := reader.data.ReadUint8()
-	a, errA := reader.data.ReadUint8()
-
-	if errB != nil || errG != nil || errR != nil || errA != nil {
-		return nil, false
+	argb, err := reader.ReadInt(colorOverrideTag)
+	if err != nil {
+		return nil, err
 	}
-
-	rgba := RGBA{R: r, G: g, B: b, A: a}
-	return &rgba, true
+	return &RGBA{
+		R: uint8(argb >> 16),
+		G: uint8(argb >> 8),
+		B: uint8(argb),
+		A: uint8(argb >> 24),
+	}, nil
 }
 
 // readLine reads a line (stroke) from the stream
@@ -458,25 +1043,24 @@ func readLine(reader *TaggedBlockReader, version uint8) (*Line, error) {
 		return nil, err
 	}
 
-	// Read timestamp (unused)
-	_, err = reader.ReadID(6)
+	timestamp, err := reader.ReadID(6)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read timestamp: %w", err)
 	}
 
-	// Try to read move_id (optional)
+	// The ID of the stroke this one was moved from, if any
 	var moveID *CrdtID
-	if reader.HasSubblock(7) {
+	if reader.HasTag(7, TagTypeID) {
 		id, err := reader.ReadID(7)
-		if err == nil {
-			moveID = &id
+		if err != nil {
+			return nil, fmt.Errorf("failed to read move ID: %w", err)
 		}
+		moveID = &id
 	}
 
-	// Check for color override (highlight/shader colors)
-	var colorOverride *RGBA
-	if rgba, hasOverride := parseColorOverride(reader); hasOverride {
-		colorOverride = rgba
+	colorOverride, err := readColorOverride(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read color: %w", err)
 	}
 
 	return &Line{
@@ -486,83 +1070,50 @@ func readLine(reader *TaggedBlockReader, version uint8) (*Line, error) {
 		Points:         points,
 		ThicknessScale: thicknessScale,
 		StartingLength: startingLength,
+		Timestamp:      timestamp,
 		MoveID:         moveID,
 	}, nil
 }
 
+// pointBytesPool holds buffers for the raw bytes of a line's points, which
+// are only needed until the points are decoded
+var pointBytesPool = sync.Pool{New: func() any { return new([]byte) }}
+
 // readPoint reads a point from the stream
 func readPoint(ds *DataStream, version uint8) (Point, error) {
-	x, err := ds.ReadFloat32()
-	if err != nil {
-		return Point{}, err
+	size := PointSizeV2
+	if version == 1 {
+		size = PointSizeV1
 	}
-
-	y, err := ds.ReadFloat32()
-	if err != nil {
+	var buf [PointSizeV1]byte
+	if _, err := io.ReadFull(ds.reader, buf[:size]); err != nil {
 		return Point{}, err
 	}
+	return decodePoint(buf[:size], version), nil
+}
 
-	var speed uint16
-	var width uint16
-	var direction uint8
-	var pressure uint8
+// decodePoint decodes a point from the start of b, which holds at least
+// PointSizeV1 bytes for version 1 and PointSizeV2 bytes otherwise
+func decodePoint(b []byte, version uint8) Point {
+	float := func(offset int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(b[offset:]))
+	}
+	point := Point{X: float(0), Y: float(4)}
 
 	if version == 1 {
 		// Version 1 format
-		speedF, err := ds.ReadFloat32()
-		if err != nil {
-			return Point{}, err
-		}
-		speed = uint16(speedF * 4)
-
-		dirF, err := ds.ReadFloat32()
-		if err != nil {
-			return Point{}, err
-		}
-		direction = uint8(255 * dirF / (math.Pi * 2))
-
-		widthF, err := ds.ReadFloat32()
-		if err != nil {
-			return Point{}, err
-		}
-		width = uint16(widthF * 4)
-
-		pressureF, err := ds.ReadFloat32()
-		if err != nil {
-			return Point{}, err
-		}
-		pressure = uint8(pressureF * 255)
+		point.Speed = uint16(float(8) * 4)
+		point.Direction = uint8(255 * float(12) / (math.Pi * 2))
+		point.Width = uint16(float(16) * 4)
+		point.Pressure = uint8(float(20) * 255)
 	} else {
 		// Version 2 format
-		speed, err = ds.ReadUint16()
-		if err != nil {
-			return Point{}, err
-		}
-
-		width, err = ds.ReadUint16()
-		if err != nil {
-			return Point{}, err
-		}
-
-		direction, err = ds.ReadUint8()
-		if err != nil {
-			return Point{}, err
-		}
-
-		pressure, err = ds.ReadUint8()
-		if err != nil {
-			return Point{}, err
-		}
+		point.Speed = binary.LittleEndian.Uint16(b[8:])
+		point.Width = binary.LittleEndian.Uint16(b[10:])
+		point.Direction = b[12]
+		point.Pressure = b[13]
 	}
-
-	return Point{
-		X:         x,
-		Y:         y,
-		Speed:     speed,
-		Width:     width,
-		Direction: direction,
-		Pressure:  pressure,
-	}, nil
+	return point
 }
 
 // readTextItems reads all text items from a CRDT sequence
@@ -657,38 +1208,48 @@ func (st *SceneTree) readRootTextBlock(reader *TaggedBlockReader) error {
 	}
 	_ = blockID
 
-	_, err = reader.ReadSubblock(2)
+	text, err := readText(reader)
+	if err != nil {
+		return err
+	}
+	st.RootText = text
+
+	return nil
+}
+
+// readText reads the characters, paragraph styles, position and width of a
+// block of text, as stored in the root text block and in text items
+func readText(reader *TaggedBlockReader) (*Text, error) {
+	_, err := reader.ReadSubblock(2)
 	if err != nil {
-		return fmt.Errorf("failed to read root text subblock: %w", err)
+		return nil, fmt.Errorf("failed to read text subblock: %w", err)
 	}
 
 	// Read text items
 	textItems, err := readTextItems(reader)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Read formatting
 	styles, err := readTextFormatting(reader)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Read position and width
 	posX, posY, width, err := readTextPosition(reader)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	st.RootText = &Text{
+	return &Text{
 		Items:  textItems,
 		Styles: styles,
 		PosX:   posX,
 		PosY:   posY,
 		Width:  width,
-	}
-
-	return nil
+	}, nil
 }
 
 // readTextItem reads a text item from the stream
@@ -720,11 +1281,19 @@ func readTextItem(reader *TaggedBlockReader) (CrdtSequenceItem, error) {
 
 	var value interface{} = ""
 	if reader.HasSubblock(6) {
-		text, err := reader.ReadString(6)
+		text, format, err := reader.ReadStringWithFormat(6)
 		if err != nil {
 			return CrdtSequenceItem{}, err
 		}
 		value = text
+		if format != nil {
+			// Formatting items carry a code instead of text, and those
+			// starting a link the link's target as well
+			value = TextFormat(*format)
+			if value == FormatLinkStart {
+				value = TextLinkStart{Target: text}
+			}
+		}
 	}
 
 	return CrdtSequenceItem{
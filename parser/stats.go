@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"math"
+	"time"
+)
+
+// Stats summarizes the content of a scene tree
+type Stats struct {
+	Layers         int              // Top-level groups
+	Strokes        int              // Strokes with at least one point
+	Points         int              // Points across all strokes
+	InkLength      float64          // Total length of all strokes, in screen units
+	StrokesByTool  map[Pen]int      // Stroke count per pen type
+	StrokesByColor map[PenColor]int // Stroke count per pen color
+	HasText        bool             // The page has typed text
+	AnchoredGroups int              // Groups positioned relative to typed text
+	LayerDetails   []LayerStats     // Label, visibility and strokes of each layer
+
+	// Creation times of the first and last stroke, counting only the
+	// TimedStrokes strokes whose time is known (see Line.Time)
+	TimedStrokes            int
+	FirstStroke, LastStroke time.Time
+
+	// Bounds of all stroke points in the coordinates stored in the file
+	// (text anchor offsets are not applied). Only valid when Strokes > 0.
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// LayerStats describes a single layer
+type LayerStats struct {
+	Label   string
+	Visible bool
+	Strokes int
+}
+
+// ComputeStats walks a scene tree and counts its layers, strokes and points
+func ComputeStats(tree *SceneTree) *Stats {
+	stats := &Stats{
+		StrokesByTool:  make(map[Pen]int),
+		StrokesByColor: make(map[PenColor]int),
+		MinX:           math.Inf(1),
+		MinY:           math.Inf(1),
+		MaxX:           math.Inf(-1),
+		MaxY:           math.Inf(-1),
+	}
+
+	if tree != nil && tree.Root != nil {
+		for _, item := range children(tree.Root) {
+			if layer, ok := item.Value.(*Group); ok && !item.Deleted() {
+				before := stats.Strokes
+				stats.addGroup(layer)
+				stats.Layers++
+				stats.LayerDetails = append(stats.LayerDetails, LayerStats{
+					Label:   layer.Label.Value,
+					Visible: layer.Visible.Value,
+					Strokes: stats.Strokes - before,
+				})
+			} else {
+				stats.addItem(item)
+			}
+		}
+		if tree.RootText != nil && tree.RootText.Items != nil && len(tree.RootText.Items.Items) > 0 {
+			stats.HasText = true
+		}
+	}
+
+	if stats.Strokes == 0 {
+		stats.MinX, stats.MinY, stats.MaxX, stats.MaxY = 0, 0, 0, 0
+	}
+	return stats
+}
+
+// addGroup adds the strokes of a group and its subgroups
+func (s *Stats) addGroup(group *Group) {
+	if group.AnchorID != nil {
+		s.AnchoredGroups++
+	}
+	for _, item := range children(group) {
+		s.addItem(item)
+	}
+}
+
+// addItem adds a single item of a group
+func (s *Stats) addItem(item CrdtSequenceItem) {
+	if item.Deleted() {
+		return
+	}
+	switch v := item.Value.(type) {
+	case *Group:
+		s.addGroup(v)
+	case *Line:
+		s.addLine(v)
+	case *Text:
+		s.HasText = true
+	}
+}
+
+// addLine adds a single stroke
+func (s *Stats) addLine(line *Line) {
+	if len(line.Points) == 0 {
+		return
+	}
+
+	s.Strokes++
+	s.StrokesByTool[line.Tool]++
+	s.StrokesByColor[line.Color]++
+	s.Points += len(line.Points)
+	if t, ok := line.Time(); ok {
+		if s.TimedStrokes == 0 || t.Before(s.FirstStroke) {
+			s.FirstStroke = t
+		}
+		if s.TimedStrokes == 0 || t.After(s.LastStroke) {
+			s.LastStroke = t
+		}
+		s.TimedStrokes++
+	}
+
+	for i, p := range line.Points {
+		x, y := float64(p.X), float64(p.Y)
+		s.MinX, s.MaxX = math.Min(s.MinX, x), math.Max(s.MaxX, x)
+		s.MinY, s.MaxY = math.Min(s.MinY, y), math.Max(s.MaxY, y)
+		if i > 0 {
+			prev := line.Points[i-1]
+			s.InkLength += math.Hypot(x-float64(prev.X), y-float64(prev.Y))
+		}
+	}
+}
+
+// children returns the items of a group, which may have no children sequence
+func children(group *Group) []CrdtSequenceItem {
+	if group.Children == nil {
+		return nil
+	}
+	return group.Children.Items
+}
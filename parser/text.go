@@ -1,15 +1,35 @@
 package parser
 
-import (
-	"fmt"
-	"strings"
-)
+import "strings"
+
+// TextSpan represents a run of text sharing the same inline formatting
+type TextSpan struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Link   string // Web address the span links to
+	PageID string // ID of the notebook page the span links to
+}
 
 // Paragraph represents a text paragraph with style
 type Paragraph struct {
 	Text    string
 	Style   ParagraphStyle
 	StartID CrdtID
+	Spans   []TextSpan
+}
+
+// formatChange records the inline formatting in effect from a text position onwards
+type formatChange struct {
+	pos    int
+	bold   bool
+	italic bool
+	link   TextLinkStart // Link made on the device, if the text is part of one
+}
+
+// sameFormat reports whether two changes set the same formatting
+func (c formatChange) sameFormat(other formatChange) bool {
+	return c.bold == other.bold && c.italic == other.italic && c.link == other.link
 }
 
 // TextDocument represents a structured text document
@@ -31,21 +51,44 @@ func BuildTextDocument(text *Text) (*TextDocument, error) {
 		start  int
 	}
 
+	// Track inline bold/italic and link changes as we go
+	var changes []formatChange
+	bold, italic := false, false
+	var link TextLinkStart
+
 	for _, item := range text.Items.Items {
 		// Skip deleted items
-		if item.DeletedLength > 0 {
+		if item.Deleted() {
 			continue
 		}
 
 		// Extract text value
 		if item.Value != nil {
-			if str, ok := item.Value.(string); ok {
+			switch v := item.Value.(type) {
+			case string:
 				startPos := allText.Len()
-				allText.WriteString(str)
+				allText.WriteString(v)
 				itemStarts = append(itemStarts, struct {
 					itemID CrdtID
 					start  int
 				}{itemID: item.ItemID, start: startPos})
+			case TextFormat:
+				switch v {
+				case FormatBoldStart:
+					bold = true
+				case FormatBoldEnd:
+					bold = false
+				case FormatItalicStart:
+					italic = true
+				case FormatItalicEnd:
+					italic = false
+				case FormatLinkEnd:
+					link = TextLinkStart{}
+				}
+				changes = append(changes, formatChange{pos: allText.Len(), bold: bold, italic: italic, link: link})
+			case TextLinkStart:
+				link = v
+				changes = append(changes, formatChange{pos: allText.Len(), bold: bold, italic: italic, link: link})
 			}
 		}
 	}
@@ -138,6 +181,7 @@ func BuildTextDocument(text *Text) (*TextDocument, error) {
 			Text:    line,
 			Style:   paraStyle,
 			StartID: startID,
+			Spans:   linkSpans(buildSpans(line, charPos, changes)),
 		}
 
 		doc.Paragraphs = append(doc.Paragraphs, para)
@@ -149,6 +193,64 @@ func BuildTextDocument(text *Text) (*TextDocument, error) {
 	return doc, nil
 }
 
+// buildSpans splits a line into runs of uniform inline formatting.
+// start is the position of the line within the full text.
+func buildSpans(line string, start int, changes []formatChange) []TextSpan {
+	if line == "" {
+		return nil
+	}
+
+	// Find the formatting in effect at the start of the line
+	current := formatChange{pos: start}
+	next := 0
+	for next < len(changes) && changes[next].pos <= start {
+		current = changes[next]
+		next++
+	}
+
+	var spans []TextSpan
+	spanStart := 0
+	for ; next < len(changes) && changes[next].pos < start+len(line); next++ {
+		change := changes[next]
+		if change.sameFormat(current) {
+			continue
+		}
+		if offset := change.pos - start; offset > spanStart {
+			spans = append(spans, current.span(line[spanStart:offset]))
+			spanStart = offset
+		}
+		current = change
+	}
+
+	return append(spans, current.span(line[spanStart:]))
+}
+
+// span returns a span of text with the formatting set by the change
+func (c formatChange) span(text string) TextSpan {
+	return TextSpan{Text: text, Bold: c.bold, Italic: c.italic, Link: c.link.WebAddress(), PageID: c.link.PageID()}
+}
+
+// HasInlineFormatting reports whether any part of the paragraph is bold or italic
+func (p Paragraph) HasInlineFormatting() bool {
+	for _, span := range p.Spans {
+		if span.Bold || span.Italic {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLinks reports whether any part of the paragraph links to a web
+// address or a page
+func (p Paragraph) HasLinks() bool {
+	for _, span := range p.Spans {
+		if span.Link != "" || span.PageID != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // String returns a string representation of the text document
 func (doc *TextDocument) String() string {
 	var sb strings.Builder
@@ -161,28 +263,8 @@ func (doc *TextDocument) String() string {
 	return sb.String()
 }
 
-// GetStyleName returns a human-readable name for a paragraph style
+// GetStyleName returns a human-readable name for a paragraph style, as
+// returned by its String method
 func GetStyleName(style ParagraphStyle) string {
-	switch style {
-	case StyleBasic:
-		return "basic"
-	case StylePlain:
-		return "plain"
-	case StyleHeading:
-		return "heading"
-	case StyleBold:
-		return "bold"
-	case StyleBullet:
-		return "bullet"
-	case StyleBullet2:
-		return "bullet2"
-	case StyleCheckbox:
-		return "checkbox"
-	case StyleCheckboxChecked:
-		return "checkbox-checked"
-	case StyleNumbered:
-		return "numbered"
-	default:
-		return fmt.Sprintf("unknown-%d", style)
-	}
+	return style.String()
 }
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stroke timestamps that hold a time count milliseconds since the Unix epoch.
+// Counters outside this range are CRDT clocks rather than times, like the
+// CrdtID(0, 1) written by most firmware versions.
+var (
+	minStrokeTime = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	maxStrokeTime = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+)
+
+// Time returns when the stroke was created. It reports false when the
+// timestamp in the file is not a time, which is the case for most files and
+// for every v3 and v5 file.
+func (l *Line) Time() (time.Time, bool) {
+	ms := l.Timestamp.Part2
+	if ms < uint64(minStrokeTime) || ms >= uint64(maxStrokeTime) {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(ms)), true
+}
+
+// TimeRange selects strokes by creation time. A zero Since or Until leaves
+// that end of the range open.
+type TimeRange struct {
+	Since time.Time // Inclusive
+	Until time.Time // Exclusive
+}
+
+// IsZero reports whether the range selects every stroke
+func (r TimeRange) IsZero() bool {
+	return r.Since.IsZero() && r.Until.IsZero()
+}
+
+// Contains reports whether a stroke was created in the range. When the
+// range is not zero, strokes without a creation time are not in it.
+func (r TimeRange) Contains(line *Line) bool {
+	if r.IsZero() {
+		return true
+	}
+	t, ok := line.Time()
+	if !ok {
+		return false
+	}
+	return (r.Since.IsZero() || !t.Before(r.Since)) && (r.Until.IsZero() || t.Before(r.Until))
+}
+
+// timeLayouts are the absolute time formats accepted by ParseTime, in local
+// time unless they carry a zone
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime parses a point in time for a TimeRange, relative to now: "today"
+// and "yesterday" (local midnight), a duration like "36h" (that long before
+// now), a date like "2024-03-01" (local midnight), a date and time like
+// "2024-03-01 14:30", or an RFC 3339 time.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(s) {
+	case "today":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use today, yesterday, a duration like 36h, YYYY-MM-DD, \"YYYY-MM-DD HH:MM\" or RFC 3339)", s)
+}
@@ -1,6 +1,10 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // TagType represents the type of following data in a tagged block
 type TagType uint8
@@ -84,6 +88,97 @@ const (
 	ColorShaderCyan    PenColor = 27
 )
 
+// penColorNames holds the names used for pen colors in output and palette files
+var penColorNames = map[PenColor]string{
+	ColorBlack:           "black",
+	ColorGray:            "gray",
+	ColorWhite:           "white",
+	ColorYellow:          "yellow",
+	ColorGreen:           "green",
+	ColorPink:            "pink",
+	ColorBlue:            "blue",
+	ColorRed:             "red",
+	ColorGrayOverlap:     "gray-overlap",
+	ColorHighlight:       "highlight",
+	ColorGreen2:          "green2",
+	ColorCyan:            "cyan",
+	ColorMagenta:         "magenta",
+	ColorYellow2:         "yellow2",
+	ColorHighlightYellow: "highlight-yellow",
+	ColorHighlightBlue:   "highlight-blue",
+	ColorHighlightPink:   "highlight-pink",
+	ColorHighlightOrange: "highlight-orange",
+	ColorHighlightGreen:  "highlight-green",
+	ColorHighlightGray:   "highlight-gray",
+	ColorShaderGray:      "shader-gray",
+	ColorShaderOrange:    "shader-orange",
+	ColorShaderMagenta:   "shader-magenta",
+	ColorShaderBlue:      "shader-blue",
+	ColorShaderRed:       "shader-red",
+	ColorShaderGreen:     "shader-green",
+	ColorShaderYellow:    "shader-yellow",
+	ColorShaderCyan:      "shader-cyan",
+}
+
+func (c PenColor) String() string {
+	if name, ok := penColorNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("color-%d", uint32(c))
+}
+
+// PenColors returns every known pen color
+func PenColors() []PenColor {
+	colors := make([]PenColor, 0, len(penColorNames))
+	for c := ColorBlack; c <= ColorShaderCyan; c++ {
+		colors = append(colors, c)
+	}
+	return colors
+}
+
+// ParsePenColor looks up a pen color by the name returned by String,
+// including the color-<id> form of unknown colors
+func ParsePenColor(name string) (PenColor, bool) {
+	for c, n := range penColorNames {
+		if n == name {
+			return c, true
+		}
+	}
+	if id, ok := parseNumberedName(name, "color-"); ok {
+		return PenColor(id), true
+	}
+	return 0, false
+}
+
+// MarshalText writes the color's name, so colors appear by name in JSON
+func (c PenColor) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText reads a color name (see ParsePenColor)
+func (c *PenColor) UnmarshalText(text []byte) error {
+	color, ok := ParsePenColor(string(text))
+	if !ok {
+		return fmt.Errorf("unknown pen color: %s", text)
+	}
+	*c = color
+	return nil
+}
+
+// parseNumberedName parses the <prefix><id> names that String methods give
+// unknown values
+func parseNumberedName(name, prefix string) (uint32, bool) {
+	digits, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(id), true
+}
+
 // RGBA represents an RGBA color from the file
 type RGBA struct {
 	R, G, B, A uint8
@@ -113,6 +208,97 @@ const (
 	PenShader             Pen = 23
 )
 
+func (p Pen) String() string {
+	switch p {
+	case PenPaintbrush1, PenPaintbrush2:
+		return "paintbrush"
+	case PenPencil1, PenPencil2:
+		return "pencil"
+	case PenBallpoint1, PenBallpoint2:
+		return "ballpoint"
+	case PenMarker1, PenMarker2:
+		return "marker"
+	case PenFineliner1, PenFineliner2:
+		return "fineliner"
+	case PenHighlighter1, PenHighlighter2:
+		return "highlighter"
+	case PenEraser:
+		return "eraser"
+	case PenMechanicalPencil1, PenMechanicalPencil2:
+		return "mechanical-pencil"
+	case PenEraserArea:
+		return "eraser-area"
+	case PenCalligraphy:
+		return "calligraphy"
+	case PenShader:
+		return "shader"
+	default:
+		return fmt.Sprintf("pen-%d", uint32(p))
+	}
+}
+
+// pens lists the known pen types, with the ID current firmware writes first
+// for pens that also have an older one
+var pens = []Pen{
+	PenPaintbrush2, PenPaintbrush1,
+	PenPencil2, PenPencil1,
+	PenBallpoint2, PenBallpoint1,
+	PenMarker2, PenMarker1,
+	PenFineliner2, PenFineliner1,
+	PenHighlighter2, PenHighlighter1,
+	PenEraser,
+	PenMechanicalPencil2, PenMechanicalPencil1,
+	PenEraserArea,
+	PenCalligraphy,
+	PenShader,
+}
+
+// ParsePen looks up a pen type by the name returned by String, such as
+// "fineliner", or the pen-<id> form of unknown pens. Names shared by an
+// older and a newer tool ID give the newer one; the older one is named with
+// a -v1 suffix, as in "fineliner-v1".
+func ParsePen(name string) (Pen, bool) {
+	base, v1 := strings.CutSuffix(name, "-v1")
+	for _, p := range pens {
+		if p.String() == base && p.isV1() == v1 {
+			return p, true
+		}
+	}
+	if id, ok := parseNumberedName(name, "pen-"); ok {
+		return Pen(id), true
+	}
+	return 0, false
+}
+
+// isV1 reports whether the pen is the older of two tool IDs for the same pen
+func (p Pen) isV1() bool {
+	switch p {
+	case PenPaintbrush1, PenPencil1, PenBallpoint1, PenMarker1, PenFineliner1, PenHighlighter1, PenMechanicalPencil1:
+		return true
+	default:
+		return false
+	}
+}
+
+// MarshalText writes the pen's name, so pens appear by name in JSON. Older
+// tool IDs get a -v1 suffix (see ParsePen), so every ID has its own name.
+func (p Pen) MarshalText() ([]byte, error) {
+	if p.isV1() {
+		return []byte(p.String() + "-v1"), nil
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText reads a pen name (see ParsePen)
+func (p *Pen) UnmarshalText(text []byte) error {
+	pen, ok := ParsePen(string(text))
+	if !ok {
+		return fmt.Errorf("unknown pen: %s", text)
+	}
+	*p = pen
+	return nil
+}
+
 // IsHighlighter returns true if the pen is a highlighter
 func (p Pen) IsHighlighter() bool {
 	return p == PenHighlighter1 || p == PenHighlighter2
@@ -134,6 +320,117 @@ const (
 	StyleNumbered        ParagraphStyle = 10 // Numbered list (1., 2., 3., etc.)
 )
 
+// paragraphStyleNames holds the names of the known paragraph styles
+var paragraphStyleNames = map[ParagraphStyle]string{
+	StyleBasic:           "basic",
+	StylePlain:           "plain",
+	StyleHeading:         "heading",
+	StyleBold:            "bold",
+	StyleBullet:          "bullet",
+	StyleBullet2:         "bullet2",
+	StyleCheckbox:        "checkbox",
+	StyleCheckboxChecked: "checkbox-checked",
+	StyleNumbered:        "numbered",
+}
+
+// String returns a human-readable name for a paragraph style
+func (s ParagraphStyle) String() string {
+	if name, ok := paragraphStyleNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown-%d", uint32(s))
+}
+
+// ParseParagraphStyle looks up a paragraph style by the name returned by
+// String, including the unknown-<id> form of unknown styles
+func ParseParagraphStyle(name string) (ParagraphStyle, bool) {
+	for s, n := range paragraphStyleNames {
+		if n == name {
+			return s, true
+		}
+	}
+	if id, ok := parseNumberedName(name, "unknown-"); ok {
+		return ParagraphStyle(id), true
+	}
+	return 0, false
+}
+
+// MarshalText writes the style's name, so styles appear by name in JSON
+func (s ParagraphStyle) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a paragraph style name (see ParseParagraphStyle)
+func (s *ParagraphStyle) UnmarshalText(text []byte) error {
+	style, ok := ParseParagraphStyle(string(text))
+	if !ok {
+		return fmt.Errorf("unknown paragraph style: %s", text)
+	}
+	*s = style
+	return nil
+}
+
+// TextFormat represents inline character formatting codes stored in text items
+type TextFormat uint32
+
+const (
+	FormatBoldStart   TextFormat = 1
+	FormatBoldEnd     TextFormat = 2
+	FormatItalicStart TextFormat = 3
+	FormatItalicEnd   TextFormat = 4
+	FormatLinkStart   TextFormat = 5
+	FormatLinkEnd     TextFormat = 6
+)
+
+// TextLinkStart is the value of a formatting item starting a link made on
+// the device. Its item carries FormatLinkStart after the link's target, which
+// is a web address or the ID of a page of the notebook. The text up to the
+// next FormatLinkEnd is the link.
+type TextLinkStart struct {
+	Target string
+}
+
+// WebAddress returns the web address the link opens, or "" if it links to a
+// page of the notebook
+func (l TextLinkStart) WebAddress() string {
+	target := strings.TrimSpace(l.Target)
+	switch lower := strings.ToLower(target); {
+	case strings.Contains(lower, "://"), strings.HasPrefix(lower, "mailto:"):
+		return target
+	case strings.HasPrefix(lower, "www."):
+		return "https://" + target
+	}
+	return ""
+}
+
+// PageID returns the ID of the notebook page the link opens, or "" if it
+// opens a web address
+func (l TextLinkStart) PageID() string {
+	if l.WebAddress() != "" {
+		return ""
+	}
+	return strings.TrimSpace(l.Target)
+}
+
+func (f TextFormat) String() string {
+	switch f {
+	case FormatBoldStart:
+		return "bold-start"
+	case FormatBoldEnd:
+		return "bold-end"
+	case FormatItalicStart:
+		return "italic-start"
+	case FormatItalicEnd:
+		return "italic-end"
+	case FormatLinkStart:
+		return "link-start"
+	case FormatLinkEnd:
+		return "link-end"
+	default:
+		return fmt.Sprintf("format-%d", uint32(f))
+	}
+}
+
 // Point represents a point in a stroke with pressure/speed data
 type Point struct {
 	X         float32
@@ -152,6 +449,7 @@ type Line struct {
 	Points         []Point
 	ThicknessScale float64
 	StartingLength float32
+	Timestamp      CrdtID // When the stroke was created, if the file says; see Time
 	MoveID         *CrdtID
 }
 
@@ -163,13 +461,14 @@ type Rectangle struct {
 	H float64
 }
 
-// GlyphRange represents highlighted text in a PDF
+// GlyphRange represents highlighted text in a PDF or EPUB
 type GlyphRange struct {
-	Start      *uint32
-	Length     uint32
-	Text       string
-	Color      PenColor
-	Rectangles []Rectangle
+	Start         *uint32 // Offset of the text on the page, if the file says
+	Length        uint32
+	Text          string
+	Color         PenColor
+	ColorOverride *RGBA // Exact highlight color written by newer firmware
+	Rectangles    []Rectangle
 }
 
 // Text represents a text block
@@ -209,7 +508,14 @@ type CrdtSequenceItem struct {
 	LeftID        CrdtID
 	RightID       CrdtID
 	DeletedLength uint32
-	Value         interface{} // Can be string, int, *Group, *Line, etc.
+	Value         interface{} // Can be string, TextFormat, TextLinkStart, *Group, *Line, etc.
+}
+
+// Deleted reports whether the item was deleted. Deleted items keep their
+// place in the sequence, as the neighbors of other items, but are not part
+// of the content.
+func (item CrdtSequenceItem) Deleted() bool {
+	return item.DeletedLength > 0
 }
 
 // CrdtSequence represents a CRDT sequence
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+)
+
+// ReadSceneStrict is like ReadScene, but also checks that a v6 file is
+// consistent and reports what isn't as warnings:
+//
+//   - the bytes of a block the parser reads but doesn't decode, such as
+//     fields added by newer firmware, must be tagged values that end
+//     exactly at the end of the block, so block lengths match their data
+//   - every group referred to, as the parent of an item or by a group
+//     item, must have a tree node block
+//   - the characters that paragraph styles apply to must be in their text,
+//     and those that groups are anchored to in the root text, including
+//     deleted ones
+//
+// A file is consistent when it is read without error or warnings. The
+// format has no checksums, so data changed without breaking its structure
+// can't be detected.
+func ReadSceneStrict(r io.Reader, logger *slog.Logger) (*ParseResult, error) {
+	return readScene(context.Background(), r, logger, true)
+}
+
+// checkBlockEnd warns about the rest of a block decoded without error when
+// it isn't made of tagged values
+func (tbr *TaggedBlockReader) checkBlockEnd(blockInfo *BlockInfo) {
+	if !decodedBlockTypes[blockInfo.BlockType] || tbr.RemainingInBlock() == 0 {
+		return
+	}
+	data, err := tbr.BlockBytes()
+	if err != nil {
+		tbr.warn("%v", err)
+		return
+	}
+	if _, rest := DecodeFields(data); len(rest) > 0 {
+		tbr.warn("block length %d doesn't match its data: the last %d bytes are not tagged values",
+			blockInfo.Size, len(rest))
+	}
+}
+
+// checkReferences warns about references to groups without a tree node
+// block, and to characters that are not in the text. It must run before
+// applyDeletions, as deleted characters can still be referred to.
+func (st *SceneTree) checkReferences(reader *TaggedBlockReader) {
+	var rootIDs idSet
+	if st.RootText != nil && st.RootText.Items != nil {
+		rootIDs = checkText(reader, st.RootText, "the root text")
+	}
+
+	for id, group := range st.Nodes {
+		if !st.treeNodes[id] && group != st.Root {
+			reader.warn("group %s is referred to, but has no tree node block", id)
+		}
+		if anchor := group.AnchorID; anchor != nil && anchor.Value.Part1 != 0 && !rootIDs.contains(anchor.Value) {
+			reader.warn("group %s is anchored to character %s, which is not in the root text", id, anchor.Value)
+		}
+		if group.Children == nil {
+			continue
+		}
+		for _, item := range group.Children.Items {
+			if text, ok := item.Value.(*Text); ok && text.Items != nil {
+				checkText(reader, text, "text item "+item.ItemID.String())
+			}
+		}
+	}
+}
+
+// checkText checks that the paragraph styles of a text apply to characters
+// in it, and returns the IDs of its characters. A style for the zero ID
+// applies to the first paragraph.
+func checkText(reader *TaggedBlockReader, text *Text, name string) idSet {
+	ids := sequenceIDs(text.Items)
+	for charID := range text.Styles {
+		if charID != (CrdtID{}) && !ids.contains(charID) {
+			reader.warn("%s has a paragraph style for character %s, which is not in it", name, charID)
+		}
+	}
+	return ids
+}
+
+// idSet holds ranges of IDs by author (Part1), sorted and merged
+type idSet map[uint][][2]uint64
+
+func (s idSet) contains(id CrdtID) bool {
+	ranges := s[id.Part1]
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i][0] > id.Part2 })
+	return i > 0 && id.Part2 < ranges[i-1][1]
+}
+
+// sequenceIDs returns the IDs of the items of a sequence. The characters of
+// a text item have consecutive IDs from the item's, so an ID can point into
+// an item; a text takes at most its length in bytes.
+func sequenceIDs(seq *CrdtSequence) idSet {
+	ids := make(idSet)
+	for _, item := range seq.Items {
+		n := uint64(1)
+		if item.DeletedLength > 0 {
+			n = uint64(item.DeletedLength)
+		} else if s, ok := item.Value.(string); ok && len(s) > 0 {
+			n = uint64(len(s))
+		}
+		start := item.ItemID.Part2
+		ids[item.ItemID.Part1] = append(ids[item.ItemID.Part1], [2]uint64{start, start + n})
+	}
+
+	for author, ranges := range ids {
+		sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+		merged := ranges[:1]
+		for _, r := range ranges[1:] {
+			last := &merged[len(merged)-1]
+			if r[0] <= last[1] {
+				last[1] = max(last[1], r[1])
+			} else {
+				merged = append(merged, r)
+			}
+		}
+		ids[author] = merged
+	}
+	return ids
+}
@@ -0,0 +1,60 @@
+package rmc
+
+import (
+	"fmt"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// Result describes what a conversion produced, so that applications can
+// record it without parsing the input again
+type Result struct {
+	// Pages describes the converted pages, in output order
+	Pages []PageResult
+}
+
+// PageResult describes one converted page
+type PageResult struct {
+	// Source is the input file of the page, or empty for data from a reader
+	Source string
+
+	// Version is the format version of the page's .rm file (3, 5 or 6)
+	Version int
+
+	// Stats counts the layers, strokes and points of the page and holds the
+	// bounds of its strokes, in reMarkable screen units
+	Stats *parser.Stats
+
+	// Text is the typed text of the page, one paragraph per line
+	Text string
+
+	// Warnings are the non-fatal problems found while parsing the page
+	Warnings []parser.Warning
+}
+
+// Warnings returns the warnings of all pages
+func (r *Result) Warnings() []parser.Warning {
+	var warnings []parser.Warning
+	for _, page := range r.Pages {
+		warnings = append(warnings, page.Warnings...)
+	}
+	return warnings
+}
+
+// newPageResult describes a parsed page
+func newPageResult(source string, parsed *parser.ParseResult) (PageResult, error) {
+	page := PageResult{
+		Source:   source,
+		Version:  parsed.Version,
+		Stats:    parser.ComputeStats(parsed.Tree),
+		Warnings: parsed.Warnings,
+	}
+	if parsed.Tree.RootText != nil {
+		doc, err := parser.BuildTextDocument(parsed.Tree.RootText)
+		if err != nil {
+			return PageResult{}, fmt.Errorf("failed to extract text: %w", err)
+		}
+		page.Text = doc.String()
+	}
+	return page, nil
+}
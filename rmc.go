@@ -2,15 +2,24 @@
 // to PDF and SVG formats. This package is designed to be used both as a library and as a CLI tool.
 //
 // For more control over the conversion process, use the parser and export packages directly.
+//
+// The functions of this package are safe to call from several goroutines at once, e.g. to
+// convert many files in parallel in a server: every call parses its own scene trees, and
+// Options are only read, so one Options value can be shared by concurrent calls. Its
+// Progress callback and Recognizer are then called concurrently too.
 package rmc
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/joagonca/rmc-go/export"
 	"github.com/joagonca/rmc-go/parser"
@@ -24,12 +33,246 @@ const (
 	FormatPDF Format = "pdf"
 	// FormatSVG represents SVG output format
 	FormatSVG Format = "svg"
+	// FormatSVGZ represents gzip-compressed SVG output format
+	FormatSVGZ Format = "svgz"
+	// FormatHTML represents HTML output format
+	FormatHTML Format = "html"
+	// FormatEPS represents Encapsulated PostScript output format
+	FormatEPS Format = "eps"
+	// FormatPNG represents PNG image output format (requires a Cairo build)
+	FormatPNG Format = "png"
+	// FormatGIF represents an animated GIF replaying the strokes (requires a Cairo build)
+	FormatGIF Format = "gif"
+	// FormatMP4 represents an MP4 video replaying the strokes (requires a Cairo build and ffmpeg)
+	FormatMP4 Format = "mp4"
+	// FormatHOCR represents hOCR, HTML with the typed text and its positions
+	FormatHOCR Format = "hocr"
+	// FormatALTO represents ALTO XML with the typed text and its positions
+	FormatALTO Format = "alto"
+	// FormatTikZ represents a TikZ picture for LaTeX documents
+	FormatTikZ Format = "tikz"
+	// FormatExcalidraw represents an Excalidraw whiteboard scene
+	FormatExcalidraw Format = "excalidraw"
 )
 
 // Options contains configuration options for conversion
 type Options struct {
-	// UseLegacy uses the Inkscape-based PDF renderer instead of Cairo (default: false)
+	// UseLegacy converts SVG with Inkscape or rsvg-convert instead of
+	// rendering with Cairo (default: false)
 	UseLegacy bool
+
+	// Logger receives non-fatal parser warnings and debug output (default: slog.Default())
+	Logger *slog.Logger
+
+	// PageSize fixes the output page size: auto, device, a4 or letter (default: auto)
+	PageSize export.PageSize
+
+	// Outline adds a bookmark per page to multipage PDFs, named after the
+	// page's first heading (default: false)
+	Outline bool
+
+	// PageTitles overrides the bookmark titles of Outline by page index
+	// (default: nil, each page's first heading)
+	PageTitles []string
+
+	// Contents prepends a table of contents to multipage PDFs, listing every
+	// page with its first heading or line of typed text and, for archives
+	// and folders, its tags, each linking to its page (default: false)
+	Contents bool
+
+	// PageTags are the tags listed for each page in the table of contents,
+	// by index of the exported pages. Archive and folder conversion set them
+	// from the .content file when none are given.
+	PageTags [][]string
+
+	// PageIDs are the IDs of the exported pages by index, to which links to
+	// pages made on the device point. Archive and folder conversion set them
+	// from the .content file when none are given.
+	PageIDs []string
+
+	// NUp places several pages on each sheet of PDF output, e.g.
+	// export.NUp{Columns: 2, Rows: 2} (default: one page per sheet)
+	NUp export.NUp
+
+	// Booklet arranges the pages of PDF output two per sheet side for a
+	// saddle-stitched booklet, printed on both sides (default: false)
+	Booklet bool
+
+	// TextLayer embeds fonts so typed text is selectable and searchable in
+	// the PDF (Cairo renderer only, default: false)
+	TextLayer bool
+
+	// FontFile is the TrueType/OpenType font embedded for TextLayer
+	// (default: empty, a system sans-serif font)
+	FontFile string
+
+	// Recognizer adds an invisible, searchable text layer of recognized
+	// handwriting to each page (default: nil)
+	Recognizer export.Recognizer
+
+	// PDFProfile selects a PDF conformance profile such as PDF/A-2b
+	// (requires Ghostscript, default: plain PDF)
+	PDFProfile export.PDFProfile
+
+	// PDFMetadata is the title, author and dates written to PDF output
+	// (default: none). Archive conversion fills in the notebook's name and
+	// dates from its .metadata file.
+	PDFMetadata export.PDFMetadata
+
+	// InkscapePath is the Inkscape executable used by the legacy renderer
+	// (default: "inkscape" from PATH)
+	InkscapePath string
+
+	// SVGConverter selects the program that converts SVG for the legacy
+	// renderer: Inkscape or rsvg-convert (default: Inkscape)
+	SVGConverter export.SVGConverter
+
+	// PdfMergeTool selects the program that merges legacy multipage PDFs:
+	// pdfunite, gs or builtin (default: pdfunite, falling back to gs and
+	// then to builtin)
+	PdfMergeTool export.PDFMergeTool
+
+	// TempDir is where the legacy renderer, PDF merging and PDF profiles
+	// keep the files they pass to external programs (default: empty,
+	// os.TempDir()). The default Cairo renderer writes no temporary files,
+	// so it also runs on a read-only file system.
+	TempDir string
+
+	// Smooth draws strokes as fitted Bezier curves instead of polylines
+	// (default: false)
+	Smooth bool
+
+	// Palette replaces the colors of the listed pen colors (default: nil,
+	// device colors). See export.ParsePalette.
+	Palette map[parser.PenColor]export.RGB
+
+	// PenProfile tunes the stroke model of each pen type (default: nil, the
+	// built-in profile). See export.ParsePenProfile.
+	PenProfile export.PenProfile
+
+	// VariableWidth draws pressure-sensitive pens as filled outlines whose
+	// width changes continuously (default: false)
+	VariableWidth bool
+
+	// ChiselMarker draws marker strokes with a chisel tip that follows the
+	// pen's tilt (default: false)
+	ChiselMarker bool
+
+	// PencilGradient shades pencil strokes smoothly with pressure instead of
+	// in steps (default: false)
+	PencilGradient bool
+
+	// SimplifyTolerance removes stroke points closer than this distance (in
+	// reMarkable screen units) to the simplified stroke (default: 0, keep all)
+	SimplifyTolerance float64
+
+	// CropToContent crops pages tightly around the drawn content instead of
+	// the full screen area (default: false)
+	CropToContent bool
+
+	// Margin adds space around the content, in points (default: 0). See
+	// export.ParseLength.
+	Margin float64
+
+	// Background fills pages with a CSS color, or "none" to leave them
+	// transparent (default: empty, transparent except for PNG, GIF and MP4
+	// output, which is white)
+	Background string
+
+	// StrokeScale multiplies the width of every stroke (default: 0, same as 1)
+	StrokeScale float64
+
+	// DPI is the resolution of PNG images and of GIF and MP4 frames, in
+	// pixels per inch (default: 0, 144 for PNG and 72 for GIF and MP4)
+	DPI float64
+
+	// OutputScale multiplies the size of the output (default: 0, same as 1)
+	OutputScale float64
+
+	// OutputWidth and OutputHeight resize the output to this width or height
+	// in points, or pixels for PNG; with only one set the aspect ratio is kept
+	// (default: 0, unchanged)
+	OutputWidth  float64
+	OutputHeight float64
+
+	// Pages selects the pages of multipage conversions to export, keeping
+	// their order (default: nil, all pages). See parser.ParsePageRanges.
+	Pages parser.PageRanges
+
+	// Tags selects the pages of archive and folder conversions that carry
+	// any of the tags, before Pages is applied; a tag of the whole notebook
+	// selects all of its pages (default: nil, all pages)
+	Tags []string
+
+	// ContentFile is the .content file that orders the pages of
+	// ConvertDirectory (default: empty, <folder>.content if it exists)
+	ContentFile string
+
+	// Landscape turns pages a quarter turn clockwise for notebooks written in
+	// landscape orientation. Archive conversion sets it from the .content file.
+	Landscape bool
+
+	// KeepErasers draws eraser strokes as white strokes instead of removing
+	// the ink they cover (default: false)
+	KeepErasers bool
+
+	// SnapHighlights draws text highlighted in a PDF or EPUB as rectangles
+	// over the text instead of the freehand highlighter strokes
+	// (default: false)
+	SnapHighlights bool
+
+	// Deterministic makes identical input give byte-identical output, with
+	// fixed PDF dates and identifiers and numbered SVG group IDs
+	// (default: false). See export.SVGOptions.Deterministic.
+	Deterministic bool
+
+	// CompactSVG writes smaller SVG and HTML: relative path data rounded to
+	// SVGPrecision decimals and stroke styles shared through CSS classes
+	// (default: false)
+	CompactSVG bool
+
+	// SVGPrecision is the number of decimals of coordinates in compact SVG
+	// (default: 0, same as 2)
+	SVGPrecision int
+
+	// Glyphs are drawn before list and checkbox paragraphs, e.g.
+	// &export.ASCIIGlyphs for fonts without bullets and ballot boxes
+	// (default: nil, same as export.UnicodeGlyphs)
+	Glyphs *export.GlyphSet
+
+	// StrokeTimes exports only the strokes created in a time range; strokes
+	// without a creation time are left out when it is set (default: zero,
+	// every stroke). See parser.ParseTime.
+	StrokeTimes parser.TimeRange
+
+	// OnlyTools exports only the strokes of these pen types, and
+	// ExcludeColors leaves out the strokes of these colors (default: nil,
+	// every stroke). See export.SVGOptions.OnlyTools.
+	OnlyTools     []parser.Pen
+	ExcludeColors []parser.PenColor
+
+	// Layers exports only the layers with these labels, and SkipHiddenLayers
+	// leaves out the layers hidden on the device (default: every layer)
+	Layers           []string
+	SkipHiddenLayers bool
+
+	// Animate makes SVG and HTML output replay the strokes as they were
+	// drawn, taking this long in total (default: 0, a still page). It also
+	// sets the length of GIF and MP4 replays (default for those: 10s).
+	Animate time.Duration
+
+	// FPS is the frame rate of GIF and MP4 replays (default: 0, 10 frames
+	// per second)
+	FPS float64
+
+	// ReplayHold is how long the finished page stays on screen at the end
+	// of GIF and MP4 replays (default: 0, 2 seconds)
+	ReplayHold time.Duration
+
+	// Progress is called as multipage conversions work through the pages,
+	// with the 1-based page number, the page count and the stage:
+	// export.StageParse, export.StageRender or export.StageMerge (default: nil)
+	Progress func(page, total int, stage string)
 }
 
 // DefaultOptions returns the default conversion options
@@ -87,28 +330,109 @@ func ConvertFile(inputPath, outputPath string, opts *Options) error {
 //	}
 //	pdfData := output.Bytes()
 func Convert(input io.Reader, output io.Writer, format Format, opts *Options) error {
+	return ConvertContext(context.Background(), input, output, format, opts)
+}
+
+// ConvertContext is like Convert, but stops and returns the context's error
+// once ctx is done, killing any external converter it started.
+// Use it to bound conversions by a request's lifetime or a timeout.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+//	defer cancel()
+//	err := rmc.ConvertContext(ctx, input, w, rmc.FormatPDF, nil)
+func ConvertContext(ctx context.Context, input io.Reader, output io.Writer, format Format, opts *Options) error {
+	_, err := ConvertWithResult(ctx, input, output, format, opts)
+	return err
+}
+
+// ConvertWithResult is like ConvertContext, but also returns a description
+// of the converted page: its stroke statistics and bounds, typed text and
+// parser warnings.
+//
+// Example:
+//
+//	result, err := rmc.ConvertWithResult(ctx, input, w, rmc.FormatPDF, nil)
+//	if err != nil {
+//	    return err
+//	}
+//	log.Printf("%d strokes, text: %q", result.Pages[0].Stats.Strokes, result.Pages[0].Text)
+func ConvertWithResult(ctx context.Context, input io.Reader, output io.Writer, format Format, opts *Options) (*Result, error) {
 	if opts == nil {
 		opts = DefaultOptions()
 	}
 
 	// Parse the .rm file
-	tree, err := parser.ReadSceneTree(input)
+	parsed, err := parser.ReadSceneContext(ctx, input, opts.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse .rm file: %w", err)
+	}
+	opts.filterLayers(parsed.Tree)
+	page, err := newPageResult("", parsed)
 	if err != nil {
-		return fmt.Errorf("failed to parse .rm file: %w", err)
+		return nil, err
+	}
+	if err := exportPage(ctx, parsed.Tree, output, format, opts); err != nil {
+		return nil, err
 	}
+	return &Result{Pages: []PageResult{page}}, nil
+}
 
+// exportPage writes a page in a format
+func exportPage(ctx context.Context, tree *parser.SceneTree, output io.Writer, format Format, opts *Options) error {
 	// Export based on format
 	switch format {
 	case FormatSVG:
-		if err := export.ExportToSVG(tree, output); err != nil {
+		if err := export.ExportToSVGContext(ctx, tree, output, &opts.pdfOptions().SVGOptions); err != nil {
 			return fmt.Errorf("failed to export to SVG: %w", err)
 		}
+	case FormatSVGZ:
+		if err := export.ExportToSVGZWithOptions(tree, output, &opts.pdfOptions().SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to SVGZ: %w", err)
+		}
 	case FormatPDF:
-		if err := export.ExportToPDF(tree, output, opts.UseLegacy); err != nil {
+		if err := export.ExportToPDFContext(ctx, tree, output, opts.pdfOptions()); err != nil {
 			return fmt.Errorf("failed to export to PDF: %w", err)
 		}
+	case FormatHTML:
+		if err := export.ExportToHTMLWithOptions(tree, output, &opts.pdfOptions().SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to HTML: %w", err)
+		}
+	case FormatEPS:
+		if err := export.ExportToEPSContext(ctx, tree, output, opts.pdfOptions()); err != nil {
+			return fmt.Errorf("failed to export to EPS: %w", err)
+		}
+	case FormatPNG:
+		if err := export.ExportToPNGWithOptions(tree, output, opts.pngOptions()); err != nil {
+			return fmt.Errorf("failed to export to PNG: %w", err)
+		}
+	case FormatGIF:
+		if err := export.ExportToGIFWithOptions(tree, output, opts.replayOptions()); err != nil {
+			return fmt.Errorf("failed to export to GIF: %w", err)
+		}
+	case FormatMP4:
+		if err := export.ExportToMP4Context(ctx, tree, output, opts.replayOptions()); err != nil {
+			return fmt.Errorf("failed to export to MP4: %w", err)
+		}
+	case FormatHOCR:
+		if err := export.ExportToHOCR([]*parser.SceneTree{tree}, output, &opts.pdfOptions().SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to hOCR: %w", err)
+		}
+	case FormatALTO:
+		if err := export.ExportToALTO([]*parser.SceneTree{tree}, output, &opts.pdfOptions().SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to ALTO: %w", err)
+		}
+	case FormatTikZ:
+		if err := export.ExportToTikZWithOptions(tree, output, &opts.pdfOptions().SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to TikZ: %w", err)
+		}
+	case FormatExcalidraw:
+		if err := export.ExportToExcalidrawWithOptions(tree, output, &opts.pdfOptions().SVGOptions); err != nil {
+			return fmt.Errorf("failed to export to Excalidraw: %w", err)
+		}
 	default:
-		return fmt.Errorf("unknown format: %s (supported: pdf, svg)", format)
+		return fmt.Errorf("unknown format: %s (supported: pdf, svg, svgz, html, eps, png, gif, mp4, hocr, alto, tikz, excalidraw)", format)
 	}
 
 	return nil
@@ -194,44 +518,72 @@ func ConvertBytesToFile(data []byte, outputPath string, format Format, opts *Opt
 //	    log.Fatal(err)
 //	}
 func ConvertFiles(inputPaths []string, outputPath string, opts *Options) error {
+	_, err := ConvertFilesWithResult(inputPaths, outputPath, opts)
+	return err
+}
+
+// ConvertFilesWithResult is like ConvertFiles, but also returns a
+// description of each page written: its input file, stroke statistics and
+// bounds, typed text and parser warnings.
+//
+// Example:
+//
+//	result, err := rmc.ConvertFilesWithResult(files, "output.pdf", nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Printf("%d pages, %d warnings\n", len(result.Pages), len(result.Warnings()))
+func ConvertFilesWithResult(inputPaths []string, outputPath string, opts *Options) (*Result, error) {
 	if opts == nil {
 		opts = DefaultOptions()
 	}
 
 	if len(inputPaths) == 0 {
-		return fmt.Errorf("no input files provided")
+		return nil, fmt.Errorf("no input files provided")
+	}
+	inputPaths = parser.SelectPages(inputPaths, opts.Pages)
+	if len(inputPaths) == 0 {
+		return nil, fmt.Errorf("no pages selected")
 	}
 
 	// Parse all .rm files into scene trees
 	var trees []*parser.SceneTree
+	result := &Result{}
 	for i, path := range inputPaths {
+		opts.reportProgress(i+1, len(inputPaths), export.StageParse)
 		file, err := os.Open(path)
 		if err != nil {
-			return fmt.Errorf("failed to open file %d (%s): %w", i+1, path, err)
+			return nil, fmt.Errorf("failed to open file %d (%s): %w", i+1, path, err)
 		}
 
-		tree, err := parser.ReadSceneTree(file)
+		parsed, err := parser.ReadScene(file, opts.Logger)
 		file.Close()
 		if err != nil {
-			return fmt.Errorf("failed to parse file %d (%s): %w", i+1, path, err)
+			return nil, fmt.Errorf("failed to parse file %d (%s): %w", i+1, path, err)
+		}
+		opts.filterLayers(parsed.Tree)
+		page, err := newPageResult(path, parsed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %d (%s): %w", i+1, path, err)
 		}
 
-		trees = append(trees, tree)
+		trees = append(trees, parsed.Tree)
+		result.Pages = append(result.Pages, page)
 	}
 
 	// Create output file
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return nil, fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outputFile.Close()
 
 	// Export to multipage PDF
-	if err := export.ExportToMultipagePDF(trees, outputFile, opts.UseLegacy); err != nil {
-		return fmt.Errorf("failed to export multipage PDF: %w", err)
+	if err := export.ExportToMultipagePDFWithOptions(trees, outputFile, opts.pdfOptions()); err != nil {
+		return nil, fmt.Errorf("failed to export multipage PDF: %w", err)
 	}
 
-	return nil
+	return result, nil
 }
 
 // ConvertMultipleFromBytes converts multiple ordered reMarkable .rm files from binary data
@@ -248,6 +600,13 @@ func ConvertFiles(inputPaths []string, outputPath string, opts *Options) error {
 //	}
 //	os.WriteFile("output.pdf", pdfData, 0644)
 func ConvertMultipleFromBytes(pages [][]byte, opts *Options) ([]byte, error) {
+	return ConvertMultipleFromBytesContext(context.Background(), pages, opts)
+}
+
+// ConvertMultipleFromBytesContext is like ConvertMultipleFromBytes, but stops
+// and returns the context's error once ctx is done, killing any external
+// process it started
+func ConvertMultipleFromBytesContext(ctx context.Context, pages [][]byte, opts *Options) ([]byte, error) {
 	if opts == nil {
 		opts = DefaultOptions()
 	}
@@ -255,21 +614,27 @@ func ConvertMultipleFromBytes(pages [][]byte, opts *Options) ([]byte, error) {
 	if len(pages) == 0 {
 		return nil, fmt.Errorf("no pages provided")
 	}
+	pages = parser.SelectPages(pages, opts.Pages)
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages selected")
+	}
 
 	// Parse all pages into scene trees
 	var trees []*parser.SceneTree
 	for i, data := range pages {
+		opts.reportProgress(i+1, len(pages), export.StageParse)
 		reader := bytes.NewReader(data)
-		tree, err := parser.ReadSceneTree(reader)
+		tree, err := parser.ReadSceneTreeContext(ctx, reader, opts.Logger)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse page %d: %w", i+1, err)
 		}
+		opts.filterLayers(tree)
 		trees = append(trees, tree)
 	}
 
 	// Export to multipage PDF
 	output := &bytes.Buffer{}
-	if err := export.ExportToMultipagePDF(trees, output, opts.UseLegacy); err != nil {
+	if err := export.ExportToMultipagePDFContext(ctx, trees, output, opts.pdfOptions()); err != nil {
 		return nil, fmt.Errorf("failed to export multipage PDF: %w", err)
 	}
 
@@ -331,14 +696,243 @@ func ConvertMultipleBytesToFile(pages [][]byte, outputPath string, opts *Options
 	return nil
 }
 
+// ConvertArchive converts a notebook archive, such as an .rmdoc file exported by
+// the reMarkable app or a zip of a notebook folder, to a multipage PDF. Pages are
+// ordered by the archive's .content file, or by modification time without one.
+//
+// Example:
+//
+//	err := rmc.ConvertArchive("notebook.rmdoc", "output.pdf", nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+func ConvertArchive(archivePath, outputPath string, opts *Options) error {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	pdfData, err := ConvertArchiveFromBytes(data, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(outputPath, pdfData, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return nil
+}
+
+// ConvertArchiveFromBytes converts a notebook archive (.rmdoc or .zip) from binary data
+// to a multipage PDF, returning the result as a byte slice.
+// Pages are ordered by the archive's .content file, and the PDF is titled
+// after the notebook, with its tags as keywords.
+//
+// Example:
+//
+//	pdfData, err := rmc.ConvertArchiveFromBytes(rmdocData, nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+func ConvertArchiveFromBytes(data []byte, opts *Options) ([]byte, error) {
+	nb, err := parser.ReadNotebookArchive(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	notebookOpts := *opts
+	notebookOpts.Landscape = opts.Landscape || (nb.Content != nil && nb.Content.IsLandscape())
+	notebookOpts.PDFMetadata = opts.PDFMetadata.WithNotebook(nb.Metadata).WithTags(nb.Content)
+	opts = &notebookOpts
+
+	ids := nb.OrderedPageIDs()
+	if len(opts.Tags) > 0 {
+		if ids = nb.TaggedPageIDs(opts.Tags...); len(ids) == 0 {
+			return nil, fmt.Errorf("no pages tagged %s", strings.Join(opts.Tags, ", "))
+		}
+	}
+	pages := make([][]byte, len(ids))
+	for i, id := range ids {
+		pages[i] = nb.Pages[id]
+	}
+	if notebookOpts.PageTags == nil {
+		notebookOpts.PageTags = parser.SelectPages(pageTagNames(nb.Content, ids), opts.Pages)
+	}
+	if notebookOpts.PageIDs == nil {
+		notebookOpts.PageIDs = parser.SelectPages(ids, opts.Pages)
+	}
+	return ConvertMultipleFromBytes(pages, opts)
+}
+
+// pageTagNames returns the tags of each of the given pages, or nil without a
+// .content file
+func pageTagNames(content *parser.ContentFile, ids []string) [][]string {
+	if content == nil {
+		return nil
+	}
+	tags := make([][]string, len(ids))
+	for i, id := range ids {
+		tags[i] = content.PageTagNames(id)
+	}
+	return tags
+}
+
+// pdfOptions converts the conversion options to export options
+func (o *Options) pdfOptions() *export.PDFOptions {
+	pdfOpts := export.DefaultPDFOptions()
+	pdfOpts.UseLegacy = o.UseLegacy
+	pdfOpts.PageWidth, pdfOpts.PageHeight = o.PageSize.Dimensions()
+	pdfOpts.CropToContent = o.CropToContent
+	pdfOpts.Margin = o.Margin
+	pdfOpts.Background = o.Background
+	if o.StrokeScale > 0 {
+		pdfOpts.StrokeScale = o.StrokeScale
+	}
+	pdfOpts.OutputScale = o.OutputScale
+	pdfOpts.OutputWidth, pdfOpts.OutputHeight = o.OutputWidth, o.OutputHeight
+	pdfOpts.Outline = o.Outline
+	pdfOpts.PageTitles = o.PageTitles
+	pdfOpts.Contents = o.Contents
+	pdfOpts.PageTags = o.PageTags
+	pdfOpts.PageIDs = o.PageIDs
+	pdfOpts.NUp = o.NUp
+	pdfOpts.Booklet = o.Booklet
+	pdfOpts.TextLayer = o.TextLayer
+	pdfOpts.FontFile = o.FontFile
+	pdfOpts.Recognizer = o.Recognizer
+	pdfOpts.Profile = o.PDFProfile
+	pdfOpts.Metadata = o.PDFMetadata
+	pdfOpts.InkscapePath = o.InkscapePath
+	pdfOpts.Converter = o.SVGConverter
+	pdfOpts.MergeTool = o.PdfMergeTool
+	pdfOpts.TempDir = o.TempDir
+	pdfOpts.Landscape = o.Landscape
+	pdfOpts.SimplifyTolerance = o.SimplifyTolerance
+	pdfOpts.Smooth = o.Smooth
+	pdfOpts.VariableWidth = o.VariableWidth
+	pdfOpts.ChiselMarker = o.ChiselMarker
+	pdfOpts.PencilGradient = o.PencilGradient
+	pdfOpts.Palette = o.Palette
+	pdfOpts.PenProfile = o.PenProfile
+	pdfOpts.KeepErasers = o.KeepErasers
+	pdfOpts.SnapHighlights = o.SnapHighlights
+	pdfOpts.Deterministic = o.Deterministic
+	pdfOpts.Compact = o.CompactSVG
+	pdfOpts.Precision = o.SVGPrecision
+	pdfOpts.Glyphs = o.Glyphs
+	pdfOpts.StrokeTimes = o.StrokeTimes
+	pdfOpts.OnlyTools = o.OnlyTools
+	pdfOpts.ExcludeColors = o.ExcludeColors
+	pdfOpts.Animate = o.Animate
+	pdfOpts.Progress = o.Progress
+	return pdfOpts
+}
+
+// filterLayers removes the layers that Layers and SkipHiddenLayers leave out
+func (o *Options) filterLayers(tree *parser.SceneTree) {
+	if len(o.Layers) == 0 && !o.SkipHiddenLayers {
+		return
+	}
+	for _, layer := range tree.Layers() {
+		if (o.SkipHiddenLayers && !layer.Visible.Value) || (len(o.Layers) > 0 && !slices.Contains(o.Layers, layer.Label.Value)) {
+			tree.DeleteLayer(layer.NodeID)
+		}
+	}
+}
+
+// reportProgress calls o.Progress if it is set
+func (o *Options) reportProgress(page, total int, stage string) {
+	if o.Progress != nil {
+		o.Progress(page, total, stage)
+	}
+}
+
+// pngOptions converts the conversion options to raster export options
+func (o *Options) pngOptions() *export.PNGOptions {
+	pngOpts := export.DefaultPNGOptions()
+	pngOpts.PageWidth, pngOpts.PageHeight = o.PageSize.Dimensions()
+	pngOpts.CropToContent = o.CropToContent
+	pngOpts.Margin = o.Margin
+	if o.Background != "" {
+		pngOpts.Background = o.Background
+	}
+	if o.StrokeScale > 0 {
+		pngOpts.StrokeScale = o.StrokeScale
+	}
+	if o.DPI > 0 {
+		pngOpts.Scale = o.DPI / 72
+	}
+	pngOpts.OutputScale = o.OutputScale
+	pngOpts.OutputWidth, pngOpts.OutputHeight = o.OutputWidth, o.OutputHeight
+	pngOpts.Landscape = o.Landscape
+	pngOpts.SimplifyTolerance = o.SimplifyTolerance
+	pngOpts.Smooth = o.Smooth
+	pngOpts.VariableWidth = o.VariableWidth
+	pngOpts.ChiselMarker = o.ChiselMarker
+	pngOpts.PencilGradient = o.PencilGradient
+	pngOpts.Palette = o.Palette
+	pngOpts.PenProfile = o.PenProfile
+	pngOpts.Glyphs = o.Glyphs
+	pngOpts.KeepErasers = o.KeepErasers
+	pngOpts.SnapHighlights = o.SnapHighlights
+	pngOpts.Deterministic = o.Deterministic
+	pngOpts.StrokeTimes = o.StrokeTimes
+	pngOpts.OnlyTools = o.OnlyTools
+	pngOpts.ExcludeColors = o.ExcludeColors
+	return pngOpts
+}
+
+// replayOptions converts the conversion options to GIF and MP4 export options
+func (o *Options) replayOptions() *export.ReplayOptions {
+	replayOpts := export.DefaultReplayOptions()
+	replayOpts.PNGOptions = *o.pngOptions()
+	if o.DPI <= 0 {
+		replayOpts.Scale = 1
+	}
+	if o.Animate > 0 {
+		replayOpts.Duration = o.Animate
+	}
+	if o.FPS > 0 {
+		replayOpts.FPS = o.FPS
+	}
+	if o.ReplayHold > 0 {
+		replayOpts.Hold = o.ReplayHold
+	}
+	return replayOpts
+}
+
 // inferFormat infers the output format from a file path based on extension
 func inferFormat(path string) Format {
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".svg":
 		return FormatSVG
+	case ".svgz":
+		return FormatSVGZ
 	case ".pdf":
 		return FormatPDF
+	case ".html", ".htm":
+		return FormatHTML
+	case ".eps":
+		return FormatEPS
+	case ".png":
+		return FormatPNG
+	case ".gif":
+		return FormatGIF
+	case ".mp4":
+		return FormatMP4
+	case ".hocr":
+		return FormatHOCR
+	case ".alto":
+		return FormatALTO
+	case ".tikz", ".tex":
+		return FormatTikZ
+	case ".excalidraw":
+		return FormatExcalidraw
 	default:
 		return FormatPDF // default to PDF
 	}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/joagonca/rmc-go/server/rmcpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chunkSize is the most output sent in one Chunk message
+const chunkSize = 64 << 10
+
+// grpcFormats maps the output formats of the gRPC service to those of export
+var grpcFormats = map[rmcpb.Format]string{
+	rmcpb.Format_FORMAT_UNSPECIFIED: "pdf",
+	rmcpb.Format_FORMAT_PDF:         "pdf",
+	rmcpb.Format_FORMAT_SVG:         "svg",
+	rmcpb.Format_FORMAT_PNG:         "png",
+	rmcpb.Format_FORMAT_HTML:        "html",
+	rmcpb.Format_FORMAT_EPS:         "eps",
+}
+
+type converter struct {
+	rmcpb.UnimplementedConverterServer
+	*server
+}
+
+// NewConverter creates the gRPC conversion service defined in
+// rmcpb/rmc.proto, which converts like the handler of New. Register it with
+// rmcpb.RegisterConverterServer. ConvertPage receives its file in one
+// message, so the gRPC server's grpc.MaxRecvMsgSize must be raised from its
+// default of 4 MiB to accept pages up to MaxRequestBytes. A nil opts uses
+// DefaultOptions().
+func NewConverter(opts *Options) rmcpb.ConverterServer {
+	return &converter{server: newServer(opts)}
+}
+
+func (c *converter) ConvertPage(req *rmcpb.ConvertPageRequest, stream grpc.ServerStreamingServer[rmcpb.Chunk]) error {
+	format, ok := grpcFormats[req.GetFormat()]
+	if !ok {
+		return c.grpcFail(codes.InvalidArgument, fmt.Errorf("unknown format: %v", req.GetFormat()))
+	}
+	if len(req.GetData()) == 0 {
+		return c.grpcFail(codes.InvalidArgument, fmt.Errorf("empty request"))
+	}
+	if int64(len(req.GetData())) > c.opts.MaxRequestBytes {
+		return c.grpcFail(codes.ResourceExhausted, fmt.Errorf("upload exceeds %d bytes", c.opts.MaxRequestBytes))
+	}
+	if bytes.HasPrefix(req.GetData(), []byte("PK\x03\x04")) {
+		return c.grpcFail(codes.InvalidArgument, fmt.Errorf("ConvertPage takes an .rm file; send notebooks to ConvertNotebook"))
+	}
+	if !c.acquire() {
+		return c.grpcFail(codes.Unavailable, fmt.Errorf("server busy"))
+	}
+	defer c.release()
+	return c.convert(stream.Context(), req.GetData(), format, stream)
+}
+
+func (c *converter) ConvertNotebook(stream grpc.BidiStreamingServer[rmcpb.ConvertNotebookRequest, rmcpb.Chunk]) error {
+	// Take a conversion slot before receiving the notebook, so calls beyond
+	// the limit hold no memory
+	if !c.acquire() {
+		return c.grpcFail(codes.Unavailable, fmt.Errorf("server busy"))
+	}
+	defer c.release()
+
+	var data []byte
+	format := ""
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if format == "" {
+			var ok bool
+			if format, ok = grpcFormats[req.GetFormat()]; !ok {
+				return c.grpcFail(codes.InvalidArgument, fmt.Errorf("unknown format: %v", req.GetFormat()))
+			}
+		}
+		if int64(len(data)+len(req.GetData())) > c.opts.MaxRequestBytes {
+			return c.grpcFail(codes.ResourceExhausted, fmt.Errorf("upload exceeds %d bytes", c.opts.MaxRequestBytes))
+		}
+		data = append(data, req.GetData()...)
+	}
+	if len(data) == 0 {
+		return c.grpcFail(codes.InvalidArgument, fmt.Errorf("empty request"))
+	}
+	return c.convert(stream.Context(), data, format, stream)
+}
+
+// convert parses an .rm file or zipped notebook and sends the converted
+// documents. Several pages give one PDF, or a document per page in other
+// formats.
+func (c *converter) convert(ctx context.Context, data []byte, format string, stream grpc.ServerStreamingServer[rmcpb.Chunk]) error {
+	trees, nb, err := c.parse(ctx, data)
+	if err != nil {
+		return c.grpcFail(codes.InvalidArgument, err)
+	}
+
+	if nb != nil && format == "pdf" {
+		w := &chunkWriter{stream: stream}
+		if err := c.export(ctx, trees, nb, w, format); err != nil {
+			return c.exportFail(ctx, err)
+		}
+		return w.Close()
+	}
+	for i := range trees {
+		w := &chunkWriter{stream: stream, page: int32(i + 1)}
+		if err := c.export(ctx, trees[i:i+1], nb, w, format); err != nil {
+			return c.exportFail(ctx, err)
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportFail returns the status of a failed export
+func (c *converter) exportFail(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return c.grpcFail(codes.Internal, err)
+}
+
+// grpcFail logs a failed call and returns its status
+func (s *server) grpcFail(code codes.Code, err error) error {
+	s.opts.Logger.Warn("conversion request failed", "code", code, "error", err)
+	return status.Error(code, err.Error())
+}
+
+// chunkWriter sends what is written to it as the chunks of a document
+type chunkWriter struct {
+	stream grpc.ServerStreamingServer[rmcpb.Chunk]
+	page   int32
+	buf    []byte
+	sent   bool
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(w.buf)+len(p) >= chunkSize {
+		take := chunkSize - len(w.buf)
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+		if err := w.send(); err != nil {
+			return 0, err
+		}
+	}
+	w.buf = append(w.buf, p...)
+	return n, nil
+}
+
+// Close sends the rest of the document, and an empty chunk for an empty
+// document so that every page is sent
+func (w *chunkWriter) Close() error {
+	if len(w.buf) > 0 || !w.sent {
+		return w.send()
+	}
+	return nil
+}
+
+func (w *chunkWriter) send() error {
+	if err := w.stream.Send(&rmcpb.Chunk{Page: w.page, Data: w.buf}); err != nil {
+		return err
+	}
+	w.buf = make([]byte, 0, chunkSize)
+	w.sent = true
+	return nil
+}
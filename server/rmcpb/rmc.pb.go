@@ -0,0 +1,336 @@
+// The conversion service of rmc-go, for microservices that want typed
+// clients. Regenerate the Go code with make proto.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: server/rmcpb/rmc.proto
+
+package rmcpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Format is an output format
+type Format int32
+
+const (
+	Format_FORMAT_UNSPECIFIED Format = 0 // PDF
+	Format_FORMAT_PDF         Format = 1
+	Format_FORMAT_SVG         Format = 2
+	Format_FORMAT_PNG         Format = 3
+	Format_FORMAT_HTML        Format = 4
+	Format_FORMAT_EPS         Format = 5
+)
+
+// Enum value maps for Format.
+var (
+	Format_name = map[int32]string{
+		0: "FORMAT_UNSPECIFIED",
+		1: "FORMAT_PDF",
+		2: "FORMAT_SVG",
+		3: "FORMAT_PNG",
+		4: "FORMAT_HTML",
+		5: "FORMAT_EPS",
+	}
+	Format_value = map[string]int32{
+		"FORMAT_UNSPECIFIED": 0,
+		"FORMAT_PDF":         1,
+		"FORMAT_SVG":         2,
+		"FORMAT_PNG":         3,
+		"FORMAT_HTML":        4,
+		"FORMAT_EPS":         5,
+	}
+)
+
+func (x Format) Enum() *Format {
+	p := new(Format)
+	*p = x
+	return p
+}
+
+func (x Format) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Format) Descriptor() protoreflect.EnumDescriptor {
+	return file_server_rmcpb_rmc_proto_enumTypes[0].Descriptor()
+}
+
+func (Format) Type() protoreflect.EnumType {
+	return &file_server_rmcpb_rmc_proto_enumTypes[0]
+}
+
+func (x Format) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Format.Descriptor instead.
+func (Format) EnumDescriptor() ([]byte, []int) {
+	return file_server_rmcpb_rmc_proto_rawDescGZIP(), []int{0}
+}
+
+type ConvertPageRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The .rm file
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// The output format (default: PDF)
+	Format        Format `protobuf:"varint,2,opt,name=format,proto3,enum=rmc.v1.Format" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConvertPageRequest) Reset() {
+	*x = ConvertPageRequest{}
+	mi := &file_server_rmcpb_rmc_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertPageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertPageRequest) ProtoMessage() {}
+
+func (x *ConvertPageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rmcpb_rmc_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertPageRequest.ProtoReflect.Descriptor instead.
+func (*ConvertPageRequest) Descriptor() ([]byte, []int) {
+	return file_server_rmcpb_rmc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ConvertPageRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ConvertPageRequest) GetFormat() Format {
+	if x != nil {
+		return x.Format
+	}
+	return Format_FORMAT_UNSPECIFIED
+}
+
+type ConvertNotebookRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The next part of the archive
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// The output format (default: PDF), read from the first message only
+	Format        Format `protobuf:"varint,2,opt,name=format,proto3,enum=rmc.v1.Format" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConvertNotebookRequest) Reset() {
+	*x = ConvertNotebookRequest{}
+	mi := &file_server_rmcpb_rmc_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertNotebookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertNotebookRequest) ProtoMessage() {}
+
+func (x *ConvertNotebookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rmcpb_rmc_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertNotebookRequest.ProtoReflect.Descriptor instead.
+func (*ConvertNotebookRequest) Descriptor() ([]byte, []int) {
+	return file_server_rmcpb_rmc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ConvertNotebookRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ConvertNotebookRequest) GetFormat() Format {
+	if x != nil {
+		return x.Format
+	}
+	return Format_FORMAT_UNSPECIFIED
+}
+
+// Chunk is part of a converted document. Each document is sent in one or
+// more chunks with the same page, in order.
+type Chunk struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The page the document shows, from 1, or 0 for a PDF of every page of a
+	// notebook
+	Page          int32  `protobuf:"varint,1,opt,name=page,proto3" json:"page,omitempty"`
+	Data          []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chunk) Reset() {
+	*x = Chunk{}
+	mi := &file_server_rmcpb_rmc_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chunk) ProtoMessage() {}
+
+func (x *Chunk) ProtoReflect() protoreflect.Message {
+	mi := &file_server_rmcpb_rmc_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return file_server_rmcpb_rmc_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Chunk) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *Chunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_server_rmcpb_rmc_proto protoreflect.FileDescriptor
+
+const file_server_rmcpb_rmc_proto_rawDesc = "" +
+	"\n" +
+	"\x16server/rmcpb/rmc.proto\x12\x06rmc.v1\"P\n" +
+	"\x12ConvertPageRequest\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12&\n" +
+	"\x06format\x18\x02 \x01(\x0e2\x0e.rmc.v1.FormatR\x06format\"T\n" +
+	"\x16ConvertNotebookRequest\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12&\n" +
+	"\x06format\x18\x02 \x01(\x0e2\x0e.rmc.v1.FormatR\x06format\"/\n" +
+	"\x05Chunk\x12\x12\n" +
+	"\x04page\x18\x01 \x01(\x05R\x04page\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data*q\n" +
+	"\x06Format\x12\x16\n" +
+	"\x12FORMAT_UNSPECIFIED\x10\x00\x12\x0e\n" +
+	"\n" +
+	"FORMAT_PDF\x10\x01\x12\x0e\n" +
+	"\n" +
+	"FORMAT_SVG\x10\x02\x12\x0e\n" +
+	"\n" +
+	"FORMAT_PNG\x10\x03\x12\x0f\n" +
+	"\vFORMAT_HTML\x10\x04\x12\x0e\n" +
+	"\n" +
+	"FORMAT_EPS\x10\x052\x8d\x01\n" +
+	"\tConverter\x12:\n" +
+	"\vConvertPage\x12\x1a.rmc.v1.ConvertPageRequest\x1a\r.rmc.v1.Chunk0\x01\x12D\n" +
+	"\x0fConvertNotebook\x12\x1e.rmc.v1.ConvertNotebookRequest\x1a\r.rmc.v1.Chunk(\x010\x01B)Z'github.com/joagonca/rmc-go/server/rmcpbb\x06proto3"
+
+var (
+	file_server_rmcpb_rmc_proto_rawDescOnce sync.Once
+	file_server_rmcpb_rmc_proto_rawDescData []byte
+)
+
+func file_server_rmcpb_rmc_proto_rawDescGZIP() []byte {
+	file_server_rmcpb_rmc_proto_rawDescOnce.Do(func() {
+		file_server_rmcpb_rmc_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_server_rmcpb_rmc_proto_rawDesc), len(file_server_rmcpb_rmc_proto_rawDesc)))
+	})
+	return file_server_rmcpb_rmc_proto_rawDescData
+}
+
+var file_server_rmcpb_rmc_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_server_rmcpb_rmc_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_server_rmcpb_rmc_proto_goTypes = []any{
+	(Format)(0),                    // 0: rmc.v1.Format
+	(*ConvertPageRequest)(nil),     // 1: rmc.v1.ConvertPageRequest
+	(*ConvertNotebookRequest)(nil), // 2: rmc.v1.ConvertNotebookRequest
+	(*Chunk)(nil),                  // 3: rmc.v1.Chunk
+}
+var file_server_rmcpb_rmc_proto_depIdxs = []int32{
+	0, // 0: rmc.v1.ConvertPageRequest.format:type_name -> rmc.v1.Format
+	0, // 1: rmc.v1.ConvertNotebookRequest.format:type_name -> rmc.v1.Format
+	1, // 2: rmc.v1.Converter.ConvertPage:input_type -> rmc.v1.ConvertPageRequest
+	2, // 3: rmc.v1.Converter.ConvertNotebook:input_type -> rmc.v1.ConvertNotebookRequest
+	3, // 4: rmc.v1.Converter.ConvertPage:output_type -> rmc.v1.Chunk
+	3, // 5: rmc.v1.Converter.ConvertNotebook:output_type -> rmc.v1.Chunk
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_server_rmcpb_rmc_proto_init() }
+func file_server_rmcpb_rmc_proto_init() {
+	if File_server_rmcpb_rmc_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_server_rmcpb_rmc_proto_rawDesc), len(file_server_rmcpb_rmc_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_server_rmcpb_rmc_proto_goTypes,
+		DependencyIndexes: file_server_rmcpb_rmc_proto_depIdxs,
+		EnumInfos:         file_server_rmcpb_rmc_proto_enumTypes,
+		MessageInfos:      file_server_rmcpb_rmc_proto_msgTypes,
+	}.Build()
+	File_server_rmcpb_rmc_proto = out.File
+	file_server_rmcpb_rmc_proto_goTypes = nil
+	file_server_rmcpb_rmc_proto_depIdxs = nil
+}
@@ -0,0 +1,177 @@
+// The conversion service of rmc-go, for microservices that want typed
+// clients. Regenerate the Go code with make proto.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: server/rmcpb/rmc.proto
+
+package rmcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Converter_ConvertPage_FullMethodName     = "/rmc.v1.Converter/ConvertPage"
+	Converter_ConvertNotebook_FullMethodName = "/rmc.v1.Converter/ConvertNotebook"
+)
+
+// ConverterClient is the client API for Converter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Converter converts reMarkable files, like the POST /convert endpoint of the
+// HTTP server
+type ConverterClient interface {
+	// ConvertPage converts one .rm file. The output is streamed back in chunks,
+	// as it can be larger than a message may be.
+	ConvertPage(ctx context.Context, in *ConvertPageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Chunk], error)
+	// ConvertNotebook converts a zipped notebook folder or .rmdoc archive,
+	// streamed in chunks. Pages are ordered by the notebook's .content file.
+	// PDF output is one document of every page; other formats give a document
+	// per page, sent in page order.
+	ConvertNotebook(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConvertNotebookRequest, Chunk], error)
+}
+
+type converterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConverterClient(cc grpc.ClientConnInterface) ConverterClient {
+	return &converterClient{cc}
+}
+
+func (c *converterClient) ConvertPage(ctx context.Context, in *ConvertPageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Chunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Converter_ServiceDesc.Streams[0], Converter_ConvertPage_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ConvertPageRequest, Chunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Converter_ConvertPageClient = grpc.ServerStreamingClient[Chunk]
+
+func (c *converterClient) ConvertNotebook(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConvertNotebookRequest, Chunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Converter_ServiceDesc.Streams[1], Converter_ConvertNotebook_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ConvertNotebookRequest, Chunk]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Converter_ConvertNotebookClient = grpc.BidiStreamingClient[ConvertNotebookRequest, Chunk]
+
+// ConverterServer is the server API for Converter service.
+// All implementations must embed UnimplementedConverterServer
+// for forward compatibility.
+//
+// Converter converts reMarkable files, like the POST /convert endpoint of the
+// HTTP server
+type ConverterServer interface {
+	// ConvertPage converts one .rm file. The output is streamed back in chunks,
+	// as it can be larger than a message may be.
+	ConvertPage(*ConvertPageRequest, grpc.ServerStreamingServer[Chunk]) error
+	// ConvertNotebook converts a zipped notebook folder or .rmdoc archive,
+	// streamed in chunks. Pages are ordered by the notebook's .content file.
+	// PDF output is one document of every page; other formats give a document
+	// per page, sent in page order.
+	ConvertNotebook(grpc.BidiStreamingServer[ConvertNotebookRequest, Chunk]) error
+	mustEmbedUnimplementedConverterServer()
+}
+
+// UnimplementedConverterServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedConverterServer struct{}
+
+func (UnimplementedConverterServer) ConvertPage(*ConvertPageRequest, grpc.ServerStreamingServer[Chunk]) error {
+	return status.Error(codes.Unimplemented, "method ConvertPage not implemented")
+}
+func (UnimplementedConverterServer) ConvertNotebook(grpc.BidiStreamingServer[ConvertNotebookRequest, Chunk]) error {
+	return status.Error(codes.Unimplemented, "method ConvertNotebook not implemented")
+}
+func (UnimplementedConverterServer) mustEmbedUnimplementedConverterServer() {}
+func (UnimplementedConverterServer) testEmbeddedByValue()                   {}
+
+// UnsafeConverterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConverterServer will
+// result in compilation errors.
+type UnsafeConverterServer interface {
+	mustEmbedUnimplementedConverterServer()
+}
+
+func RegisterConverterServer(s grpc.ServiceRegistrar, srv ConverterServer) {
+	// If the following call panics, it indicates UnimplementedConverterServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Converter_ServiceDesc, srv)
+}
+
+func _Converter_ConvertPage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ConvertPageRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConverterServer).ConvertPage(m, &grpc.GenericServerStream[ConvertPageRequest, Chunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Converter_ConvertPageServer = grpc.ServerStreamingServer[Chunk]
+
+func _Converter_ConvertNotebook_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ConverterServer).ConvertNotebook(&grpc.GenericServerStream[ConvertNotebookRequest, Chunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Converter_ConvertNotebookServer = grpc.BidiStreamingServer[ConvertNotebookRequest, Chunk]
+
+// Converter_ServiceDesc is the grpc.ServiceDesc for Converter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Converter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rmc.v1.Converter",
+	HandlerType: (*ConverterServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ConvertPage",
+			Handler:       _Converter_ConvertPage_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ConvertNotebook",
+			Handler:       _Converter_ConvertNotebook_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "server/rmcpb/rmc.proto",
+}
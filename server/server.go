@@ -0,0 +1,268 @@
+// Package server provides an HTTP handler and a gRPC service that convert
+// uploaded reMarkable files, so rmc-go can back a web service directly.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// Options configures the conversion server
+type Options struct {
+	// MaxRequestBytes caps the size of an uploaded file or archive (default: 32 MiB)
+	MaxRequestBytes int64
+
+	// MaxConcurrent limits how many conversions run at once (default: number
+	// of CPUs). Further requests are turned away before their upload is read,
+	// with 503 Service Unavailable and a Retry-After header over HTTP and
+	// codes.Unavailable over gRPC, so waiting uploads don't pile up in memory.
+	MaxConcurrent int
+
+	// PDF holds the export settings for PDF and EPS output (default: export.DefaultPDFOptions())
+	PDF *export.PDFOptions
+
+	// PNG holds the export settings for PNG output (default: export.DefaultPNGOptions())
+	PNG *export.PNGOptions
+
+	// Logger receives request errors and parser warnings (default: slog.Default())
+	Logger *slog.Logger
+}
+
+// DefaultOptions returns the default server options
+func DefaultOptions() *Options {
+	return &Options{
+		MaxRequestBytes: 32 << 20,
+		MaxConcurrent:   runtime.NumCPU(),
+		PDF:             export.DefaultPDFOptions(),
+		PNG:             export.DefaultPNGOptions(),
+		Logger:          slog.Default(),
+	}
+}
+
+// contentTypes maps output formats to response content types
+var contentTypes = map[string]string{
+	"pdf":  "application/pdf",
+	"svg":  "image/svg+xml",
+	"png":  "image/png",
+	"html": "text/html; charset=utf-8",
+	"eps":  "application/postscript",
+}
+
+// retryAfter is the number of seconds busy clients are told to wait
+const retryAfter = "1"
+
+type server struct {
+	opts  Options
+	slots chan struct{}
+}
+
+// acquire takes a conversion slot if one is free, without waiting. The
+// caller releases the slot with release.
+func (s *server) acquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a slot taken with acquire
+func (s *server) release() {
+	<-s.slots
+}
+
+// New creates the HTTP handler. It serves:
+//
+//	POST /convert?format=pdf|svg|png|html|eps  convert an uploaded .rm file or zipped notebook
+//	GET  /healthz                              report that the server is up
+//
+// The file is sent as the raw request body or as the "file" field of a
+// multipart form. A nil opts uses DefaultOptions().
+func New(opts *Options) http.Handler {
+	s := newServer(opts)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /convert", s.handleConvert)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// newServer fills in the defaults of the options left unset
+func newServer(opts *Options) *server {
+	o := *DefaultOptions()
+	if opts != nil {
+		if opts.MaxRequestBytes > 0 {
+			o.MaxRequestBytes = opts.MaxRequestBytes
+		}
+		if opts.MaxConcurrent > 0 {
+			o.MaxConcurrent = opts.MaxConcurrent
+		}
+		if opts.PDF != nil {
+			o.PDF = opts.PDF
+		}
+		if opts.PNG != nil {
+			o.PNG = opts.PNG
+		}
+		if opts.Logger != nil {
+			o.Logger = opts.Logger
+		}
+	}
+
+	return &server{opts: o, slots: make(chan struct{}, o.MaxConcurrent)}
+}
+
+func (s *server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "pdf"
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		s.fail(w, http.StatusBadRequest, fmt.Errorf("unknown format: %s (supported: pdf, svg, png, html, eps)", format))
+		return
+	}
+
+	// Take a conversion slot before reading the upload, so requests beyond
+	// the limit hold no memory
+	if !s.acquire() {
+		w.Header().Set("Retry-After", retryAfter)
+		s.fail(w, http.StatusServiceUnavailable, fmt.Errorf("server busy"))
+		return
+	}
+	defer s.release()
+
+	data, err := s.readUpload(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.fail(w, http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds %d bytes", s.opts.MaxRequestBytes))
+			return
+		}
+		s.fail(w, http.StatusBadRequest, err)
+		return
+	}
+
+	trees, nb, err := s.parse(r.Context(), data)
+	if err != nil {
+		s.fail(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var out bytes.Buffer
+	if err := s.export(r.Context(), trees, nb, &out, format); err != nil {
+		s.fail(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(out.Len()))
+	w.Write(out.Bytes())
+}
+
+// readUpload reads the uploaded file from a multipart form or the raw body,
+// enforcing the size cap
+func (s *server) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxRequestBytes)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read form file: %w", err)
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty request body")
+	}
+	return data, nil
+}
+
+// parse reads the pages of an uploaded .rm file or zipped notebook, along
+// with the notebook for its .content and .metadata files (nil for a single
+// .rm file)
+func (s *server) parse(ctx context.Context, data []byte) ([]*parser.SceneTree, *parser.Notebook, error) {
+	pages := [][]byte{data}
+	var notebook *parser.Notebook
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		nb, err := parser.ReadNotebookArchive(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, nil, err
+		}
+		pages = nb.OrderedPages()
+		notebook = nb
+	}
+
+	trees := make([]*parser.SceneTree, 0, len(pages))
+	for i, page := range pages {
+		tree, err := parser.ReadSceneTreeContext(ctx, bytes.NewReader(page), s.opts.Logger)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse page %d: %w", i+1, err)
+		}
+		trees = append(trees, tree)
+	}
+	return trees, notebook, nil
+}
+
+// export writes the pages in the requested format, laid out for the
+// notebook's orientation and titled after it. Several pages can only be
+// combined into a PDF.
+func (s *server) export(ctx context.Context, trees []*parser.SceneTree, nb *parser.Notebook, w io.Writer, format string) error {
+	// Copy the shared options so concurrent requests don't affect each other
+	pdfOpts, pngOpts := *s.opts.PDF, *s.opts.PNG
+	landscape := false
+	if nb != nil {
+		landscape = nb.Content != nil && nb.Content.IsLandscape()
+		pdfOpts.Metadata = pdfOpts.Metadata.WithNotebook(nb.Metadata).WithTags(nb.Content)
+		pdfOpts.PageIDs = nb.OrderedPageIDs()
+	}
+	pdfOpts.Landscape = landscape
+	pngOpts.Landscape = landscape
+
+	if len(trees) > 1 {
+		if format != "pdf" {
+			return fmt.Errorf("multipage output is only supported for PDF format, not %s", strings.ToUpper(format))
+		}
+		return export.ExportToMultipagePDFContext(ctx, trees, w, &pdfOpts)
+	}
+
+	tree := trees[0]
+	switch format {
+	case "svg":
+		return export.ExportToSVGContext(ctx, tree, w, &pdfOpts.SVGOptions)
+	case "png":
+		return export.ExportToPNGWithOptions(tree, w, &pngOpts)
+	case "html":
+		return export.ExportToHTMLWithOptions(tree, w, &pdfOpts.SVGOptions)
+	case "eps":
+		return export.ExportToEPSContext(ctx, tree, w, &pdfOpts)
+	default:
+		return export.ExportToPDFContext(ctx, tree, w, &pdfOpts)
+	}
+}
+
+// fail writes a JSON error response
+func (s *server) fail(w http.ResponseWriter, status int, err error) {
+	s.opts.Logger.Warn("conversion request failed", "status", status, "error", err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,128 @@
+// Package tablet fetches notebooks directly from a reMarkable tablet over SSH.
+package tablet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// DefaultDataDir is where the tablet stores notebooks
+const DefaultDataDir = "/home/root/.local/share/remarkable/xochitl"
+
+// DefaultHost is the tablet's address when connected over USB
+const DefaultHost = "root@10.11.99.1"
+
+// documentID matches reMarkable document UUIDs
+var documentID = regexp.MustCompile(`^[0-9a-fA-F-]+$`)
+
+// SSHOptions controls how the tablet is reached
+type SSHOptions struct {
+	// Command is the ssh executable (default: "ssh" from PATH)
+	Command string
+
+	// Port is the SSH port (default: 22)
+	Port int
+
+	// IdentityFile is the private key to authenticate with (default: ssh's own configuration)
+	IdentityFile string
+
+	// DataDir is the notebook storage directory on the tablet (default: DefaultDataDir)
+	DataDir string
+}
+
+// ParseTarget splits a target of the form [user@]host:<uuid>.
+// The host defaults to DefaultHost when only a UUID is given.
+func ParseTarget(target string) (host, id string, err error) {
+	host, id = DefaultHost, target
+	if i := strings.LastIndex(target, ":"); i >= 0 {
+		host, id = target[:i], target[i+1:]
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("missing host in %q", target)
+	}
+	if err := checkHost(host); err != nil {
+		return "", "", err
+	}
+	if !documentID.MatchString(id) {
+		return "", "", fmt.Errorf("invalid document ID %q (expected a UUID)", id)
+	}
+	return host, id, nil
+}
+
+// Fetch copies a notebook's pages, .content and .metadata files from the
+// tablet at host (e.g. "root@10.11.99.1") using the system ssh client.
+// A nil opts uses the defaults.
+func Fetch(ctx context.Context, host, id string, opts *SSHOptions) (*parser.Notebook, error) {
+	if opts == nil {
+		opts = &SSHOptions{}
+	}
+	if err := checkHost(host); err != nil {
+		return nil, err
+	}
+	if !documentID.MatchString(id) {
+		return nil, fmt.Errorf("invalid document ID %q (expected a UUID)", id)
+	}
+
+	command := opts.Command
+	if command == "" {
+		command = "ssh"
+	}
+	dataDir := opts.DataDir
+	if dataDir == "" {
+		dataDir = DefaultDataDir
+	}
+
+	args := []string{"-o", "BatchMode=yes"}
+	if opts.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(opts.Port))
+	}
+	if opts.IdentityFile != "" {
+		args = append(args, "-i", opts.IdentityFile)
+	}
+	// Stream the notebook as a tar archive; the tablet's busybox provides tar
+	remote := fmt.Sprintf("cd %s && tar -cf - %s.content %s.metadata %s",
+		shellQuote(dataDir), id, id, id)
+	// "--" ends the options, so the host cannot be taken for one
+	args = append(args, "--", host, remote)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to fetch notebook %s from %s: %w\n  %s",
+				id, host, err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("failed to run %s: %w", command, err)
+	}
+
+	nb, err := parser.ReadNotebookTar(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("notebook %s: %w", id, err)
+	}
+	return nb, nil
+}
+
+// checkHost rejects hosts that ssh would read as options, such as
+// "-oProxyCommand=...", which could run any command
+func checkHost(host string) error {
+	if strings.HasPrefix(host, "-") {
+		return fmt.Errorf("invalid host %q: must not start with '-'", host)
+	}
+	return nil
+}
+
+// shellQuote quotes a string for the remote POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,46 @@
+package rmc
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joagonca/rmc-go/export"
+	"github.com/joagonca/rmc-go/parser"
+)
+
+// Thumbnail draws a small PNG preview of an .rm file, for gallery views of
+// many notebooks. Strokes are simplified to what shows at the thumbnail's
+// size, and drawn in black without Cairo; see export.ExportThumbnail. A nil opts
+// uses export.DefaultThumbnailOptions(). Parser warnings are reported to
+// slog.Default().
+//
+// Example:
+//
+//	opts := export.DefaultThumbnailOptions()
+//	opts.MaxDim = 128
+//	err := rmc.Thumbnail(input, output, opts)
+func Thumbnail(input io.Reader, output io.Writer, opts *export.ThumbnailOptions) error {
+	tree, err := parser.ReadSceneTree(input)
+	if err != nil {
+		return fmt.Errorf("failed to parse .rm file: %w", err)
+	}
+	return export.ExportThumbnail(tree, output, opts)
+}
+
+// ThumbnailFile is like Thumbnail for files on disk
+func ThumbnailFile(inputPath, outputPath string, opts *export.ThumbnailOptions) error {
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outputFile.Close()
+
+	return Thumbnail(inputFile, outputFile, opts)
+}